@@ -0,0 +1,78 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math/big"
+	"sort"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// accountReader is the part of the node API the account summary uses.
+type accountReader interface {
+	NonceAt(ctx context.Context, account common.Address, blockNumber *big.Int) (uint64, error)
+	PendingNonceAt(ctx context.Context, account common.Address) (uint64, error)
+	HeaderByNumber(ctx context.Context, number *big.Int) (*types.Header, error)
+}
+
+// walletAccount reads a wallet's transaction count on a chain as of block,
+// the head when nil, how many of its transactions are pending, and the
+// block of the last transaction it sent. The last is a binary search over
+// historic nonces, about 25 calls on mainnet, and is left at zero when the
+// node cannot serve old state. The pool only holds transactions on top of
+// the head, so a historical block has none pending. Incoming transfers do
+// not change the nonce and are not counted as activity.
+func walletAccount(ctx context.Context, client accountReader, chain string, wallet common.Address, block *big.Int, historical bool) (reportAccount, error) {
+	acc := reportAccount{Chain: chain}
+	head, err := client.HeaderByNumber(ctx, block)
+	if err != nil {
+		return acc, err
+	}
+	if acc.Nonce, err = client.NonceAt(ctx, wallet, head.Number); err != nil {
+		return acc, err
+	}
+	if !historical {
+		pending, err := client.PendingNonceAt(ctx, wallet)
+		if err != nil {
+			return acc, err
+		}
+		if pending > acc.Nonce {
+			acc.Pending = pending - acc.Nonce
+		}
+	}
+	if acc.Nonce == 0 {
+		return acc, nil
+	}
+	// The first block whose state has the current nonce holds the last
+	// transaction sent.
+	var searchErr error
+	n := sort.Search(int(head.Number.Uint64()), func(i int) bool {
+		if searchErr != nil {
+			return true
+		}
+		nonce, err := client.NonceAt(ctx, wallet, big.NewInt(int64(i)))
+		searchErr = err
+		return err == nil && nonce >= acc.Nonce
+	})
+	if searchErr == nil {
+		acc.LastSentBlock = uint64(n)
+	}
+	return acc, nil
+}
+
+// printAccounts prints one account line per chain under the wallet header.
+func printAccounts(w io.Writer, accounts []reportAccount) {
+	for _, a := range accounts {
+		line := fmt.Sprintf("%s: nonce %d", a.Chain, a.Nonce)
+		if a.Pending > 0 {
+			line += fmt.Sprintf(", %d pending", a.Pending)
+		}
+		if a.LastSentBlock > 0 {
+			line += fmt.Sprintf(", last sent in block %d", a.LastSentBlock)
+		}
+		fmt.Fprintln(w, line)
+	}
+}
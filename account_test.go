@@ -0,0 +1,50 @@
+package main
+
+import (
+	"context"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+func TestWalletAccount(t *testing.T) {
+	key, _ := crypto.GenerateKey()
+	sender := crypto.PubkeyToAddress(key.PublicKey)
+	sim := newSim(t, core.GenesisAlloc{sender: {Balance: big.NewInt(1e18)}})
+	ctx := context.Background()
+
+	signer := types.LatestSignerForChainID(big.NewInt(simChainID))
+	send := func(nonce uint64) {
+		tx := types.MustSignNewTx(key, signer, &types.LegacyTx{Nonce: nonce, To: &testWallet, Value: big.NewInt(1), Gas: 21000, GasPrice: big.NewInt(1e10)})
+		if err := sim.SendTransaction(ctx, tx); err != nil {
+			t.Fatal(err)
+		}
+	}
+	send(0)
+	sim.Commit() // block 1
+	sim.Commit()
+	send(1)
+	sim.Commit() // block 3
+	sim.Commit()
+	send(2) // left pending
+
+	acc, err := walletAccount(ctx, sim, "sim", sender, nil, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if acc.Nonce != 2 || acc.Pending != 1 || acc.LastSentBlock != 3 {
+		t.Errorf("account = %+v, want nonce 2, 1 pending, last sent in block 3", acc)
+	}
+
+	// Pinned at block 2, before the second transaction.
+	if acc, err := walletAccount(ctx, sim, "sim", sender, big.NewInt(2), true); err != nil || acc.Nonce != 1 || acc.Pending != 0 || acc.LastSentBlock != 1 {
+		t.Errorf("account at block 2 = %+v, %v, want nonce 1, none pending, last sent in block 1", acc, err)
+	}
+
+	if acc, err := walletAccount(ctx, sim, "sim", testWallet, nil, false); err != nil || acc.Nonce != 0 || acc.LastSentBlock != 0 {
+		t.Errorf("receiving-only wallet = %+v, %v", acc, err)
+	}
+}
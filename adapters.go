@@ -0,0 +1,273 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"slices"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/ethereum/go-ethereum/common"
+
+	"Test2/source"
+)
+
+// Protocol adapters are the asset sources beyond registry balances: the
+// built-in ones below and those registered through the source package.
+// Each can be switched on or off by name, in the config's "adapters"
+// section or with -adapters, and registered ones get their settings from
+// that section too. One failing, or even panicking, only loses its own
+// positions; see collectHoldings.
+
+// findFunc finds a wallet's holdings in one protocol.
+type findFunc func(ctx context.Context, wallet common.Address) ([]*holding, error)
+
+// adapter is a built-in protocol integration.
+type adapter struct {
+	name  string
+	about string
+	// chains are the chain IDs it applies to; nil for every chain.
+	chains []uint64
+	// optIn adapters are off unless enabled, because of their cost.
+	optIn bool
+	// needs is the flag saying what to look at, for adapters that only
+	// value what they are pointed to.
+	needs string
+	// open returns nil when the run gives the adapter nothing to do.
+	open func(client chainClient, chainID uint64, cfg *config, opts options) findFunc
+}
+
+var builtinAdapters = []adapter{
+	// The validators are the withdrawal address's when one is given, and
+	// otherwise the single wallet's that the run values; see main.
+	{name: "beacon", about: "beacon chain validators", chains: []uint64{1}, needs: "-validators or -withdrawal-address", open: func(client chainClient, _ uint64, _ *config, opts options) findFunc {
+		if opts.Validators == "" && opts.Withdrawal == "" {
+			return nil
+		}
+		return func(ctx context.Context, w common.Address) ([]*holding, error) {
+			if opts.Withdrawal != "" && w != common.HexToAddress(opts.Withdrawal) {
+				return nil, nil
+			}
+			h, err := beaconHolding(ctx, client, opts.Validators, opts.Withdrawal)
+			if h == nil {
+				return nil, err
+			}
+			return []*holding{h}, err
+		}
+	}},
+	{name: "eigenlayer", about: "EigenLayer strategy shares and EigenPods", chains: []uint64{1}, open: func(client chainClient, _ uint64, _ *config, _ options) findFunc {
+		return func(ctx context.Context, w common.Address) ([]*holding, error) {
+			return eigenLayerHoldings(ctx, client, w)
+		}
+	}},
+	{name: "vaults", about: "vault shares in their underlying tokens", chains: []uint64{1}, open: func(client chainClient, _ uint64, _ *config, _ options) findFunc {
+		return func(ctx context.Context, w common.Address) ([]*holding, error) { return vaultHoldings(ctx, client, w) }
+	}},
+	{name: "vesting", about: "Sablier streams and the config's vesting wallets", chains: []uint64{1}, open: func(client chainClient, _ uint64, cfg *config, _ options) findFunc {
+		return func(ctx context.Context, w common.Address) ([]*holding, error) {
+			return vestingHoldings(ctx, client, w, cfg.Vesting)
+		}
+	}},
+	{name: "vote-escrow", about: "vote-escrow locks", chains: []uint64{1}, open: func(client chainClient, _ uint64, _ *config, _ options) findFunc {
+		return func(ctx context.Context, w common.Address) ([]*holding, error) {
+			return voteEscrowHoldings(ctx, client, w)
+		}
+	}},
+	{name: "convex", about: "Curve LP tokens staked in Convex (one call per pool)", chains: []uint64{1}, optIn: true, open: func(client chainClient, _ uint64, _ *config, _ options) findFunc {
+		return func(ctx context.Context, w common.Address) ([]*holding, error) { return convexHoldings(ctx, client, w) }
+	}},
+	{name: "gmx", about: "GMX positions and staked GMX", chains: []uint64{42161}, open: func(client chainClient, _ uint64, _ *config, _ options) findFunc {
+		return func(ctx context.Context, w common.Address) ([]*holding, error) { return gmxHoldings(ctx, client, w) }
+	}},
+	{name: "pendle", about: "Pendle PT and YT", needs: "-pendle-markets", open: func(client chainClient, _ uint64, _ *config, opts options) findFunc {
+		if opts.PendleMarkets == "" {
+			return nil
+		}
+		return func(ctx context.Context, w common.Address) ([]*holding, error) {
+			return pendleHoldings(ctx, client, w, opts.PendleMarkets)
+		}
+	}},
+	{name: "balancer", about: "Balancer LP shares", needs: "-balancer", open: func(client chainClient, _ uint64, _ *config, opts options) findFunc {
+		if opts.Balancer == "" {
+			return nil
+		}
+		return func(ctx context.Context, w common.Address) ([]*holding, error) {
+			return balancerHoldings(ctx, client, w, opts.Balancer)
+		}
+	}},
+	{name: "superfluid", about: "Superfluid super token balances", open: func(client chainClient, chainID uint64, cfg *config, _ options) findFunc {
+		return func(ctx context.Context, w common.Address) ([]*holding, error) {
+			return superfluidHoldings(ctx, client, chainID, w, cfg.SuperTokens[chainName(chainID)])
+		}
+	}},
+}
+
+// adapterConfig is an adapter's entry in the config, e.g. {"convex":
+// {"enabled": true}, "my-protocol": {"settings": {"api_key": "..."}}}.
+type adapterConfig struct {
+	// Enabled overrides whether the adapter runs; unset keeps its default.
+	Enabled *bool `json:"enabled"`
+	// Settings are handed to a registered adapter that is
+	// source.Configurable.
+	Settings json.RawMessage `json:"settings"`
+}
+
+// adapterNames lists the built-in adapters, then the registered ones.
+func adapterNames() []string {
+	var names []string
+	for _, a := range builtinAdapters {
+		names = append(names, a.name)
+	}
+	for _, r := range source.Assets() {
+		names = append(names, r.Name)
+	}
+	return names
+}
+
+func checkAdapterName(name string) error {
+	if !slices.Contains(adapterNames(), name) {
+		return fmt.Errorf("unknown adapter %q (want %s)", name, strings.Join(adapterNames(), ", "))
+	}
+	return nil
+}
+
+// checkAdapters validates an -adapters list.
+func checkAdapters(list string) error {
+	for _, n := range strings.Split(list, ",") {
+		if err := checkAdapterName(strings.TrimPrefix(strings.TrimSpace(n), "-")); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func checkAdaptersConfig(adapters map[string]adapterConfig) error {
+	for name, a := range adapters {
+		if err := checkAdapterName(name); err != nil {
+			return err
+		}
+		if len(a.Settings) > 0 && slices.ContainsFunc(builtinAdapters, func(b adapter) bool { return b.name == name }) {
+			return fmt.Errorf("%s: built-in adapters take no settings", name)
+		}
+	}
+	return nil
+}
+
+// adapterEnabled says whether the named adapter runs: as the last mention
+// in -adapters says ("convex" on, "-gmx" off), else as the config says,
+// else by default. -convex is -adapters convex.
+func adapterEnabled(name string, optIn bool, cfg *config, opts options) bool {
+	on := !optIn
+	if a, ok := cfg.Adapters[name]; ok && a.Enabled != nil {
+		on = *a.Enabled
+	}
+	if name == "convex" && opts.Convex {
+		on = true
+	}
+	for _, n := range strings.Split(opts.Adapters, ",") {
+		switch strings.TrimSpace(n) {
+		case name:
+			on = true
+		case "-" + name:
+			on = false
+		}
+	}
+	return on
+}
+
+// failedSource stands in for a registered adapter that could not be set
+// up, so that the failure is reported like any other.
+type failedSource struct{ err error }
+
+func (s failedSource) Positions(context.Context, common.Address) ([]source.Position, error) {
+	return nil, s.err
+}
+
+// openRegistered opens a registered adapter on the chain and configures it.
+func openRegistered(r source.Registration[source.AssetSource], chainID uint64, client chainClient, cfg *config) source.AssetSource {
+	s := r.Open(chainID, client)
+	if s == nil {
+		return nil
+	}
+	settings := cfg.Adapters[r.Name].Settings
+	if len(settings) == 0 {
+		return s
+	}
+	c, ok := s.(source.Configurable)
+	if !ok {
+		return failedSource{errors.New("settings given but the adapter takes none")}
+	}
+	if err := c.Configure(settings); err != nil {
+		if cl, ok := s.(io.Closer); ok {
+			cl.Close()
+		}
+		return failedSource{fmt.Errorf("settings: %w", err)}
+	}
+	return s
+}
+
+// positionsOf asks an adapter for the wallet's positions and closes it
+// afterwards when it wants that, turning a panic into an error.
+func positionsOf(ctx context.Context, src namedAssets, wallet common.Address) (ps []source.Position, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			ps, err = nil, fmt.Errorf("panic: %v", r)
+		}
+	}()
+	if c, ok := src.AssetSource.(io.Closer); ok {
+		defer c.Close()
+	}
+	return src.Positions(ctx, wallet)
+}
+
+// adaptersCmd lists the adapters and whether a run would use them.
+func adaptersCmd(args []string) {
+	fs := flag.NewFlagSet("adapters", flag.ExitOnError)
+	fs.String("config", "config.json", "path to the JSON config file")
+	list := fs.String("adapters", "", "comma-separated adapters to turn on or, prefixed with -, off, as for a run")
+	cfg, err := parseSettings(fs, args)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if *list != "" {
+		if err := checkAdapters(*list); err != nil {
+			log.Fatalf("-adapters: %v", err)
+		}
+	}
+	printAdapters(os.Stdout, cfg, options{Adapters: *list})
+}
+
+func printAdapters(w io.Writer, cfg *config, opts options) {
+	state := func(name string, optIn bool, needs string) string {
+		switch {
+		case !adapterEnabled(name, optIn, cfg, opts):
+			return "off"
+		case needs != "":
+			return "with " + needs
+		}
+		return "on"
+	}
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "ADAPTER\tCHAINS\tSTATE\tCOVERS")
+	for _, a := range builtinAdapters {
+		chains := "all"
+		if a.chains != nil {
+			var names []string
+			for _, id := range a.chains {
+				names = append(names, chainName(id))
+			}
+			chains = strings.Join(names, ",")
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", a.name, chains, state(a.name, a.optIn, a.needs), a.about)
+	}
+	for _, r := range source.Assets() {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", r.Name, "any", state(r.Name, false, ""), "registered")
+	}
+	tw.Flush()
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core"
+
+	"Test2/source"
+)
+
+// adapterTestChain keeps the adapters registered here out of the other
+// tests.
+const adapterTestChain = 4243
+
+type configuredAdapter struct {
+	amount float64
+	closed *bool
+}
+
+func (a *configuredAdapter) Configure(settings json.RawMessage) error {
+	var s struct {
+		Amount float64 `json:"amount"`
+	}
+	if err := json.Unmarshal(settings, &s); err != nil {
+		return err
+	}
+	a.amount = s.Amount
+	return nil
+}
+
+func (a *configuredAdapter) Close() error {
+	*a.closed = true
+	return nil
+}
+
+func (a *configuredAdapter) Positions(ctx context.Context, owner common.Address) ([]source.Position, error) {
+	return []source.Position{{Asset: source.Asset{Chain: adapterTestChain, Symbol: "CFG"}, Amount: big.NewFloat(a.amount), Price: big.NewFloat(1)}}, nil
+}
+
+type panickingAdapter struct{}
+
+func (panickingAdapter) Positions(ctx context.Context, owner common.Address) ([]source.Position, error) {
+	var m map[string]int
+	m["boom"]++
+	return nil, nil
+}
+
+var configuredClosed bool
+
+func init() {
+	source.RegisterAssets("test-configured", func(chainID uint64, c source.Client) source.AssetSource {
+		if chainID != adapterTestChain {
+			return nil
+		}
+		return &configuredAdapter{closed: &configuredClosed}
+	})
+	source.RegisterAssets("test-panics", func(chainID uint64, c source.Client) source.AssetSource {
+		if chainID != adapterTestChain {
+			return nil
+		}
+		return panickingAdapter{}
+	})
+}
+
+func TestAdapterEnabled(t *testing.T) {
+	off := false
+	cfg := &config{Adapters: map[string]adapterConfig{"gmx": {Enabled: &off}}}
+	for _, c := range []struct {
+		name     string
+		optIn    bool
+		adapters string
+		convex   bool
+		want     bool
+	}{
+		{"vaults", false, "", false, true},
+		{"gmx", false, "", false, false},
+		{"gmx", false, "gmx", false, true},
+		{"convex", true, "", false, false},
+		{"convex", true, "", true, true},
+		{"convex", true, "convex,-convex", false, false},
+		{"vaults", false, "-vaults", false, false},
+	} {
+		if got := adapterEnabled(c.name, c.optIn, cfg, options{Adapters: c.adapters, Convex: c.convex}); got != c.want {
+			t.Errorf("%s with %q, -convex=%v: enabled %v, want %v", c.name, c.adapters, c.convex, got, c.want)
+		}
+	}
+	if err := checkAdapters("convex,-test-panics"); err != nil {
+		t.Error(err)
+	}
+	if err := checkAdapters("-nope"); err == nil {
+		t.Error("unknown adapter accepted")
+	}
+	if err := checkAdaptersConfig(map[string]adapterConfig{"gmx": {Settings: json.RawMessage(`{}`)}}); err == nil {
+		t.Error("settings for a built-in adapter accepted")
+	}
+}
+
+func TestAdapterIsolation(t *testing.T) {
+	sim := newSim(t, core.GenesisAlloc{})
+	cfg := &config{Adapters: map[string]adapterConfig{"test-configured": {Settings: json.RawMessage(`{"amount": 4}`)}}}
+	summary := func(opts options) string {
+		var got []string
+		for _, h := range collectHoldings(context.Background(), sim, adapterTestChain, common.Address{1}, cfg, opts) {
+			if h.err != nil {
+				got = append(got, h.tf.Symbol+": "+h.err.Error())
+			} else {
+				got = append(got, h.tf.Symbol+" "+h.amt.Text('f', 0))
+			}
+		}
+		return strings.Join(got, "|")
+	}
+	got := summary(options{})
+	if !strings.HasPrefix(got, "CFG 4|test-panics: panic: ") {
+		t.Errorf("holdings %q", got)
+	}
+	if !configuredClosed {
+		t.Error("adapter not closed")
+	}
+	if got := summary(options{Adapters: "-test-panics"}); got != "CFG 4" {
+		t.Errorf("with test-panics off: %q", got)
+	}
+}
+
+func TestBeaconAdapterScope(t *testing.T) {
+	owner := common.HexToAddress("0x00000000000000000000000000000000000000aa")
+	opts := options{Validators: "1", Withdrawal: owner.Hex()}
+	beacon := func(chainID uint64) findFunc {
+		for _, src := range assetSources(nil, chainID, &config{}, opts) {
+			if src.name == "beacon" {
+				return src.AssetSource.(holdingSource).find
+			}
+		}
+		return nil
+	}
+	if beacon(10) != nil {
+		t.Error("validators valued on an L2")
+	}
+	find := beacon(1)
+	if find == nil {
+		t.Fatal("no beacon adapter on mainnet")
+	}
+	if hs, err := find(context.Background(), common.Address{1}); hs != nil || err != nil {
+		t.Errorf("another wallet credited with the validators: %v, %v", hs, err)
+	}
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+
+	"Test2/source"
+)
+
+// addressBook labels and tags known addresses. It is loaded from the CSV the
+// config file names and applies to every command: reports name labelled
+// wallets, labels stand in for addresses on the command line, and -tag picks
+// wallets by tag. A nil addressBook is empty.
+type addressBook map[common.Address]bookEntry
+
+type bookEntry struct {
+	Label string
+	Tags  []string
+}
+
+// book is the address book of the run, loaded with the config.
+var book addressBook
+
+// loadAddressBook reads address,label,tags rows; tags are separated by
+// spaces or semicolons. A header row and lines starting with # are skipped.
+func loadAddressBook(path string) (addressBook, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	r := csv.NewReader(f)
+	r.Comment = '#'
+	r.FieldsPerRecord = -1
+	r.TrimLeadingSpace = true
+	b := addressBook{}
+	for line := 1; ; line++ {
+		rec, err := r.Read()
+		if errors.Is(err, io.EOF) {
+			return b, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		addr := strings.TrimSpace(rec[0])
+		if !common.IsHexAddress(addr) {
+			if line == 1 {
+				continue // header
+			}
+			return nil, fmt.Errorf("%s: line %d: %w %q", path, line, source.ErrInvalidAddress, addr)
+		}
+		var e bookEntry
+		if len(rec) > 1 {
+			e.Label = strings.TrimSpace(rec[1])
+		}
+		if len(rec) > 2 {
+			e.Tags = strings.FieldsFunc(rec[2], func(r rune) bool { return r == ';' || r == ' ' })
+		}
+		b[common.HexToAddress(addr)] = e
+	}
+}
+
+func (b addressBook) label(addr common.Address) string {
+	return b[addr].Label
+}
+
+// resolve returns the address labelled s, matched case-insensitively.
+func (b addressBook) resolve(s string) (common.Address, bool) {
+	for addr, e := range b {
+		if e.Label != "" && strings.EqualFold(e.Label, s) {
+			return addr, true
+		}
+	}
+	return common.Address{}, false
+}
+
+func (b addressBook) hasTag(addr common.Address, tag string) bool {
+	return slices.Contains(b[addr].Tags, tag)
+}
+
+// tagged lists the addresses carrying tag, in hex.
+func (b addressBook) tagged(tag string) []string {
+	var out []string
+	for addr, e := range b {
+		if slices.Contains(e.Tags, tag) {
+			out = append(out, addr.Hex())
+		}
+	}
+	slices.Sort(out)
+	return out
+}
+
+// addresses lists every address in the book, in hex order.
+func (b addressBook) addresses() []common.Address {
+	var out []common.Address
+	for addr := range b {
+		out = append(out, addr)
+	}
+	slices.SortFunc(out, func(x, y common.Address) int { return x.Cmp(y) })
+	return out
+}
+
+// labels lists every label, for completion.
+func (b addressBook) labels() []string {
+	var out []string
+	for _, e := range b {
+		if e.Label != "" {
+			out = append(out, e.Label)
+		}
+	}
+	slices.Sort(out)
+	return out
+}
+
+// walletArg parses a command-line wallet: a hex address or an address book
+// label.
+func walletArg(s string) (common.Address, bool) {
+	if common.IsHexAddress(s) {
+		return common.HexToAddress(s), true
+	}
+	return book.resolve(s)
+}
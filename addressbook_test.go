@@ -0,0 +1,41 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+func TestLoadAddressBook(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "book.csv")
+	os.WriteFile(path, []byte("address,label,tags\n"+
+		"# deposit wallets\n"+
+		testWallet.Hex()+",Binance deposit,exchange-deposit;hot\n"+
+		"0x0000000000000000000000000000000000000b0b,cold,\"cold vault\"\n"), 0o644)
+
+	b, err := loadAddressBook(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := b.label(testWallet); got != "Binance deposit" {
+		t.Errorf("label = %q", got)
+	}
+	if addr, ok := b.resolve("binance DEPOSIT"); !ok || addr != testWallet {
+		t.Errorf("resolve = %s, %v", addr.Hex(), ok)
+	}
+	if !b.hasTag(testWallet, "hot") || b.hasTag(testWallet, "cold") {
+		t.Errorf("tags = %q", b[testWallet].Tags)
+	}
+	cold := common.HexToAddress("0x0000000000000000000000000000000000000b0b")
+	if got := b.tagged("vault"); !slices.Equal(got, []string{cold.Hex()}) {
+		t.Errorf("tagged(vault) = %q", got)
+	}
+
+	os.WriteFile(path, []byte("address,label\nnot-an-address,x\n"), 0o644)
+	if _, err := loadAddressBook(path); err == nil {
+		t.Error("bad address accepted")
+	}
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// forEachAddress calls fn for every address given on the command line and
+// then for every line of file ("-" reads stdin). Lines are handled as they
+// are read, so results stream out while a long list is still being fed in.
+// Blank lines and lines starting with # are skipped; invalid addresses are
+// reported and skipped; address book labels stand in for their address.
+// Once ctx is done no further addresses are handled.
+func forEachAddress(ctx context.Context, args []string, file string, fn func(common.Address)) error {
+	emit := func(s string) {
+		s = strings.TrimSpace(s)
+		if ctx.Err() != nil || s == "" || strings.HasPrefix(s, "#") {
+			return
+		}
+		addr, ok := walletArg(s)
+		if !ok {
+			log.Printf("skipping invalid address %q", s)
+			return
+		}
+		fn(addr)
+	}
+	for _, a := range args {
+		emit(a)
+	}
+	if file == "" {
+		return nil
+	}
+
+	var r io.Reader = os.Stdin
+	if file != "-" {
+		f, err := os.Open(file)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		r = f
+	}
+	sc := bufio.NewScanner(r)
+	for ctx.Err() == nil && sc.Scan() {
+		emit(sc.Text())
+	}
+	if err := sc.Err(); err != nil {
+		return fmt.Errorf("reading addresses: %w", err)
+	}
+	return nil
+}
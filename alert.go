@@ -0,0 +1,61 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+)
+
+// alert is a condition worth telling someone about as it happens. Key
+// identifies the condition, so services that track incidents see repeats
+// of it as one, and a Resolved alert as its end.
+type alert struct {
+	Key      string
+	Title    string
+	Text     string
+	Critical bool
+	Resolved bool
+}
+
+// notifiers deliver alerts to the services -notify names, each configured
+// in its own config section.
+var notifiers = map[string]struct {
+	name   string
+	notify func(ctx context.Context, cfg *config, a alert) error
+}{
+	"pagerduty": {"PagerDuty", notifyPagerDuty},
+	"slack":     {"Slack", notifySlack},
+}
+
+func checkNotifiers(names string) error {
+	for _, n := range strings.Split(names, ",") {
+		if _, ok := notifiers[strings.TrimSpace(n)]; !ok {
+			return fmt.Errorf("unknown notifier %q (want %s)", n, strings.Join(notifierNames(), ", "))
+		}
+	}
+	return nil
+}
+
+func notifierNames() []string {
+	var names []string
+	for n := range notifiers {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// sendAlert delivers a to every notifier named; failures are logged.
+func sendAlert(ctx context.Context, names string, cfg *config, a alert) {
+	if names == "" {
+		return
+	}
+	for _, n := range strings.Split(names, ",") {
+		nt := notifiers[strings.TrimSpace(n)]
+		if err := nt.notify(ctx, cfg, a); err != nil {
+			log.Printf("notify: %s: %v", nt.name, err)
+		}
+	}
+}
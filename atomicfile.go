@@ -0,0 +1,52 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// atomicFile collects output in a temp file next to its destination and
+// renames it into place on Commit, so readers of the destination only ever
+// see the previous version or the complete new one. A nil *atomicFile is
+// valid and does nothing.
+type atomicFile struct {
+	*os.File
+	path string
+	mode os.FileMode
+}
+
+func createAtomic(path string) (*atomicFile, error) {
+	f, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return nil, err
+	}
+	return &atomicFile{File: f, path: path, mode: 0o644}, nil
+}
+
+func (f *atomicFile) Commit() error {
+	if f == nil {
+		return nil
+	}
+	if err := f.Sync(); err != nil {
+		f.Abort()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+	if err := os.Chmod(f.Name(), f.mode); err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+	return os.Rename(f.Name(), f.path)
+}
+
+// Abort drops the temp file, leaving the destination untouched.
+func (f *atomicFile) Abort() {
+	if f == nil {
+		return
+	}
+	f.Close()
+	os.Remove(f.Name())
+}
@@ -0,0 +1,363 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"math/big"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+
+	"Test2/bindings"
+)
+
+// -audit writes an evidence bundle next to the run's output: the reports,
+// the block every chain was read at, and every node call the valuation made
+// with its exact calldata and raw answer, Chainlink rounds decoded. As the
+// run reads each chain at one block (see -pin-block, which cannot be off),
+// all of its calls can be re-run; `portfolio audit verify` does that against
+// any node with the state of those blocks. Prices from DefiLlama, CoinGecko and other
+// HTTP APIs are not node calls: the reports name them in price_source.
+
+// auditFormat identifies the bundle layout.
+const auditFormat = "portfolio-audit/1"
+
+type auditBundle struct {
+	Format    string       `json:"format"`
+	Version   string       `json:"version"`
+	Generated time.Time    `json:"generated"`
+	Chains    []auditChain `json:"chains"`
+	Calls     []auditCall  `json:"calls"`
+	Reports   []*report    `json:"reports"`
+}
+
+// auditChain is the block a chain's state was read at.
+type auditChain struct {
+	Chain   string      `json:"chain"`
+	ChainID uint64      `json:"chain_id"`
+	Block   uint64      `json:"block"`
+	Hash    common.Hash `json:"hash"`
+	Time    time.Time   `json:"time"`
+}
+
+// auditCall is one node call and its answer.
+type auditCall struct {
+	Chain  string          `json:"chain"`
+	Block  uint64          `json:"block"`
+	Method string          `json:"method"`
+	To     *common.Address `json:"to,omitempty"`
+	// Data is the calldata of eth_call; the account of eth_getBalance and
+	// eth_getCode is To.
+	Data hexutil.Bytes `json:"data,omitempty"`
+	// Result is eth_call's raw return data, eth_getBalance's balance as a
+	// 32-byte word, or the keccak256 of eth_getCode's code.
+	Result hexutil.Bytes `json:"result,omitempty"`
+	Logs   *auditLogs    `json:"logs,omitempty"`
+	Round  *auditRound   `json:"round,omitempty"`
+	Error  string        `json:"error,omitempty"`
+}
+
+// auditLogs is an eth_getLogs query and the logs it returned.
+type auditLogs struct {
+	From      uint64           `json:"from_block"`
+	To        uint64           `json:"to_block"`
+	Addresses []common.Address `json:"addresses,omitempty"`
+	Topics    [][]common.Hash  `json:"topics,omitempty"`
+	Found     []auditLogRef    `json:"found"`
+}
+
+type auditLogRef struct {
+	Tx    common.Hash `json:"tx"`
+	Index uint        `json:"index"`
+}
+
+// auditRound is the Chainlink round a latestRoundData call answered with.
+type auditRound struct {
+	ID        *hexutil.Big `json:"id"`
+	Answer    *hexutil.Big `json:"answer"`
+	UpdatedAt time.Time    `json:"updated_at"`
+}
+
+// auditTrail collects a run's calls; the lookups of a chain run in
+// parallel.
+type auditTrail struct {
+	mu     sync.Mutex
+	chains []auditChain
+	calls  []auditCall
+}
+
+func (t *auditTrail) add(c auditCall) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.calls = append(t.calls, c)
+}
+
+// record notes the block the connection is pinned at and records its
+// calls from then on.
+func (t *auditTrail) record(c *chainConn) {
+	c.audit = t
+	t.chains = append(t.chains, auditChain{
+		Chain:   chainName(c.id),
+		ChainID: c.id,
+		Block:   c.block.Uint64(),
+		Hash:    c.blockHash,
+		Time:    c.blockTime,
+	})
+}
+
+// write stores the bundle at path, replacing it atomically.
+func (t *auditTrail) write(path string, reps []*report) error {
+	t.mu.Lock()
+	b := auditBundle{Format: auditFormat, Version: version, Generated: time.Now().UTC(), Chains: t.chains, Calls: t.calls, Reports: reps}
+	data, err := json.MarshalIndent(b, "", "  ")
+	t.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	f, err := createAtomic(path)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		f.Abort()
+		return err
+	}
+	return f.Commit()
+}
+
+// auditClient records the calls made through a pinned client.
+type auditClient struct {
+	pinnedClient
+	chain string
+	trail *auditTrail
+}
+
+func (a auditClient) call(block *big.Int, method string, to *common.Address) auditCall {
+	return auditCall{Chain: a.chain, Block: a.at(block).Uint64(), Method: method, To: to}
+}
+
+func (a auditClient) CallContract(ctx context.Context, call ethereum.CallMsg, block *big.Int) ([]byte, error) {
+	out, err := a.pinnedClient.CallContract(ctx, call, block)
+	c := a.call(block, "eth_call", call.To)
+	c.Data, c.Result, c.Round = call.Data, out, decodeRound(call.Data, out)
+	a.record(ctx, c, err)
+	return out, err
+}
+
+func (a auditClient) BalanceAt(ctx context.Context, account common.Address, block *big.Int) (*big.Int, error) {
+	bal, err := a.pinnedClient.BalanceAt(ctx, account, block)
+	c := a.call(block, "eth_getBalance", &account)
+	if err == nil {
+		c.Result = common.BigToHash(bal).Bytes()
+	}
+	a.record(ctx, c, err)
+	return bal, err
+}
+
+func (a auditClient) CodeAt(ctx context.Context, contract common.Address, block *big.Int) ([]byte, error) {
+	code, err := a.pinnedClient.CodeAt(ctx, contract, block)
+	c := a.call(block, "eth_getCode", &contract)
+	if err == nil {
+		c.Result = crypto.Keccak256(code)
+	}
+	a.record(ctx, c, err)
+	return code, err
+}
+
+func (a auditClient) FilterLogs(ctx context.Context, q ethereum.FilterQuery) ([]types.Log, error) {
+	logs, err := a.pinnedClient.FilterLogs(ctx, q)
+	c := a.call(q.ToBlock, "eth_getLogs", nil)
+	l := &auditLogs{To: c.Block, Addresses: q.Addresses, Topics: q.Topics, Found: []auditLogRef{}}
+	if q.FromBlock != nil {
+		l.From = q.FromBlock.Uint64()
+	}
+	for _, log := range logs {
+		l.Found = append(l.Found, auditLogRef{log.TxHash, log.Index})
+	}
+	c.Logs = l
+	a.record(ctx, c, err)
+	return logs, err
+}
+
+// record keeps a call unless it was cut short by the run ending.
+func (a auditClient) record(ctx context.Context, c auditCall, err error) {
+	if err != nil {
+		if ctx.Err() != nil {
+			return
+		}
+		c.Error = err.Error()
+	}
+	a.trail.add(c)
+}
+
+var latestRoundData = func() []byte {
+	parsed, err := bindings.AggregatorMetaData.GetAbi()
+	if err != nil {
+		panic(err)
+	}
+	return parsed.Methods["latestRoundData"].ID
+}()
+
+// decodeRound reads the round out of a latestRoundData answer.
+func decodeRound(data, out []byte) *auditRound {
+	if len(out) != 5*32 || !bytes.HasPrefix(data, latestRoundData) {
+		return nil
+	}
+	word := func(i int) *big.Int { return new(big.Int).SetBytes(out[32*i : 32*(i+1)]) }
+	answer := word(1)
+	if out[32] >= 0x80 { // int256
+		answer.Sub(answer, new(big.Int).Lsh(big.NewInt(1), 256))
+	}
+	return &auditRound{ID: (*hexutil.Big)(word(0)), Answer: (*hexutil.Big)(answer), UpdatedAt: time.Unix(word(3).Int64(), 0).UTC()}
+}
+
+// auditCmd verifies an evidence bundle.
+func auditCmd(args []string) {
+	if len(args) == 0 || args[0] != "verify" {
+		log.Fatalf("Usage: %s audit verify [flags] bundle.json", os.Args[0])
+	}
+	fs := flag.NewFlagSet("audit verify", flag.ExitOnError)
+	fs.String("config", "config.json", "path to the JSON config file")
+	rpc := fs.String("rpc", "", "RPC endpoint to re-run the calls on, for a bundle of one chain (default: ETH_RPC_URL)")
+	cfg, err := parseSettings(fs, args[1:])
+	if err != nil {
+		log.Fatal(err)
+	}
+	if fs.NArg() != 1 {
+		log.Fatalf("Usage: %s audit verify [flags] bundle.json", os.Args[0])
+	}
+	data, err := os.ReadFile(fs.Arg(0))
+	if err != nil {
+		log.Fatal(err)
+	}
+	var b auditBundle
+	if err := json.Unmarshal(data, &b); err != nil {
+		log.Fatalf("%s: %v", fs.Arg(0), err)
+	}
+	if b.Format != auditFormat {
+		log.Fatalf("%s: not an audit bundle (format %q)", fs.Arg(0), b.Format)
+	}
+	ctx := context.Background()
+	var names []string
+	for _, c := range b.Chains {
+		names = append(names, c.Chain)
+	}
+	list := ""
+	if *rpc == "" || len(names) > 1 {
+		list = strings.Join(names, ",")
+	}
+	conns := map[string]headerClient{}
+	for _, c := range openChains(ctx, *rpc, list, cfg) {
+		conns[chainName(c.id)] = c.client
+	}
+	if mismatches := verifyBundle(ctx, os.Stdout, &b, conns); mismatches > 0 {
+		os.Exit(1)
+	}
+}
+
+// headerClient is a node that also serves block headers.
+type headerClient interface {
+	chainClient
+	HeaderByNumber(ctx context.Context, number *big.Int) (*types.Header, error)
+}
+
+// verifyBundle re-runs the bundle's calls and prints the ones that now
+// answer differently, returning how many did.
+func verifyBundle(ctx context.Context, w io.Writer, b *auditBundle, conns map[string]headerClient) int {
+	bad := 0
+	fail := func(format string, args ...any) {
+		bad++
+		fmt.Fprintf(w, "MISMATCH "+format+"\n", args...)
+	}
+	for _, ch := range b.Chains {
+		c, ok := conns[ch.Chain]
+		if !ok {
+			fail("%s: no connection", ch.Chain)
+			continue
+		}
+		head, err := c.HeaderByNumber(ctx, new(big.Int).SetUint64(ch.Block))
+		if err != nil {
+			fail("%s block %d: %v", ch.Chain, ch.Block, err)
+		} else if head.Hash() != ch.Hash {
+			fail("%s block %d: hash %s, bundle has %s", ch.Chain, ch.Block, head.Hash().Hex(), ch.Hash.Hex())
+		}
+	}
+	verified := 0
+	for i, call := range b.Calls {
+		c, ok := conns[call.Chain]
+		if !ok {
+			continue
+		}
+		got, err := replayCall(ctx, c, call)
+		switch {
+		case err != nil && call.Error == "":
+			fail("call %d (%s on %s): %v", i, call.Method, call.Chain, err)
+		case err == nil && call.Error != "":
+			fail("call %d (%s on %s): succeeds now, bundle has %q", i, call.Method, call.Chain, call.Error)
+		case err == nil && !bytes.Equal(got, call.Result):
+			fail("call %d (%s on %s at %d): answer %s, bundle has %s", i, call.Method, call.Chain, call.Block, hexutil.Encode(got), hexutil.Encode(call.Result))
+		default:
+			verified++
+		}
+	}
+	fmt.Fprintf(w, "%d of %d calls verified, %d mismatches\n", verified, len(b.Calls), bad)
+	return bad
+}
+
+// replayCall re-runs a recorded call and returns its answer in the form
+// the bundle keeps it.
+func replayCall(ctx context.Context, client chainClient, call auditCall) ([]byte, error) {
+	block := new(big.Int).SetUint64(call.Block)
+	switch call.Method {
+	case "eth_call":
+		return client.CallContract(ctx, ethereum.CallMsg{To: call.To, Data: call.Data}, block)
+	case "eth_getBalance":
+		bal, err := client.BalanceAt(ctx, *call.To, block)
+		if err != nil {
+			return nil, err
+		}
+		return common.BigToHash(bal).Bytes(), nil
+	case "eth_getCode":
+		code, err := client.CodeAt(ctx, *call.To, block)
+		if err != nil {
+			return nil, err
+		}
+		return crypto.Keccak256(code), nil
+	case "eth_getLogs":
+		q := call.Logs
+		logs, err := client.FilterLogs(ctx, ethereum.FilterQuery{
+			FromBlock: new(big.Int).SetUint64(q.From), ToBlock: new(big.Int).SetUint64(q.To),
+			Addresses: q.Addresses, Topics: q.Topics,
+		})
+		if err != nil {
+			return nil, err
+		}
+		var got []auditLogRef
+		for _, l := range logs {
+			got = append(got, auditLogRef{l.TxHash, l.Index})
+		}
+		if len(got) != len(q.Found) {
+			return nil, fmt.Errorf("%d logs, bundle has %d", len(got), len(q.Found))
+		}
+		for i := range got {
+			if got[i] != q.Found[i] {
+				return nil, fmt.Errorf("log %d is %s/%d, bundle has %s/%d", i, got[i].Tx.Hex(), got[i].Index, q.Found[i].Tx.Hex(), q.Found[i].Index)
+			}
+		}
+		return call.Result, nil
+	}
+	return nil, errors.New("unknown method " + call.Method)
+}
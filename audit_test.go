@@ -0,0 +1,65 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core"
+)
+
+func TestAuditTrail(t *testing.T) {
+	link, _ := tokenBySymbol("LINK")
+	sim := newSim(t, core.GenesisAlloc{
+		link.TokenAddr: mockToken(18, map[common.Address]*big.Int{testWallet: scaled(t, "4", 18)}),
+		link.FeedAddr:  mockFeed(8, scaled(t, "15", 8), 1_700_000_000),
+	})
+	ctx := context.Background()
+	head, err := sim.HeaderByNumber(ctx, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	trail := &auditTrail{chains: []auditChain{{Chain: "mainnet", ChainID: 1, Block: 0, Hash: head.Hash()}}}
+	client := auditClient{pinnedClient{sim, big.NewInt(0)}, "mainnet", trail}
+	held := options{Only: "ETH,LINK"}.filter(1, walletHoldings(ctx, client, 1, testWallet, &config{}))
+	if _, err := feedPrice(ctx, client, link.FeedAddr); err != nil {
+		t.Fatal(err)
+	}
+	if len(held) != 1 {
+		t.Fatalf("%d holdings", len(held))
+	}
+
+	var balance, round *auditCall
+	for i, c := range trail.calls {
+		if c.Block != 0 {
+			t.Errorf("call %d at block %d, want 0", i, c.Block)
+		}
+		switch {
+		case c.Method == "eth_getBalance":
+			balance = &trail.calls[i]
+		case c.Round != nil:
+			round = &trail.calls[i]
+		}
+	}
+	if balance == nil || *balance.To != testWallet {
+		t.Fatalf("no eth_getBalance of the wallet in %+v", trail.calls)
+	}
+	if round == nil || *round.To != link.FeedAddr || (*big.Int)(round.Round.Answer).Cmp(scaled(t, "15", 8)) != 0 || round.Round.UpdatedAt.Unix() != 1_700_000_000 {
+		t.Fatalf("latestRoundData not decoded: %+v", round)
+	}
+
+	b := &auditBundle{Format: auditFormat, Chains: trail.chains, Calls: trail.calls}
+	var out bytes.Buffer
+	if n := verifyBundle(ctx, &out, b, map[string]headerClient{"mainnet": sim}); n != 0 {
+		t.Fatalf("%d mismatches on the same chain:\n%s", n, out.String())
+	}
+	round.Result = hexutil.Bytes(bytes.Repeat([]byte{0}, len(round.Result)))
+	out.Reset()
+	if n := verifyBundle(ctx, &out, b, map[string]headerClient{"mainnet": sim}); n != 1 || !strings.Contains(out.String(), "MISMATCH call") {
+		t.Fatalf("tampered answer: %d mismatches:\n%s", n, out.String())
+	}
+}
@@ -0,0 +1,304 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"log"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+
+	"Test2/bindings"
+)
+
+// Price history lives under <data dir>/history/<chain>/<SYMBOL>.csv, one
+// "time,price" row per point, oldest first.
+
+func historyPath(chain, symbol string) string {
+	return filepath.Join(dataDir(), "history", chain, strings.ToUpper(symbol)+".csv")
+}
+
+// feedRound is the part of a Chainlink round the backfill uses.
+type feedRound struct {
+	answer    *big.Int
+	updatedAt uint64
+}
+
+// roundSource reads a feed's rounds. A round that does not exist reads as
+// an error or a zero updatedAt.
+type roundSource interface {
+	latestRoundID(ctx context.Context) (*big.Int, error)
+	round(ctx context.Context, id *big.Int) (feedRound, error)
+}
+
+type aggregatorRounds struct {
+	feed *bindings.AggregatorCaller
+}
+
+func (a aggregatorRounds) latestRoundID(ctx context.Context) (*big.Int, error) {
+	r, err := a.feed.LatestRoundData(&bind.CallOpts{Context: ctx})
+	if err != nil {
+		return nil, err
+	}
+	return r.RoundId, nil
+}
+
+func (a aggregatorRounds) round(ctx context.Context, id *big.Int) (feedRound, error) {
+	r, err := a.feed.GetRoundData(&bind.CallOpts{Context: ctx}, id)
+	if err != nil {
+		return feedRound{}, err
+	}
+	return feedRound{answer: r.Answer, updatedAt: r.UpdatedAt.Uint64()}, nil
+}
+
+// feedHistory answers "what did the feed say at time t" by binary search
+// over its rounds. A proxy's round ID is phase<<64 | aggregator round, each
+// phase being one aggregator whose rounds count up from 1 in time order, so
+// earlier times are looked up in earlier phases.
+type feedHistory struct {
+	src    roundSource
+	phase  uint64            // latest phase
+	last   map[uint64]uint64 // last round of each phase seen
+	rounds map[string]feedRound
+}
+
+func newFeedHistory(ctx context.Context, src roundSource) (*feedHistory, error) {
+	id, err := src.latestRoundID(ctx)
+	if err != nil {
+		return nil, err
+	}
+	phase := new(big.Int).Rsh(id, 64).Uint64()
+	n := new(big.Int).And(id, new(big.Int).SetUint64(1<<64-1)).Uint64()
+	return &feedHistory{src: src, phase: phase, last: map[uint64]uint64{phase: n}, rounds: map[string]feedRound{}}, nil
+}
+
+// get reads a round, remembering it; ok is false when it does not exist.
+func (h *feedHistory) get(ctx context.Context, phase, n uint64) (feedRound, bool, error) {
+	id := new(big.Int).Or(new(big.Int).Lsh(new(big.Int).SetUint64(phase), 64), new(big.Int).SetUint64(n))
+	if r, ok := h.rounds[id.String()]; ok {
+		return r, r.updatedAt != 0, nil
+	}
+	r, err := h.src.round(ctx, id)
+	if err != nil {
+		if ctx.Err() != nil {
+			return r, false, ctx.Err()
+		}
+		r = feedRound{} // no such round
+	}
+	h.rounds[id.String()] = r
+	return r, r.updatedAt != 0, nil
+}
+
+// lastRound finds the last round of an earlier phase by doubling and then
+// bisecting; 0 means the phase has no rounds.
+func (h *feedHistory) lastRound(ctx context.Context, phase uint64) (uint64, error) {
+	if n, ok := h.last[phase]; ok {
+		return n, nil
+	}
+	lo, hi := uint64(0), uint64(1)
+	for {
+		_, ok, err := h.get(ctx, phase, hi)
+		if err != nil {
+			return 0, err
+		}
+		if !ok {
+			break
+		}
+		lo, hi = hi, hi*2
+	}
+	for hi-lo > 1 { // round lo exists (or is 0), round hi does not
+		mid := lo + (hi-lo)/2
+		_, ok, err := h.get(ctx, phase, mid)
+		if err != nil {
+			return 0, err
+		}
+		if ok {
+			lo = mid
+		} else {
+			hi = mid
+		}
+	}
+	h.last[phase] = lo
+	return lo, nil
+}
+
+// at returns the answer of the last round updated at or before t; ok is
+// false when the feed had no answer yet. A plain aggregator, read directly
+// rather than through a proxy, has only phase 0.
+func (h *feedHistory) at(ctx context.Context, t uint64) (*big.Int, bool, error) {
+	for phase := h.phase + 1; phase > 0; {
+		phase--
+		last, err := h.lastRound(ctx, phase)
+		if err != nil {
+			return nil, false, err
+		}
+		first, ok, err := h.get(ctx, phase, 1)
+		if err != nil {
+			return nil, false, err
+		}
+		if last == 0 || !ok || first.updatedAt > t {
+			continue
+		}
+		lo, hi := uint64(1), last // round lo is at or before t
+		for lo < hi {
+			mid := lo + (hi-lo+1)/2
+			r, ok, err := h.get(ctx, phase, mid)
+			if err != nil {
+				return nil, false, err
+			}
+			if ok && r.updatedAt <= t {
+				lo = mid
+			} else {
+				hi = mid - 1
+			}
+		}
+		r, _, err := h.get(ctx, phase, lo)
+		return r.answer, err == nil, err
+	}
+	return nil, false, nil
+}
+
+// backfillCmd walks the Chainlink rounds of the registry's feeds and stores
+// one price per interval from -from to now in the local price history.
+// Points already stored are replaced.
+func backfillCmd(args []string) {
+	fs := flag.NewFlagSet("backfill", flag.ExitOnError)
+	fs.String("config", "config.json", "path to the JSON config file")
+	rpc := fs.String("rpc", "", "RPC endpoint of the chain to backfill by default (default: ETH_RPC_URL)")
+	chainList := fs.String("chains", "", "comma-separated chains to backfill (default: the chain -rpc points at)")
+	from := fs.String("from", "", "first day to backfill, e.g. 2023-01-01")
+	interval := fs.String("interval", "1d", "spacing of the points (e.g. 1d, 6h)")
+	symbols := fs.String("symbols", "", "comma-separated symbols to backfill (default: every token with a feed)")
+	cfg, err := parseSettings(fs, args)
+	if err != nil {
+		log.Fatal(err)
+	}
+	start, err := time.Parse(time.DateOnly, *from)
+	if err != nil {
+		log.Fatalf("backfill: -from: %v", err)
+	}
+	step, err := parseSince(*interval)
+	if err != nil || step <= 0 {
+		log.Fatalf("backfill: bad -interval %q", *interval)
+	}
+	only := options{Only: *symbols}
+
+	ctx := context.Background()
+	for _, c := range openChains(ctx, *rpc, *chainList, cfg) {
+		seen := map[common.Address]bool{}
+		for _, tf := range registry(c.id) {
+			if tf.FeedAddr == (common.Address{}) || seen[tf.FeedAddr] || !only.wants(tf.Symbol) {
+				continue
+			}
+			seen[tf.FeedAddr] = true
+			n, err := c.backfill(ctx, tf, start, step)
+			if err != nil {
+				log.Printf("%s %s: %v", chainName(c.id), tf.Symbol, err)
+				continue
+			}
+			fmt.Printf("%s %s: %d points -> %s\n", chainName(c.id), tf.Symbol, n, historyPath(chainName(c.id), tf.Symbol))
+		}
+	}
+}
+
+func (c *chainConn) backfill(ctx context.Context, tf tokenFeed, start time.Time, step time.Duration) (int, error) {
+	feed, err := bindings.NewAggregatorCaller(tf.FeedAddr, c.client)
+	if err != nil {
+		return 0, err
+	}
+	dec, err := feed.Decimals(&bind.CallOpts{Context: ctx})
+	if err != nil {
+		return 0, fmt.Errorf("decimals: %w", err)
+	}
+	h, err := newFeedHistory(ctx, aggregatorRounds{feed})
+	if err != nil {
+		return 0, err
+	}
+	points := map[time.Time]float64{}
+	for t := start.UTC(); !t.After(time.Now()); t = t.Add(step) {
+		answer, ok, err := h.at(ctx, uint64(t.Unix()))
+		if err != nil {
+			return 0, err
+		}
+		if ok {
+			points[t], _ = tokenAmount(answer, int(dec)).Float64()
+		}
+	}
+	return len(points), mergeHistory(historyPath(chainName(c.id), tf.Symbol), points)
+}
+
+// loadHistory reads a price history file; a missing file is empty.
+func loadHistory(path string) (map[time.Time]float64, error) {
+	points := map[time.Time]float64{}
+	f, err := os.Open(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return points, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	rows, err := csv.NewReader(f).ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	for i, row := range rows {
+		if i == 0 && row[0] == "time" {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, row[0])
+		if err != nil || len(row) < 2 {
+			return nil, fmt.Errorf("%s: line %d: bad row", path, i+1)
+		}
+		if points[t], err = strconv.ParseFloat(row[1], 64); err != nil {
+			return nil, fmt.Errorf("%s: line %d: %w", path, i+1, err)
+		}
+	}
+	return points, nil
+}
+
+// mergeHistory adds points to a price history file, replacing the stored
+// price of any time it already has.
+func mergeHistory(path string, points map[time.Time]float64) error {
+	all, err := loadHistory(path)
+	if err != nil {
+		return err
+	}
+	for t, p := range points {
+		all[t] = p
+	}
+	times := make([]time.Time, 0, len(all))
+	for t := range all {
+		times = append(times, t)
+	}
+	sort.Slice(times, func(i, j int) bool { return times[i].Before(times[j]) })
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	f, err := createAtomic(path)
+	if err != nil {
+		return err
+	}
+	w := csv.NewWriter(f)
+	w.Write([]string{"time", "price"})
+	for _, t := range times {
+		w.Write([]string{t.UTC().Format(time.RFC3339), strconv.FormatFloat(all[t], 'g', -1, 64)})
+	}
+	if w.Flush(); w.Error() != nil {
+		f.Abort()
+		return w.Error()
+	}
+	return f.Commit()
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// fakeRounds is a proxy whose phases hold rounds updated at the given
+// times, each answering its own update time.
+type fakeRounds struct {
+	phases map[uint64][]uint64
+	latest uint64
+	calls  int
+}
+
+func (f *fakeRounds) latestRoundID(context.Context) (*big.Int, error) {
+	n := uint64(len(f.phases[f.latest]))
+	return new(big.Int).Or(new(big.Int).Lsh(new(big.Int).SetUint64(f.latest), 64), new(big.Int).SetUint64(n)), nil
+}
+
+func (f *fakeRounds) round(_ context.Context, id *big.Int) (feedRound, error) {
+	f.calls++
+	phase := new(big.Int).Rsh(id, 64).Uint64()
+	n := new(big.Int).And(id, new(big.Int).SetUint64(1<<64-1)).Uint64()
+	times := f.phases[phase]
+	if n == 0 || n > uint64(len(times)) {
+		return feedRound{}, errors.New("execution reverted: No data present")
+	}
+	t := times[n-1]
+	return feedRound{answer: new(big.Int).SetUint64(t), updatedAt: t}, nil
+}
+
+func TestFeedHistoryAcrossPhases(t *testing.T) {
+	var old, cur []uint64
+	for i := uint64(0); i < 100; i++ {
+		old = append(old, 1000+10*i) // 1000 .. 1990
+	}
+	for i := uint64(0); i < 37; i++ {
+		cur = append(cur, 3000+100*i) // 3000 .. 6600
+	}
+	src := &fakeRounds{phases: map[uint64][]uint64{2: old, 3: cur}, latest: 3}
+	h, err := newFeedHistory(context.Background(), src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tc := range []struct {
+		at   uint64
+		want uint64 // 0: no answer yet
+	}{
+		{500, 0},
+		{1000, 1000},
+		{1455, 1450},
+		{2500, 1990}, // between phases: the old aggregator's last answer
+		{3050, 3000},
+		{9999, 6600},
+	} {
+		got, ok, err := h.at(context.Background(), tc.at)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if tc.want == 0 {
+			if ok {
+				t.Errorf("at %d: got %v, want no answer", tc.at, got)
+			}
+			continue
+		}
+		if !ok || got.Uint64() != tc.want {
+			t.Errorf("at %d: got %v (ok %v), want %d", tc.at, got, ok, tc.want)
+		}
+	}
+	if src.calls > 60 {
+		t.Errorf("%d round reads, want a logarithmic number", src.calls)
+	}
+}
+
+func TestMergeHistory(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "mainnet", "ETH.csv")
+	day := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	if err := mergeHistory(path, map[time.Time]float64{day: 1200, day.AddDate(0, 0, 1): 1210}); err != nil {
+		t.Fatal(err)
+	}
+	if err := mergeHistory(path, map[time.Time]float64{day.AddDate(0, 0, 1): 1215.5, day.AddDate(0, 0, 2): 1230}); err != nil {
+		t.Fatal(err)
+	}
+	got, err := loadHistory(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[time.Time]float64{day: 1200, day.AddDate(0, 0, 1): 1215.5, day.AddDate(0, 0, 2): 1230}
+	if len(got) != len(want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	for k, v := range want {
+		if got[k] != v {
+			t.Errorf("%s: got %v, want %v", k.Format(time.DateOnly), got[k], v)
+		}
+	}
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+
+	"Test2/source"
+)
+
+// balancerVault holds the tokens of every Balancer v2 pool; the pool contract
+// itself is the BPT.
+var balancerVault = common.HexToAddress("0xBA12222222228d8Ba445958a75a0704d566BF2C8")
+
+var balancerABI = mustABI(`[
+  {"inputs":[],"name":"getPoolId","outputs":[{"type":"bytes32"}],"stateMutability":"view","type":"function"},
+  {"inputs":[{"name":"poolId","type":"bytes32"}],"name":"getPoolTokens","outputs":[{"name":"tokens","type":"address[]"},{"name":"balances","type":"uint256[]"},{"name":"lastChangeBlock","type":"uint256"}],"stateMutability":"view","type":"function"},
+  {"inputs":[],"name":"getActualSupply","outputs":[{"type":"uint256"}],"stateMutability":"view","type":"function"},
+  {"inputs":[],"name":"totalSupply","outputs":[{"type":"uint256"}],"stateMutability":"view","type":"function"},
+  {"inputs":[],"name":"lp_token","outputs":[{"type":"address"}],"stateMutability":"view","type":"function"}
+]`)
+
+// balancerHoldings values the wallet's share of each listed pool, given
+// either as the pool (BPT) address or as a liquidity gauge staking it. The
+// share is reported as pro-rata amounts of the pool's tokens.
+func balancerHoldings(ctx context.Context, client chainClient, wallet common.Address, addrs string) ([]*holding, error) {
+	var held []*holding
+	for _, a := range strings.Split(addrs, ",") {
+		if a = strings.TrimSpace(a); a == "" {
+			continue
+		}
+		if !common.IsHexAddress(a) {
+			return held, fmt.Errorf("pool: %w %q", source.ErrInvalidAddress, a)
+		}
+		pool, note := common.HexToAddress(a), "Balancer LP"
+		id := "balancer:" + pool.Hex()
+		bpt, err := erc20Balance(ctx, client, pool, wallet)
+		if err != nil {
+			return held, err
+		}
+		if vs, err := callView(ctx, client, pool, balancerABI, "lp_token"); err == nil {
+			// A gauge: its balance is staked BPT of the pool it wraps.
+			pool, note = vs[0].(common.Address), "Balancer LP, gauge-staked"
+			id = "balancer:" + pool.Hex() + ":gauge"
+		}
+		if bpt.Sign() == 0 {
+			continue
+		}
+		hs, err := balancerShare(ctx, client, pool, bpt, note, id)
+		if err != nil {
+			return held, err
+		}
+		held = append(held, hs...)
+	}
+	return held, nil
+}
+
+// balancerShare splits bpt of the pool into its tokens, each line with the
+// given note and position id.
+func balancerShare(ctx context.Context, client chainClient, pool common.Address, bpt *big.Int, note, id string) ([]*holding, error) {
+	vs, err := callView(ctx, client, pool, balancerABI, "getPoolId")
+	if err != nil {
+		return nil, err
+	}
+	poolID := vs[0].([32]byte)
+	// Composable stable pools pre-mint BPT, so only the actual supply counts.
+	vs, err = callView(ctx, client, pool, balancerABI, "getActualSupply")
+	if err != nil {
+		if vs, err = callView(ctx, client, pool, balancerABI, "totalSupply"); err != nil {
+			return nil, err
+		}
+	}
+	supply := vs[0].(*big.Int)
+	if supply.Sign() == 0 {
+		return nil, nil
+	}
+	vs, err = callView(ctx, client, balancerVault, balancerABI, "getPoolTokens", poolID)
+	if err != nil {
+		return nil, err
+	}
+	tokens, balances := vs[0].([]common.Address), vs[1].([]*big.Int)
+
+	var held []*holding
+	for i, token := range tokens {
+		if token == pool {
+			continue
+		}
+		tf, err := resolveToken(ctx, client, token)
+		if err != nil {
+			return held, err
+		}
+		share := new(big.Int).Mul(balances[i], bpt)
+		share.Quo(share, supply)
+		held = append(held, &holding{tf: tf, amt: tokenAmount(share, tf.Decimals), note: note, id: id})
+	}
+	return held, nil
+}
@@ -0,0 +1,334 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"math/big"
+	"os"
+	"os/signal"
+	"slices"
+	"sort"
+	"strings"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// `portfolio balances` rebuilds a wallet's ERC-20 balances over time without
+// reading past state: it starts from the balances at the head block and
+// walks the wallet's Transfer logs backwards, undoing each one. Any node
+// that serves logs can answer that, archive or not. Tokens whose balances
+// change without a Transfer, such as rebasing ones, come out wrong; a
+// balance that would go negative is flagged.
+
+// balanceLogChunk is the block range asked for in one eth_getLogs call,
+// halved whenever the node refuses a range.
+const balanceLogChunk = 50_000
+
+// balanceChange is a token's balance after one block's transfers.
+type balanceChange struct {
+	Block   uint64    `json:"block"`
+	Time    time.Time `json:"time"`
+	Change  float64   `json:"change"`
+	Balance float64   `json:"balance"`
+}
+
+// balanceHistory is a token's balance at From and after every change since.
+type balanceHistory struct {
+	Symbol  string          `json:"symbol"`
+	Token   common.Address  `json:"token"`
+	From    uint64          `json:"from_block"`
+	Start   float64         `json:"start_balance"`
+	Changes []balanceChange `json:"changes"`
+	// Inconsistent is set when undoing the transfers goes below zero.
+	Inconsistent bool `json:"inconsistent,omitempty"`
+}
+
+// replayBalance undoes the wallet's transfers of one token, newest first,
+// from its balance now. logs are the token's Transfer logs from or to the
+// wallet since from, in any order and without duplicates.
+func replayBalance(tf tokenFeed, wallet common.Address, now *big.Int, from uint64, logs []types.Log) balanceHistory {
+	sort.Slice(logs, func(i, j int) bool {
+		if logs[i].BlockNumber != logs[j].BlockNumber {
+			return logs[i].BlockNumber > logs[j].BlockNumber
+		}
+		return logs[i].Index > logs[j].Index
+	})
+	h := balanceHistory{Symbol: tf.Symbol, Token: tf.TokenAddr, From: from}
+	balance := new(big.Int).Set(now)
+	var deltas []*big.Int
+	for _, l := range logs {
+		if len(l.Topics) != 3 || len(l.Data) != 32 {
+			continue // ERC-721 transfers index the token ID
+		}
+		amount := new(big.Int).SetBytes(l.Data)
+		delta := new(big.Int)
+		if common.BytesToAddress(l.Topics[2].Bytes()) == wallet {
+			delta.Add(delta, amount)
+		}
+		if common.BytesToAddress(l.Topics[1].Bytes()) == wallet {
+			delta.Sub(delta, amount)
+		}
+		if n := len(h.Changes); n > 0 && h.Changes[n-1].Block == l.BlockNumber {
+			deltas[n-1].Add(deltas[n-1], delta)
+		} else {
+			h.Changes = append(h.Changes, balanceChange{Block: l.BlockNumber, Balance: floatAmount(balance, tf.Decimals)})
+			deltas = append(deltas, delta)
+		}
+		balance.Sub(balance, delta)
+		if balance.Sign() < 0 {
+			h.Inconsistent = true
+		}
+	}
+	for i := range h.Changes {
+		h.Changes[i].Change = floatAmount(deltas[i], tf.Decimals)
+	}
+	slices.Reverse(h.Changes)
+	h.Start = floatAmount(balance, tf.Decimals)
+	return h
+}
+
+func floatAmount(raw *big.Int, decimals int) float64 {
+	f, _ := tokenAmount(raw, decimals).Float64()
+	return f
+}
+
+// transferLogs fetches the Transfer logs of tokens from or to the wallet
+// between from and to, splitting the range whenever the node refuses it.
+// A transfer to oneself is returned once.
+func transferLogs(ctx context.Context, client chainClient, tokens []common.Address, wallet common.Address, from, to uint64) ([]types.Log, error) {
+	who := []common.Hash{common.BytesToHash(wallet.Bytes())}
+	var logs []types.Log
+	seen := map[[2]any]bool{}
+	chunk := uint64(balanceLogChunk)
+	for start := from; start <= to; {
+		end := min(start+chunk-1, to)
+		var got []types.Log
+		var err error
+		for _, topics := range [][][]common.Hash{{{transferTopic}, who}, {{transferTopic}, nil, who}} {
+			var ls []types.Log
+			ls, err = client.FilterLogs(ctx, ethereum.FilterQuery{
+				FromBlock: new(big.Int).SetUint64(start),
+				ToBlock:   new(big.Int).SetUint64(end),
+				Addresses: tokens,
+				Topics:    topics,
+			})
+			if err != nil {
+				break
+			}
+			got = append(got, ls...)
+		}
+		if err != nil {
+			if chunk == 1 || ctx.Err() != nil {
+				return nil, fmt.Errorf("transfer logs %d..%d: %w", start, end, err)
+			}
+			chunk /= 2
+			continue
+		}
+		for _, l := range got {
+			key := [2]any{l.TxHash, l.Index}
+			if !seen[key] {
+				seen[key] = true
+				logs = append(logs, l)
+			}
+		}
+		runProgress.set("read logs up to block %d of %d", end, to)
+		start = end + 1
+	}
+	return logs, nil
+}
+
+// pagedLogs runs q over from..to in chunks, splitting the range whenever
+// the node refuses it.
+func pagedLogs(ctx context.Context, client chainClient, q ethereum.FilterQuery, from, to uint64) ([]types.Log, error) {
+	var logs []types.Log
+	chunk := uint64(balanceLogChunk)
+	for start := from; start <= to; {
+		end := min(start+chunk-1, to)
+		q.FromBlock, q.ToBlock = new(big.Int).SetUint64(start), new(big.Int).SetUint64(end)
+		got, err := client.FilterLogs(ctx, q)
+		if err != nil {
+			if chunk == 1 || ctx.Err() != nil {
+				return nil, fmt.Errorf("logs %d..%d: %w", start, end, err)
+			}
+			chunk /= 2
+			continue
+		}
+		logs = append(logs, got...)
+		runProgress.set("read logs up to block %d of %d", end, to)
+		start = end + 1
+	}
+	return logs, nil
+}
+
+// blockAtTime finds the first block at or after t by bisecting headers.
+func (c *chainConn) blockAtTime(ctx context.Context, t time.Time, head uint64) (uint64, error) {
+	lo, hi := uint64(0), head
+	for lo < hi {
+		mid := lo + (hi-lo)/2
+		h, err := c.client.HeaderByNumber(ctx, new(big.Int).SetUint64(mid))
+		if err != nil {
+			return 0, err
+		}
+		if int64(h.Time) < t.Unix() {
+			lo = mid + 1
+		} else {
+			hi = mid
+		}
+	}
+	return lo, nil
+}
+
+// balanceHistories rebuilds the wallet's balances of tokens since from, as
+// of the head block.
+func (c *chainConn) balanceHistories(ctx context.Context, wallet common.Address, tokens []tokenFeed, from, head uint64) ([]balanceHistory, error) {
+	client := pinnedClient{c.client, new(big.Int).SetUint64(head)}
+	addrs := make([]common.Address, len(tokens))
+	for i, tf := range tokens {
+		addrs[i] = tf.TokenAddr
+	}
+	logs, err := transferLogs(ctx, client, addrs, wallet, from, head)
+	if err != nil {
+		return nil, err
+	}
+	byToken := map[common.Address][]types.Log{}
+	for _, l := range logs {
+		byToken[l.Address] = append(byToken[l.Address], l)
+	}
+	times := map[uint64]time.Time{}
+	var out []balanceHistory
+	for _, tf := range tokens {
+		now, err := erc20Balance(ctx, client, tf.TokenAddr, wallet)
+		if err != nil {
+			return out, fmt.Errorf("%s balance: %w", tf.Symbol, err)
+		}
+		h := replayBalance(tf, wallet, now, from, byToken[tf.TokenAddr])
+		if len(h.Changes) == 0 && now.Sign() == 0 {
+			continue
+		}
+		for i, ch := range h.Changes {
+			t, ok := times[ch.Block]
+			if !ok {
+				hd, err := c.client.HeaderByNumber(ctx, new(big.Int).SetUint64(ch.Block))
+				if err != nil {
+					return out, fmt.Errorf("block %d: %w", ch.Block, err)
+				}
+				t = time.Unix(int64(hd.Time), 0).UTC()
+				times[ch.Block] = t
+			}
+			h.Changes[i].Time = t
+		}
+		out = append(out, h)
+	}
+	return out, nil
+}
+
+// balancesCmd prints a wallet's ERC-20 balance history on one chain.
+func balancesCmd(args []string) {
+	fs := flag.NewFlagSet("balances", flag.ExitOnError)
+	fs.String("config", "config.json", "path to the JSON config file")
+	rpc := fs.String("rpc", "", "RPC endpoint of the chain to read (default: ETH_RPC_URL)")
+	chain := fs.String("chain", "", "chain to read, by name (default: the chain -rpc points at)")
+	since := fs.String("since", "30d", "how far back to go, e.g. 7d or 72h")
+	fromBlock := fs.Uint64("from-block", 0, "go back to this block instead of -since")
+	only := fs.String("only", "", "comma-separated symbols to rebuild; default: every registry token")
+	format := fs.String("format", "text", "output format: text or json")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s balances [flags] address|label\n", os.Args[0])
+		fs.PrintDefaults()
+	}
+	cfg, err := parseSettings(fs, args)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if *format != "text" && *format != "json" {
+		log.Fatalf("-format: unknown format %q", *format)
+	}
+	age, err := parseSince(*since)
+	if err != nil {
+		log.Fatalf("-since: %v", err)
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	var wallet common.Address
+	if err := forEachAddress(ctx, fs.Args(), "", func(a common.Address) { wallet = a }); err != nil {
+		log.Fatal(err)
+	}
+	c := openChains(ctx, *rpc, *chain, cfg)
+	if len(c) == 0 {
+		log.Fatalf("%s: unreachable", *chain)
+	}
+	conn := c[0]
+	var tokens []tokenFeed
+	for _, tf := range registry(conn.id) {
+		if tf.TokenAddr == (common.Address{}) || !cfg.tokenAllowed(tf.TokenAddr) {
+			continue
+		}
+		if *only != "" && !slices.ContainsFunc(strings.Split(*only, ","), func(s string) bool { return strings.EqualFold(strings.TrimSpace(s), tf.Symbol) }) {
+			continue
+		}
+		tokens = append(tokens, tf)
+	}
+	if len(tokens) == 0 {
+		log.Fatalf("no ERC-20 tokens to rebuild on %s", chainName(conn.id))
+	}
+	head, err := conn.client.BlockNumber(ctx)
+	if err != nil {
+		log.Fatal(err)
+	}
+	from := *fromBlock
+	if from == 0 {
+		if from, err = conn.blockAtTime(ctx, time.Now().Add(-age), head); err != nil {
+			log.Fatalf("-since: %v", err)
+		}
+	}
+	hist, err := conn.balanceHistories(ctx, wallet, tokens, from, head)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if *format == "json" {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(hist); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+	printBalances(os.Stdout, hist)
+}
+
+func printBalances(w io.Writer, hist []balanceHistory) {
+	if len(hist) == 0 {
+		fmt.Fprintln(w, "no balances or transfers in range")
+		return
+	}
+	for i, h := range hist {
+		if i > 0 {
+			fmt.Fprintln(w)
+		}
+		fmt.Fprintf(w, "%s (%s)\n", h.Symbol, h.Token.Hex())
+		if h.Inconsistent {
+			fmt.Fprintln(w, "warning: the transfers do not add up to the balance; the token may rebase or charge fees")
+		}
+		tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', tabwriter.AlignRight)
+		fmt.Fprintf(tw, "BLOCK\tTIME\tCHANGE\tBALANCE\t\n")
+		fmt.Fprintf(tw, "%d\t\t\t%.6f\t\n", h.From, h.Start)
+		for _, ch := range h.Changes {
+			fmt.Fprintf(tw, "%d\t%s\t%+.6f\t%.6f\t\n", ch.Block, ch.Time.Local().Format("2006-01-02 15:04"), ch.Change, ch.Balance)
+		}
+		tw.Flush()
+	}
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+func transferLog(block uint64, index uint, from, to common.Address, amount int64) types.Log {
+	return types.Log{
+		BlockNumber: block,
+		Index:       index,
+		TxHash:      common.BigToHash(big.NewInt(int64(block)*100 + int64(index))),
+		Topics:      []common.Hash{transferTopic, common.BytesToHash(from.Bytes()), common.BytesToHash(to.Bytes())},
+		Data:        common.BigToHash(big.NewInt(amount)).Bytes(),
+	}
+}
+
+func TestReplayBalance(t *testing.T) {
+	other := common.HexToAddress("0x00000000000000000000000000000000000000bb")
+	tf := tokenFeed{Symbol: "TKN", Decimals: 0}
+	logs := []types.Log{
+		transferLog(120, 0, testWallet, other, 30),
+		transferLog(100, 3, other, testWallet, 50),
+		transferLog(120, 1, other, testWallet, 5),
+		transferLog(110, 0, testWallet, testWallet, 7),
+	}
+	h := replayBalance(tf, testWallet, big.NewInt(25), 90, logs)
+	if h.Start != 0 || h.Inconsistent {
+		t.Fatalf("start %v, inconsistent %v; want 0, false", h.Start, h.Inconsistent)
+	}
+	want := []balanceChange{{Block: 100, Change: 50, Balance: 50}, {Block: 110, Change: 0, Balance: 50}, {Block: 120, Change: -25, Balance: 25}}
+	if len(h.Changes) != len(want) {
+		t.Fatalf("%d changes, want %d", len(h.Changes), len(want))
+	}
+	for i, w := range want {
+		if h.Changes[i] != w {
+			t.Errorf("change %d = %+v, want %+v", i, h.Changes[i], w)
+		}
+	}
+
+	// Less held now than the transfers account for: a rebasing token.
+	if h := replayBalance(tf, testWallet, big.NewInt(10), 90, logs); !h.Inconsistent {
+		t.Error("negative start balance not flagged")
+	}
+}
+
+// rangeLimitedClient refuses log queries wider than max blocks.
+type rangeLimitedClient struct {
+	chainClient
+	max  uint64
+	logs []types.Log
+}
+
+func (r *rangeLimitedClient) FilterLogs(ctx context.Context, q ethereum.FilterQuery) ([]types.Log, error) {
+	from, to := q.FromBlock.Uint64(), q.ToBlock.Uint64()
+	if to-from+1 > r.max {
+		return nil, errors.New("query exceeds max block range")
+	}
+	var out []types.Log
+	for _, l := range r.logs {
+		if l.BlockNumber < from || l.BlockNumber > to {
+			continue
+		}
+		if len(q.Topics) > 1 && q.Topics[1] != nil && l.Topics[1] != q.Topics[1][0] {
+			continue
+		}
+		if len(q.Topics) > 2 && q.Topics[2] != nil && l.Topics[2] != q.Topics[2][0] {
+			continue
+		}
+		out = append(out, l)
+	}
+	return out, nil
+}
+
+func TestTransferLogs(t *testing.T) {
+	other := common.HexToAddress("0x00000000000000000000000000000000000000bb")
+	client := &rangeLimitedClient{max: 1000, logs: []types.Log{
+		transferLog(10, 0, other, testWallet, 1),
+		transferLog(60_000, 0, testWallet, other, 1),
+		transferLog(70_000, 0, testWallet, testWallet, 1),
+		transferLog(80_000, 0, other, other, 1),
+	}}
+	logs, err := transferLogs(context.Background(), client, nil, testWallet, 0, 100_000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(logs) != 3 {
+		t.Fatalf("%d logs, want 3 with the self-transfer once", len(logs))
+	}
+}
+
+func TestPagedLogs(t *testing.T) {
+	other := common.HexToAddress("0x00000000000000000000000000000000000000bb")
+	client := &rangeLimitedClient{max: 1000, logs: []types.Log{
+		transferLog(17_600_010, 0, other, testWallet, 1),
+		transferLog(17_700_000, 0, other, testWallet, 1),
+		transferLog(17_800_000, 0, testWallet, other, 1),
+	}}
+	q := ethereum.FilterQuery{Topics: [][]common.Hash{{transferTopic}, nil, {common.BytesToHash(testWallet.Bytes())}}}
+	logs, err := pagedLogs(context.Background(), client, q, sablierFromBlock, 17_850_000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(logs) != 2 {
+		t.Fatalf("%d logs, want the 2 transfers to the wallet", len(logs))
+	}
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Validator balances come from a standard beacon node API (BEACON_API_URL).
+// Looking validators up by withdrawal address is not part of that API, so it
+// goes through beaconcha.in instead (BEACONCHAIN_API_URL overrides the host).
+const defaultBeaconchainURL = "https://beaconcha.in"
+
+const (
+	// beaconchainPage is the most validators beaconcha.in lists at once.
+	beaconchainPage = 200
+	// beaconIDsPerQuery keeps a big operator's validator lookups within
+	// what nodes accept in a URL.
+	beaconIDsPerQuery = 100
+	// secondsPerSlot is mainnet's slot time.
+	secondsPerSlot = 12
+)
+
+// beaconValidator is a validator as the beacon node API describes it.
+// Balances are in gwei.
+type beaconValidator struct {
+	Balance   string `json:"balance"`
+	Status    string `json:"status"`
+	Validator struct {
+		EffectiveBalance string `json:"effective_balance"`
+	} `json:"validator"`
+}
+
+// beaconHolding sums the balances of the given validators, plus every
+// validator withdrawing to the given address, into one staked-ETH holding,
+// as of the slot of the block client reads. Rewards are what each balance
+// holds above its effective balance, the part not yet swept to the
+// withdrawal address; slashed, exited and withdrawn validators are counted
+// by status, at whatever balance they still have.
+func beaconHolding(ctx context.Context, client chainClient, indices, withdrawal string) (*holding, error) {
+	var ids []string
+	for _, id := range strings.Split(indices, ",") {
+		if id = strings.TrimSpace(id); id != "" {
+			ids = append(ids, id)
+		}
+	}
+	if withdrawal != "" {
+		more, err := validatorsByWithdrawal(ctx, withdrawal)
+		if err != nil {
+			return nil, err
+		}
+		ids = append(ids, more...)
+	}
+	if len(ids) == 0 {
+		return nil, nil
+	}
+
+	node := strings.TrimRight(os.Getenv("BEACON_API_URL"), "/")
+	if node == "" {
+		return nil, errors.New("please set BEACON_API_URL env var")
+	}
+	at, err := stateTime(ctx, client)
+	if err != nil {
+		return nil, err
+	}
+	state, err := beaconSlot(ctx, node, at)
+	if err != nil {
+		return nil, err
+	}
+	var validators []beaconValidator
+	for start := 0; start < len(ids); start += beaconIDsPerQuery {
+		var resp struct {
+			Data []beaconValidator `json:"data"`
+		}
+		url := node + "/eth/v1/beacon/states/" + state + "/validators?id=" + strings.Join(ids[start:min(start+beaconIDsPerQuery, len(ids))], ",")
+		if err := getJSON(ctx, url, &resp); err != nil {
+			return nil, err
+		}
+		validators = append(validators, resp.Data...)
+	}
+
+	total, rewards := new(big.Int), new(big.Int)
+	var active, pending, exited int
+	for _, v := range validators {
+		bal, ok := new(big.Int).SetString(v.Balance, 10)
+		if !ok {
+			return nil, fmt.Errorf("bad validator balance %q", v.Balance)
+		}
+		effective, ok := new(big.Int).SetString(v.Validator.EffectiveBalance, 10)
+		if !ok {
+			return nil, fmt.Errorf("bad validator effective balance %q", v.Validator.EffectiveBalance)
+		}
+		total.Add(total, bal)
+		if excess := new(big.Int).Sub(bal, effective); excess.Sign() > 0 {
+			rewards.Add(rewards, excess)
+		}
+		switch {
+		case strings.HasPrefix(v.Status, "active"):
+			active++
+		case strings.HasPrefix(v.Status, "pending"):
+			pending++
+		default: // exited_* and withdrawal_*
+			exited++
+		}
+	}
+	return &holding{
+		tf:  defaultTokens[0],
+		amt: tokenAmount(total, 9),
+		id:  "beacon",
+		note: fmt.Sprintf("staked, %d validators (%d active, %d pending, %d exited), %s ETH rewards",
+			len(validators), active, pending, exited, tokenAmount(rewards, 9).Text('f', 6)),
+	}, nil
+}
+
+// beaconSlot is the beacon state to read for a block mined at t: the slot
+// that block went into.
+func beaconSlot(ctx context.Context, node string, t time.Time) (string, error) {
+	var resp struct {
+		Data struct {
+			GenesisTime string `json:"genesis_time"`
+		} `json:"data"`
+	}
+	if err := getJSON(ctx, node+"/eth/v1/beacon/genesis", &resp); err != nil {
+		return "", fmt.Errorf("beacon genesis: %w", err)
+	}
+	genesis, err := strconv.ParseInt(resp.Data.GenesisTime, 10, 64)
+	if err != nil {
+		return "", fmt.Errorf("bad beacon genesis time %q", resp.Data.GenesisTime)
+	}
+	if t.Unix() < genesis {
+		return "", fmt.Errorf("%s is before the beacon chain", t.UTC().Format(time.RFC3339))
+	}
+	return strconv.FormatInt((t.Unix()-genesis)/secondsPerSlot, 10), nil
+}
+
+// validatorsByWithdrawal returns the indices of validators whose withdrawal
+// credentials point at addr, a page at a time.
+func validatorsByWithdrawal(ctx context.Context, addr string) ([]string, error) {
+	host := os.Getenv("BEACONCHAIN_API_URL")
+	if host == "" {
+		host = defaultBeaconchainURL
+	}
+	var ids []string
+	for offset := 0; ; offset += beaconchainPage {
+		var resp struct {
+			Data []struct {
+				ValidatorIndex uint64 `json:"validatorindex"`
+			} `json:"data"`
+		}
+		url := fmt.Sprintf("%s/api/v1/validator/withdrawalCredentials/%s?limit=%d&offset=%d", strings.TrimRight(host, "/"), addr, beaconchainPage, offset)
+		if err := getJSON(ctx, url, &resp); err != nil {
+			return nil, fmt.Errorf("withdrawal lookup: %w", err)
+		}
+		for _, v := range resp.Data {
+			ids = append(ids, fmt.Sprint(v.ValidatorIndex))
+		}
+		if len(resp.Data) < beaconchainPage {
+			return ids, nil
+		}
+	}
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/core"
+)
+
+func TestBeaconHolding(t *testing.T) {
+	sim := newSim(t, core.GenesisAlloc{})
+	ctx := context.Background()
+	head, err := sim.HeaderByNumber(ctx, big.NewInt(0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	genesis := int64(head.Time) - 120*secondsPerSlot
+
+	// 201 validators withdraw to the address: a full page and one more. The
+	// last is exited and withdrawn; one active one earned 0.5 ETH.
+	var pages []string
+	beaconchain := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		pages = append(pages, r.URL.Query().Get("offset"))
+		var data []map[string]int
+		from := map[string]int{"0": 0, "200": 200}[r.URL.Query().Get("offset")]
+		for i := from; i < min(from+beaconchainPage, 201); i++ {
+			data = append(data, map[string]int{"validatorindex": i})
+		}
+		json.NewEncoder(w).Encode(map[string]any{"data": data})
+	}))
+	defer beaconchain.Close()
+	var states []string
+	node := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/eth/v1/beacon/genesis" {
+			fmt.Fprintf(w, `{"data": {"genesis_time": "%d"}}`, genesis)
+			return
+		}
+		states = append(states, r.URL.Path)
+		var data []beaconValidator
+		for _, id := range strings.Split(r.URL.Query().Get("id"), ",") {
+			v := beaconValidator{Balance: "32000000000", Status: "active_ongoing"}
+			v.Validator.EffectiveBalance = "32000000000"
+			switch id {
+			case "7":
+				v.Balance = "32500000000"
+			case "200":
+				v.Balance, v.Status, v.Validator.EffectiveBalance = "0", "withdrawal_done", "0"
+			}
+			data = append(data, v)
+		}
+		json.NewEncoder(w).Encode(map[string]any{"data": data})
+	}))
+	defer node.Close()
+	t.Setenv("BEACON_API_URL", node.URL)
+	t.Setenv("BEACONCHAIN_API_URL", beaconchain.URL)
+
+	h, err := beaconHolding(ctx, pinnedClient{sim, big.NewInt(0)}, "", "0xabc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := 200*32 + 0.5; !floatEq(h.amt, want) {
+		t.Errorf("staked %v ETH, want %v", h.amt, want)
+	}
+	if want := "staked, 201 validators (200 active, 0 pending, 1 exited), 0.500000 ETH rewards"; h.note != want {
+		t.Errorf("note %q, want %q", h.note, want)
+	}
+	if len(pages) != 2 || pages[1] != "200" {
+		t.Errorf("beaconcha.in pages at offsets %v, want 0 and 200", pages)
+	}
+	if len(states) != 3 || states[0] != "/eth/v1/beacon/states/120/validators" {
+		t.Errorf("validator queries %v, want 3 at slot 120", states)
+	}
+}
@@ -0,0 +1,191 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/rpc"
+
+	"Test2/bindings"
+)
+
+// benchBatchSize is how many eth_getBalance calls go into one batched
+// request, about what a wallet's registry balance scan would send.
+const benchBatchSize = 20
+
+// benchPattern is one kind of request the tool sends; run makes one request
+// and returns how many RPC calls it carried.
+type benchPattern struct {
+	name string
+	run  func(ctx context.Context, client *ethclient.Client) (int, error)
+}
+
+var benchPatterns = []benchPattern{
+	{"eth_call", func(ctx context.Context, client *ethclient.Client) (int, error) {
+		// A raw call, so chains without the token answer just as quickly.
+		token := defaultTokens[1].TokenAddr
+		erc20, err := bindings.ERC20MetaData.GetAbi()
+		if err != nil {
+			return 0, err
+		}
+		bz, err := erc20.Pack("balanceOf", common.Address{})
+		if err != nil {
+			return 0, err
+		}
+		_, err = client.CallContract(ctx, ethereum.CallMsg{To: &token, Data: bz}, nil)
+		return 1, err
+	}},
+	{"eth_getBalance", func(ctx context.Context, client *ethclient.Client) (int, error) {
+		_, err := client.BalanceAt(ctx, common.Address{}, nil)
+		return 1, err
+	}},
+	{"batch", func(ctx context.Context, client *ethclient.Client) (int, error) {
+		batch := make([]rpc.BatchElem, benchBatchSize)
+		for i := range batch {
+			var result string
+			batch[i] = rpc.BatchElem{
+				Method: "eth_getBalance",
+				Args:   []any{common.Address{}, "latest"},
+				Result: &result,
+			}
+		}
+		if err := client.Client().BatchCallContext(ctx, batch); err != nil {
+			return 0, err
+		}
+		for _, b := range batch {
+			if b.Error != nil {
+				return 0, b.Error
+			}
+		}
+		return len(batch), nil
+	}},
+}
+
+// benchCmd times the tool's call patterns against each endpoint: latency
+// from sequential requests, throughput from the same number of requests
+// sent concurrently.
+func benchCmd(args []string) {
+	fs := flag.NewFlagSet("bench", flag.ExitOnError)
+	n := fs.Int("n", 20, "requests per pattern and endpoint")
+	workers := fs.Int("c", 8, "concurrent requests for the throughput run")
+	fs.String("config", "config.json", "path to the JSON config file")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s bench [flags] [rpc-url...]\n(default: ETH_RPC_URL and every configured chain endpoint)\n", os.Args[0])
+		fs.PrintDefaults()
+	}
+	cfg, err := parseSettings(fs, args)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	urls := fs.Args()
+	if len(urls) == 0 {
+		urls = configuredRPCs(cfg)
+	}
+	if len(urls) == 0 {
+		log.Fatal("bench: no endpoints; pass URLs or set ETH_RPC_URL")
+	}
+
+	ctx := context.Background()
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "ENDPOINT\tPATTERN\tMIN\tP50\tP95\tCALLS/S\tERRORS")
+	for _, url := range urls {
+		client, err := ethclient.Dial(url)
+		if err != nil {
+			fmt.Fprintf(tw, "%s\t\t\t\t\t\t%v\n", url, err)
+			continue
+		}
+		for _, p := range benchPatterns {
+			r := benchRun(ctx, client, p, *n, *workers)
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%.1f\t%d\n", url, p.name,
+				r.pct(0), r.pct(50), r.pct(95), r.throughput, r.errors)
+		}
+		client.Close()
+	}
+	tw.Flush()
+}
+
+// configuredRPCs lists the distinct endpoints the environment configures.
+func configuredRPCs(cfg *config) []string {
+	var urls []string
+	seen := map[string]bool{}
+	add := func(url string) {
+		if url != "" && !seen[url] {
+			seen[url] = true
+			urls = append(urls, url)
+		}
+	}
+	add(os.Getenv("ETH_RPC_URL"))
+	for _, c := range chains {
+		add(chainRPC(c, cfg))
+	}
+	return urls
+}
+
+type benchResult struct {
+	latencies  []time.Duration // successful sequential requests, sorted
+	throughput float64         // RPC calls per second in the concurrent run
+	errors     int             // failed requests across both runs
+}
+
+func (r benchResult) pct(p int) string {
+	if len(r.latencies) == 0 {
+		return "-"
+	}
+	i := (len(r.latencies) - 1) * p / 100
+	return r.latencies[i].Round(100 * time.Microsecond).String()
+}
+
+func benchRun(ctx context.Context, client *ethclient.Client, p benchPattern, n, workers int) benchResult {
+	var r benchResult
+	for i := 0; i < n; i++ {
+		start := time.Now()
+		if _, err := p.run(ctx, client); err != nil {
+			r.errors++
+			continue
+		}
+		r.latencies = append(r.latencies, time.Since(start))
+	}
+	sort.Slice(r.latencies, func(i, j int) bool { return r.latencies[i] < r.latencies[j] })
+
+	var (
+		mu    sync.Mutex
+		calls int
+		wg    sync.WaitGroup
+	)
+	jobs := make(chan struct{}, n)
+	for i := 0; i < n; i++ {
+		jobs <- struct{}{}
+	}
+	close(jobs)
+	start := time.Now()
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range jobs {
+				k, err := p.run(ctx, client)
+				mu.Lock()
+				if err != nil {
+					r.errors++
+				} else {
+					calls += k
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	r.throughput = float64(calls) / time.Since(start).Seconds()
+	return r
+}
@@ -0,0 +1,7 @@
+// Package bindings holds typed Go bindings for the contracts the tool calls.
+// Each binding is generated from the ABI file next to it; to bind another
+// contract, add its ABI and a go:generate line below.
+package bindings
+
+//go:generate go run ../internal/abigen -type ERC20 -abi erc20.json -out erc20.go
+//go:generate go run ../internal/abigen -type Aggregator -abi aggregator.json -out aggregator.go
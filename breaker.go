@@ -0,0 +1,125 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"Test2/source"
+)
+
+// After breakerFailures failures in a row a source is degraded: it is not
+// called again for breakerCooldown, and lookups that need it fail at once
+// with source.ErrDegraded. The first call after the cool-down tries it again; one
+// more failure degrades it anew, a success restores it. Sources are keyed
+// by what fails together: an API by its name, a lookup on a chain by the
+// source and the token or integration.
+const (
+	breakerFailures = 3
+	breakerCooldown = 5 * time.Minute
+)
+
+// circuits are the process's breakers, shared by every valuation so that
+// serve, daemon and watch remember failures across refreshes.
+var circuits = newBreaker()
+
+type breaker struct {
+	mu     sync.Mutex
+	now    func() time.Time
+	states map[string]*circuit
+}
+
+type circuit struct {
+	failures int
+	until    time.Time // degraded until then
+	trips    uint64
+	last     error
+}
+
+func newBreaker() *breaker {
+	return &breaker{now: time.Now, states: map[string]*circuit{}}
+}
+
+// guard calls f through the breaker of key. Failures while ctx is done are
+// the caller giving up, not the source failing, and an ErrUnsupported
+// answer is neither a failure nor a success.
+func guard[T any](ctx context.Context, b *breaker, key string, f func() (T, error)) (T, error) {
+	var zero T
+	if err := b.allow(key); err != nil {
+		return zero, err
+	}
+	v, err := f()
+	switch {
+	case err == nil:
+		b.record(key, nil)
+	case ctx.Err() != nil, errors.Is(err, source.ErrUnsupported):
+	default:
+		b.record(key, err)
+	}
+	return v, err
+}
+
+func (b *breaker) allow(key string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	c := b.states[key]
+	if c == nil || !b.now().Before(c.until) {
+		return nil
+	}
+	return fmt.Errorf("%w after %d failures, retried after %s (last: %v)", source.ErrDegraded, c.failures, c.until.Local().Format(time.TimeOnly), c.last)
+}
+
+func (b *breaker) record(key string, err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	c := b.states[key]
+	if err == nil {
+		if c != nil {
+			c.failures, c.until = 0, time.Time{}
+		}
+		return
+	}
+	if c == nil {
+		c = &circuit{}
+		b.states[key] = c
+	}
+	c.failures++
+	c.last = err
+	if c.failures >= breakerFailures {
+		c.until = b.now().Add(breakerCooldown)
+		c.trips++
+		log.Printf("warning: %s failed %d times in a row (%v); skipping it for %s", key, c.failures, err, breakerCooldown)
+	}
+}
+
+// degraded lists the sources currently skipped, by key.
+func (b *breaker) degraded() []reportDegraded {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	var out []reportDegraded
+	now := b.now()
+	for key, c := range b.states {
+		if now.Before(c.until) {
+			out = append(out, reportDegraded{Source: key, Failures: c.failures, Until: c.until.UTC(), Error: c.last.Error()})
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Source < out[j].Source })
+	return out
+}
+
+// trips counts each source's degradations since the process started.
+func (b *breaker) trips() map[string]uint64 {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	out := map[string]uint64{}
+	for key, c := range b.states {
+		if c.trips > 0 {
+			out[key] = c.trips
+		}
+	}
+	return out
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"Test2/source"
+)
+
+func TestBreaker(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	b := newBreaker()
+	b.now = func() time.Time { return now }
+	ctx := context.Background()
+	calls := 0
+	call := func(err error) error {
+		_, got := guard(ctx, b, "api", func() (int, error) { calls++; return 0, err })
+		return got
+	}
+	down := errors.New("503 Service Unavailable")
+
+	// Misses and give-ups do not count.
+	call(fmt.Errorf("X: %w", source.ErrUnsupported))
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	guard(cancelled, b, "api", func() (int, error) { return 0, context.Canceled })
+	for i := 0; i < breakerFailures-1; i++ {
+		call(down)
+	}
+	if len(b.degraded()) != 0 {
+		t.Fatalf("degraded after %d failures", breakerFailures-1)
+	}
+	call(nil)
+	for i := 0; i < breakerFailures; i++ {
+		call(down)
+	}
+	before := calls
+	if err := call(nil); !errors.Is(err, source.ErrDegraded) || calls != before {
+		t.Fatalf("degraded source was called: %v", err)
+	}
+	if d := b.degraded(); len(d) != 1 || d[0].Source != "api" || d[0].Failures != breakerFailures || d[0].Error != down.Error() {
+		t.Errorf("degraded = %+v", d)
+	}
+
+	// After the cool-down one failure degrades it again; a success ends it.
+	now = now.Add(breakerCooldown)
+	if err := call(down); errors.Is(err, source.ErrDegraded) {
+		t.Fatal("not retried after the cool-down")
+	}
+	if err := call(nil); !errors.Is(err, source.ErrDegraded) {
+		t.Fatalf("not degraded again: %v", err)
+	}
+	now = now.Add(breakerCooldown)
+	if err := call(nil); err != nil {
+		t.Fatal(err)
+	}
+	if len(b.degraded()) != 0 || b.trips()["api"] != 2 {
+		t.Errorf("after recovery: %+v, trips %v", b.degraded(), b.trips())
+	}
+}
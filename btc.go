@@ -0,0 +1,26 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math/big"
+)
+
+// btcPrice prices one bitcoin in USD on the first chain that lists WBTC,
+// mainnet's BTC/USD Chainlink feed when it is among them. Overrides and
+// fallbacks apply as to any holding, so -price WBTC=... sets it too.
+func btcPrice(ctx context.Context, conns []*chainConn, cfg *config, opts options) (*big.Float, error) {
+	q, err := priceQuote(ctx, conns, cfg, opts, "WBTC")
+	if err != nil {
+		return nil, fmt.Errorf("BTC/USD: %w", err)
+	}
+	return q.usd, nil
+}
+
+// printTotalBTC repeats the total in bitcoin under the USD one.
+func printTotalBTC(w io.Writer, r *report) {
+	if r.BTCUSD > 0 {
+		fmt.Fprintf(w, "TOTAL %12s => ₿%.8f (BTC/USD $%.2f)\n", "", r.TotalBTC, r.BTCUSD)
+	}
+}
@@ -0,0 +1,28 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"Test2/source"
+)
+
+func TestPrintTotalBTC(t *testing.T) {
+	var b strings.Builder
+	printTotalBTC(&b, &report{TotalUSD: 1000})
+	if b.Len() != 0 {
+		t.Errorf("printed without a BTC price: %q", b.String())
+	}
+	printTotalBTC(&b, &report{TotalUSD: 1000, TotalBTC: 0.0125, BTCUSD: 80000})
+	if !strings.Contains(b.String(), "₿0.01250000 (BTC/USD $80000.00)") {
+		t.Errorf("got %q", b.String())
+	}
+}
+
+func TestBTCPriceNoChain(t *testing.T) {
+	if _, err := btcPrice(context.Background(), nil, &config{}, options{}); !errors.Is(err, source.ErrNoPriceSource) {
+		t.Errorf("err = %v, want source.ErrNoPriceSource", err)
+	}
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/core"
+
+	"Test2/source"
+)
+
+// A run out of budget gives up on the lookups in flight instead of waiting
+// for them, and does not hold that against the sources.
+func TestBudgetStopsSlowLookups(t *testing.T) {
+	link, _ := tokenBySymbol("LINK")
+	sim := newSim(t, core.GenesisAlloc{})
+	release := make(chan struct{})
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	}))
+	defer slow.Close()
+	defer close(release)
+	defer func(u string) { coingeckoURL = u }(coingeckoURL)
+	coingeckoURL = slow.URL
+	coingeckoPlatforms[simChainID] = "sim"
+	defer delete(coingeckoPlatforms, simChainID)
+
+	cfg := &config{PriceSources: priceSourcesConfig{Order: []string{"coingecko"}}}
+	for i := 0; i < breakerFailures; i++ {
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		h := &holding{tf: link, amt: big.NewFloat(1)}
+		start := time.Now()
+		newPricer(ctx, sim, simChainID, true, cfg, options{}).priceAll([]*holding{h})
+		cancel()
+		if took := time.Since(start); took > 5*time.Second {
+			t.Fatalf("priceAll took %s past its budget", took)
+		}
+		if !errors.Is(h.err, source.ErrNoPriceSource) || !errors.Is(h.err, context.DeadlineExceeded) {
+			t.Fatalf("error %v, want no price for the deadline", h.err)
+		}
+	}
+	for _, d := range circuits.degraded() {
+		if strings.Contains(d.Source, "coingecko") {
+			t.Errorf("%s degraded by lookups the budget cut short", d.Source)
+		}
+	}
+}
@@ -0,0 +1,184 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// cacheStats counts how serve's report cache has been used: requests
+// answered from it, valuations it made, and reports it dropped, either
+// expired or removed by unregistering or clearing.
+type cacheStats struct {
+	Hits      uint64 `json:"hits"`
+	Misses    uint64 `json:"misses"`
+	Evictions uint64 `json:"evictions"`
+}
+
+// cacheStatus is what GET /api/cache answers for the caller's tenant.
+type cacheStatus struct {
+	cacheStats
+	Refresh float64      `json:"refresh_seconds"`
+	Entries []cacheEntry `json:"entries"`
+}
+
+type cacheEntry struct {
+	Wallet   common.Address `json:"wallet"`
+	ValuedAt time.Time      `json:"valued_at"`
+	Age      float64        `json:"age_seconds"`
+	Stale    bool           `json:"stale,omitempty"`
+}
+
+// evict drops the tenant's report of a wallet, if it has one.
+func (s *server) evict(t *tenant, wallet common.Address) {
+	s.valuing.Lock()
+	defer s.valuing.Unlock()
+	if _, ok := t.reports[wallet]; ok {
+		delete(t.reports, wallet)
+		t.cache.Evictions++
+	}
+}
+
+// cacheStatus reads the tenant's cache, oldest entry first.
+func (s *server) cacheStatus(t *tenant) cacheStatus {
+	s.valuing.Lock()
+	defer s.valuing.Unlock()
+	now := time.Now()
+	st := cacheStatus{cacheStats: t.cache, Refresh: s.refresh.Seconds(), Entries: []cacheEntry{}}
+	for wallet, rep := range t.reports {
+		age := now.Sub(rep.Time)
+		st.Entries = append(st.Entries, cacheEntry{Wallet: wallet, ValuedAt: rep.Time, Age: age.Seconds(), Stale: age >= s.refresh})
+	}
+	sort.Slice(st.Entries, func(i, j int) bool { return st.Entries[i].ValuedAt.Before(st.Entries[j].ValuedAt) })
+	return st
+}
+
+func (s *server) handleCache(w http.ResponseWriter, r *http.Request, t *tenant) {
+	writeJSON(w, http.StatusOK, s.cacheStatus(t))
+}
+
+func (s *server) handleCacheClear(w http.ResponseWriter, r *http.Request, t *tenant) {
+	s.valuing.Lock()
+	n := len(t.reports)
+	t.cache.Evictions += uint64(n)
+	t.reports = map[common.Address]*report{}
+	s.valuing.Unlock()
+	writeJSON(w, http.StatusOK, map[string]int{"cleared": n})
+}
+
+// handleMetrics writes the tenant's cache statistics in the Prometheus text
+// format.
+func (s *server) handleMetrics(w http.ResponseWriter, r *http.Request, t *tenant) {
+	st := s.cacheStatus(t)
+	var oldest float64
+	if len(st.Entries) > 0 {
+		oldest = st.Entries[0].Age
+	}
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	for _, m := range []struct {
+		name, kind, help string
+		value            float64
+	}{
+		{"portfolio_cache_hits_total", "counter", "Report requests answered from the cache.", float64(st.Hits)},
+		{"portfolio_cache_misses_total", "counter", "Report requests that valued the wallet.", float64(st.Misses)},
+		{"portfolio_cache_evictions_total", "counter", "Cached reports dropped as expired, unregistered or cleared.", float64(st.Evictions)},
+		{"portfolio_cache_entries", "gauge", "Reports in the cache.", float64(len(st.Entries))},
+		{"portfolio_cache_oldest_entry_age_seconds", "gauge", "Age of the oldest cached report.", oldest},
+	} {
+		fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n%s{tenant=%q} %g\n", m.name, m.help, m.name, m.kind, m.name, t.name, m.value)
+	}
+
+	// Sources are shared by every tenant.
+	fmt.Fprint(w, "# HELP portfolio_source_degraded Sources skipped after repeated failures.\n# TYPE portfolio_source_degraded gauge\n")
+	for _, d := range circuits.degraded() {
+		fmt.Fprintf(w, "portfolio_source_degraded{source=%q} 1\n", d.Source)
+	}
+	fmt.Fprint(w, "# HELP portfolio_source_trips_total Times each source was degraded.\n# TYPE portfolio_source_trips_total counter\n")
+	trips := circuits.trips()
+	keys := make([]string, 0, len(trips))
+	for key := range trips {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		fmt.Fprintf(w, "portfolio_source_trips_total{source=%q} %d\n", key, trips[key])
+	}
+}
+
+// cacheCmd inspects or empties the report cache of a running serve.
+func cacheCmd(args []string) {
+	fs := flag.NewFlagSet("cache", flag.ExitOnError)
+	fs.String("config", "config.json", "path to the JSON config file")
+	addr := fs.String("server", "http://127.0.0.1:8080", "URL of the running serve")
+	key := fs.String("api-key", "", "API key to authenticate with, as PORTFOLIO_API_KEY")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s cache [flags] status|clear\n", os.Args[0])
+		fs.PrintDefaults()
+	}
+	if _, err := parseSettings(fs, args); err != nil {
+		log.Fatal(err)
+	}
+	method := map[string]string{"status": http.MethodGet, "clear": http.MethodDelete}[fs.Arg(0)]
+	if method == "" || fs.NArg() != 1 {
+		fs.Usage()
+		os.Exit(2)
+	}
+	req, err := http.NewRequest(method, strings.TrimSuffix(*addr, "/")+"/api/cache", nil)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if *key != "" {
+		req.Header.Set("Authorization", "Bearer "+*key)
+	}
+	var body json.RawMessage
+	if err := exchangeDo(req, &body); err != nil {
+		log.Fatalf("cache: %v", err)
+	}
+	if method == http.MethodDelete {
+		var cleared struct{ Cleared int }
+		if err := json.Unmarshal(body, &cleared); err != nil {
+			log.Fatalf("cache: %v", err)
+		}
+		fmt.Printf("cleared %d cached reports\n", cleared.Cleared)
+		return
+	}
+	var st cacheStatus
+	if err := json.Unmarshal(body, &st); err != nil {
+		log.Fatalf("cache: %v", err)
+	}
+	printCacheStatus(os.Stdout, st)
+}
+
+func printCacheStatus(w io.Writer, st cacheStatus) {
+	ratio := 0.0
+	if n := st.Hits + st.Misses; n > 0 {
+		ratio = 100 * float64(st.Hits) / float64(n)
+	}
+	fmt.Fprintf(w, "%d hits, %d misses (%.0f%% hit rate), %d evictions; reports are reused for %s\n",
+		st.Hits, st.Misses, ratio, st.Evictions, time.Duration(st.Refresh*float64(time.Second)))
+	if len(st.Entries) == 0 {
+		fmt.Fprintln(w, "no cached reports")
+		return
+	}
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "WALLET\tVALUED\tAGE\t")
+	for _, e := range st.Entries {
+		state := ""
+		if e.Stale {
+			state = "stale"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", walletName(e.Wallet), e.ValuedAt.Local().Format(time.DateTime),
+			time.Duration(e.Age*float64(time.Second)).Round(time.Second), state)
+	}
+	tw.Flush()
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+func TestServerCacheStats(t *testing.T) {
+	wallet := common.HexToAddress("0x00000000000000000000000000000000000000aa")
+	s := &server{
+		open:    &tenant{name: "default", fixed: true, wallets: []common.Address{wallet}, reports: map[common.Address]*report{}},
+		refresh: time.Hour,
+		value:   func(w common.Address) *report { return newReport(w.Hex(), nil) },
+	}
+	srv := httptest.NewServer(s.handler())
+	defer srv.Close()
+	do := func(method, path string) string {
+		t.Helper()
+		req, _ := http.NewRequest(method, srv.URL+path, nil)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		if resp.StatusCode != 200 {
+			t.Fatalf("%s %s: %d %s", method, path, resp.StatusCode, body)
+		}
+		return string(body)
+	}
+	status := func() cacheStatus {
+		var st cacheStatus
+		if err := json.Unmarshal([]byte(do("GET", "/api/cache")), &st); err != nil {
+			t.Fatal(err)
+		}
+		return st
+	}
+
+	for i := 0; i < 3; i++ {
+		do("GET", "/api/wallets/"+wallet.Hex()+"/report")
+	}
+	st := status()
+	if st.Hits != 2 || st.Misses != 1 || len(st.Entries) != 1 || st.Entries[0].Wallet != wallet || st.Entries[0].Stale {
+		t.Errorf("after three reports: %+v", st)
+	}
+
+	// An expired report is evicted by the next request for it.
+	s.open.reports[wallet].Time = time.Now().Add(-2 * time.Hour)
+	if st := status(); !st.Entries[0].Stale {
+		t.Errorf("expired entry not stale: %+v", st.Entries[0])
+	}
+	do("GET", "/api/wallets/"+wallet.Hex()+"/report")
+	if st := status(); st.Misses != 2 || st.Evictions != 1 {
+		t.Errorf("after expiry: %+v", st)
+	}
+
+	metrics := do("GET", "/api/metrics")
+	for _, want := range []string{
+		`portfolio_cache_hits_total{tenant="default"} 2`,
+		`portfolio_cache_evictions_total{tenant="default"} 1`,
+		`# TYPE portfolio_cache_entries gauge`,
+	} {
+		if !strings.Contains(metrics, want) {
+			t.Errorf("metrics lack %q:\n%s", want, metrics)
+		}
+	}
+
+	if got := do("DELETE", "/api/cache"); !strings.Contains(got, `"cleared":1`) {
+		t.Errorf("clear: %s", got)
+	}
+	if st := status(); len(st.Entries) != 0 || st.Evictions != 2 {
+		t.Errorf("after clear: %+v", st)
+	}
+	var b strings.Builder
+	printCacheStatus(&b, status())
+	if !strings.Contains(b.String(), "2 hits, 2 misses (50% hit rate), 2 evictions") {
+		t.Errorf("status text: %s", b.String())
+	}
+}
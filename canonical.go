@@ -0,0 +1,70 @@
+package main
+
+import (
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// canonicalAsset names the economic asset a token contract represents and,
+// when it differs, what the representation is called on its chain.
+type canonicalAsset struct {
+	Symbol  string
+	Variant string
+}
+
+// bridgedTokens maps each chain's representations of the common bridged
+// assets to their canonical symbol, so natively issued and bridged USDC,
+// and every flavour of WETH, read the same everywhere.
+var bridgedTokens = map[uint64]map[common.Address]canonicalAsset{
+	1: {
+		common.HexToAddress("0xC02aaA39b223FE8D0A0E5C4F27eAD9083C756Cc2"): {"ETH", "WETH"},
+	},
+	10: {
+		common.HexToAddress("0x0b2C639c533813f4Aa9D7837CAf62653d097Ff85"): {"USDC", ""},
+		common.HexToAddress("0x7F5c764cBc14f9669B88837ca1490cCa17c31607"): {"USDC", "USDC.e"},
+		common.HexToAddress("0x94b008aA00579c1307B0EF2c499aD98a8ce58e58"): {"USDT", ""},
+		common.HexToAddress("0xDA10009cBd5D07dd0CeCc66161FC93D7c9000da1"): {"DAI", ""},
+		common.HexToAddress("0x68f180fcCe6836688e9084f035309E29Bf0A2095"): {"WBTC", ""},
+		common.HexToAddress("0x4200000000000000000000000000000000000006"): {"ETH", "WETH"},
+	},
+	137: {
+		common.HexToAddress("0x3c499c542cEF5E3811e1192ce70d8cC03d5c3359"): {"USDC", ""},
+		common.HexToAddress("0x2791Bca1f2de4661ED88A30C99A7a9449Aa84174"): {"USDC", "USDC.e"},
+		common.HexToAddress("0xc2132D05D31c914a87C6611C10748AEb04B58e8F"): {"USDT", ""},
+		common.HexToAddress("0x8f3Cf7ad23Cd3CaDbD9735AFf958023239c6A063"): {"DAI", ""},
+		common.HexToAddress("0x1BFD67037B42Cf73acF2047067bd4F2C47D9BfD6"): {"WBTC", ""},
+		common.HexToAddress("0x7ceB23fD6bC0adD59E62ac25578270cFf1b9f619"): {"ETH", "WETH"},
+	},
+	8453: {
+		common.HexToAddress("0x833589fCD6eDb6E08f4c7C32D4f71b54bdA02913"): {"USDC", ""},
+		common.HexToAddress("0xd9aAEc86B65D86f6A7B5B1b0c42FFA531710b6CA"): {"USDC", "USDbC"},
+		common.HexToAddress("0x4200000000000000000000000000000000000006"): {"ETH", "WETH"},
+	},
+	42161: {
+		common.HexToAddress("0xaf88d065e77c8cC2239327C5EDb3A432268e5831"): {"USDC", ""},
+		common.HexToAddress("0xFF970A61A04b1cA14834A43f5dE4533eBDDB5CC8"): {"USDC", "USDC.e"},
+		common.HexToAddress("0xFd086bC7CD5C481DCC9C85ebE478A1C0b69FCbb9"): {"USDT", ""},
+		common.HexToAddress("0xDA10009cBd5D07dd0CeCc66161FC93D7c9000da1"): {"DAI", ""},
+		common.HexToAddress("0x2f2a2543B76A4166549F7aaB2e75Bef0aefC5B0f"): {"WBTC", ""},
+		common.HexToAddress("0x82aF49447D8a07e3bd95BD0d56f35241523fBab1"): {"ETH", "WETH"},
+	},
+}
+
+// canonicalize relabels holdings of bridged representations with their
+// canonical symbol, keeping the local name in the note.
+func canonicalize(chainID uint64, held []*holding) {
+	for _, h := range held {
+		asset, ok := bridgedTokens[chainID][h.tf.TokenAddr]
+		if !ok {
+			continue
+		}
+		h.tf.Symbol = asset.Symbol
+		if asset.Variant == "" {
+			continue
+		}
+		if h.note == "" {
+			h.note = asset.Variant
+		} else {
+			h.note = asset.Variant + ", " + h.note
+		}
+	}
+}
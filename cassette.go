@@ -0,0 +1,244 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// rpcCassette, when set, records or replays every JSON-RPC exchange of the
+// run (-record / -replay).
+var rpcCassette *cassette
+
+// cassette is a VCR-style log of JSON-RPC exchanges, one JSON object per
+// line. Endpoints are numbered in the order they are dialled rather than
+// named, so a cassette carries no provider URLs or API keys and replays
+// with whatever URLs (or none) the replaying user has.
+//
+// Replay matches requests by endpoint, method and params; request IDs are
+// ignored and rewritten into the answers. Identical requests are answered
+// in recorded order. Only node traffic is covered: DefiLlama and other
+// HTTP APIs are still called live.
+type cassette struct {
+	mu        sync.Mutex
+	endpoints int
+
+	w *os.File // recording
+
+	tape map[string][]cassetteEntry // replaying
+}
+
+// replayURL stands in for endpoints that are not configured when
+// replaying; it is never contacted.
+const replayURL = "http://replay.invalid"
+
+type cassetteEntry struct {
+	Endpoint int             `json:"endpoint"`
+	Request  json.RawMessage `json:"request"`
+	Response json.RawMessage `json:"response"`
+}
+
+func recordCassette(path string) (*cassette, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	return &cassette{w: f}, nil
+}
+
+func replayCassette(path string) (*cassette, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	c := &cassette{tape: map[string][]cassetteEntry{}}
+	sc := bufio.NewScanner(f)
+	sc.Buffer(nil, 64<<20)
+	for line := 1; sc.Scan(); line++ {
+		var e cassetteEntry
+		if err := json.Unmarshal(sc.Bytes(), &e); err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", path, line, err)
+		}
+		key, err := e.key()
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", path, line, err)
+		}
+		c.tape[key] = append(c.tape[key], e)
+	}
+	return c, sc.Err()
+}
+
+func (c *cassette) replaying() bool { return c != nil && c.tape != nil }
+
+// Close finishes a recording. It is a no-op on a nil cassette.
+func (c *cassette) Close() error {
+	if c == nil || c.w == nil {
+		return nil
+	}
+	return c.w.Close()
+}
+
+// dialRPC connects to an endpoint, through the cassette when one is set.
+// HTTP requests wait for one of the -concurrency slots.
+func dialRPC(ctx context.Context, url string) (*ethclient.Client, error) {
+	if !strings.HasPrefix(url, "http") {
+		if rpcCassette != nil {
+			return nil, fmt.Errorf("cassettes only work over HTTP, not %s", url)
+		}
+		return ethclient.DialContext(ctx, url)
+	}
+	base := http.DefaultTransport
+	if rpcCassette != nil {
+		base = rpcCassette.transport()
+	}
+	hc := &http.Client{Transport: limitedTransport{base}}
+	client, err := rpc.DialOptions(ctx, url, rpc.WithHTTPClient(hc))
+	if err != nil {
+		return nil, err
+	}
+	return ethclient.NewClient(client), nil
+}
+
+// transport returns the round tripper for the next dialled endpoint.
+func (c *cassette) transport() http.RoundTripper {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.endpoints++
+	return &cassetteTransport{c: c, endpoint: c.endpoints}
+}
+
+type cassetteTransport struct {
+	c        *cassette
+	endpoint int
+}
+
+func (t *cassetteTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	body, err := io.ReadAll(req.Body)
+	req.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	e := cassetteEntry{Endpoint: t.endpoint, Request: body}
+
+	if t.c.replaying() {
+		resp, err := t.c.replay(e)
+		if err != nil {
+			return nil, err
+		}
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Header:     http.Header{"Content-Type": {"application/json"}},
+			Body:       io.NopCloser(bytes.NewReader(resp)),
+			Request:    req,
+		}, nil
+	}
+
+	req.Body = io.NopCloser(bytes.NewReader(body))
+	resp, err := http.DefaultTransport.RoundTrip(req)
+	if err != nil || resp.StatusCode != http.StatusOK {
+		return resp, err
+	}
+	if e.Response, err = io.ReadAll(resp.Body); err != nil {
+		return nil, err
+	}
+	resp.Body.Close()
+	resp.Body = io.NopCloser(bytes.NewReader(e.Response))
+	return resp, t.c.record(e)
+}
+
+func (c *cassette) record(e cassetteEntry) error {
+	line, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	_, err = c.w.Write(append(line, '\n'))
+	return err
+}
+
+// replay finds the next recorded answer to e's request and gives it the
+// request's own IDs.
+func (c *cassette) replay(e cassetteEntry) ([]byte, error) {
+	key, err := e.key()
+	if err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	recs := c.tape[key]
+	if len(recs) == 0 {
+		return nil, fmt.Errorf("cassette: no recorded answer for %s on endpoint %d", e.Request, e.Endpoint)
+	}
+	rec := recs[0]
+	c.tape[key] = recs[1:]
+	return rewriteIDs(rec.Request, e.Request, rec.Response)
+}
+
+// key identifies a request regardless of its JSON-RPC IDs.
+func (e cassetteEntry) key() (string, error) {
+	msgs, _, err := rpcMessages(e.Request)
+	if err != nil {
+		return "", err
+	}
+	for _, m := range msgs {
+		delete(m, "id")
+	}
+	b, err := json.Marshal(msgs)
+	return fmt.Sprintf("%d %s", e.Endpoint, b), err
+}
+
+// rpcMessages decodes a single JSON-RPC message or a batch.
+func rpcMessages(data []byte) (msgs []map[string]json.RawMessage, batch bool, err error) {
+	if data = bytes.TrimSpace(data); len(data) > 0 && data[0] == '[' {
+		err = json.Unmarshal(data, &msgs)
+		return msgs, true, err
+	}
+	var m map[string]json.RawMessage
+	err = json.Unmarshal(data, &m)
+	return []map[string]json.RawMessage{m}, false, err
+}
+
+// rewriteIDs maps the IDs of a recorded response from the recorded request
+// onto the new request's. Batch answers may come in any order, so IDs are
+// matched by value, not position.
+func rewriteIDs(recordedReq, req, resp []byte) ([]byte, error) {
+	oldReqs, _, err := rpcMessages(recordedReq)
+	if err != nil {
+		return nil, err
+	}
+	newReqs, _, err := rpcMessages(req)
+	if err != nil {
+		return nil, err
+	}
+	ids := map[string]json.RawMessage{}
+	for i := range oldReqs {
+		if i < len(newReqs) {
+			ids[string(oldReqs[i]["id"])] = newReqs[i]["id"]
+		}
+	}
+	msgs, batch, err := rpcMessages(resp)
+	if err != nil {
+		return nil, err
+	}
+	for _, m := range msgs {
+		if id, ok := ids[string(m["id"])]; ok {
+			m["id"] = id
+		}
+	}
+	if batch {
+		return json.Marshal(msgs)
+	}
+	return json.Marshal(msgs[0])
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// fakeNode answers eth_chainId and eth_getBalance, counting requests.
+func fakeNode(t *testing.T, hits *int) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		*hits++
+		var req struct {
+			ID     json.RawMessage `json:"id"`
+			Method string          `json:"method"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("bad request: %v", err)
+		}
+		result := map[string]string{"eth_chainId": "0x1", "eth_getBalance": "0x64"}[req.Method]
+		json.NewEncoder(w).Encode(map[string]any{"jsonrpc": "2.0", "id": req.ID, "result": result})
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestCassetteRecordReplay(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "run.cassette")
+	ctx := context.Background()
+	var hits int
+	srv := fakeNode(t, &hits)
+	t.Cleanup(func() { rpcCassette = nil })
+
+	var err error
+	if rpcCassette, err = recordCassette(path); err != nil {
+		t.Fatal(err)
+	}
+	client, err := dialRPC(ctx, srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bal, err := client.BalanceAt(ctx, testWallet, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.ChainID(ctx); err != nil {
+		t.Fatal(err)
+	}
+	client.Close()
+	rpcCassette.Close()
+	recorded := hits
+
+	if rpcCassette, err = replayCassette(path); err != nil {
+		t.Fatal(err)
+	}
+	client, err = dialRPC(ctx, replayURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	// Replayed out of recorded order and with fresh request IDs.
+	id, err := client.ChainID(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id.Int64() != 1 {
+		t.Errorf("chain ID = %s, want 1", id)
+	}
+	got, err := client.BalanceAt(ctx, testWallet, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Cmp(bal) != 0 {
+		t.Errorf("replayed balance = %s, want %s", got, bal)
+	}
+	if hits != recorded {
+		t.Errorf("replay reached the node %d times", hits-recorded)
+	}
+
+	// Each recorded answer is used once, and other requests are not made up.
+	if _, err := client.BalanceAt(ctx, testWallet, nil); err == nil || !strings.Contains(err.Error(), "no recorded answer") {
+		t.Errorf("second replay of one recording: err = %v", err)
+	}
+	if _, err := client.BalanceAt(ctx, common.HexToAddress("0xB0B"), nil); err == nil {
+		t.Error("unrecorded request: want error")
+	}
+}
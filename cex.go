@@ -0,0 +1,268 @@
+package main
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// exchangeConfig is a centralized exchange account whose balances join the
+// reports, under the venue's name as the chain. The API key only needs read
+// access; Key and Secret default to $<VENUE>_API_KEY and $<VENUE>_API_SECRET.
+// Label names the account in the notes, for several accounts on one venue.
+// URL replaces the venue's API base, for testnets.
+type exchangeConfig struct {
+	Venue  string `json:"venue"` // binance, coinbase or kraken
+	Key    string `json:"key"`
+	Secret string `json:"secret"`
+	Label  string `json:"label"`
+	Wallet string `json:"wallet"` // as offChainAsset.Wallet
+	URL    string `json:"url"`
+}
+
+// exchangeVenues reads an account's balances. Amounts are whole units by
+// the venue's own asset code, which is mapped to a registry symbol where
+// the two differ.
+var exchangeVenues = map[string]struct {
+	name    string
+	baseURL string
+	fetch   func(ctx context.Context, base, key, secret string) (map[string]float64, error)
+}{
+	"binance":  {"Binance", "https://api.binance.com", binanceBalances},
+	"coinbase": {"Coinbase", "https://api.coinbase.com", coinbaseBalances},
+	"kraken":   {"Kraken", "https://api.kraken.com", krakenBalances},
+}
+
+// fiatUSD is priced at 1 without looking it up.
+const fiatUSD = "USD"
+
+// exchangeBalances returns an account's non-zero balances as off-chain
+// entries, in symbol order.
+func exchangeBalances(ctx context.Context, x exchangeConfig) ([]offChainAsset, error) {
+	venue, ok := exchangeVenues[strings.ToLower(x.Venue)]
+	if !ok {
+		return nil, fmt.Errorf("unknown exchange %q (want binance, coinbase or kraken)", x.Venue)
+	}
+	env := strings.ToUpper(x.Venue)
+	key, secret := x.Key, x.Secret
+	if key == "" {
+		key = os.Getenv(env + "_API_KEY")
+	}
+	if secret == "" {
+		secret = os.Getenv(env + "_API_SECRET")
+	}
+	if key == "" || secret == "" {
+		return nil, fmt.Errorf("%s: no API key; set %s_API_KEY and %s_API_SECRET", venue.name, env, env)
+	}
+	base := venue.baseURL
+	if x.URL != "" {
+		base = strings.TrimSuffix(x.URL, "/")
+	}
+	balances, err := venue.fetch(ctx, base, key, secret)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", venue.name, err)
+	}
+
+	note := venue.name
+	if x.Label != "" {
+		note += " " + x.Label
+	}
+	var out []offChainAsset
+	for sym, amt := range balances {
+		if amt <= 0 {
+			continue
+		}
+		a := offChainAsset{Symbol: sym, Amount: amt, Note: note, chain: strings.ToLower(x.Venue)}
+		if sym == fiatUSD {
+			a.Price = 1
+		}
+		out = append(out, a)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Symbol < out[j].Symbol })
+	return out, nil
+}
+
+// exchangeDo sends a signed request and decodes its JSON answer. Exchanges
+// explain refusals in the body, so a failed request reports it.
+func exchangeDo(req *http.Request, v any) error {
+	return exchangeDoWith(&http.Client{Timeout: 30 * time.Second}, req, v)
+}
+
+func exchangeDoWith(client *http.Client, req *http.Request, v any) error {
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("%s %s: %s: %s", req.Method, req.URL.Path, resp.Status, strings.TrimSpace(string(body)))
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+func hmacHex(key []byte, msg string) string {
+	m := hmac.New(sha256.New, key)
+	m.Write([]byte(msg))
+	return hex.EncodeToString(m.Sum(nil))
+}
+
+// binanceBalances reads the spot account, free plus locked.
+func binanceBalances(ctx context.Context, base, key, secret string) (map[string]float64, error) {
+	q := url.Values{"omitZeroBalances": {"true"}, "timestamp": {strconv.FormatInt(time.Now().UnixMilli(), 10)}}.Encode()
+	q += "&signature=" + hmacHex([]byte(secret), q)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, base+"/api/v3/account?"+q, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("X-MBX-APIKEY", key)
+	var resp struct {
+		Balances []struct {
+			Asset  string `json:"asset"`
+			Free   string `json:"free"`
+			Locked string `json:"locked"`
+		} `json:"balances"`
+	}
+	if err := exchangeDo(req, &resp); err != nil {
+		return nil, err
+	}
+	out := map[string]float64{}
+	for _, b := range resp.Balances {
+		free, err1 := strconv.ParseFloat(b.Free, 64)
+		locked, err2 := strconv.ParseFloat(b.Locked, 64)
+		if err1 != nil || err2 != nil {
+			return nil, fmt.Errorf("bad %s balance %q/%q", b.Asset, b.Free, b.Locked)
+		}
+		out[b.Asset] += free + locked
+	}
+	return out, nil
+}
+
+// coinbaseBalances reads the Advanced Trade accounts, available plus on
+// hold, with a legacy HMAC API key.
+func coinbaseBalances(ctx context.Context, base, key, secret string) (map[string]float64, error) {
+	const path = "/api/v3/brokerage/accounts"
+	out := map[string]float64{}
+	cursor := ""
+	for {
+		q := url.Values{"limit": {"250"}}
+		if cursor != "" {
+			q.Set("cursor", cursor)
+		}
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, base+path+"?"+q.Encode(), nil)
+		if err != nil {
+			return nil, err
+		}
+		ts := strconv.FormatInt(time.Now().Unix(), 10)
+		req.Header.Set("CB-ACCESS-KEY", key)
+		req.Header.Set("CB-ACCESS-TIMESTAMP", ts)
+		req.Header.Set("CB-ACCESS-SIGN", hmacHex([]byte(secret), ts+http.MethodGet+path))
+		type amount struct {
+			Value string `json:"value"`
+		}
+		var resp struct {
+			Accounts []struct {
+				Currency  string `json:"currency"`
+				Available amount `json:"available_balance"`
+				Hold      amount `json:"hold"`
+			} `json:"accounts"`
+			HasNext bool   `json:"has_next"`
+			Cursor  string `json:"cursor"`
+		}
+		if err := exchangeDo(req, &resp); err != nil {
+			return nil, err
+		}
+		for _, a := range resp.Accounts {
+			for _, v := range []string{a.Available.Value, a.Hold.Value} {
+				if v == "" {
+					continue
+				}
+				f, err := strconv.ParseFloat(v, 64)
+				if err != nil {
+					return nil, fmt.Errorf("bad %s balance %q", a.Currency, v)
+				}
+				out[a.Currency] += f
+			}
+		}
+		if !resp.HasNext || resp.Cursor == "" {
+			return out, nil
+		}
+		cursor = resp.Cursor
+	}
+}
+
+// krakenBalances reads the account balance, staked and earning assets
+// included under their base symbol.
+func krakenBalances(ctx context.Context, base, key, secret string) (map[string]float64, error) {
+	const path = "/0/private/Balance"
+	secretKey, err := base64.StdEncoding.DecodeString(secret)
+	if err != nil {
+		return nil, fmt.Errorf("API secret is not base64: %w", err)
+	}
+	nonce := strconv.FormatInt(time.Now().UnixMilli(), 10)
+	body := url.Values{"nonce": {nonce}}.Encode()
+	digest := sha256.Sum256([]byte(nonce + body))
+	m := hmac.New(sha512.New, secretKey)
+	m.Write(append([]byte(path), digest[:]...))
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, base+path, strings.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("API-Key", key)
+	req.Header.Set("API-Sign", base64.StdEncoding.EncodeToString(m.Sum(nil)))
+	var resp struct {
+		Error  []string          `json:"error"`
+		Result map[string]string `json:"result"`
+	}
+	if err := exchangeDo(req, &resp); err != nil {
+		return nil, err
+	}
+	if len(resp.Error) > 0 {
+		return nil, fmt.Errorf("%s", strings.Join(resp.Error, "; "))
+	}
+	out := map[string]float64{}
+	for asset, v := range resp.Result {
+		f, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return nil, fmt.Errorf("bad %s balance %q", asset, v)
+		}
+		out[krakenSymbol(asset)] += f
+	}
+	return out, nil
+}
+
+// krakenLegacy are Kraken's X/Z-prefixed codes for its oldest assets.
+var krakenLegacy = map[string]string{
+	"XXBT": "BTC", "XBT": "BTC", "XXDG": "DOGE", "XDG": "DOGE",
+	"XETH": "ETH", "XETC": "ETC", "XLTC": "LTC", "XXRP": "XRP", "XXLM": "XLM",
+	"XXMR": "XMR", "XZEC": "ZEC", "XREP": "REP", "XMLN": "MLN",
+	"ZUSD": "USD", "ZEUR": "EUR", "ZGBP": "GBP", "ZCAD": "CAD", "ZJPY": "JPY",
+	"ETH2": "ETH",
+}
+
+// krakenSymbol maps a Kraken asset code to its usual symbol, dropping the
+// .S/.M/.B/.F suffixes of staked and earning balances.
+func krakenSymbol(asset string) string {
+	if i := strings.IndexByte(asset, '.'); i > 0 {
+		asset = asset[:i]
+	}
+	if sym, ok := krakenLegacy[asset]; ok {
+		return sym
+	}
+	return asset
+}
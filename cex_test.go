@@ -0,0 +1,122 @@
+package main
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+func TestExchangeBalances(t *testing.T) {
+	krakenSecret := base64.StdEncoding.EncodeToString([]byte("kraken secret"))
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/v3/account":
+			q := r.URL.Query()
+			sig := q.Get("signature")
+			q.Del("signature")
+			signed := strings.TrimSuffix(r.URL.RawQuery, "&signature="+sig)
+			if r.Header.Get("X-MBX-APIKEY") != "bkey" || sig != hmacHex([]byte("bsecret"), signed) {
+				http.Error(w, `{"code":-1022,"msg":"Signature for this request is not valid."}`, http.StatusUnauthorized)
+				return
+			}
+			fmt.Fprint(w, `{"balances":[{"asset":"BTC","free":"0.25","locked":"0.05"},{"asset":"USDT","free":"100","locked":"0"},{"asset":"DUST","free":"0","locked":"0"}]}`)
+		case "/api/v3/brokerage/accounts":
+			ts := r.Header.Get("CB-ACCESS-TIMESTAMP")
+			if r.Header.Get("CB-ACCESS-SIGN") != hmacHex([]byte("csecret"), ts+"GET"+r.URL.Path) {
+				http.Error(w, "unauthorized", http.StatusUnauthorized)
+				return
+			}
+			if r.URL.Query().Get("cursor") == "" {
+				fmt.Fprint(w, `{"accounts":[{"currency":"ETH","available_balance":{"value":"1.5"},"hold":{"value":"0.5"}}],"has_next":true,"cursor":"p2"}`)
+				return
+			}
+			fmt.Fprint(w, `{"accounts":[{"currency":"USD","available_balance":{"value":"250"},"hold":{"value":"0"}}],"has_next":false}`)
+		case "/0/private/Balance":
+			body, _ := io.ReadAll(r.Body)
+			form, _ := url.ParseQuery(string(body))
+			digest := sha256.Sum256([]byte(form.Get("nonce") + string(body)))
+			secret, _ := base64.StdEncoding.DecodeString(krakenSecret)
+			m := hmac.New(sha512.New, secret)
+			m.Write(append([]byte(r.URL.Path), digest[:]...))
+			if r.Header.Get("API-Sign") != base64.StdEncoding.EncodeToString(m.Sum(nil)) {
+				fmt.Fprint(w, `{"error":["EAPI:Invalid signature"]}`)
+				return
+			}
+			fmt.Fprint(w, `{"error":[],"result":{"XXBT":"0.1","XETH":"1","ETH2.S":"2","ZUSD":"10"}}`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	for _, tc := range []struct {
+		x    exchangeConfig
+		want string
+	}{
+		{exchangeConfig{Venue: "binance", Key: "bkey", Secret: "bsecret"}, "BTC=0.3 USDT=100"},
+		{exchangeConfig{Venue: "coinbase", Key: "ckey", Secret: "csecret"}, "ETH=2 USD=250"},
+		{exchangeConfig{Venue: "kraken", Key: "kkey", Secret: krakenSecret}, "BTC=0.1 ETH=3 USD=10"},
+	} {
+		tc.x.URL = srv.URL
+		got, err := exchangeBalances(context.Background(), tc.x)
+		if err != nil {
+			t.Errorf("%s: %v", tc.x.Venue, err)
+			continue
+		}
+		var s []string
+		for _, a := range got {
+			s = append(s, fmt.Sprintf("%s=%g", a.Symbol, a.Amount))
+			if a.chain != tc.x.Venue {
+				t.Errorf("%s: %s reported on %q", tc.x.Venue, a.Symbol, a.chain)
+			}
+		}
+		if strings.Join(s, " ") != tc.want {
+			t.Errorf("%s: got %s, want %s", tc.x.Venue, strings.Join(s, " "), tc.want)
+		}
+	}
+
+	bad := exchangeConfig{Venue: "binance", Key: "bkey", Secret: "wrong", URL: srv.URL}
+	if _, err := exchangeBalances(context.Background(), bad); err == nil || !strings.Contains(err.Error(), "Signature") {
+		t.Errorf("bad signature: %v", err)
+	}
+	t.Setenv("KRAKEN_API_KEY", "")
+	if _, err := exchangeBalances(context.Background(), exchangeConfig{Venue: "kraken"}); err == nil || !strings.Contains(err.Error(), "KRAKEN_API_KEY") {
+		t.Errorf("missing key: %v", err)
+	}
+
+	// Balances join the report like manual entries; a failing venue is a
+	// failed holding named after it.
+	cfg := &config{Exchanges: []exchangeConfig{
+		{Venue: "coinbase", Key: "ckey", Secret: "csecret", URL: srv.URL},
+		{Venue: "kraken", Key: "kkey", Secret: "bm90IHRoaXM=", URL: srv.URL},
+	}}
+	held := (&chainConn{id: 1}).offChainHoldings(context.Background(), common.Address{}, cfg, options{Prices: priceOverrides{"ETH": 2000}})
+	if len(held) != 3 {
+		t.Fatalf("got %d holdings, want ETH, USD and the kraken failure", len(held))
+	}
+	total := 0.0
+	for _, h := range held[:2] {
+		usd, _ := h.usd().Float64()
+		total += usd
+		if h.note != "Coinbase" {
+			t.Errorf("%s note %q", h.tf.Symbol, h.note)
+		}
+	}
+	if total != 4250 {
+		t.Errorf("coinbase worth $%v, want 4250", total)
+	}
+	if held[2].tf.Symbol != "kraken" || held[2].err == nil {
+		t.Errorf("kraken failure: %+v", held[2])
+	}
+}
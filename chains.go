@@ -0,0 +1,327 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/ethclient"
+
+	"Test2/source"
+)
+
+// chainProfile is a network the tool knows how to value wallets on, and
+// the native coin its gas is paid in, priced by the chain's own USD feed
+// of it. NativeToken is set on chains where the native coin also has an
+// ERC-20 face, a contract whose balances are the native balances, such as
+// Polygon's; that contract is valued as the native coin and never counted
+// a second time.
+type chainProfile struct {
+	Name        string
+	ID          uint64
+	Native      tokenFeed
+	NativeToken common.Address
+	// Multicall is the chain's Multicall3 deployment, which registry
+	// balances are read through when set.
+	Multicall common.Address
+}
+
+var chains = []chainProfile{
+	{Name: "mainnet", ID: 1, Native: defaultTokens[0]},
+	{Name: "optimism", ID: 10, Native: tokenFeed{Symbol: "ETH", FeedAddr: common.HexToAddress("0x13e3Ee699D1909E989722E753853AE30b17e08c5"), Decimals: 18}},
+	{Name: "base", ID: 8453, Native: tokenFeed{Symbol: "ETH", FeedAddr: common.HexToAddress("0x71041dddad3595F9CEd3DcCFBe3D1F4b0a16Bb70"), Decimals: 18}},
+	{Name: "arbitrum", ID: 42161, Native: tokenFeed{Symbol: "ETH", FeedAddr: common.HexToAddress("0x639Fe6ab55C921f74e7fac1ee960C0B6293ba612"), Decimals: 18}},
+	{
+		Name: "polygon", ID: 137,
+		Native:      tokenFeed{Symbol: "POL", FeedAddr: common.HexToAddress("0xAB594600376Ec9fD91F8e885dADF0CE036862dE0"), Decimals: 18},
+		NativeToken: common.HexToAddress("0x0000000000000000000000000000000000001010"),
+	},
+	{Name: "bsc", ID: 56, Native: tokenFeed{Symbol: "BNB", FeedAddr: common.HexToAddress("0x0567F2323251f0Aab15c8dFb1967E4e8A7D42aeE"), Decimals: 18}},
+	{Name: "avalanche", ID: 43114, Native: tokenFeed{Symbol: "AVAX", FeedAddr: common.HexToAddress("0x0A77230d17318075983913bC2145DB16C7366156"), Decimals: 18}},
+}
+
+// customRegistries and fixedPrices hold what config-defined chains declare:
+// their token tables, native coin first, and the tokens priced at a fixed
+// USD value.
+var (
+	customRegistries = map[uint64][]tokenFeed{}
+	fixedPrices      = map[uint64]map[common.Address]float64{}
+)
+
+// addChain makes a config-defined chain known to every command.
+func addChain(c chainConfig) error {
+	switch {
+	case c.Name == "" || c.ChainID == 0:
+		return errors.New("name and chain_id are required")
+	case c.Native.Symbol == "":
+		return errors.New("native.symbol is required")
+	}
+	if _, ok := chainByName(c.Name); ok {
+		return errors.New("name already in use")
+	}
+	if name := chainName(c.ChainID); !strings.HasPrefix(name, "chain-") {
+		return fmt.Errorf("chain ID %d is already %s", c.ChainID, name)
+	}
+	native := c.Native
+	native.Address = common.Address{}
+	prices := map[common.Address]float64{}
+	var tokens []tokenFeed
+	for _, t := range append([]customToken{native}, c.Tokens...) {
+		if t.Decimals == 0 {
+			t.Decimals = 18
+		}
+		if face := c.Native.Address; face != (common.Address{}) && t.Address == face {
+			continue // the native coin's ERC-20 face, counted as the coin
+		}
+		tokens = append(tokens, tokenFeed{Symbol: t.Symbol, TokenAddr: t.Address, FeedAddr: t.Feed, Decimals: t.Decimals})
+		if t.Price > 0 {
+			prices[t.Address] = t.Price
+		}
+	}
+	chains = append(chains, chainProfile{Name: c.Name, ID: c.ChainID, Native: tokens[0], NativeToken: c.Native.Address, Multicall: c.Multicall})
+	customRegistries[c.ChainID] = tokens
+	fixedPrices[c.ChainID] = prices
+	if c.LlamaChain != "" {
+		llamaChains[c.ChainID] = c.LlamaChain
+	}
+	return nil
+}
+
+// chainByID is the profile of a known chain.
+func chainByID(id uint64) (chainProfile, bool) {
+	for _, c := range chains {
+		if c.ID == id {
+			return c, true
+		}
+	}
+	return chainProfile{}, false
+}
+
+func chainByName(name string) (chainProfile, bool) {
+	for _, c := range chains {
+		if c.Name == name {
+			return c, true
+		}
+	}
+	return chainProfile{}, false
+}
+
+func chainName(id uint64) string {
+	for _, c := range chains {
+		if c.ID == id {
+			return c.Name
+		}
+	}
+	return fmt.Sprintf("chain-%d", id)
+}
+
+func chainNames() string {
+	var names []string
+	for _, c := range chains {
+		names = append(names, c.Name)
+	}
+	return strings.Join(names, ", ")
+}
+
+// chainRPC returns the HTTP endpoint for a chain: RPC_URL_<NAME>, then the
+// config file's rpc.<name>.http, then ETH_RPC_URL for mainnet.
+func chainRPC(c chainProfile, cfg *config) string {
+	if url := os.Getenv("RPC_URL_" + strings.ToUpper(c.Name)); url != "" {
+		return url
+	}
+	if url := cfg.RPC[c.Name].HTTP; url != "" {
+		return url
+	}
+	if c.ID == 1 {
+		return os.Getenv("ETH_RPC_URL")
+	}
+	return ""
+}
+
+// chainWS returns the websocket endpoint for a chain's subscriptions:
+// WS_URL_<NAME>, then the config file's rpc.<name>.ws.
+func chainWS(c chainProfile, cfg *config) string {
+	if url := os.Getenv("WS_URL_" + strings.ToUpper(c.Name)); url != "" {
+		return url
+	}
+	return cfg.RPC[c.Name].WS
+}
+
+// chainConn is an open connection to one chain, reused for every wallet
+// valued in a run. dev is set when the endpoint is a local anvil or hardhat
+// node; id is then the chain it forks. ws is the chain's websocket endpoint
+// for subscriptions, if one is configured. block, when set, is the block
+// lookups read, mined at blockTime; historical when it is a past block
+// whose prices are wanted too, see atBlock.
+type chainConn struct {
+	client     *ethclient.Client
+	id         uint64
+	trustFeeds bool
+	dev        string
+	ws         string
+	block      *big.Int
+	blockHash  common.Hash
+	blockTime  time.Time
+	historical bool
+	audit      *auditTrail
+}
+
+// caller is the client lookups go through, pinned to c.block when set and
+// recorded into c.audit under -audit.
+func (c *chainConn) caller() chainClient {
+	if c.block == nil {
+		return c.client
+	}
+	p := pinnedClient{c.client, c.block}
+	if c.audit != nil {
+		return auditClient{p, chainName(c.id), c.audit}
+	}
+	return p
+}
+
+// pricer prices at the connection's block, with the prices of its time
+// when it is a historical one.
+func (c *chainConn) pricer(ctx context.Context, cfg *config, opts options) *pricer {
+	p := newPricer(ctx, c.caller(), c.id, c.trustFeeds, cfg, opts)
+	if c.historical {
+		p.at = c.blockTime
+	}
+	return p
+}
+
+// connectChain dials rpc, identifies the chain and checks whether its
+// Chainlink answers can be trusted right now.
+func connectChain(ctx context.Context, rpc string) (*chainConn, error) {
+	client, err := dialRPC(ctx, rpc)
+	if err != nil {
+		return nil, fmt.Errorf("%w: dial: %w", source.ErrRPCUnavailable, err)
+	}
+	chainID, err := client.ChainID(ctx)
+	if err != nil {
+		client.Close()
+		return nil, fmt.Errorf("%w: chain ID: %w", source.ErrRPCUnavailable, err)
+	}
+	c := &chainConn{client: client, id: chainID.Uint64(), dev: devNode(ctx, client.Client())}
+	if c.dev == "hardhat" && c.id == hardhatChainID {
+		if forked := forkedChainID(ctx, client.Client()); forked != 0 {
+			c.id = forked
+		}
+	}
+	if c.dev != "" {
+		log.Printf("note: %s is a local %s node; DefiLlama prices are live, not fork-time", chainName(c.id), c.dev)
+	}
+	c.trustFeeds, err = checkSequencer(ctx, client, c.id)
+	if err != nil {
+		log.Printf("warning: %v", err)
+	}
+	if !c.trustFeeds {
+		log.Printf("warning: ignoring Chainlink answers on %s until the sequencer is stable", chainName(c.id))
+	}
+	return c, nil
+}
+
+// openChains connects to the chain rpc points at (ETH_RPC_URL when empty,
+// mainnet's endpoint when that is unset too), or to each chain of a
+// comma-separated list. Listed chains that
+// cannot be reached are skipped with a warning; a missing endpoint is fatal.
+func openChains(ctx context.Context, rpc, list string, cfg *config) []*chainConn {
+	var conns []*chainConn
+	if list == "" {
+		if rpc == "" {
+			rpc = os.Getenv("ETH_RPC_URL")
+		}
+		if rpc == "" {
+			rpc = chainRPC(chains[0], cfg)
+		}
+		if rpc == "" && rpcCassette.replaying() {
+			rpc = replayURL
+		}
+		if rpc == "" {
+			log.Fatal("Please pass -rpc, set PORTFOLIO_RPC, ETH_RPC_URL or RPC_URL_MAINNET, or rpc.mainnet.http in the config")
+		}
+		c, err := connectChain(ctx, rpc)
+		if err != nil {
+			log.Fatal(err)
+		}
+		p, ok := chainByName(chainName(c.id))
+		if !ok {
+			log.Fatalf("the RPC endpoint is on chain %d, which has no token and feed table; supported: %s", c.id, chainNames())
+		}
+		c.ws = chainWS(p, cfg)
+		return append(conns, c)
+	}
+	for _, name := range strings.Split(list, ",") {
+		c, ok := chainByName(strings.TrimSpace(name))
+		if !ok {
+			log.Fatalf("unknown chain %q", name)
+		}
+		rpc := chainRPC(c, cfg)
+		if rpc == "" && rpcCassette.replaying() {
+			rpc = replayURL
+		}
+		if rpc == "" {
+			log.Fatalf("Please set RPC_URL_%s env var, or rpc.%s.http in the config", strings.ToUpper(c.Name), c.Name)
+		}
+		conn, err := connectChain(ctx, rpc)
+		if err != nil {
+			log.Printf("%s: %v", c.Name, err)
+			continue
+		}
+		if conn.id != c.ID {
+			log.Fatalf("the %s endpoint is on chain %d (%s), not %d", c.Name, conn.id, chainName(conn.id), c.ID)
+		}
+		conn.ws = chainWS(c, cfg)
+		conns = append(conns, conn)
+	}
+	return conns
+}
+
+// valueWallet values a wallet on every connection, as the front-ends that
+// keep revaluing it show it. Each valuation reads every chain at its head
+// block of the moment.
+func valueWallet(ctx context.Context, conns []*chainConn, wallet common.Address, cfg *config, opts options) *report {
+	var held []*holding
+	var pinned []*chainConn
+	for _, c := range conns {
+		if c.block == nil {
+			at := *c
+			if err := at.pin(ctx, nil); err != nil {
+				log.Printf("%v; reading the latest state", err)
+			} else {
+				c = &at
+			}
+		}
+		pinned = append(pinned, c)
+		held = append(held, c.value(ctx, wallet, cfg, opts)...)
+	}
+	if len(conns) > 0 {
+		held = append(held, conns[0].offChainHoldings(ctx, wallet, cfg, opts)...)
+	}
+	rep := newReport(wallet.Hex(), held)
+	rep.Drift = computeDrift(held, cfg.Targets)
+	rep.Blocks = pinnedBlocks(pinned)
+	return rep
+}
+
+// value collects and prices the wallet's holdings on the chain, keeping the
+// symbols opts wants. Every holding is tagged with the chain it was found on.
+func (c *chainConn) value(ctx context.Context, wallet common.Address, cfg *config, opts options) []*holding {
+	runProgress.at(chainName(c.id))
+	held := collectHoldings(ctx, c.caller(), c.id, wallet, cfg, opts)
+	canonicalize(c.id, held)
+	held = opts.filter(c.id, held)
+	held = slices.DeleteFunc(held, func(h *holding) bool { return !cfg.tokenAllowed(h.tf.TokenAddr) })
+	runProgress.set("pricing %d holdings", len(held))
+	c.pricer(ctx, cfg, opts).priceAll(held)
+	for _, h := range held {
+		h.chain = chainName(c.id)
+	}
+	return held
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+
+	"Test2/source"
+)
+
+// coingeckoURL is CoinGecko's public API. COINGECKO_API_KEY, a demo key,
+// raises its rate limit.
+var coingeckoURL = "https://api.coingecko.com/api/v3"
+
+// coingeckoPlatforms are CoinGecko's names of the chains, for token
+// contract lookups, and coingeckoCoins its IDs of their native coins where
+// those are not ETH.
+var (
+	coingeckoPlatforms = map[uint64]string{
+		1:     "ethereum",
+		10:    "optimistic-ethereum",
+		56:    "binance-smart-chain",
+		137:   "polygon-pos",
+		8453:  "base",
+		42161: "arbitrum-one",
+		43114: "avalanche",
+	}
+	coingeckoCoins = map[uint64]string{
+		56:    "binancecoin",
+		137:   "polygon-ecosystem-token",
+		43114: "avalanche-2",
+	}
+)
+
+// coingeckoPrice asks CoinGecko for a token's USD price, by contract or,
+// for the native coin, by its coin ID.
+func coingeckoPrice(ctx context.Context, chainID uint64, tf tokenFeed) (*big.Float, error) {
+	platform, ok := coingeckoPlatforms[chainID]
+	if !ok {
+		return nil, source.ErrUnsupported
+	}
+	id, ok := coingeckoCoins[chainID]
+	if !ok {
+		id = "ethereum"
+	}
+	path := "/simple/price?" + url.Values{"ids": {id}, "vs_currencies": {"usd"}}.Encode()
+	if tf.TokenAddr != (common.Address{}) {
+		id = strings.ToLower(tf.TokenAddr.Hex())
+		path = "/simple/token_price/" + platform + "?" + url.Values{"contract_addresses": {id}, "vs_currencies": {"usd"}}.Encode()
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, coingeckoURL+path, nil)
+	if err != nil {
+		return nil, err
+	}
+	if key := os.Getenv("COINGECKO_API_KEY"); key != "" {
+		req.Header.Set("x-cg-demo-api-key", key)
+	}
+	var resp map[string]struct {
+		USD *float64 `json:"usd"`
+	}
+	if _, err := guard(ctx, circuits, "coingecko", func() (any, error) { return nil, exchangeDo(req, &resp) }); err != nil {
+		return nil, err
+	}
+	price := resp[id].USD
+	if price == nil {
+		return nil, fmt.Errorf("unknown coin %s", id)
+	}
+	return big.NewFloat(*price), nil
+}
@@ -0,0 +1,216 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"text/template"
+)
+
+// subcommands are the words main dispatches on before parsing flags.
+var subcommands = []string{"adapters", "audit", "backfill", "balances", "bench", "cache", "completion", "daemon", "diff", "doctor", "healthcheck", "sanctions", "schema", "serve", "stats", "sweep", "tokens", "update", "version", "whales"}
+
+// completeArg is the hidden first argument the completion scripts call back
+// with: `portfolio __complete <words after the program name...>`, the last
+// word being the one under the cursor (empty for a new word).
+const completeArg = "__complete"
+
+// completionCmd prints the completion script for a shell. The scripts are
+// thin: they hand the command line to completeArg, which knows the flags,
+// the chain names and the token symbols, including those the config file
+// defines.
+func completionCmd(args []string) {
+	fs := flag.NewFlagSet("completion", flag.ExitOnError)
+	name := fs.String("name", filepath.Base(os.Args[0]), "name of the installed binary to complete")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s completion [-name prog] bash|zsh|fish\n", os.Args[0])
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	tmpl, ok := completionScripts[fs.Arg(0)]
+	if fs.NArg() != 1 || !ok {
+		fs.Usage()
+		os.Exit(2)
+	}
+	fn := regexp.MustCompile(`\W`).ReplaceAllString(*name, "_")
+	err := template.Must(template.New(fs.Arg(0)).Parse(tmpl)).Execute(os.Stdout, map[string]string{"Name": *name, "Func": fn})
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+var completionScripts = map[string]string{
+	"bash": `_{{.Func}}() {
+	local IFS=$'\n'
+	COMPREPLY=($({{.Name}} ` + completeArg + ` "${COMP_WORDS[@]:1:COMP_CWORD}" 2>/dev/null))
+}
+complete -o default -F _{{.Func}} {{.Name}}
+`,
+	"zsh": `#compdef {{.Name}}
+_{{.Func}}() {
+	local -a candidates
+	candidates=("${(@f)$({{.Name}} ` + completeArg + ` "${(@)words[2,CURRENT]}" 2>/dev/null)}")
+	if (( ${#candidates[@]} )) && [[ -n ${candidates[1]} ]]; then
+		compadd -- "${candidates[@]}"
+	else
+		_files
+	fi
+}
+compdef _{{.Func}} {{.Name}}
+`,
+	"fish": `function _{{.Func}}
+	set -l words (commandline -opc) (commandline -ct)
+	{{.Name}} ` + completeArg + ` $words[2..-1] 2>/dev/null
+end
+complete -c {{.Name}} -a '(_{{.Func}})'
+`,
+}
+
+// completeCmd prints the candidates for the last of words, one per line.
+// fs holds the main command's flags. Printing nothing lets the shell fall
+// back to file names, which is what the path-valued flags want.
+func completeCmd(fs *flag.FlagSet, words []string) {
+	for _, c := range completions(fs, words) {
+		fmt.Println(c)
+	}
+}
+
+func completions(fs *flag.FlagSet, words []string) []string {
+	if len(words) == 0 {
+		words = []string{""}
+	}
+	cur := words[len(words)-1]
+	prev := ""
+	if len(words) > 1 {
+		prev = words[len(words)-2]
+	}
+	sub := ""
+	if len(words) > 1 && slices.Contains(subcommands, words[0]) {
+		sub = words[0]
+	}
+	if sub == "completion" {
+		return matching(cur, []string{"bash", "zsh", "fish"})
+	}
+
+	cfg := completionConfig(words)
+	if name := strings.TrimLeft(prev, "-"); strings.HasPrefix(prev, "-") {
+		// -chains is also doctor's, which fs does not define.
+		if f := fs.Lookup(name); f != nil && !isBoolFlag(f) || name == "chains" {
+			return matchingList(cur, flagValues(name))
+		}
+	}
+	if strings.HasPrefix(cur, "-") {
+		if sub != "" {
+			return nil
+		}
+		var flags []string
+		fs.VisitAll(func(f *flag.Flag) { flags = append(flags, "-"+f.Name) })
+		return matching(cur, flags)
+	}
+	if sub != "" {
+		return nil
+	}
+	candidates := append(slices.Clone(cfg.Addresses), book.labels()...)
+	if len(words) == 1 {
+		candidates = append(slices.Clone(subcommands), candidates...)
+	}
+	return matching(cur, candidates)
+}
+
+// flagValues lists what a flag takes; nil for free-form and path values.
+func flagValues(name string) []string {
+	switch name {
+	case "chains":
+		var names []string
+		for _, c := range chains {
+			names = append(names, c.Name)
+		}
+		return names
+	case "only", "exclude":
+		return registrySymbols()
+	case "price":
+		var out []string
+		for _, sym := range registrySymbols() {
+			out = append(out, sym+"=")
+		}
+		return out
+	case "format":
+		return []string{"text", "json", "template"}
+	case "tag":
+		var tags []string
+		for _, e := range book {
+			for _, t := range e.Tags {
+				if !slices.Contains(tags, t) {
+					tags = append(tags, t)
+				}
+			}
+		}
+		return tags
+	}
+	return nil
+}
+
+// registrySymbols are the symbols of every chain's registry, deduplicated.
+func registrySymbols() []string {
+	var syms []string
+	for _, c := range chains {
+		for _, tf := range registry(c.ID) {
+			if !slices.Contains(syms, tf.Symbol) {
+				syms = append(syms, tf.Symbol)
+			}
+		}
+	}
+	return syms
+}
+
+// completionConfig loads the config the command line would use, so that
+// config-defined chains and addresses complete too. Errors leave the
+// built-in candidates only.
+func completionConfig(words []string) *config {
+	path := "config.json"
+	if v := os.Getenv(settingEnv("config")); v != "" {
+		path = v
+	}
+	if i := slices.Index(words, "-config"); i >= 0 && i+1 < len(words)-1 {
+		path = words[i+1]
+	}
+	cfg, err := loadConfig(path)
+	if err != nil {
+		return &config{}
+	}
+	return cfg
+}
+
+func isBoolFlag(f *flag.Flag) bool {
+	b, ok := f.Value.(interface{ IsBoolFlag() bool })
+	return ok && b.IsBoolFlag()
+}
+
+func matching(cur string, candidates []string) []string {
+	var out []string
+	for _, c := range candidates {
+		if strings.HasPrefix(c, cur) {
+			out = append(out, c)
+		}
+	}
+	return out
+}
+
+// matchingList completes the last element of a comma-separated value,
+// keeping the elements before it.
+func matchingList(cur string, candidates []string) []string {
+	head, last := "", cur
+	if i := strings.LastIndex(cur, ","); i >= 0 {
+		head, last = cur[:i+1], cur[i+1:]
+	}
+	var out []string
+	for _, c := range matching(last, candidates) {
+		out = append(out, head+c)
+	}
+	return out
+}
@@ -0,0 +1,31 @@
+package main
+
+import (
+	"flag"
+	"slices"
+	"testing"
+)
+
+func TestCompletions(t *testing.T) {
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.String("chains", "", "")
+	fs.String("config", "", "")
+	fs.Bool("strict", false, "")
+
+	tests := []struct {
+		words []string
+		want  []string
+	}{
+		{[]string{"-st"}, []string{"-strict"}},
+		{[]string{"-chains", "mainnet,arb"}, []string{"mainnet,arbitrum"}},
+		{[]string{"doctor", "-chains", "ba"}, []string{"base"}},
+		{[]string{"-config", ""}, nil},
+		{[]string{"completion", "z"}, []string{"zsh"}},
+		{[]string{"heal"}, []string{"healthcheck"}},
+	}
+	for _, tt := range tests {
+		if got := completions(fs, tt.words); !slices.Equal(got, tt.want) {
+			t.Errorf("completions(%q) = %q, want %q", tt.words, got, tt.want)
+		}
+	}
+}
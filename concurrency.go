@@ -0,0 +1,110 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+)
+
+// rpcConcurrency is how many node requests may be in flight at once,
+// across every endpoint of the run (-concurrency), and how many registry
+// balances of a chain are read at once. Public endpoints throttle or ban
+// aggressive clients; paid ones answer faster the more are asked at once.
+var rpcConcurrency = 4
+
+// concurrencyFlag registers -concurrency on a command that reads from
+// nodes.
+func concurrencyFlag(fs *flag.FlagSet) {
+	fs.Var(concurrencyValue{}, "concurrency", "allow up to `n` RPC requests in flight at once (default "+strconv.Itoa(rpcConcurrency)+"); lower it for public endpoints, raise it for paid ones")
+}
+
+// concurrencyValue sets rpcConcurrency, which must be positive.
+type concurrencyValue struct{}
+
+func (concurrencyValue) String() string { return strconv.Itoa(rpcConcurrency) }
+
+func (concurrencyValue) Set(s string) error {
+	n, err := strconv.Atoi(s)
+	if err != nil || n < 1 {
+		return errors.New("want a positive number of requests")
+	}
+	rpcConcurrency = n
+	return nil
+}
+
+// rpcSlots holds one token per request in flight over HTTP. It is made
+// on first use, after the flags are parsed, and again if the limit has
+// changed since.
+var (
+	rpcSlotsMu sync.Mutex
+	rpcSlots   chan struct{}
+)
+
+func rpcSlot() chan struct{} {
+	rpcSlotsMu.Lock()
+	defer rpcSlotsMu.Unlock()
+	if n := max(rpcConcurrency, 1); cap(rpcSlots) != n {
+		rpcSlots = make(chan struct{}, n)
+	}
+	return rpcSlots
+}
+
+// limitedTransport waits for a free slot before each request and holds it
+// until the answer is read in full, which the JSON-RPC client does before
+// it returns.
+type limitedTransport struct {
+	base http.RoundTripper
+}
+
+func (t limitedTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	slots := rpcSlot()
+	select {
+	case slots <- struct{}{}:
+	case <-req.Context().Done():
+		return nil, req.Context().Err()
+	}
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		<-slots
+		return nil, err
+	}
+	resp.Body = &slotBody{ReadCloser: resp.Body, release: func() { <-slots }}
+	return resp, nil
+}
+
+// slotBody gives its slot back when the body is closed.
+type slotBody struct {
+	io.ReadCloser
+	once    sync.Once
+	release func()
+}
+
+func (b *slotBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.once.Do(b.release)
+	return err
+}
+
+// forEachLimited calls f for 0..n-1 with at most rpcConcurrency calls
+// running at once, and returns when all have.
+func forEachLimited(n int, f func(i int)) {
+	var wg sync.WaitGroup
+	work := make(chan int)
+	for range min(max(rpcConcurrency, 1), n) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range work {
+				f(i)
+			}
+		}()
+	}
+	for i := range n {
+		work <- i
+	}
+	close(work)
+	wg.Wait()
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// peak tracks the most calls running at once.
+type peak struct {
+	now, most atomic.Int32
+}
+
+func (p *peak) enter() {
+	n := p.now.Add(1)
+	for {
+		m := p.most.Load()
+		if n <= m || p.most.CompareAndSwap(m, n) {
+			return
+		}
+	}
+}
+
+func (p *peak) leave() { p.now.Add(-1) }
+
+func TestConcurrencyLimit(t *testing.T) {
+	defer func(n int) { rpcConcurrency = n }(rpcConcurrency)
+	if err := (concurrencyValue{}).Set("0"); err == nil {
+		t.Error("-concurrency 0 accepted")
+	}
+	if err := (concurrencyValue{}).Set("2"); err != nil || rpcConcurrency != 2 {
+		t.Fatalf("-concurrency 2: %v, limit %d", err, rpcConcurrency)
+	}
+
+	var calls peak
+	forEachLimited(10, func(int) {
+		calls.enter()
+		defer calls.leave()
+		time.Sleep(5 * time.Millisecond)
+	})
+	if calls.most.Load() != 2 {
+		t.Errorf("%d calls at once, want 2", calls.most.Load())
+	}
+
+	var requests peak
+	node := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.enter()
+		defer requests.leave()
+		time.Sleep(5 * time.Millisecond)
+		w.Write([]byte(`{"jsonrpc":"2.0","id":1,"result":"0x1"}`))
+	}))
+	defer node.Close()
+	client := &http.Client{Transport: limitedTransport{http.DefaultTransport}}
+	var wg sync.WaitGroup
+	for range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			resp, err := client.Post(node.URL, "application/json", strings.NewReader(`{}`))
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			io.ReadAll(resp.Body)
+			resp.Body.Close()
+		}()
+	}
+	wg.Wait()
+	if n := requests.most.Load(); n < 1 || n > 2 {
+		t.Errorf("%d requests in flight at once, want at most 2", n)
+	}
+	if n := len(rpcSlot()); n != 0 {
+		t.Errorf("%d slots still taken", n)
+	}
+}
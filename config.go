@@ -0,0 +1,226 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"slices"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// config is the optional JSON config file. A missing file is the same as an
+// empty one.
+type config struct {
+	// Settings gives flags by name ("format": "json", "chains": ["mainnet",
+	// "base"]) for when neither the command line nor a PORTFOLIO_<FLAG>
+	// environment variable sets them.
+	Settings map[string]json.RawMessage `json:"settings"`
+	// Addresses are the wallets valued when none are given on the command
+	// line or in PORTFOLIO_ADDRESSES.
+	Addresses []string `json:"addresses"`
+	// AddressBook is a CSV of address,label,tags used by every command;
+	// see addressBook.
+	AddressBook string          `json:"address_book"`
+	Vesting     []vestingConfig `json:"vesting"`
+	// SuperTokens are Superfluid super tokens to check beside the built-in
+	// ones, by chain name, as their addresses differ from chain to chain.
+	SuperTokens map[string][]common.Address `json:"super_tokens"`
+	// AllowTokens, when set, is the only token contracts reported; DenyTokens
+	// are never reported. Both apply to registry tokens and to whatever the
+	// protocol sources discover, on every chain. Native coins and off-chain
+	// positions have no contract and are not affected.
+	AllowTokens []common.Address `json:"allow_tokens"`
+	DenyTokens  []common.Address `json:"deny_tokens"`
+	// RPC holds endpoints by chain name ("mainnet", "arbitrum", ...).
+	// RPC_URL_<NAME> and WS_URL_<NAME> take precedence.
+	RPC map[string]rpcConfig `json:"rpc"`
+	// Targets are the wanted portfolio weights by symbol, e.g. {"ETH": 60,
+	// "USDC": 40}; they are normalised to their sum. When set, reports show
+	// each asset's drift from its target and the trade that closes it.
+	Targets map[string]float64 `json:"targets"`
+	// PriceFeeds composes a token's USD price from several feeds, for
+	// tokens Chainlink only quotes in ETH or another asset.
+	PriceFeeds []feedPathConfig `json:"price_feeds"`
+	// PriceSources orders the sources prices are looked up in; see
+	// priceSourcesConfig.
+	PriceSources priceSourcesConfig `json:"price_sources"`
+	// Pyth configures the pyth price source; see pythConfig.
+	Pyth pythConfig `json:"pyth"`
+	// Redstone configures the redstone price source; see redstoneConfig.
+	Redstone redstoneConfig `json:"redstone"`
+	// Tellor configures the tellor price source; see tellorConfig.
+	Tellor tellorConfig `json:"tellor"`
+	// Adapters switches protocol adapters on or off and configures
+	// registered ones, by name; see adapterConfig.
+	Adapters map[string]adapterConfig `json:"adapters"`
+	// TokenLists are token list URLs (the Uniswap format) positions take
+	// their logos, CoinGecko IDs and websites from; see tokenMeta.
+	TokenLists []string `json:"token_lists"`
+	// OffChain adds assets no chain shows to the reports; see
+	// offChainAsset.
+	OffChain []offChainAsset `json:"off_chain"`
+	// Exchanges adds read-only exchange accounts' balances to the reports;
+	// see exchangeConfig.
+	Exchanges []exchangeConfig `json:"exchanges"`
+	// Retention thins out stored snapshots after every save, so that
+	// long-running servers and scheduled runs keep a bounded history; see
+	// retentionTier. Unset keeps every snapshot.
+	Retention []retentionTier `json:"retention"`
+	// Sheets is where -export sheets appends rows; see sheetsConfig.
+	Sheets sheetsConfig `json:"sheets"`
+	// MQTT is the broker -export mqtt publishes to; see mqttConfig.
+	MQTT mqttConfig `json:"mqtt"`
+	// Notion is where -export notion upserts rows; see notionConfig.
+	Notion notionConfig `json:"notion"`
+	// Slack is the webhook -export slack and -notify slack post to; see
+	// slackConfig.
+	Slack slackConfig `json:"slack"`
+	// Alerts are the conditions the daemon notifies about; see
+	// alertsConfig.
+	Alerts alertsConfig `json:"alerts"`
+	// PagerDuty is the service -notify pagerduty pages through; see
+	// pagerDutyConfig.
+	PagerDuty pagerDutyConfig `json:"pagerduty"`
+	// Whales are third-party addresses `portfolio whales` watches when
+	// none are given on its command line.
+	Whales []string `json:"whales"`
+	// Tenants makes serve a multi-user service; see tenantConfig.
+	Tenants []tenantConfig `json:"tenants"`
+	// CORS lets dashboards hosted elsewhere call serve's API.
+	CORS corsConfig `json:"cors"`
+	// Chains defines networks beyond the built-in ones, such as private
+	// EVM chains.
+	Chains []chainConfig `json:"chains"`
+}
+
+// feedPathConfig prices Token on Chain (mainnet when empty) at the product
+// of its feeds' answers, e.g. the TOKEN/ETH feed followed by ETH/USD. The
+// native coin is the zero address. An inverted leg contributes 1/answer, for
+// assets only quoted the other way round (a USD/X feed).
+type feedPathConfig struct {
+	Chain string         `json:"chain"`
+	Token common.Address `json:"token"`
+	Feeds []feedLeg      `json:"feeds"`
+}
+
+type feedLeg struct {
+	Feed   common.Address `json:"feed"`
+	Invert bool           `json:"invert"`
+}
+
+// feedPaths returns the configured feed paths of a chain by token.
+func (c *config) feedPaths(chainID uint64) map[common.Address][]feedLeg {
+	paths := map[common.Address][]feedLeg{}
+	for _, fp := range c.PriceFeeds {
+		chain := fp.Chain
+		if chain == "" {
+			chain = "mainnet"
+		}
+		if p, ok := chainByName(chain); ok && p.ID == chainID && len(fp.Feeds) > 0 {
+			paths[fp.Token] = fp.Feeds
+		}
+	}
+	return paths
+}
+
+// chainConfig is a user-defined chain. Tokens are scanned like a registry;
+// native and tokens are priced by their Chainlink-compatible feed, by a
+// fixed price, or through DefiLlama when llama_chain names the chain there.
+type chainConfig struct {
+	Name       string        `json:"name"`
+	ChainID    uint64        `json:"chain_id"`
+	RPC        rpcConfig     `json:"rpc"`
+	Native     customToken   `json:"native"`
+	Tokens     []customToken `json:"tokens"`
+	LlamaChain string        `json:"llama_chain"`
+	// Multicall is the chain's Multicall3 deployment; when set, registry
+	// balances are read through it in batches.
+	Multicall common.Address `json:"multicall"`
+}
+
+type customToken struct {
+	Symbol   string         `json:"symbol"`
+	Address  common.Address `json:"address"` // for the native coin, its ERC-20 face if it has one
+	Decimals int            `json:"decimals"`
+	Feed     common.Address `json:"feed"`
+	Price    float64        `json:"price"`
+}
+
+type rpcConfig struct {
+	HTTP string `json:"http"`
+	WS   string `json:"ws"`
+}
+
+// vestingConfig is an OpenZeppelin-style VestingWallet paying out token.
+type vestingConfig struct {
+	Label    string         `json:"label"`
+	Contract common.Address `json:"contract"`
+	Token    common.Address `json:"token"`
+}
+
+// tokenAllowed applies the allow and deny lists to a token contract.
+func (c *config) tokenAllowed(addr common.Address) bool {
+	if addr == (common.Address{}) {
+		return true
+	}
+	return !slices.Contains(c.DenyTokens, addr) &&
+		(len(c.AllowTokens) == 0 || slices.Contains(c.AllowTokens, addr))
+}
+
+func loadConfig(path string) (*config, error) {
+	cfg := &config{}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return cfg, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, cfg); err != nil {
+		return nil, err
+	}
+	if cfg.AddressBook != "" {
+		if book, err = loadAddressBook(cfg.AddressBook); err != nil {
+			return nil, fmt.Errorf("address book: %w", err)
+		}
+	}
+	tokenLists = cfg.TokenLists
+	if err := checkAdaptersConfig(cfg.Adapters); err != nil {
+		return nil, fmt.Errorf("adapters: %w", err)
+	}
+	if err := cfg.PriceSources.check(); err != nil {
+		return nil, fmt.Errorf("price_sources: %w", err)
+	}
+	if err := cfg.Pyth.parse(); err != nil {
+		return nil, fmt.Errorf("pyth: %w", err)
+	}
+	if err := cfg.Redstone.parse(); err != nil {
+		return nil, fmt.Errorf("redstone: %w", err)
+	}
+	if err := cfg.Tellor.parse(); err != nil {
+		return nil, fmt.Errorf("tellor: %w", err)
+	}
+	if err := parseRetention(cfg.Retention); err != nil {
+		return nil, fmt.Errorf("retention: %w", err)
+	}
+	for _, c := range cfg.Chains {
+		if err := addChain(c); err != nil {
+			return nil, fmt.Errorf("chain %q: %w", c.Name, err)
+		}
+		if _, ok := cfg.RPC[c.Name]; !ok && c.RPC != (rpcConfig{}) {
+			if cfg.RPC == nil {
+				cfg.RPC = map[string]rpcConfig{}
+			}
+			cfg.RPC[c.Name] = c.RPC
+		}
+	}
+	for name := range cfg.SuperTokens {
+		if _, ok := chainByName(name); !ok {
+			return nil, fmt.Errorf("super_tokens: unknown chain %q", name)
+		}
+	}
+	return cfg, nil
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"context"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// convexBooster indexes every Convex pool. Staked Curve LP tokens are held
+// by each pool's BaseRewardPool (crvRewards), so the wallet's balance there is
+// its position; earned() is the pending CRV.
+var (
+	convexBooster = common.HexToAddress("0xF403C135812408BFbE8713b5A23a04b3D48AAE31")
+	cvxToken      = common.HexToAddress("0x4e3FBD56CD56c3e72c1403e103b45Db9da5B9D2B")
+)
+
+// CVX is minted alongside CRV rewards at a rate that drops every cliff of
+// 100k CVX supply, down to zero at the 100M cap.
+var (
+	cvxCliffSize   = new(big.Int).Mul(big.NewInt(100_000), big.NewInt(1e18))
+	cvxTotalCliffs = big.NewInt(1000)
+	cvxMaxSupply   = new(big.Int).Mul(big.NewInt(100_000_000), big.NewInt(1e18))
+)
+
+var convexABI = mustABI(`[
+  {"inputs":[],"name":"poolLength","outputs":[{"type":"uint256"}],"stateMutability":"view","type":"function"},
+  {"inputs":[{"type":"uint256"}],"name":"poolInfo","outputs":[
+     {"name":"lptoken","type":"address"},{"name":"token","type":"address"},{"name":"gauge","type":"address"},
+     {"name":"crvRewards","type":"address"},{"name":"stash","type":"address"},{"name":"shutdown","type":"bool"}
+  ],"stateMutability":"view","type":"function"},
+  {"inputs":[{"name":"account","type":"address"}],"name":"earned","outputs":[{"type":"uint256"}],"stateMutability":"view","type":"function"},
+  {"inputs":[],"name":"totalSupply","outputs":[{"type":"uint256"}],"stateMutability":"view","type":"function"}
+]`)
+
+// convexHoldings scans every live Convex pool for LP tokens the wallet has
+// staked, and adds the CRV and CVX those stakes have earned. Curve LP tokens
+// are priced through the regular fallback chain.
+func convexHoldings(ctx context.Context, client chainClient, wallet common.Address) ([]*holding, error) {
+	vs, err := callView(ctx, client, convexBooster, convexABI, "poolLength")
+	if err != nil {
+		return nil, err
+	}
+	n := vs[0].(*big.Int).Int64()
+
+	var held []*holding
+	pendingCRV := new(big.Int)
+	for pid := int64(0); pid < n; pid++ {
+		vs, err := callView(ctx, client, convexBooster, convexABI, "poolInfo", big.NewInt(pid))
+		if err != nil {
+			return held, err
+		}
+		lp, rewards, shutdown := vs[0].(common.Address), vs[3].(common.Address), vs[5].(bool)
+		if shutdown {
+			continue
+		}
+		staked, err := erc20Balance(ctx, client, rewards, wallet)
+		if err != nil {
+			return held, err
+		}
+		if staked.Sign() == 0 {
+			continue
+		}
+		tf, err := resolveToken(ctx, client, lp)
+		if err != nil {
+			return held, err
+		}
+		held = append(held, &holding{tf: tf, amt: tokenAmount(staked, tf.Decimals), note: "staked in Convex", id: "convex:" + rewards.Hex()})
+		vs, err = callView(ctx, client, rewards, convexABI, "earned", wallet)
+		if err != nil {
+			return held, err
+		}
+		pendingCRV.Add(pendingCRV, vs[0].(*big.Int))
+	}
+	if pendingCRV.Sign() == 0 {
+		return held, nil
+	}
+
+	crv, _ := tokenBySymbol("CRV")
+	cvx, _ := tokenBySymbol("CVX")
+	held = append(held, &holding{tf: crv, amt: tokenAmount(pendingCRV, 18), note: "pending Convex rewards"})
+	vs, err = callView(ctx, client, cvxToken, convexABI, "totalSupply")
+	if err != nil {
+		return held, err
+	}
+	if minted := cvxMinted(pendingCRV, vs[0].(*big.Int)); minted.Sign() > 0 {
+		held = append(held, &holding{tf: cvx, amt: tokenAmount(minted, 18), note: "pending Convex rewards"})
+	}
+	return held, nil
+}
+
+// cvxMinted mirrors the CVX token's mint schedule: the amount of CVX that
+// claiming crv CRV would mint at the current supply.
+func cvxMinted(crv, supply *big.Int) *big.Int {
+	cliff := new(big.Int).Quo(supply, cvxCliffSize)
+	if cliff.Cmp(cvxTotalCliffs) >= 0 {
+		return new(big.Int)
+	}
+	out := new(big.Int).Mul(crv, new(big.Int).Sub(cvxTotalCliffs, cliff))
+	out.Quo(out, cvxTotalCliffs)
+	if left := new(big.Int).Sub(cvxMaxSupply, supply); out.Cmp(left) > 0 {
+		out = left
+	}
+	return out
+}
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// The daemon is serve's API on a unix socket, kept running with its chain
+// connections open and recent reports cached, so that `portfolio -daemon`
+// runs answer without dialing or valuing anything themselves. It values any
+// wallet asked for, with its own flags; the socket's permissions are its
+// authentication. With -notify it also watches the config's alert
+// conditions; see monitor.
+
+func defaultSocket() string {
+	return filepath.Join(dataDir(), "daemon.sock")
+}
+
+// daemonCmd runs the daemon until interrupted.
+func daemonCmd(args []string) {
+	fs := flag.NewFlagSet("daemon", flag.ExitOnError)
+	fs.String("config", "config.json", "path to the JSON config file")
+	rpc := fs.String("rpc", "", "RPC endpoint of the chain valued by default (default: ETH_RPC_URL)")
+	chainList := fs.String("chains", "", "comma-separated chains to value wallets on (default: the chain -rpc points at)")
+	socket := fs.String("socket", defaultSocket(), "unix socket to listen on")
+	refresh := fs.Duration("refresh", 30*time.Second, "how long a valuation is reused for later queries of the same wallet")
+	notify := fs.String("notify", "", "check the config's alert conditions and send alerts to these comma-separated services: "+strings.Join(notifierNames(), ", "))
+	export := fs.String("export", "", "send every fresh valuation to these comma-separated services: "+strings.Join(exporterNames(), ", "))
+	checkEvery := fs.Duration("check-every", time.Minute, "with -notify, how often to check the alert conditions")
+	concurrencyFlag(fs)
+	cfg, err := parseSettings(fs, args)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if *notify != "" {
+		if err := checkNotifiers(*notify); err != nil {
+			log.Fatalf("-notify: %v", err)
+		}
+	}
+	if *export != "" {
+		if err := checkExporters(*export); err != nil {
+			log.Fatalf("-export: %v", err)
+		}
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	conns := openChains(ctx, *rpc, *chainList, cfg)
+	s := &server{
+		refresh: *refresh,
+		open:    &tenant{name: "daemon", dir: dataDir(), fixed: true, anyWallet: true, reports: map[common.Address]*report{}},
+		value: func(wallet common.Address) *report {
+			return valueWallet(ctx, conns, wallet, cfg, options{})
+		},
+	}
+
+	if *export != "" {
+		s.valued = func(rep *report) { exportReports(ctx, *export, cfg, []*report{rep}) }
+	}
+	if *notify != "" {
+		m, err := newMonitor(conns, cfg, func(wallet common.Address) *report { return s.report(s.open, wallet) })
+		if err != nil {
+			log.Fatalf("daemon: %v", err)
+		}
+		go m.run(ctx, *checkEvery, *notify)
+	}
+
+	ln, err := listenSocket(*socket)
+	if err != nil {
+		log.Fatalf("daemon: %v", err)
+	}
+	srv := &http.Server{Handler: s.handler(), ReadHeaderTimeout: 10 * time.Second}
+	go func() {
+		<-ctx.Done()
+		srv.Close()
+	}()
+	log.Printf("daemon listening on %s", *socket)
+	if err := srv.Serve(ln); !errors.Is(err, http.ErrServerClosed) {
+		log.Fatal(err)
+	}
+}
+
+// listenSocket listens on a unix socket only its owner can use, replacing
+// the socket of a daemon that is no longer running.
+func listenSocket(path string) (net.Listener, error) {
+	if conn, err := net.Dial("unix", path); err == nil {
+		conn.Close()
+		return nil, fmt.Errorf("%s: a daemon is already running", path)
+	}
+	os.Remove(path)
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return nil, err
+	}
+	ln, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.Chmod(path, 0o600); err != nil {
+		ln.Close()
+		return nil, err
+	}
+	return ln, nil
+}
+
+// daemonClient queries a running daemon.
+type daemonClient struct {
+	socket string
+	http   *http.Client
+}
+
+func newDaemonClient(socket string) *daemonClient {
+	return &daemonClient{socket: socket, http: &http.Client{
+		Timeout: 5 * time.Minute,
+		Transport: &http.Transport{DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, "unix", socket)
+		}},
+	}}
+}
+
+// report asks the daemon for a wallet's report.
+func (d *daemonClient) report(ctx context.Context, wallet common.Address) (*report, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, "http://daemon/api/wallets/"+wallet.Hex()+"/report", nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Connection", "keep-alive")
+	rep := &report{}
+	if err := exchangeDoWith(d.http, req, rep); err != nil {
+		var op *net.OpError
+		if errors.As(err, &op) {
+			return nil, fmt.Errorf("no daemon on %s; start one with `portfolio daemon`: %w", d.socket, err)
+		}
+		return nil, err
+	}
+	return rep, nil
+}
+
+// reportHoldings turns a report back into holdings for the text printers.
+// Prices are recovered as value over amount.
+func reportHoldings(rep *report) []*holding {
+	var held []*holding
+	for _, p := range rep.Positions {
+		h := &holding{tf: tokenFeed{Symbol: p.Symbol}, amt: big.NewFloat(p.Amount), note: p.Note, id: p.ID, chain: p.Chain, source: p.PriceSource}
+		switch {
+		case p.Error != "":
+			h.err = errors.New(p.Error)
+		case p.Amount != 0:
+			h.price = big.NewFloat(p.USD / p.Amount)
+		default:
+			h.price = new(big.Float)
+		}
+		held = append(held, h)
+	}
+	return held
+}
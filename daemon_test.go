@@ -0,0 +1,92 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+func TestDaemon(t *testing.T) {
+	// Unix socket paths are short; t.TempDir's can be too long.
+	dir, err := os.MkdirTemp("", "pd")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	t.Setenv("PORTFOLIO_HOME", dir)
+	socket := filepath.Join(dir, "daemon.sock")
+
+	valued := 0
+	s := &server{
+		refresh: time.Hour,
+		open:    &tenant{name: "daemon", dir: dir, fixed: true, anyWallet: true, reports: map[common.Address]*report{}},
+		value: func(w common.Address) *report {
+			valued++
+			rep := newReport(w.Hex(), nil)
+			rep.Positions = []reportPosition{
+				{Symbol: "ETH", Chain: "mainnet", Amount: 2, USD: 5000},
+				{Symbol: "LINK", Chain: "mainnet", Error: "no price"},
+			}
+			return rep
+		},
+	}
+	ln, err := listenSocket(socket)
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := &http.Server{Handler: s.handler()}
+	go srv.Serve(ln)
+	defer srv.Close()
+
+	if fi, err := os.Stat(socket); err != nil || fi.Mode().Perm() != 0o600 {
+		t.Errorf("socket mode: %v %v", fi.Mode(), err)
+	}
+	if _, err := listenSocket(socket); err == nil {
+		t.Error("second daemon listened on a live socket")
+	}
+
+	c := newDaemonClient(socket)
+	wallet := common.HexToAddress("0x00000000000000000000000000000000000000bb") // never registered
+	for i := 0; i < 2; i++ {
+		rep, err := c.report(context.Background(), wallet)
+		if err != nil {
+			t.Fatal(err)
+		}
+		held := reportHoldings(rep)
+		if len(held) != 2 || held[1].err == nil {
+			t.Fatalf("holdings: %+v", held)
+		}
+		if usd, _ := held[0].usd().Float64(); usd != 5000 {
+			t.Errorf("ETH usd %v", usd)
+		}
+	}
+	if valued != 1 {
+		t.Errorf("valued %d times, want once", valued)
+	}
+
+	if _, err := newDaemonClient(filepath.Join(dir, "none.sock")).report(context.Background(), wallet); err == nil {
+		t.Error("no error without a daemon")
+	}
+}
+
+func TestListenSocketStale(t *testing.T) {
+	dir, err := os.MkdirTemp("", "pd")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	socket := filepath.Join(dir, "daemon.sock")
+	if err := os.WriteFile(socket, nil, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	ln, err := listenSocket(socket)
+	if err != nil {
+		t.Fatalf("stale socket not replaced: %v", err)
+	}
+	ln.Close()
+}
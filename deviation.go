@@ -0,0 +1,54 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"math/big"
+
+	"Test2/source"
+)
+
+// priceDeviation is another source's answer for a holding that disagrees
+// with the price it was valued at by more than price_sources
+// max_deviation_pct.
+type priceDeviation struct {
+	source string
+	price  *big.Float
+	pct    float64
+}
+
+// crossCheck compares a later source's answer with the price a holding
+// already has and remembers it when the two are further apart than the
+// configured percentage of that price.
+func (p *pricer) crossCheck(h *holding, name string, price *big.Float) {
+	pct := deviationPct(h.price, price)
+	if pct <= p.order.MaxDeviation {
+		return
+	}
+	h.deviations = append(h.deviations, priceDeviation{source: name, price: price, pct: pct})
+	log.Printf("price deviation: %s on %s: %s says $%s, %s says $%s (%.1f%% apart)",
+		h.tf.Symbol, chainName(p.chainID), h.source, h.price.Text('g', 8), name, price.Text('g', 8), pct)
+}
+
+// deviationPct is how far other is from price, in percent of price.
+func deviationPct(price, other *big.Float) float64 {
+	if price.Sign() == 0 {
+		if other.Sign() == 0 {
+			return 0
+		}
+		return 100
+	}
+	d := new(big.Float).Sub(other, price)
+	d.Quo(d.Abs(d), new(big.Float).Abs(price))
+	pct, _ := d.Float64()
+	return pct * 100
+}
+
+// deviationError describes a holding's disagreeing sources for -strict.
+func deviationError(h *holding) error {
+	var errs causes
+	for _, d := range h.deviations {
+		errs = append(errs, fmt.Errorf("%s $%s is %.1f%% off %s $%s", d.source, d.price.Text('g', 8), d.pct, h.source, h.price.Text('g', 8)))
+	}
+	return fmt.Errorf("%w (%w)", source.ErrPriceDeviation, errs)
+}
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"math/big"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+
+	"Test2/bindings"
+
+	"Test2/source"
+)
+
+// feedMaxAge is how stale a feed answer may be before doctor flags it. The
+// slowest mainnet USD feeds have a 24h heartbeat; the extra hour allows for
+// a late round.
+const feedMaxAge = 25 * time.Hour
+
+// doctorCheck is one row of the doctor table; err is nil when it passed.
+type doctorCheck struct {
+	chain, check, target, detail string
+	err                          error
+}
+
+// doctorCmd checks that every endpoint, feed and registry token the tool
+// relies on answers sensibly, and exits non-zero if any check fails.
+func doctorCmd(args []string) {
+	fs := flag.NewFlagSet("doctor", flag.ExitOnError)
+	fs.String("config", "config.json", "path to the JSON config file")
+	rpc := fs.String("rpc", "", "RPC endpoint of the chain checked by default (default: ETH_RPC_URL)")
+	chainList := fs.String("chains", "", "comma-separated chains to check, each using RPC_URL_<CHAIN> or its config entry (default: the chain -rpc points at)")
+	cfg, err := parseSettings(fs, args)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	ctx := context.Background()
+	var checks []doctorCheck
+	for _, c := range openChains(ctx, *rpc, *chainList, cfg) {
+		checks = append(checks, c.doctor(ctx)...)
+	}
+
+	failed := 0
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "CHAIN\tCHECK\tTARGET\tRESULT\tDETAIL")
+	for _, c := range checks {
+		result, detail := "ok", c.detail
+		if c.err != nil {
+			failed++
+			result, detail = "FAIL", c.err.Error()
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", c.chain, c.check, c.target, result, detail)
+	}
+	tw.Flush()
+	if failed > 0 {
+		fmt.Fprintf(os.Stderr, "%d of %d checks failed\n", failed, len(checks))
+		os.Exit(1)
+	}
+}
+
+// doctor runs the endpoint checks and a check of every feed and token
+// contract in the chain's registry.
+func (c *chainConn) doctor(ctx context.Context) []doctorCheck {
+	name := chainName(c.id)
+	row := func(check, target, detail string, err error) doctorCheck {
+		return doctorCheck{chain: name, check: check, target: target, detail: detail, err: err}
+	}
+
+	checks := []doctorCheck{row("rpc", "chain id", fmt.Sprint(c.id), nil)}
+
+	head, err := c.client.HeaderByNumber(ctx, nil)
+	if err != nil {
+		return append(checks, row("rpc", "sync", "", err))
+	}
+	progress, err := c.client.SyncProgress(ctx)
+	switch {
+	case err != nil:
+	case progress != nil:
+		err = fmt.Errorf("syncing, at block %d of %d", progress.CurrentBlock, progress.HighestBlock)
+	default:
+		if age := time.Since(time.Unix(int64(head.Time), 0)); age > 5*time.Minute {
+			err = fmt.Errorf("head block %d is %s old", head.Number, age.Round(time.Second))
+		}
+	}
+	checks = append(checks, row("rpc", "sync", fmt.Sprintf("head %d", head.Number), err))
+
+	// Pruned nodes keep only recent state; an archive node can answer for
+	// block 1.
+	_, err = c.client.BalanceAt(ctx, common.Address{}, big.NewInt(1))
+	checks = append(checks, row("rpc", "archive", "state at block 1", err))
+
+	if _, ok := sequencerFeeds[c.id]; ok {
+		_, err := checkSequencer(ctx, c.client, c.id)
+		checks = append(checks, row("feed", "sequencer uptime", "up", err))
+	}
+
+	seen := map[common.Address]bool{}
+	for _, tf := range registry(c.id) {
+		if tf.FeedAddr != (common.Address{}) && !seen[tf.FeedAddr] {
+			seen[tf.FeedAddr] = true
+			detail, err := c.checkFeed(ctx, tf.FeedAddr, feedMaxAge)
+			checks = append(checks, row("feed", tf.Symbol+"/USD", detail, err))
+		}
+		if tf.TokenAddr != (common.Address{}) {
+			detail, err := c.checkToken(ctx, tf)
+			checks = append(checks, row("token", tf.Symbol, detail, err))
+		}
+	}
+	return checks
+}
+
+// checkFeed calls a Chainlink USD feed and checks that its decimals are the
+// usual 8, its answer is positive and it was updated within maxAge.
+func (c *chainConn) checkFeed(ctx context.Context, feed common.Address, maxAge time.Duration) (string, error) {
+	agg, err := bindings.NewAggregatorCaller(feed, c.client)
+	if err != nil {
+		return "", err
+	}
+	opts := &bind.CallOpts{Context: ctx}
+	dec, err := agg.Decimals(opts)
+	if err != nil {
+		return "", fmt.Errorf("decimals: %w", err)
+	}
+	round, err := agg.LatestRoundData(opts)
+	if err != nil {
+		return "", fmt.Errorf("latestRoundData: %w", err)
+	}
+	answer := round.Answer
+	age := time.Since(time.Unix(round.UpdatedAt.Int64(), 0)).Round(time.Second)
+	detail := fmt.Sprintf("%s, updated %s ago", tokenAmount(answer, int(dec)).Text('f', 4), age)
+	switch {
+	case dec != 8:
+		return detail, fmt.Errorf("%d decimals, USD feeds have 8", dec)
+	case answer.Sign() <= 0:
+		return detail, fmt.Errorf("non-positive answer %s", answer)
+	case age > maxAge:
+		return detail, fmt.Errorf("%w: updated %s ago", source.ErrStaleFeed, age)
+	}
+	return detail, nil
+}
+
+// checkToken checks that a registry token contract answers and agrees with
+// the registry on its decimals.
+func (c *chainConn) checkToken(ctx context.Context, tf tokenFeed) (string, error) {
+	token, err := bindings.NewERC20Caller(tf.TokenAddr, c.client)
+	if err != nil {
+		return "", err
+	}
+	dec, err := token.Decimals(&bind.CallOpts{Context: ctx})
+	if err != nil {
+		return "", fmt.Errorf("decimals: %w", err)
+	}
+	if dec := int(dec); dec != tf.Decimals {
+		return "", fmt.Errorf("contract has %d decimals, registry says %d", dec, tf.Decimals)
+	}
+	if _, err := erc20Balance(ctx, c.client, tf.TokenAddr, common.Address{}); err != nil {
+		return "", fmt.Errorf("balanceOf: %w", err)
+	}
+	return fmt.Sprintf("%d decimals", tf.Decimals), nil
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strings"
+)
+
+// computeDrift compares the allocation of held with targets, weights by
+// canonical symbol normalised to their sum, and works out the trade that
+// would bring each asset back to its target. Held assets without a target
+// are targeted at zero. Failed or unpriced positions are left out of both
+// the allocation and the total. The result is sorted by drift, most
+// overweight first.
+func computeDrift(held []*holding, targets map[string]float64) []reportDrift {
+	usd := map[string]float64{}
+	amt := map[string]float64{}
+	total := 0.0
+	for _, h := range held {
+		if h.err != nil || h.price == nil {
+			continue
+		}
+		sym := strings.ToUpper(h.tf.Symbol)
+		v, _ := h.usd().Float64()
+		a, _ := h.amt.Float64()
+		usd[sym] += v
+		amt[sym] += a
+		total += v
+	}
+	weights := 0.0
+	norm := map[string]float64{}
+	for sym, w := range targets {
+		if w > 0 {
+			norm[strings.ToUpper(sym)] += w
+			weights += w
+		}
+	}
+	if total <= 0 || weights <= 0 {
+		return nil
+	}
+
+	var out []reportDrift
+	seen := map[string]bool{}
+	add := func(sym string) {
+		if seen[sym] {
+			return
+		}
+		seen[sym] = true
+		d := reportDrift{
+			Symbol:  sym,
+			Current: 100 * usd[sym] / total,
+			Target:  100 * norm[sym] / weights,
+		}
+		d.Drift = d.Current - d.Target
+		d.TradeUSD = (d.Target - d.Current) / 100 * total
+		if amt[sym] > 0 {
+			d.TradeAmount = d.TradeUSD / (usd[sym] / amt[sym])
+		}
+		out = append(out, d)
+	}
+	for sym := range norm {
+		add(sym)
+	}
+	for sym := range usd {
+		add(sym)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Drift != out[j].Drift {
+			return out[i].Drift > out[j].Drift
+		}
+		return out[i].Symbol < out[j].Symbol
+	})
+	return out
+}
+
+// printDrift prints one line per asset: its weight against the target and
+// the trade that closes the gap. Assets within 0.05 points of their target
+// need no trade.
+func printDrift(w io.Writer, drift []reportDrift) {
+	if len(drift) == 0 {
+		return
+	}
+	fmt.Fprintln(w, "DRIFT vs target allocation:")
+	for _, d := range drift {
+		line := fmt.Sprintf("  %-6s %6.2f%% target %6.2f%%  %+6.2f%%", d.Symbol, d.Current, d.Target, d.Drift)
+		switch {
+		case math.Abs(d.Drift) < 0.05:
+		case d.TradeAmount == 0:
+			line += fmt.Sprintf("  buy $%.2f (not held, no price)", d.TradeUSD)
+		case d.TradeUSD > 0:
+			line += fmt.Sprintf("  buy %f ($%.2f)", d.TradeAmount, d.TradeUSD)
+		default:
+			line += fmt.Sprintf("  sell %f ($%.2f)", -d.TradeAmount, -d.TradeUSD)
+		}
+		fmt.Fprintln(w, line)
+	}
+}
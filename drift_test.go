@@ -0,0 +1,36 @@
+package main
+
+import (
+	"math"
+	"math/big"
+	"testing"
+)
+
+func TestComputeDrift(t *testing.T) {
+	held := []*holding{
+		{tf: tokenFeed{Symbol: "ETH"}, amt: big.NewFloat(2), price: big.NewFloat(3000)},  // $6000
+		{tf: tokenFeed{Symbol: "USDC"}, amt: big.NewFloat(3000), price: big.NewFloat(1)}, // $3000
+		{tf: tokenFeed{Symbol: "LINK"}, amt: big.NewFloat(100), price: big.NewFloat(10)}, // $1000, no target
+		{tf: tokenFeed{Symbol: "BAD"}, err: errFake},
+	}
+	drift := computeDrift(held, map[string]float64{"eth": 5, "USDC": 4, "WBTC": 1})
+
+	bySym := map[string]reportDrift{}
+	for _, d := range drift {
+		bySym[d.Symbol] = d
+	}
+	near := func(a, b float64) bool { return math.Abs(a-b) < 1e-9 }
+	if eth := bySym["ETH"]; !near(eth.Current, 60) || !near(eth.Target, 50) || !near(eth.TradeUSD, -1000) || !near(eth.TradeAmount, -1.0/3) {
+		t.Errorf("ETH = %+v", eth)
+	}
+	if link := bySym["LINK"]; !near(link.Target, 0) || !near(link.TradeAmount, -100) {
+		t.Errorf("LINK = %+v", link)
+	}
+	if wbtc := bySym["WBTC"]; !near(wbtc.TradeUSD, 1000) || wbtc.TradeAmount != 0 {
+		t.Errorf("WBTC = %+v", wbtc)
+	}
+	// ETH and LINK are both 10 points over; ties go by symbol.
+	if len(drift) != 4 || drift[0].Symbol != "ETH" || drift[1].Symbol != "LINK" || drift[3].Symbol != "WBTC" {
+		t.Errorf("order = %+v, want most overweight first", drift)
+	}
+}
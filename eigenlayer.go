@@ -0,0 +1,67 @@
+package main
+
+import (
+	"context"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// EigenLayer core contracts on mainnet. Restaked LSTs are held by strategy
+// contracts and only show up as shares in the StrategyManager; natively
+// restaked ETH is accounted as shares in the EigenPodManager.
+var (
+	eigenStrategyManager   = common.HexToAddress("0x858646372CC42E1A627fcE94aa7A7033e7CF075A")
+	eigenDelegationManager = common.HexToAddress("0x39053D51B77DC0d36036Fc1fCc8Cb819df8Ef37A")
+	eigenPodManager        = common.HexToAddress("0x91E677b07F7AF907ec9a428aafA9fc14a0d3A338")
+)
+
+var eigenABI = mustABI(`[
+  {"inputs":[{"name":"staker","type":"address"}],"name":"getDeposits","outputs":[{"type":"address[]"},{"type":"uint256[]"}],"stateMutability":"view","type":"function"},
+  {"inputs":[],"name":"underlyingToken","outputs":[{"type":"address"}],"stateMutability":"view","type":"function"},
+  {"inputs":[{"name":"amountShares","type":"uint256"}],"name":"sharesToUnderlyingView","outputs":[{"type":"uint256"}],"stateMutability":"view","type":"function"},
+  {"inputs":[{"name":"staker","type":"address"}],"name":"delegatedTo","outputs":[{"type":"address"}],"stateMutability":"view","type":"function"},
+  {"inputs":[{"name":"podOwner","type":"address"}],"name":"podOwnerShares","outputs":[{"type":"int256"}],"stateMutability":"view","type":"function"}
+]`)
+
+// eigenLayerHoldings values the wallet's strategy shares in their underlying
+// tokens, plus any natively restaked ETH held through an EigenPod.
+func eigenLayerHoldings(ctx context.Context, client chainClient, wallet common.Address) ([]*holding, error) {
+	note := "restaked in EigenLayer"
+	if vs, err := callView(ctx, client, eigenDelegationManager, eigenABI, "delegatedTo", wallet); err == nil {
+		if op := vs[0].(common.Address); op != (common.Address{}) {
+			note += ", delegated to " + op.Hex()
+		}
+	}
+
+	var held []*holding
+	vs, err := callView(ctx, client, eigenStrategyManager, eigenABI, "getDeposits", wallet)
+	if err != nil {
+		return nil, err
+	}
+	strategies, shares := vs[0].([]common.Address), vs[1].([]*big.Int)
+	for i, strategy := range strategies {
+		vs, err := callView(ctx, client, strategy, eigenABI, "underlyingToken")
+		if err != nil {
+			return held, err
+		}
+		tf, err := resolveToken(ctx, client, vs[0].(common.Address))
+		if err != nil {
+			return held, err
+		}
+		vs, err = callView(ctx, client, strategy, eigenABI, "sharesToUnderlyingView", shares[i])
+		if err != nil {
+			return held, err
+		}
+		held = append(held, &holding{tf: tf, amt: tokenAmount(vs[0].(*big.Int), tf.Decimals), note: note, id: "eigenlayer:" + strategy.Hex()})
+	}
+
+	vs, err = callView(ctx, client, eigenPodManager, eigenABI, "podOwnerShares", wallet)
+	if err != nil {
+		return held, err
+	}
+	if wei := vs[0].(*big.Int); wei.Sign() > 0 {
+		held = append(held, &holding{tf: defaultTokens[0], amt: tokenAmount(wei, 18), note: note + " (native)", id: "eigenlayer:pod"})
+	}
+	return held, nil
+}
@@ -0,0 +1,22 @@
+package main
+
+import "strings"
+
+// The failures worth telling apart are the Err values of package source,
+// which library callers can check for as well.
+
+// causes is several failures reported as one, separated by semicolons;
+// errors.Is and errors.As see each of them.
+type causes []error
+
+func (c causes) Error() string {
+	var parts []string
+	for _, err := range c {
+		if msg := err.Error(); msg != "" {
+			parts = append(parts, msg)
+		}
+	}
+	return strings.Join(parts, "; ")
+}
+
+func (c causes) Unwrap() []error { return c }
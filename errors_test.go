@@ -0,0 +1,40 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/core"
+
+	"Test2/source"
+)
+
+func TestTypedErrors(t *testing.T) {
+	link, _ := tokenBySymbol("LINK")
+	sim := newSim(t, core.GenesisAlloc{
+		link.FeedAddr: mockFeed(8, scaled(t, "14.25", 8), 0),
+	})
+	if _, err := feedPrice(context.Background(), sim, link.FeedAddr); !errors.Is(err, source.ErrStaleFeed) {
+		t.Errorf("incomplete round: err = %v, want source.ErrStaleFeed", err)
+	}
+
+	if _, err := connectChain(context.Background(), "http://127.0.0.1:1"); !errors.Is(err, source.ErrRPCUnavailable) {
+		t.Errorf("closed port: err = %v, want source.ErrRPCUnavailable", err)
+	}
+
+	path := filepath.Join(t.TempDir(), "book.csv")
+	if err := os.WriteFile(path, []byte("address,label\n0xnope,x\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := loadAddressBook(path); !errors.Is(err, source.ErrInvalidAddress) {
+		t.Errorf("address book: err = %v, want source.ErrInvalidAddress", err)
+	}
+
+	c := causes{errors.New(""), source.ErrStaleFeed, errors.New("1inch: no liquidity")}
+	if c.Error() != "stale; 1inch: no liquidity" || !errors.Is(c, source.ErrStaleFeed) {
+		t.Errorf("causes = %q", c.Error())
+	}
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+)
+
+// exporters push the reports of a run to the outside services -export
+// names, each configured in its own config section.
+var exporters = map[string]struct {
+	name   string
+	export func(ctx context.Context, cfg *config, reps []*report) error
+}{
+	"mqtt":   {"MQTT", exportMQTT},
+	"notion": {"Notion", exportNotion},
+	"sheets": {"Google Sheets", exportSheets},
+	"slack":  {"Slack", exportSlack},
+}
+
+// checkExporters rejects unknown -export names before anything is valued.
+func checkExporters(names string) error {
+	for _, n := range strings.Split(names, ",") {
+		if _, ok := exporters[strings.TrimSpace(n)]; !ok {
+			return fmt.Errorf("unknown exporter %q (want %s)", n, strings.Join(exporterNames(), ", "))
+		}
+	}
+	return nil
+}
+
+func exporterNames() []string {
+	var names []string
+	for n := range exporters {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// exportReports sends reps to every exporter named; failures are logged so
+// that one unreachable service does not lose the others' exports.
+func exportReports(ctx context.Context, names string, cfg *config, reps []*report) {
+	if names == "" || len(reps) == 0 {
+		return
+	}
+	for _, n := range strings.Split(names, ",") {
+		e := exporters[strings.TrimSpace(n)]
+		if err := e.export(ctx, cfg, reps); err != nil {
+			log.Printf("export: %s: %v", e.name, err)
+		} else {
+			log.Printf("export: %d reports sent to %s", len(reps), e.name)
+		}
+	}
+}
+
+// exportBuffer holds the reports of a run for the exporters, sending them
+// every size reports so that a scan of many wallets keeps at most that
+// many in memory. A size of 0 holds them all until flush.
+type exportBuffer struct {
+	ctx   context.Context
+	names string
+	cfg   *config
+	size  int
+	reps  []*report
+}
+
+func (b *exportBuffer) add(r *report) {
+	if b.names == "" {
+		return
+	}
+	b.reps = append(b.reps, r)
+	if b.size > 0 && len(b.reps) >= b.size {
+		b.flush()
+	}
+}
+
+// flush sends the reports held so far and lets them go.
+func (b *exportBuffer) flush() {
+	exportReports(b.ctx, b.names, b.cfg, b.reps)
+	b.reps = nil
+}
+
+// exportColumns are the columns of exportRows, for exporters that name
+// them.
+var exportColumns = []string{"time", "wallet", "label", "chain", "symbol", "note", "amount", "usd", "error"}
+
+// exportRows flattens a report into one row per position.
+func exportRows(r *report) [][]any {
+	var rows [][]any
+	for _, p := range r.Positions {
+		rows = append(rows, []any{r.Time.Format(time.RFC3339), r.Wallet, r.Label, p.Chain, p.Symbol, p.Note, p.Amount, p.USD, p.Error})
+	}
+	return rows
+}
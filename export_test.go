@@ -0,0 +1,44 @@
+package main
+
+import (
+	"context"
+	"testing"
+)
+
+func TestExportBuffer(t *testing.T) {
+	var sent []int
+	exporters["test"] = struct {
+		name   string
+		export func(ctx context.Context, cfg *config, reps []*report) error
+	}{"test", func(_ context.Context, _ *config, reps []*report) error {
+		sent = append(sent, len(reps))
+		return nil
+	}}
+	defer delete(exporters, "test")
+
+	b := &exportBuffer{ctx: context.Background(), names: "test", cfg: &config{}, size: 2}
+	for range 5 {
+		b.add(&report{})
+	}
+	if len(b.reps) != 1 {
+		t.Errorf("%d reports held, want 1", len(b.reps))
+	}
+	b.flush()
+	b.flush()
+	if len(sent) != 3 || sent[0] != 2 || sent[1] != 2 || sent[2] != 1 {
+		t.Errorf("sent batches %v, want [2 2 1]", sent)
+	}
+
+	all := &exportBuffer{ctx: context.Background(), names: "test", cfg: &config{}}
+	for range 3 {
+		all.add(&report{})
+	}
+	all.flush()
+	if sent[len(sent)-1] != 3 {
+		t.Errorf("size 0 sent batches %v, want all 3 at once", sent)
+	}
+	none := &exportBuffer{size: 1}
+	if none.add(&report{}); len(none.reps) != 0 {
+		t.Error("reports held without -export")
+	}
+}
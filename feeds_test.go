@@ -0,0 +1,46 @@
+package main
+
+import (
+	"context"
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/core"
+)
+
+func TestFeedRounds(t *testing.T) {
+	link, _ := tokenBySymbol("LINK")
+	updated := time.Now().Add(-90 * time.Second).Unix()
+	sim := newSim(t, core.GenesisAlloc{
+		link.FeedAddr: mockFeed(8, scaled(t, "14.25", 8), updated),
+	})
+	fed := &holding{tf: link, amt: big.NewFloat(2)}
+	fixed := &holding{tf: tokenFeed{Symbol: "MOCK", TokenAddr: testToken}, amt: big.NewFloat(1)}
+	again := &holding{tf: link, amt: big.NewFloat(1), chain: "optimism"}
+	newPricer(context.Background(), sim, simChainID, true, &config{}, options{PriceFile: priceFile{"MOCK": {Price: 3}}}).priceAll([]*holding{fed, fixed, again})
+
+	rep := newReport(testWallet.Hex(), []*holding{fed, fixed, again})
+	got := rep.Positions[0].Feeds
+	if len(got) != 1 || got[0].Address != link.FeedAddr.Hex() || got[0].RoundID != "1" || got[0].Decimals != 8 || got[0].Answer != 14.25 || got[0].UpdatedAt.Unix() != updated {
+		t.Fatalf("feeds %+v", got)
+	}
+	if f := rep.Positions[1].Feeds; f != nil {
+		t.Errorf("fixed price carries feeds %+v", f)
+	}
+	if f := rep.Positions[2].Feeds; len(f) != 1 {
+		t.Errorf("a cached feed answer was not attributed: %+v", f)
+	}
+
+	// Phase 2, aggregator round 7.
+	rep.Positions[0].Feeds[0].RoundID = new(big.Int).Add(new(big.Int).Lsh(big.NewInt(2), 64), big.NewInt(7)).String()
+	var b strings.Builder
+	printFeeds(&b, rep.Positions[:1], time.Unix(updated+90, 0))
+	line := b.String()
+	for _, want := range []string{"LINK", link.FeedAddr.Hex(), "(phase 2, round 7)", "updated 1m30s ago", "8 decimals", "answer 14.25"} {
+		if !strings.Contains(line, want) {
+			t.Errorf("%q lacks %q", line, want)
+		}
+	}
+}
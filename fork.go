@@ -0,0 +1,134 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// hardhatChainID is what Hardhat Network reports regardless of the chain it
+// forks; the forked chain is found through hardhat_metadata instead.
+const hardhatChainID = 31337
+
+// devNode names the local development node behind an endpoint, "anvil" or
+// "hardhat", or returns "" for a regular node. It is also the prefix of the
+// node's custom RPC methods.
+func devNode(ctx context.Context, client *rpc.Client) string {
+	var version string
+	if err := client.CallContext(ctx, &version, "web3_clientVersion"); err != nil {
+		return ""
+	}
+	switch v := strings.ToLower(version); {
+	case strings.HasPrefix(v, "anvil"):
+		return "anvil"
+	case strings.HasPrefix(v, "hardhatnetwork"):
+		return "hardhat"
+	}
+	return ""
+}
+
+// forkedChainID returns the chain a Hardhat fork was made from, or 0 when
+// the node is not forking.
+func forkedChainID(ctx context.Context, client *rpc.Client) uint64 {
+	var meta struct {
+		ForkedNetwork *struct {
+			ChainID uint64 `json:"chainId"`
+		} `json:"forkedNetwork"`
+	}
+	if err := client.CallContext(ctx, &meta, "hardhat_metadata"); err != nil || meta.ForkedNetwork == nil {
+		return 0
+	}
+	return meta.ForkedNetwork.ChainID
+}
+
+// whatIf is a hypothetical state to value wallets in, applied to a local
+// fork before the run and reverted after it. Balances are set first, then
+// the transactions are sent from their (impersonated) senders, then Mine
+// empty blocks are mined Interval seconds apart. A run that aborts leaves
+// the changes in place until the node is restarted.
+type whatIf struct {
+	Balances     map[common.Address]*hexutil.Big `json:"balances"`
+	Transactions []whatIfTx                      `json:"transactions"`
+	Mine         uint64                          `json:"mine"`
+	Interval     uint64                          `json:"interval"`
+}
+
+type whatIfTx struct {
+	From  common.Address `json:"from"`
+	To    common.Address `json:"to"`
+	Value *hexutil.Big   `json:"value,omitempty"`
+	Data  hexutil.Bytes  `json:"data,omitempty"`
+}
+
+func loadWhatIf(path string) (*whatIf, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	w := &whatIf{Interval: 12}
+	if err := json.Unmarshal(data, w); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return w, nil
+}
+
+// applyWhatIf snapshots the fork, applies w and returns a function that
+// reverts the fork to the snapshot.
+func (c *chainConn) applyWhatIf(ctx context.Context, w *whatIf) (func() error, error) {
+	if c.dev == "" {
+		return nil, fmt.Errorf("%s is not a local anvil or hardhat fork", chainName(c.id))
+	}
+	rc := c.client.Client()
+	var snapshot hexutil.Big
+	if err := rc.CallContext(ctx, &snapshot, "evm_snapshot"); err != nil {
+		return nil, fmt.Errorf("evm_snapshot: %w", err)
+	}
+	revert := func() error {
+		var ok bool
+		return rc.CallContext(ctx, &ok, "evm_revert", &snapshot)
+	}
+	if err := c.whatIf(ctx, rc, w); err != nil {
+		revert()
+		return nil, err
+	}
+	return revert, nil
+}
+
+func (c *chainConn) whatIf(ctx context.Context, rc *rpc.Client, w *whatIf) error {
+	for addr, bal := range w.Balances {
+		if err := rc.CallContext(ctx, nil, c.dev+"_setBalance", addr, bal); err != nil {
+			return fmt.Errorf("set balance of %s: %w", addr.Hex(), err)
+		}
+	}
+	for i, tx := range w.Transactions {
+		if err := rc.CallContext(ctx, nil, c.dev+"_impersonateAccount", tx.From); err != nil {
+			return fmt.Errorf("impersonate %s: %w", tx.From.Hex(), err)
+		}
+		var hash common.Hash
+		err := rc.CallContext(ctx, &hash, "eth_sendTransaction", tx)
+		rc.CallContext(ctx, nil, c.dev+"_stopImpersonatingAccount", tx.From)
+		if err != nil {
+			return fmt.Errorf("transaction %d: %w", i, err)
+		}
+		receipt, err := c.client.TransactionReceipt(ctx, hash)
+		if err != nil {
+			return fmt.Errorf("transaction %d: %w", i, err)
+		}
+		if receipt.Status == 0 {
+			return fmt.Errorf("transaction %d (%s) reverted", i, hash.Hex())
+		}
+	}
+	if w.Mine > 0 {
+		blocks, interval := hexutil.Uint64(w.Mine), hexutil.Uint64(w.Interval)
+		if err := rc.CallContext(ctx, nil, c.dev+"_mine", blocks, interval); err != nil {
+			return fmt.Errorf("mine %d blocks: %w", w.Mine, err)
+		}
+	}
+	return nil
+}
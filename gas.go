@@ -0,0 +1,150 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/params"
+
+	"Test2/bindings"
+)
+
+// gasReader is the part of the node API the gas panel uses.
+type gasReader interface {
+	HeaderByNumber(ctx context.Context, number *big.Int) (*types.Header, error)
+	SuggestGasTipCap(ctx context.Context) (*big.Int, error)
+	EstimateGas(ctx context.Context, call ethereum.CallMsg) (uint64, error)
+}
+
+// gasPanel reads a chain's current fees and, with a target, estimates what
+// sending each plain wallet balance in held to it would cost at those fees.
+// Protocol positions are not movable by a transfer and are not estimated.
+// nativeUSD prices the gas; a nil one leaves the costs in the native coin
+// only, in the decimals of the chain's profile.
+func gasPanel(ctx context.Context, client gasReader, chain string, wallet common.Address, to *common.Address, held []*holding, nativeUSD *big.Float) (reportGas, error) {
+	g := reportGas{Chain: chain}
+	head, err := client.HeaderByNumber(ctx, nil)
+	if err != nil {
+		return g, err
+	}
+	tip, err := client.SuggestGasTipCap(ctx)
+	if err != nil {
+		return g, fmt.Errorf("priority fee: %w", err)
+	}
+	baseFee := new(big.Int)
+	if head.BaseFee != nil {
+		baseFee = head.BaseFee
+	}
+	g.BaseFeeGwei = gwei(baseFee)
+	g.PriorityFeeGwei = gwei(tip)
+	if to == nil {
+		return g, nil
+	}
+
+	price := new(big.Int).Add(baseFee, tip)
+	profile, _ := chainByName(chain)
+	native := nativeToken(profile.ID)
+	for _, h := range held {
+		if h.err != nil || h.raw == nil {
+			continue
+		}
+		s := reportSweep{Symbol: h.tf.Symbol}
+		if h.price != nil {
+			s.ValueUSD, _ = h.usd().Float64()
+		}
+		call, err := sweepCall(wallet, *to, h)
+		if err != nil {
+			return g, err
+		}
+		gas, err := client.EstimateGas(ctx, call)
+		if err != nil {
+			s.Error = err.Error()
+			g.Sweeps = append(g.Sweeps, s)
+			continue
+		}
+		s.Gas = gas
+		cost := tokenAmount(new(big.Int).Mul(price, new(big.Int).SetUint64(gas)), native.Decimals)
+		s.CostNative, _ = cost.Float64()
+		if nativeUSD != nil {
+			s.CostUSD, _ = new(big.Float).Mul(cost, nativeUSD).Float64()
+		}
+		g.Sweeps = append(g.Sweeps, s)
+	}
+	return g, nil
+}
+
+// sweepCall is the call that sends a plain wallet balance to a target: an
+// ERC-20 transfer of all of it, or, for the native coin, a plain value
+// transfer whose amount is left to the caller.
+func sweepCall(wallet, to common.Address, h *holding) (ethereum.CallMsg, error) {
+	if h.tf.TokenAddr == (common.Address{}) {
+		return ethereum.CallMsg{From: wallet, To: &to, Value: big.NewInt(1)}, nil
+	}
+	erc20, err := bindings.ERC20MetaData.GetAbi()
+	if err != nil {
+		return ethereum.CallMsg{}, err
+	}
+	data, err := erc20.Pack("transfer", to, h.raw)
+	if err != nil {
+		return ethereum.CallMsg{}, err
+	}
+	token := h.tf.TokenAddr
+	return ethereum.CallMsg{From: wallet, To: &token, Data: data}, nil
+}
+
+func gwei(wei *big.Int) float64 {
+	v, _ := new(big.Float).Quo(new(big.Float).SetInt(wei), big.NewFloat(params.GWei)).Float64()
+	return v
+}
+
+// gas prices the native coin and builds the chain's gas panel.
+func (c *chainConn) gas(ctx context.Context, wallet common.Address, to *common.Address, held []*holding, cfg *config, opts options) (reportGas, error) {
+	var nativeUSD *big.Float
+	native := nativeToken(c.id)
+	one := new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(native.Decimals)), nil)
+	if h := c.priced(ctx, cfg, opts, native, one); h.err == nil {
+		nativeUSD = h.price
+	}
+	g, err := gasPanel(ctx, c.client, chainName(c.id), wallet, to, c.heldOn(held), nativeUSD)
+	g.Native = native.Symbol
+	return g, err
+}
+
+// heldOn keeps the holdings found on the connection's chain.
+func (c *chainConn) heldOn(held []*holding) []*holding {
+	var out []*holding
+	for _, h := range held {
+		if h.chain == chainName(c.id) {
+			out = append(out, h)
+		}
+	}
+	return out
+}
+
+// printGas prints each chain's fees and, when there are estimates, what
+// moving each balance would cost, flagging those that cost more than they
+// are worth.
+func printGas(w io.Writer, panels []reportGas) {
+	for _, g := range panels {
+		fmt.Fprintf(w, "GAS %s: base fee %.3f gwei, priority fee %.3f gwei\n", g.Chain, g.BaseFeeGwei, g.PriorityFeeGwei)
+		for _, s := range g.Sweeps {
+			if s.Error != "" {
+				fmt.Fprintf(w, "  sweep %-6s error: %s\n", s.Symbol, s.Error)
+				continue
+			}
+			line := fmt.Sprintf("  sweep %-6s %8d gas  %.6f %s", s.Symbol, s.Gas, s.CostNative, g.Native)
+			if s.CostUSD > 0 {
+				line += fmt.Sprintf("  $%.2f", s.CostUSD)
+				if s.ValueUSD > 0 && s.CostUSD >= s.ValueUSD {
+					line += fmt.Sprintf("  not worth it (balance $%.2f)", s.ValueUSD)
+				}
+			}
+			fmt.Fprintln(w, line)
+		}
+	}
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"context"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+func TestGasPanel(t *testing.T) {
+	sim := newSim(t, core.GenesisAlloc{
+		testWallet: {Balance: big.NewInt(params.Ether)},
+		testToken:  mockToken(18, nil),
+	})
+	to := common.HexToAddress("0x0000000000000000000000000000000000000b0b")
+	held := []*holding{
+		{tf: defaultTokens[0], amt: big.NewFloat(1), raw: big.NewInt(params.Ether), price: big.NewFloat(3000)},
+		// Protocol positions have no raw balance and are not estimated.
+		{tf: defaultTokens[0], amt: big.NewFloat(2), price: big.NewFloat(3000), note: "staked"},
+		// The mock token does not implement transfer, so estimating fails.
+		{tf: tokenFeed{Symbol: "MOCK", TokenAddr: testToken, Decimals: 18}, amt: big.NewFloat(1), raw: big.NewInt(1e18)},
+	}
+
+	g, err := gasPanel(context.Background(), sim, "sim", testWallet, &to, held, big.NewFloat(3000))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if g.BaseFeeGwei <= 0 {
+		t.Errorf("base fee = %g gwei", g.BaseFeeGwei)
+	}
+	if len(g.Sweeps) != 2 {
+		t.Fatalf("sweeps = %+v, want ETH and MOCK", g.Sweeps)
+	}
+	eth := g.Sweeps[0]
+	if eth.Gas != params.TxGas || eth.CostUSD <= 0 || eth.ValueUSD != 3000 {
+		t.Errorf("ETH sweep = %+v", eth)
+	}
+	if g.Sweeps[1].Error == "" {
+		t.Errorf("MOCK sweep = %+v, want an estimation error", g.Sweeps[1])
+	}
+}
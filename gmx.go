@@ -0,0 +1,116 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// GMX contracts on Arbitrum One. Open positions live in the vault, keyed by
+// (account, collateral token, index token, side); staked GMX and esGMX are
+// deposit balances of the staked-GMX reward tracker.
+var (
+	gmxVault         = common.HexToAddress("0x489ee077994B6658eAfA855C308275EAd8097C4A")
+	gmxStakedTracker = common.HexToAddress("0x908C4D94D34924765f1eDc22A1DD098397c59dD4")
+	gmxToken         = tokenFeed{"GMX", common.HexToAddress("0xfc5A1A6EB076a2C7aD06eD22C90d7E710E35ad0a"), common.HexToAddress("0xDB98056FecFff59D032aB628337A4887110df3dB"), 18}
+	esGmxToken       = common.HexToAddress("0xf42Ae1D54fd613C9bb14810b0588FaAa09a426cA")
+)
+
+// gmxIndexTokens can be traded long (backed by themselves) or short (backed
+// by one of gmxStables).
+var gmxIndexTokens = []struct {
+	Symbol string
+	Addr   common.Address
+}{
+	{"ETH", common.HexToAddress("0x82aF49447D8a07e3bd95BD0d56f35241523fBab1")},
+	{"BTC", common.HexToAddress("0x2f2a2543B76A4166549F7aaB2e75Bef0aefC5B0f")},
+	{"LINK", common.HexToAddress("0xf97f4df75117a78c1A5a0DBb814Af92458539FB4")},
+	{"UNI", common.HexToAddress("0xFa7F8980b0f1E64A2062791cc3b0871572f1F7f0")},
+}
+
+var gmxStables = []common.Address{
+	common.HexToAddress("0xFF970A61A04b1cA14834A43f5dE4533eBDDB5CC8"), // USDC.e
+	common.HexToAddress("0xFd086bC7CD5C481DCC9C85ebE478A1C0b69FCbb9"), // USDT
+	common.HexToAddress("0xDA10009cBd5D07dd0CeCc66161FC93D7c9000da1"), // DAI
+	common.HexToAddress("0x17FC002b466eEc40DaE837Fc4bE5c67993ddBd6F"), // FRAX
+}
+
+var gmxABI = mustABI(`[
+  {"inputs":[{"name":"account","type":"address"},{"name":"collateralToken","type":"address"},{"name":"indexToken","type":"address"},{"name":"isLong","type":"bool"}],"name":"getPosition","outputs":[
+     {"name":"size","type":"uint256"},{"name":"collateral","type":"uint256"},{"name":"averagePrice","type":"uint256"},{"name":"entryFundingRate","type":"uint256"},
+     {"name":"reserveAmount","type":"uint256"},{"name":"realisedPnl","type":"uint256"},{"name":"hasRealisedProfit","type":"bool"},{"name":"lastIncreasedTime","type":"uint256"}
+  ],"stateMutability":"view","type":"function"},
+  {"inputs":[{"name":"account","type":"address"},{"name":"collateralToken","type":"address"},{"name":"indexToken","type":"address"},{"name":"isLong","type":"bool"}],"name":"getPositionDelta","outputs":[{"name":"hasProfit","type":"bool"},{"name":"delta","type":"uint256"}],"stateMutability":"view","type":"function"},
+  {"inputs":[{"name":"account","type":"address"},{"name":"token","type":"address"}],"name":"depositBalances","outputs":[{"type":"uint256"}],"stateMutability":"view","type":"function"}
+]`)
+
+// usdToken is the pseudo-token for positions that are already valued in USD.
+var usdToken = tokenFeed{Symbol: "USD", Decimals: 0}
+
+// gmxHoldings returns the wallet's open GMX positions at their net value
+// (collateral plus unrealized PnL) and its staked GMX and esGMX. esGMX is
+// priced as GMX, which it vests into.
+func gmxHoldings(ctx context.Context, client chainClient, wallet common.Address) ([]*holding, error) {
+	var held []*holding
+	for _, dep := range []struct {
+		token common.Address
+		note  string
+	}{{gmxToken.TokenAddr, "staked"}, {esGmxToken, "staked esGMX"}} {
+		vs, err := callView(ctx, client, gmxStakedTracker, gmxABI, "depositBalances", wallet, dep.token)
+		if err != nil {
+			return held, err
+		}
+		if bal := vs[0].(*big.Int); bal.Sign() > 0 {
+			held = append(held, &holding{tf: gmxToken, amt: tokenAmount(bal, 18), note: "GMX " + dep.note})
+		}
+	}
+
+	for _, it := range gmxIndexTokens {
+		index := it.Addr
+		if h, err := gmxPosition(ctx, client, wallet, index, index, true, "long "+it.Symbol); err != nil {
+			return held, err
+		} else if h != nil {
+			held = append(held, h)
+		}
+		for _, stable := range gmxStables {
+			if h, err := gmxPosition(ctx, client, wallet, stable, index, false, "short "+it.Symbol); err != nil {
+				return held, err
+			} else if h != nil {
+				held = append(held, h)
+			}
+		}
+	}
+	return held, nil
+}
+
+// gmxPosition returns nil when the wallet has no such position. Vault
+// amounts are USD with 30 decimals.
+func gmxPosition(ctx context.Context, client chainClient, wallet, collateral, index common.Address, isLong bool, label string) (*holding, error) {
+	vs, err := callView(ctx, client, gmxVault, gmxABI, "getPosition", wallet, collateral, index, isLong)
+	if err != nil {
+		return nil, err
+	}
+	size, value := vs[0].(*big.Int), new(big.Int).Set(vs[1].(*big.Int))
+	if size.Sign() == 0 {
+		return nil, nil
+	}
+	vs, err = callView(ctx, client, gmxVault, gmxABI, "getPositionDelta", wallet, collateral, index, isLong)
+	if err != nil {
+		return nil, err
+	}
+	pnl := new(big.Int).Set(vs[1].(*big.Int))
+	if !vs[0].(bool) {
+		pnl.Neg(pnl)
+	}
+	value.Add(value, pnl)
+	return &holding{
+		tf:    usdToken,
+		amt:   tokenAmount(value, 30),
+		price: big.NewFloat(1),
+		id:    "gmx:" + label,
+		note: fmt.Sprintf("GMX %s, size $%s, PnL $%s", label,
+			tokenAmount(size, 30).Text('f', 2), tokenAmount(pnl, 30).Text('f', 2)),
+	}, nil
+}
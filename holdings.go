@@ -0,0 +1,125 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"slices"
+	"strings"
+	"sync/atomic"
+
+	"github.com/ethereum/go-ethereum/common"
+
+	"Test2/source"
+)
+
+// options are the per-run switches of the optional position sources, and
+// the symbol filters applied to what they find.
+type options struct {
+	Validators    string
+	Withdrawal    string
+	PendleMarkets string
+	Convex        bool
+	Balancer      string
+	Adapters      string
+	Only          string
+	Exclude       string
+	Prices        priceOverrides
+	PriceFile     priceFile
+}
+
+// wants reports whether positions in symbols pass the -only and -exclude
+// lists: one of them is listed in -only, when it is set, and none in
+// -exclude. Symbols compare case-insensitively.
+func (o options) wants(symbols ...string) bool {
+	listed := func(list string) bool {
+		for _, s := range strings.Split(list, ",") {
+			for _, sym := range symbols {
+				if strings.EqualFold(strings.TrimSpace(s), sym) {
+					return true
+				}
+			}
+		}
+		return false
+	}
+	return (o.Only == "" || listed(o.Only)) && !listed(o.Exclude)
+}
+
+// filter drops the holdings whose symbol is not wanted. It runs after
+// canonicalize, and a bridged representation goes by its local name as
+// well, so that on mainnet both -only ETH and -only WETH keep WETH.
+func (o options) filter(chainID uint64, held []*holding) []*holding {
+	out := held[:0]
+	for _, h := range held {
+		names := []string{h.tf.Symbol}
+		if asset, ok := bridgedTokens[chainID][h.tf.TokenAddr]; ok && asset.Variant != "" {
+			names = append(names, asset.Variant)
+		}
+		if o.wants(names...) {
+			out = append(out, h)
+		}
+	}
+	return out
+}
+
+// collectHoldings gathers every position of the wallet on the connected
+// chain: registry token balances first, then whatever the asset sources
+// find. A failing source is recorded as a failed holding named after it, so
+// one broken integration does not hide the rest.
+func collectHoldings(ctx context.Context, client chainClient, chainID uint64, wallet common.Address, cfg *config, opts options) []*holding {
+	held := walletHoldings(ctx, client, chainID, wallet, cfg)
+	add := func(source string, hs []*holding, err error) {
+		runProgress.set("%s done", source)
+		if err != nil {
+			hs = append(hs, &holding{tf: tokenFeed{Symbol: source}, err: err})
+		}
+		held = append(held, hs...)
+	}
+
+	for _, src := range assetSources(client, chainID, cfg, opts) {
+		key := src.name + " on " + chainName(chainID)
+		ps, err := guard(ctx, circuits, key, func() ([]source.Position, error) { return positionsOf(ctx, src, wallet) })
+		hs := make([]*holding, len(ps))
+		for i, p := range ps {
+			hs[i] = holdingOf(chainID, p)
+		}
+		add(src.name, hs, err)
+	}
+	return held
+}
+
+// walletHoldings returns the non-zero balances of every allowed token of
+// the chain's registry, in registry order. They are read through the
+// chain's Multicall3 when it has one, else up to -concurrency at once, one
+// call each. Which symbols are wanted is left to options.filter, since it
+// goes by the canonical symbols.
+func walletHoldings(ctx context.Context, client chainClient, chainID uint64, wallet common.Address, cfg *config) []*holding {
+	tokens := slices.DeleteFunc(slices.Clone(registry(chainID)), func(tf tokenFeed) bool { return !cfg.tokenAllowed(tf.TokenAddr) })
+	var balances []*big.Int
+	var errs []error
+	if chain, _ := chainByID(chainID); chain.Multicall != (common.Address{}) {
+		runProgress.set("tokens through multicall")
+		balances, errs = multicallBalances(ctx, client, chain.Multicall, tokens, wallet)
+	} else {
+		balances, errs = make([]*big.Int, len(tokens)), make([]error, len(tokens))
+		var done atomic.Int32
+		forEachLimited(len(tokens), func(i int) {
+			defer func() { runProgress.set("tokens %d/%d", done.Add(1), len(tokens)) }()
+			if tf := tokens[i]; tf.TokenAddr == (common.Address{}) {
+				balances[i], errs[i] = client.BalanceAt(ctx, wallet, nil)
+			} else {
+				balances[i], errs[i] = erc20Balance(ctx, client, tf.TokenAddr, wallet)
+			}
+		})
+	}
+	var held []*holding
+	for i, tf := range tokens {
+		switch {
+		case errs[i] != nil:
+			held = append(held, &holding{tf: tf, err: fmt.Errorf("balance: %w", errs[i])})
+		case balances[i].Sign() != 0:
+			held = append(held, &holding{tf: tf, amt: tokenAmount(balances[i], tf.Decimals), raw: balances[i]})
+		}
+	}
+	return held
+}
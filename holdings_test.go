@@ -0,0 +1,161 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core"
+)
+
+var errFake = errors.New("fake")
+
+func TestWalletHoldings(t *testing.T) {
+	usdc, _ := tokenBySymbol("USDC")
+	link, _ := tokenBySymbol("LINK")
+	oneETH := new(big.Int).Exp(big.NewInt(10), big.NewInt(18), nil)
+	sim := newSim(t, core.GenesisAlloc{
+		testWallet: {Balance: new(big.Int).Mul(big.NewInt(2), oneETH)},
+		usdc.TokenAddr: mockToken(6, map[common.Address]*big.Int{
+			testWallet: big.NewInt(2_500_000_000),
+		}),
+		// LINK's contract is gone: its balance lookup fails.
+		link.TokenAddr: {Balance: new(big.Int)},
+	})
+
+	held := walletHoldings(context.Background(), sim, 1, testWallet, &config{})
+	got := map[string]*holding{}
+	for _, h := range held {
+		got[h.tf.Symbol] = h
+	}
+	if len(held) != 3 {
+		t.Errorf("got %d holdings, want ETH, USDC and the failed LINK", len(held))
+	}
+	if h := got["ETH"]; h == nil || h.err != nil || !floatEq(h.amt, 2) {
+		t.Errorf("ETH = %+v, want 2", h)
+	}
+	if h := got["USDC"]; h == nil || h.err != nil || !floatEq(h.amt, 2500) {
+		t.Errorf("USDC = %+v, want 2500", h)
+	}
+	if h := got["LINK"]; h == nil || h.err == nil {
+		t.Errorf("LINK = %+v, want a failed lookup", h)
+	}
+}
+
+func TestOptionsWants(t *testing.T) {
+	for _, tc := range []struct {
+		only, exclude, symbol string
+		want                  bool
+	}{
+		{"", "", "LINK", true},
+		{"ETH,USDC", "", "usdc", true},
+		{"ETH, USDC", "", "USDC", true},
+		{"ETH,USDC", "", "LINK", false},
+		{"", "LINK", "LINK", false},
+		{"", "LINK", "ETH", true},
+		{"ETH,LINK", "LINK", "LINK", false},
+	} {
+		o := options{Only: tc.only, Exclude: tc.exclude}
+		if got := o.wants(tc.symbol); got != tc.want {
+			t.Errorf("only %q exclude %q: wants(%q) = %v, want %v", tc.only, tc.exclude, tc.symbol, got, tc.want)
+		}
+	}
+}
+
+func TestOptionsFilterBridged(t *testing.T) {
+	weth, _ := tokenBySymbol("WETH")
+	for _, tc := range []struct {
+		only, exclude, want string
+	}{
+		{"ETH", "", "ETH,ETH"},
+		{"WETH", "", "ETH"},
+		{"weth,LINK", "", "ETH"},
+		{"", "WETH", "ETH"},
+		{"", "ETH", ""},
+	} {
+		held := []*holding{{tf: defaultTokens[0]}, {tf: weth}}
+		canonicalize(1, held)
+		var got []string
+		for _, h := range (options{Only: tc.only, Exclude: tc.exclude}).filter(1, held) {
+			got = append(got, h.tf.Symbol)
+		}
+		if strings.Join(got, ",") != tc.want {
+			t.Errorf("only %q exclude %q: kept %v, want %s", tc.only, tc.exclude, got, tc.want)
+		}
+	}
+}
+
+// multicallClient serves aggregate3 calls to its Multicall3 address by
+// making each call on the backend, and counts the calls it was sent.
+type multicallClient struct {
+	chainClient
+	multicall      common.Address
+	batches, other int
+}
+
+func (c *multicallClient) CallContract(ctx context.Context, call ethereum.CallMsg, block *big.Int) ([]byte, error) {
+	if *call.To != c.multicall {
+		c.other++
+		return c.chainClient.CallContract(ctx, call, block)
+	}
+	c.batches++
+	method := multicallABI.Methods["aggregate3"]
+	args, err := method.Inputs.Unpack(call.Data[4:])
+	if err != nil {
+		return nil, err
+	}
+	var results []multicallResult
+	for _, sub := range *abi.ConvertType(args[0], new([]multicallCall)).(*[]multicallCall) {
+		if sub.Target == c.multicall {
+			vs, _ := multicallABI.Methods["getEthBalance"].Inputs.Unpack(sub.CallData[4:])
+			bal, err := c.chainClient.BalanceAt(ctx, vs[0].(common.Address), block)
+			if err != nil {
+				return nil, err
+			}
+			results = append(results, multicallResult{true, common.LeftPadBytes(bal.Bytes(), 32)})
+			continue
+		}
+		out, err := c.chainClient.CallContract(ctx, ethereum.CallMsg{To: &sub.Target, Data: sub.CallData}, block)
+		results = append(results, multicallResult{err == nil, out})
+	}
+	return method.Outputs.Pack(results)
+}
+
+func TestWalletHoldingsMulticall(t *testing.T) {
+	defer func(saved []chainProfile) { chains = saved }(chains)
+	defer delete(customRegistries, 4245)
+	defer delete(fixedPrices, 4245)
+	gone := common.HexToAddress("0x00000000000000000000000000000000000dead1")
+	mc := common.HexToAddress("0x00000000000000000000000000000000000ca113")
+	if err := addChain(chainConfig{Name: "batched", ChainID: 4245, Multicall: mc,
+		Native: customToken{Symbol: "DEV"},
+		Tokens: []customToken{{Symbol: "USDX", Address: testToken, Decimals: 6}, {Symbol: "GONE", Address: gone}}}); err != nil {
+		t.Fatal(err)
+	}
+	sim := newSim(t, core.GenesisAlloc{
+		testWallet: {Balance: big.NewInt(3e18)},
+		testToken:  mockToken(6, map[common.Address]*big.Int{testWallet: big.NewInt(2_500_000)}),
+	})
+	client := &multicallClient{chainClient: sim, multicall: mc}
+	got := map[string]*holding{}
+	for _, h := range walletHoldings(context.Background(), client, 4245, testWallet, &config{}) {
+		got[h.tf.Symbol] = h
+	}
+	if h := got["DEV"]; h == nil || h.err != nil || !floatEq(h.amt, 3) {
+		t.Errorf("DEV = %+v, want 3", h)
+	}
+	if h := got["USDX"]; h == nil || h.err != nil || !floatEq(h.amt, 2.5) {
+		t.Errorf("USDX = %+v, want 2.5", h)
+	}
+	if h := got["GONE"]; h == nil || h.err == nil {
+		t.Errorf("GONE = %+v, want a failed balance", h)
+	}
+	if client.batches != 1 || client.other != 0 {
+		t.Errorf("%d aggregate3 calls and %d others, want one batch", client.batches, client.other)
+	}
+}
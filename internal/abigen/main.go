@@ -0,0 +1,36 @@
+// Command abigen writes the Go binding of one contract ABI for package
+// bindings. It is the library half of go-ethereum's abigen: the abigen
+// command itself does not link with current Go toolchains at the
+// go-ethereum version this module uses.
+package main
+
+import (
+	"flag"
+	"log"
+	"os"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+)
+
+func main() {
+	typ := flag.String("type", "", "Go type name of the binding")
+	abiPath := flag.String("abi", "", "contract ABI JSON file")
+	out := flag.String("out", "", "output file")
+	pkg := flag.String("pkg", "bindings", "package name")
+	flag.Parse()
+	if *typ == "" || *abiPath == "" || *out == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+	data, err := os.ReadFile(*abiPath)
+	if err != nil {
+		log.Fatal(err)
+	}
+	code, err := bind.Bind([]string{*typ}, []string{string(data)}, []string{""}, nil, *pkg, bind.LangGo, nil, nil)
+	if err != nil {
+		log.Fatalf("%s: %v", *abiPath, err)
+	}
+	if err := os.WriteFile(*out, []byte(code), 0o644); err != nil {
+		log.Fatal(err)
+	}
+}
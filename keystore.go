@@ -0,0 +1,57 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// keystoreAddresses lists the accounts of a geth keystore directory. Only
+// each file's address field is decoded; the encrypted key is never looked
+// at, so no password is needed. Files that are not keystore JSON, such as
+// editor backups, are skipped with a warning.
+func keystoreAddresses(dir string) ([]string, error) {
+	if strings.HasPrefix(dir, "~/") {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return nil, err
+		}
+		dir = filepath.Join(home, dir[2:])
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var addrs []string
+	seen := map[common.Address]bool{}
+	for _, e := range entries {
+		if e.IsDir() || strings.HasPrefix(e.Name(), ".") {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(dir, e.Name()))
+		if err != nil {
+			return nil, err
+		}
+		var key struct {
+			Address string `json:"address"`
+		}
+		if err := json.Unmarshal(data, &key); err != nil || !common.IsHexAddress(key.Address) {
+			log.Printf("keystore: skipping %s: not a key file", e.Name())
+			continue
+		}
+		addr := common.HexToAddress(key.Address)
+		if !seen[addr] {
+			seen[addr] = true
+			addrs = append(addrs, addr.Hex())
+		}
+	}
+	if len(addrs) == 0 {
+		return nil, fmt.Errorf("no key files in %s", dir)
+	}
+	return addrs, nil
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// DefiLlama's current-price endpoint accepts a comma-separated list of
+// chain:address coin ids, so every token without an oracle price is looked up
+// in a single request; the historical one takes a Unix time first.
+const (
+	llamaPricesURL     = "https://coins.llama.fi/prices/current/"
+	llamaHistoricalURL = "https://coins.llama.fi/prices/historical/"
+)
+
+// llamaChains maps chain IDs to DefiLlama's chain names.
+var llamaChains = map[uint64]string{
+	1:     "ethereum",
+	10:    "optimism",
+	56:    "bsc",
+	137:   "polygon",
+	8453:  "base",
+	42161: "arbitrum",
+	43114: "avax",
+}
+
+// llamaKey returns the DefiLlama coin id of a token on the given chain.
+// DefiLlama lists native coins under the zero address, except ETH, which is
+// the same coin on every chain that uses it.
+func llamaKey(chain string, tf tokenFeed) string {
+	if tf.TokenAddr == (common.Address{}) && tf.Symbol == "ETH" {
+		return "coingecko:ethereum"
+	}
+	return chain + ":" + strings.ToLower(tf.TokenAddr.Hex())
+}
+
+// llamaPrices returns USD prices keyed by coin id, current or, for a
+// non-zero at, as of then. Coins DefiLlama does not know are simply absent
+// from the result.
+func llamaPrices(ctx context.Context, at time.Time, keys []string) (map[string]*big.Float, error) {
+	if len(keys) == 0 {
+		return nil, nil
+	}
+	var resp struct {
+		Coins map[string]struct {
+			Price float64 `json:"price"`
+		} `json:"coins"`
+	}
+	url := llamaPricesURL
+	if !at.IsZero() {
+		url = fmt.Sprintf("%s%d/", llamaHistoricalURL, at.Unix())
+	}
+	get := func() (any, error) { return nil, getJSON(ctx, url+strings.Join(keys, ","), &resp) }
+	if _, err := guard(ctx, circuits, "defillama", get); err != nil {
+		return nil, fmt.Errorf("defillama: %w", err)
+	}
+	out := make(map[string]*big.Float, len(resp.Coins))
+	for k, c := range resp.Coins {
+		out[k] = big.NewFloat(c.Price)
+	}
+	return out, nil
+}
@@ -2,43 +2,30 @@ package main
 
 import (
 	"context"
+	"flag"
 	"fmt"
+	"io"
 	"log"
 	"math"
 	"math/big"
 	"os"
+	"os/signal"
+	"slices"
 	"strings"
+	"sync"
+	"syscall"
+	"text/template"
+	"time"
 
 	"github.com/ethereum/go-ethereum"
 	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
 	"github.com/ethereum/go-ethereum/common"
-	"github.com/ethereum/go-ethereum/ethclient"
-)
 
-var erc20ABI = mustABI(`[
-  {"constant":true,"inputs":[{"name":"owner","type":"address"}],"name":"balanceOf","outputs":[{"name":"","type":"uint256"}],"type":"function"},
-  {"constant":true,"inputs":[],"name":"decimals","outputs":[{"name":"","type":"uint8"}],"type":"function"}
-]`)
-
-var feedABI = mustABI(`[
-  {"inputs":[],"name":"decimals","outputs":[{"type":"uint8"}],"stateMutability":"view","type":"function"},
-  {"inputs":[],"name":"latestRoundData","outputs":[
-     {"type":"uint80"},{"type":"int256"},{"type":"uint256"},{"type":"uint256"},{"type":"uint80"}
-  ],"stateMutability":"view","type":"function"}
-]`)
-
-var tokenFeeds = []struct {
-	Symbol    string
-	TokenAddr common.Address
-	FeedAddr  common.Address
-	Decimals  int
-}{
-	{"ETH", common.Address{}, common.HexToAddress("0x5f4ec3df9cbd43714fe2740f5e3616155c5b8419"), 18},
-	{"WETH", common.HexToAddress("0xC02aaA39b223FE8D0A0E5C4F27eAD9083C756Cc2"), common.HexToAddress("0x5f4ec3df9cbd43714fe2740f5e3616155c5b8419"), 18},
-	{"USDC", common.HexToAddress("0xA0b86991c6218b36c1d19d4a2e9eb0ce3606eb48"), common.HexToAddress("0x8fFfFfd4AfB6115b954Bd326cbe7b4Ba576818f6"), 6},
-	{"DAI", common.HexToAddress("0x6B175474E89094C44Da98b954EedeAC495271d0F"), common.HexToAddress("0xAed0c38402a5d19df6E4c03F4E2DceD6e29c1ee9"), 18},
-	{"LINK", common.HexToAddress("0x514910771AF9Ca656af840dff83E8264EcF986CA"), common.HexToAddress("0x2c1d072e956AFFC0D435Cb7AC38EF18d24d9127c"), 18},
-}
+	"Test2/bindings"
+
+	"Test2/source"
+)
 
 func mustABI(jsonStr string) abi.ABI {
 	a, err := abi.JSON(strings.NewReader(jsonStr))
@@ -48,103 +35,685 @@ func mustABI(jsonStr string) abi.ABI {
 	return a
 }
 
+// holding is one line of the report: an amount of a token and, once priced,
+// its USD price. note carries extra context such as where the funds sit.
+// A holding whose balance or price could not be fetched has err set instead.
+// raw is the balance in base units for plain wallet balances, the ones a
+// transfer can move; it is nil for protocol positions.
+type holding struct {
+	tf    tokenFeed
+	amt   *big.Float
+	raw   *big.Int
+	price *big.Float
+	note  string
+	// id tells the position apart from others of its chain and symbol
+	// when the note does not stay the same from run to run.
+	id    string
+	chain string
+	err   error
+	// source names what priced the holding, when the pricer did, and
+	// feeds are the Chainlink answers that went into its price.
+	source string
+	feeds  []*feedRead
+	// deviations are the other sources' answers that disagreed with price,
+	// when cross-checking is on.
+	deviations []priceDeviation
+}
+
+func (h *holding) usd() *big.Float {
+	return new(big.Float).Mul(h.amt, h.price)
+}
+
 func main() {
-	if len(os.Args) != 2 {
-		log.Fatalf("Usage: %s <ethereum_address>", os.Args[0])
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "tokens":
+			tokensCmd(os.Args[2:])
+			return
+		case "diff":
+			diffCmd(os.Args[2:])
+			return
+		case "schema":
+			schemaCmd(os.Args[2:])
+			return
+		case "bench":
+			benchCmd(os.Args[2:])
+			return
+		case "doctor", "healthcheck":
+			doctorCmd(os.Args[2:])
+			return
+		case "completion":
+			completionCmd(os.Args[2:])
+			return
+		case "update":
+			updateCmd(os.Args[2:])
+			return
+		case "sweep":
+			sweepCmd(os.Args[2:])
+			return
+		case "stats":
+			statsCmd(os.Args[2:])
+			return
+		case "backfill":
+			backfillCmd(os.Args[2:])
+			return
+		case "serve":
+			serveCmd(os.Args[2:])
+			return
+		case "cache":
+			cacheCmd(os.Args[2:])
+			return
+		case "daemon":
+			daemonCmd(os.Args[2:])
+			return
+		case "whales":
+			whalesCmd(os.Args[2:])
+			return
+		case "adapters":
+			adaptersCmd(os.Args[2:])
+			return
+		case "audit":
+			auditCmd(os.Args[2:])
+			return
+		case "sanctions":
+			sanctionsCmd(os.Args[2:])
+			return
+		case "balances":
+			balancesCmd(os.Args[2:])
+			return
+		case "version", "-version", "--version":
+			versionCmd(os.Args[2:])
+			return
+		}
+	}
+	flag.String("config", "config.json", "path to the JSON config file")
+	rpc := flag.String("rpc", "", "RPC endpoint of the chain valued by default (default: ETH_RPC_URL)")
+	var opts options
+	flag.StringVar(&opts.Validators, "validators", "", "comma-separated beacon validator indices to include")
+	flag.StringVar(&opts.Withdrawal, "withdrawal-address", "", "include validators withdrawing to this address")
+	flag.StringVar(&opts.PendleMarkets, "pendle-markets", "", "comma-separated Pendle market addresses to value PT/YT in")
+	flag.BoolVar(&opts.Convex, "convex", false, "scan Convex pools for staked Curve LP tokens (one call per pool)")
+	flag.StringVar(&opts.Adapters, "adapters", "", "comma-separated protocol adapters to turn on or, prefixed with -, off, e.g. convex,-gmx; see `portfolio adapters`")
+	chainList := flag.String("chains", "", "comma-separated chains to value the wallet on, each using RPC_URL_<CHAIN> or its config entry (default: the chain -rpc points at)")
+	flag.StringVar(&opts.Balancer, "balancer", "", "comma-separated Balancer pool or gauge addresses to value LP shares in")
+	flag.StringVar(&opts.Only, "only", "", "comma-separated symbols to value, e.g. ETH,USDC; everything else is left out")
+	flag.StringVar(&opts.Exclude, "exclude", "", "comma-separated symbols to leave out, e.g. LINK")
+	opts.Prices = priceOverrides{}
+	flag.Var(opts.Prices, "price", "override or supply a USD price, e.g. LINK=14.25 (repeatable or comma-separated)")
+	pricesFile := flag.String("prices-file", "", "CSV or JSON file of fallback USD prices by symbol or address, used when no source has one")
+	format := flag.String("format", "text", "output format: text, json or template")
+	rowTemplate := flag.String("template", "", "Go template rendered per position with -format template, e.g. '{{.Symbol}} {{.USD}}'")
+	headerTemplate := flag.String("header-template", "", "Go template rendered once before the rows with -format template, e.g. '{{range .Blocks}}{{.Chain}} block {{.Number}} {{end}}'")
+	summaryTemplate := flag.String("summary-template", "", "Go template rendered once after the rows with -format template, e.g. 'TOTAL {{.TotalUSD}}'")
+	hideBelow := flag.Float64("hide-below", 0, "leave positions worth less than this many USD out of text and template output; they still count in the total")
+	strict := flag.Bool("strict", false, "fail with a non-zero exit if any balance, price or source lookup fails, or price sources disagree beyond price_sources max_deviation_pct")
+	save := flag.Bool("save", false, "store the result as a snapshot for later diffs")
+	outPath := flag.String("out", "", "write the report to this file, replacing it atomically when the run completes")
+	addressesFile := flag.String("addresses-file", "", "read newline-separated addresses from this file (- for stdin)")
+	tag := flag.String("tag", "", "value only wallets with this address book tag; all of them when no wallets are given")
+	keystoreDir := flag.String("keystore", "", "also value every account of this geth keystore directory, e.g. ~/.ethereum/keystore (only addresses are read)")
+	showProgress := flag.Bool("progress", isTerminal(os.Stderr), "show a status line on stderr while the run is in progress; on by default when stderr is a terminal")
+	watch := flag.Bool("watch", false, "after the report, follow Transfer events of the wallets over each chain's websocket endpoint and print them with their USD value until interrupted")
+	mempool := flag.Bool("mempool", false, "after the report, follow pending transactions from or to the wallets over each chain's websocket endpoint until interrupted")
+	largeUSD := flag.Float64("large-usd", 10_000, "with -mempool, flag pending outgoing transfers worth at least this many USD")
+	gas := flag.Bool("gas", false, "show each chain's base and priority fee")
+	sweepTo := flag.String("sweep-to", "", "with -gas, also estimate what sending each wallet balance to this address would cost")
+	whatIfPath := flag.String("whatif", "", "on a local anvil/hardhat fork, apply the balances, transactions and blocks in this JSON file first, and revert them after the run")
+	record := flag.String("record", "", "record every JSON-RPC exchange of the run to this cassette file")
+	replay := flag.String("replay", "", "answer JSON-RPC calls from this cassette file instead of a node")
+	tui := flag.Bool("tui", false, "show the wallets in an interactive terminal dashboard, revalued every -refresh, instead of printing a report")
+	refresh := flag.Duration("refresh", time.Minute, "with -tui, how often to revalue the wallets")
+	useDaemon := flag.Bool("daemon", false, "ask the running `portfolio daemon` for the valuations instead of the nodes; the daemon's own flags decide what is valued")
+	safeDepth := flag.Int("safe-depth", 0, "also value the Safes linked to each wallet by ownership, up to this many links away: Safe owners of a Safe, and Safes among the wallets and address book a wallet is an owner of")
+	inBTC := flag.Bool("btc", false, "also express the total in BTC at the BTC/USD feed")
+	quoteArg := flag.String("quote", "USD", "express values in this token instead of USD: a registry symbol such as USDC or WBTC, or a token address")
+	export := flag.String("export", "", "send the reports to these comma-separated services after the run, each configured in its config section: "+strings.Join(exporterNames(), ", "))
+	exportEvery := flag.Int("export-every", 100, "with -export, send the reports every this many wallets instead of holding the whole run in memory; 0 sends them all after the run")
+	socket := flag.String("socket", defaultSocket(), "with -daemon, the daemon's unix socket")
+	screen := flag.Bool("screen", false, "check the wallets and the counterparties of their registry token transfers against the sanctions list and flag matches; see `portfolio sanctions`")
+	screenSince := flag.String("screen-since", "30d", "with -screen, how far back to look at transfers, e.g. 90d")
+	pinBlock := flag.String("pin-block", "latest", "read every balance and price of the run at one block per chain: latest resolves each chain's head once at startup, a number values one chain at that past block, off reads the latest state at every call")
+	auditPath := flag.String("audit", "", "write an evidence bundle to this file: every node call behind the reports, with block, calldata and raw answer, each chain pinned to its head block; check it with `portfolio audit verify`")
+	blockList := flag.String("blocks", "", "value the wallets at each of these past blocks of one chain instead of now: numbers and first..last:step ranges, e.g. 18000000..19000000:50000")
+	verbose := flag.Bool("verbose", false, "after each text report, list the Chainlink feed, round, answer age and decimals behind every feed price")
+	concurrencyFlag(flag.CommandLine)
+	budget := flag.Duration("budget", 0, "stop every lookup after this much time in all, e.g. 10s, and report what finished, marked as partial; 0 for no limit")
+	if len(os.Args) > 1 && os.Args[1] == completeArg {
+		completeCmd(flag.CommandLine, os.Args[2:])
+		return
+	}
+	cfg, err := parseSettings(flag.CommandLine, os.Args[1:])
+	if err != nil {
+		log.Fatal(err)
+	}
+	wallets := flag.Args()
+	if len(wallets) == 0 && *keystoreDir == "" {
+		if wallets = settingAddresses(cfg); *tag != "" && len(wallets) == 0 {
+			wallets = book.tagged(*tag)
+		}
+	}
+	if *keystoreDir != "" {
+		accounts, err := keystoreAddresses(*keystoreDir)
+		if err != nil {
+			log.Fatalf("keystore: %v", err)
+		}
+		wallets = append(wallets, accounts...)
+	}
+	if len(wallets) == 0 && *addressesFile == "" {
+		log.Fatalf("Usage: %s [flags] <ethereum_address>...", os.Args[0])
+	}
+	batch := len(wallets) > 1 || *addressesFile != ""
+	if opts.Withdrawal != "" && !common.IsHexAddress(opts.Withdrawal) {
+		log.Fatalf("-withdrawal-address %q is not an address", opts.Withdrawal)
+	}
+	if (batch || *safeDepth > 0) && opts.Validators != "" && opts.Withdrawal == "" {
+		log.Fatal("-validators with several wallets needs -withdrawal-address to say whose validators they are")
+	}
+	if *pricesFile != "" {
+		if opts.PriceFile, err = loadPriceFile(*pricesFile); err != nil {
+			log.Fatalf("prices file: %v", err)
+		}
 	}
-	wallet := common.HexToAddress(os.Args[1])
 
-	rpc := os.Getenv("ETH_RPC_URL")
-	if rpc == "" {
-		log.Fatal("Please set ETH_RPC_URL env var")
+	if *export != "" {
+		if err := checkExporters(*export); err != nil {
+			log.Fatalf("-export: %v", err)
+		}
+	}
+	if *exportEvery < 0 {
+		log.Fatalf("-export-every %d is negative", *exportEvery)
+	}
+	if opts.Adapters != "" {
+		if err := checkAdapters(opts.Adapters); err != nil {
+			log.Fatalf("-adapters: %v", err)
+		}
+	}
+	screenAge, err := parseSince(*screenSince)
+	if err != nil {
+		log.Fatalf("-screen-since: %v", err)
+	}
+	if *screen {
+		list, from, err := loadSanctions()
+		if err != nil {
+			log.Fatalf("-screen: %v", err)
+		}
+		screening = list
+		log.Printf("screening against %s (%d addresses)", from, len(list))
 	}
 
-	client, err := ethclient.Dial(rpc)
+	var header, row, summary *template.Template
+	if *format == "template" {
+		if *rowTemplate == "" && *summaryTemplate == "" {
+			log.Fatal("-format template needs -template and/or -summary-template")
+		}
+		if header, err = parseTemplate("header-template", *headerTemplate); err != nil {
+			log.Fatal(err)
+		}
+		if row, err = parseTemplate("template", *rowTemplate); err != nil {
+			log.Fatal(err)
+		}
+		if summary, err = parseTemplate("summary-template", *summaryTemplate); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	switch {
+	case *record != "" && *replay != "":
+		log.Fatal("-record and -replay are mutually exclusive")
+	case *record != "":
+		rpcCassette, err = recordCassette(*record)
+	case *replay != "":
+		rpcCassette, err = replayCassette(*replay)
+	}
 	if err != nil {
-		log.Fatalf("RPC dial error: %v", err)
+		log.Fatalf("cassette: %v", err)
 	}
+	defer rpcCassette.Close()
+
 	ctx := context.Background()
 
-	totalUSD := big.NewFloat(0)
+	if *blockList != "" && (*useDaemon || *whatIfPath != "" || *watch || *mempool || *gas || *sweepTo != "" || *tui || *save || *export != "" || *safeDepth > 0 || *inBTC || !quoteIsUSD(*quoteArg) || *format == "template" || *screen) {
+		log.Fatal("-blocks cannot be combined with -daemon, -whatif, -watch, -mempool, -gas, -sweep-to, -tui, -save, -export, -safe-depth, -btc, -quote, -screen or -format template")
+	}
+
+	if *auditPath != "" && (*useDaemon || *blockList != "" || *tui || *pinBlock == "off") {
+		log.Fatal("-audit cannot be combined with -daemon, -blocks, -tui or -pin-block off")
+	}
+	if *pinBlock != "latest" && (*useDaemon || *blockList != "" || *tui) {
+		log.Fatal("-pin-block cannot be combined with -daemon, -blocks or -tui")
+	}
 
-	for _, tf := range tokenFeeds {
-		var balRaw *big.Int
-		if tf.Symbol == "ETH" {
-			balRaw, err = client.BalanceAt(ctx, wallet, nil)
-		} else {
-			balRaw, err = erc20Balance(ctx, client, tf.TokenAddr, wallet)
+	var daemon *daemonClient
+	var conns []*chainConn
+	if *useDaemon {
+		if *whatIfPath != "" || *watch || *mempool || *gas || *sweepTo != "" || *record != "" || *replay != "" || *tui || *inBTC || !quoteIsUSD(*quoteArg) {
+			log.Fatal("-daemon cannot be combined with -whatif, -watch, -mempool, -gas, -sweep-to, -record, -replay, -tui, -btc or -quote")
+		}
+		daemon = newDaemonClient(*socket)
+	} else {
+		conns = openChains(ctx, *rpc, *chainList, cfg)
+	}
+	defer runExitHooks()
+	if *whatIfPath != "" {
+		w, err := loadWhatIf(*whatIfPath)
+		if err != nil {
+			fatalf("whatif: %v", err)
 		}
-		if err != nil || balRaw.Sign() == 0 {
-			continue
+		for _, c := range conns {
+			revert, err := c.applyWhatIf(ctx, w)
+			if err != nil {
+				fatalf("whatif: %v", err)
+			}
+			atExit(func() {
+				if err := revert(); err != nil {
+					log.Printf("whatif: %s: revert: %v", chainName(c.id), err)
+				}
+			})
+		}
+	}
+	// The TUI keeps revaluing and -blocks pins each block itself.
+	if !*tui && *blockList == "" {
+		if err := pinChains(ctx, conns, *pinBlock); err != nil {
+			fatalf("-pin-block: %v", err)
 		}
+	}
+	var trail *auditTrail
+	if *auditPath != "" {
+		trail = &auditTrail{}
+		for _, c := range conns {
+			trail.record(c)
+		}
+	}
 
-		price, err := feedPrice(ctx, client, tf.FeedAddr)
+	if *tui {
+		var tuiWallets []common.Address
+		err := forEachAddress(ctx, wallets, *addressesFile, func(wallet common.Address) {
+			if *tag == "" || book.hasTag(wallet, *tag) {
+				tuiWallets = append(tuiWallets, wallet)
+			}
+		})
 		if err != nil {
-			continue
+			fatalf("%v", err)
+		}
+		if len(tuiWallets) == 0 {
+			fatalf("tui: no wallets to show")
+		}
+		runCtx, stop := signal.NotifyContext(ctx, syscall.SIGTERM)
+		defer stop()
+		if err := runTUI(runCtx, conns, tuiWallets, cfg, opts, *refresh, *addressesFile == "-"); err != nil {
+			fatalf("tui: %v", err)
+		}
+		return
+	}
+
+	var out io.Writer = os.Stdout
+	var outFile *atomicFile
+	if *outPath != "" {
+		if outFile, err = createAtomic(*outPath); err != nil {
+			fatalf("out: %v", err)
+		}
+		// Dropping the temp file is a no-op once it is committed.
+		atExit(outFile.Abort)
+		out = outFile
+	}
+
+	// The first SIGINT or SIGTERM cancels the lookups in flight and prints
+	// what was fetched so far, marked as partial; a second one kills. Running
+	// out of -budget does the same.
+	sigCtx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-sigCtx.Done()
+		stop()
+	}()
+	runCtx := sigCtx
+	if *budget > 0 {
+		var cancel context.CancelFunc
+		runCtx, cancel = context.WithTimeout(sigCtx, *budget)
+		defer cancel()
+	}
+
+	if *showProgress {
+		runProgress = startProgress(os.Stderr)
+		log.SetOutput(runProgress)
+	}
+	var sweepTarget *common.Address
+	if *sweepTo != "" {
+		addr, ok := walletArg(*sweepTo)
+		if !ok {
+			fatalf("-sweep-to: bad address %q", *sweepTo)
+		}
+		sweepTarget, *gas = &addr, true
+	}
+
+	var btcUSD *big.Float
+	if *inBTC {
+		if btcUSD, err = btcPrice(runCtx, conns, cfg, opts); err != nil {
+			log.Printf("-btc: %v", err)
+		}
+	}
+
+	if !quoteIsUSD(*quoteArg) {
+		if reportQuote, err = priceQuote(runCtx, conns, cfg, opts, *quoteArg); err != nil {
+			fatalf("-quote: %v", err)
 		}
+	}
 
-		amt := new(big.Float).Quo(new(big.Float).SetInt(balRaw),
-			big.NewFloat(math.Pow10(tf.Decimals)))
-		usd := new(big.Float).Mul(amt, price)
+	var safeGraphs []*safeGraph
+	if *safeDepth > 0 {
+		tracked := book.addresses()
+		for _, w := range wallets {
+			if addr, ok := walletArg(w); ok && !slices.Contains(tracked, addr) {
+				tracked = append(tracked, addr)
+			}
+		}
+		for _, c := range conns {
+			safeGraphs = append(safeGraphs, newSafeGraph(c.client, tracked))
+		}
+	}
 
-		fmt.Printf("%-6s %12s => $%s\n",
-			tf.Symbol,
-			amt.Text('f', 6),
-			usd.Text('f', 2),
-		)
-		totalUSD.Add(totalUSD, usd)
+	if *blockList != "" {
+		runSeries(runCtx, out, conns, wallets, *addressesFile, *tag, *blockList, *format == "json", batch, cfg, opts)
+		runProgress.Stop()
+		if err := outFile.Commit(); err != nil {
+			fatalf("out: %v", err)
+		}
+		return
 	}
 
-	fmt.Printf("TOTAL %12s => $%s\n", "",
-		totalUSD.Text('f', 2))
+	// Only what comes after the loop is kept across wallets: the addresses
+	// for -watch and -mempool, the reports for -audit, and at most
+	// -export-every reports for -export. Everything else goes to out as
+	// each wallet is valued, so a long -addresses-file scan runs in flat
+	// memory.
+	var valued []common.Address
+	var audited []*report
+	exported := &exportBuffer{ctx: ctx, names: *export, cfg: cfg, size: *exportEvery}
+	err = forEachAddress(runCtx, wallets, *addressesFile, func(wallet common.Address) {
+		if *tag != "" && !book.hasTag(wallet, *tag) {
+			return
+		}
+		if *watch || *mempool {
+			valued = append(valued, wallet)
+		}
+		runProgress.wallet()
+		var held []*holding
+		var accounts []reportAccount
+		blocks := pinnedBlocks(conns)
+		if daemon != nil {
+			rep, err := daemon.report(runCtx, wallet)
+			switch {
+			case err == nil:
+				held, accounts, blocks = reportHoldings(rep), rep.Accounts, rep.Blocks
+			case runCtx.Err() == nil:
+				fatalf("daemon: %v", err)
+			}
+		}
+		for i, c := range conns {
+			held = append(held, c.value(runCtx, wallet, cfg, opts)...)
+			if safeGraphs != nil {
+				held = append(held, c.safeHoldings(runCtx, safeGraphs[i], wallet, *safeDepth, cfg, opts)...)
+			}
+			if acc, err := walletAccount(runCtx, c.client, chainName(c.id), wallet, c.block, c.historical); err != nil {
+				log.Printf("%s: account: %v", chainName(c.id), err)
+			} else {
+				accounts = append(accounts, acc)
+			}
+		}
+		if len(conns) > 0 {
+			held = append(held, conns[0].offChainHoldings(runCtx, wallet, cfg, opts)...)
+		}
+		var panels []reportGas
+		for _, c := range conns {
+			if !*gas {
+				continue
+			}
+			if g, err := c.gas(runCtx, wallet, sweepTarget, held, cfg, opts); err != nil {
+				log.Printf("%s: gas: %v", chainName(c.id), err)
+			} else {
+				panels = append(panels, g)
+			}
+		}
+		partial := runCtx.Err() != nil
+		stopped := "interrupted"
+		if sigCtx.Err() == nil {
+			stopped = "out of -budget"
+		}
+		runProgress.clear()
+
+		if *strict {
+			if failed := failures(held); len(failed) > 0 {
+				reportFailures(os.Stderr, wallet, failed)
+				exit(1)
+			}
+		}
+
+		rep := newReport(wallet.Hex(), held)
+		rep.Accounts = accounts
+		rep.Gas = panels
+		rep.Drift = computeDrift(held, cfg.Targets)
+		rep.Partial = partial
+		rep.Blocks = blocks
+		if *screen {
+			rep.Sanctions = screenWallet(runCtx, conns, wallet, screenAge)
+		}
+		if btcUSD != nil {
+			rep.BTCUSD, _ = btcUSD.Float64()
+			rep.TotalBTC = rep.TotalUSD / rep.BTCUSD
+		}
+		reportQuote.apply(rep)
+		switch {
+		case *format == "json":
+			if err := printJSON(out, rep, batch); err != nil {
+				fatalf("%v", err)
+			}
+		case *format == "template":
+			if err := printTemplate(out, rep, header, row, summary, *hideBelow); err != nil {
+				fatalf("template: %v", err)
+			}
+		case *chainList != "":
+			printHeader(out, batch, wallet)
+			printBlocks(out, rep.Blocks)
+			printAccounts(out, accounts)
+			printRollup(out, held, *hideBelow)
+			if *verbose {
+				printFeeds(out, rep.Positions, rep.Time)
+			}
+			printTotalBTC(out, rep)
+			printDrift(out, rep.Drift)
+			printGas(out, panels)
+			printSanctions(out, rep.Sanctions)
+			printDegraded(out, rep.Degraded)
+			printPartial(out, partial, stopped)
+		default:
+			printHeader(out, batch, wallet)
+			printBlocks(out, rep.Blocks)
+			printAccounts(out, accounts)
+			printHoldings(out, held, *hideBelow)
+			if *verbose {
+				printFeeds(out, rep.Positions, rep.Time)
+			}
+			printTotalBTC(out, rep)
+			printDrift(out, rep.Drift)
+			printGas(out, panels)
+			printSanctions(out, rep.Sanctions)
+			printDegraded(out, rep.Degraded)
+			printPartial(out, partial, stopped)
+		}
+
+		if !partial {
+			exported.add(rep)
+		}
+		if trail != nil {
+			audited = append(audited, rep)
+		}
+
+		if *save && partial {
+			log.Printf("%s: not saving a partial snapshot", stopped)
+		} else if *save {
+			path, err := saveSnapshot(rep)
+			if err != nil {
+				fatalf("snapshot: %v", err)
+			}
+			log.Printf("snapshot saved to %s", path)
+			if n, err := pruneSnapshotsIn(snapshotDir(), cfg.Retention, rep.Time); err != nil {
+				log.Printf("snapshot retention: %v", err)
+			} else if n > 0 {
+				log.Printf("retention: pruned %d older snapshots", n)
+			}
+		}
+	})
+	runProgress.Stop()
+	if err != nil {
+		fatalf("%v", err)
+	}
+	exported.flush()
+	if trail != nil {
+		if err := trail.write(*auditPath, audited); err != nil {
+			fatalf("-audit: %v", err)
+		}
+		log.Printf("audit bundle written to %s", *auditPath)
+	}
+	if (*watch || *mempool) && runCtx.Err() == nil && len(valued) > 0 {
+		w := &lineWriter{w: os.Stdout}
+		var wg sync.WaitGroup
+		if *watch {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				watchTransfers(runCtx, w, conns, valued, cfg, opts, *hideBelow)
+			}()
+		}
+		if *mempool {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				watchMempool(runCtx, w, conns, valued, cfg, opts, *hideBelow, *largeUSD)
+			}()
+		}
+		wg.Wait()
+	}
+	if err := outFile.Commit(); err != nil {
+		fatalf("out: %v", err)
+	}
+}
+
+// exitHooks undo, newest first, what a run changed outside the process,
+// such as the what-if state of a fork or an uncommitted -out temp file. Deferred calls do not run on
+// os.Exit, so once a hook is set the run leaves through exit or fatalf.
+var exitHooks []func()
+
+func atExit(f func()) {
+	exitHooks = append(exitHooks, f)
+}
+
+func runExitHooks() {
+	for i := len(exitHooks) - 1; i >= 0; i-- {
+		exitHooks[i]()
+	}
+	exitHooks = nil
+}
+
+func exit(code int) {
+	runExitHooks()
+	os.Exit(code)
 }
 
-func feedPrice(ctx context.Context, client *ethclient.Client, feedAddr common.Address) (*big.Float, error) {
-	bz, err := feedABI.Pack("decimals")
+func fatalf(format string, v ...any) {
+	log.Printf(format, v...)
+	exit(1)
+}
+
+// parseTemplate returns nil for an empty template text.
+func parseTemplate(name, text string) (*template.Template, error) {
+	if text == "" {
+		return nil, nil
+	}
+	t, err := template.New(name).Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("-%s: %w", name, err)
+	}
+	return t, nil
+}
+
+// tokenAmount converts a raw integer balance into whole tokens.
+func tokenAmount(raw *big.Int, decimals int) *big.Float {
+	return new(big.Float).Quo(new(big.Float).SetInt(raw),
+		big.NewFloat(math.Pow10(decimals)))
+}
+
+func feedPrice(ctx context.Context, client chainClient, feedAddr common.Address) (*big.Float, error) {
+	r, err := readFeed(ctx, client, feedAddr)
 	if err != nil {
 		return nil, err
 	}
-	out, err := client.CallContract(ctx, ethereum.CallMsg{To: &feedAddr, Data: bz}, nil)
+	return r.answer, nil
+}
+
+// feedRead is a Chainlink feed's latest answer and the round it is from.
+type feedRead struct {
+	feed      common.Address
+	round     *big.Int
+	answer    *big.Float
+	decimals  uint8
+	updatedAt time.Time
+}
+
+func readFeed(ctx context.Context, client chainClient, feedAddr common.Address) (*feedRead, error) {
+	feed, err := bindings.NewAggregatorCaller(feedAddr, client)
 	if err != nil {
 		return nil, err
 	}
-	dec := new(big.Int).SetBytes(out)
-	bz, err = feedABI.Pack("latestRoundData")
+	opts := &bind.CallOpts{Context: ctx}
+	dec, err := feed.Decimals(opts)
 	if err != nil {
 		return nil, err
 	}
-	out2, err := client.CallContract(ctx, ethereum.CallMsg{To: &feedAddr, Data: bz}, nil)
+	round, err := feed.LatestRoundData(opts)
 	if err != nil {
 		return nil, err
 	}
-	_, answerRaw, _, _, _ := unpackLatest(out2)
-	price := new(big.Float).Quo(
-		new(big.Float).SetInt(answerRaw),
-		big.NewFloat(math.Pow10(int(dec.Int64()))),
-	)
-	return price, nil
+	if round.UpdatedAt.Sign() == 0 {
+		return nil, fmt.Errorf("%w: round %s never completed", source.ErrStaleFeed, round.RoundId)
+	}
+	return &feedRead{
+		feed:      feedAddr,
+		round:     round.RoundId,
+		answer:    tokenAmount(round.Answer, int(dec)),
+		decimals:  dec,
+		updatedAt: time.Unix(round.UpdatedAt.Int64(), 0).UTC(),
+	}, nil
 }
 
-func unpackLatest(data []byte) (roundId *big.Int, answer *big.Int, startedAt, updatedAt, answeredInRound *big.Int) {
-	vs, err := feedABI.Unpack("latestRoundData", data)
+func erc20Balance(ctx context.Context, client chainClient, tokenAddr, user common.Address) (*big.Int, error) {
+	token, err := bindings.NewERC20Caller(tokenAddr, client)
 	if err != nil {
-		log.Fatalf("unpack latestRoundData: %v", err)
+		return nil, err
 	}
-	return vs[0].(*big.Int), vs[1].(*big.Int), vs[2].(*big.Int), vs[3].(*big.Int), vs[4].(*big.Int)
+	return token.BalanceOf(&bind.CallOpts{Context: ctx}, user)
 }
 
-func erc20Balance(ctx context.Context, client *ethclient.Client, tokenAddr, user common.Address) (*big.Int, error) {
-	bz, err := erc20ABI.Pack("balanceOf", user)
+// chainClient is the part of the node API the valuation code uses.
+// *ethclient.Client implements it; tests run against a simulated backend.
+type chainClient interface {
+	bind.ContractCaller
+	ethereum.LogFilterer
+	BalanceAt(ctx context.Context, account common.Address, blockNumber *big.Int) (*big.Int, error)
+}
+
+// callView packs a call to a view method, runs it against the latest block
+// and returns the unpacked outputs.
+func callView(ctx context.Context, client chainClient, to common.Address, contract abi.ABI, method string, args ...any) ([]any, error) {
+	bz, err := contract.Pack(method, args...)
 	if err != nil {
 		return nil, err
 	}
-	out, err := client.CallContract(ctx, ethereum.CallMsg{To: &tokenAddr, Data: bz}, nil)
+	out, err := client.CallContract(ctx, ethereum.CallMsg{To: &to, Data: bz}, nil)
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("%s: %w", method, err)
 	}
-	vs, err := erc20ABI.Unpack("balanceOf", out)
+	vs, err := contract.Unpack(method, out)
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("%s: %w", method, err)
 	}
-	return vs[0].(*big.Int), nil
+	return vs, nil
 }
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"context"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core"
+)
+
+var (
+	testWallet = common.HexToAddress("0x00000000000000000000000000000000000A11CE")
+	testToken  = common.HexToAddress("0x000000000000000000000000000000000000700C")
+	testFeed   = common.HexToAddress("0x000000000000000000000000000000000000FEED")
+)
+
+func TestERC20Balance(t *testing.T) {
+	sim := newSim(t, core.GenesisAlloc{
+		testToken: mockToken(6, map[common.Address]*big.Int{testWallet: big.NewInt(1_234_567)}),
+	})
+	ctx := context.Background()
+
+	bal, err := erc20Balance(ctx, sim, testToken, testWallet)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bal.Int64() != 1_234_567 {
+		t.Errorf("balance = %s, want 1234567", bal)
+	}
+	bal, err = erc20Balance(ctx, sim, testToken, common.HexToAddress("0xB0B"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bal.Sign() != 0 {
+		t.Errorf("balance of stranger = %s, want 0", bal)
+	}
+}
+
+func TestCallViewErrors(t *testing.T) {
+	sim := newSim(t, core.GenesisAlloc{
+		testToken: mockToken(6, nil),
+	})
+	ctx := context.Background()
+
+	// Not a method of the mock: it reverts.
+	if _, err := callView(ctx, sim, testToken, erc20ABI, "symbol"); err == nil {
+		t.Error("symbol on a contract without it: want error")
+	}
+	// No code at all: the empty answer does not unpack.
+	if _, err := callView(ctx, sim, testFeed, erc20ABI, "decimals"); err == nil {
+		t.Error("decimals on an empty account: want error")
+	}
+}
+
+func TestFeedPrice(t *testing.T) {
+	sim := newSim(t, core.GenesisAlloc{
+		testFeed: mockFeed(8, scaled(t, "3012.45", 8), time.Now().Unix()),
+	})
+
+	price, err := feedPrice(context.Background(), sim, testFeed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !floatEq(price, 3012.45) {
+		t.Errorf("price = %s, want 3012.45", price.Text('f', 8))
+	}
+}
+
+func TestRunExitHooks(t *testing.T) {
+	var order []int
+	atExit(func() { order = append(order, 1) })
+	atExit(func() { order = append(order, 2) })
+	runExitHooks()
+	runExitHooks()
+	if len(order) != 2 || order[0] != 2 || order[1] != 1 {
+		t.Errorf("hooks ran as %v, want [2 1] once", order)
+	}
+}
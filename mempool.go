@@ -0,0 +1,144 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math/big"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient/gethclient"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// ERC-20 selectors recognised in pending calldata.
+var (
+	transferSelector     = [4]byte{0xa9, 0x05, 0x9c, 0xbb} // transfer(address,uint256)
+	transferFromSelector = [4]byte{0x23, 0xb8, 0x72, 0xdd} // transferFrom(address,address,uint256)
+)
+
+// pendingMove is what a pending transaction does to a tracked wallet: a
+// native or ERC-20 transfer, or, with a nil token, some other call.
+type pendingMove struct {
+	from, to common.Address
+	token    *common.Address // nil for the native coin
+	amount   *big.Int
+	call     bool
+}
+
+// watchMempool follows the pending transactions of every chain with a
+// websocket endpoint and prints those sent from or to wallets, including
+// ERC-20 transfers they make, before they confirm. Outgoing transfers worth
+// at least largeUSD are flagged LARGE. The node has to support eth_subscribe
+// newPendingTransactions with full transaction bodies, as geth, erigon and
+// reth do; what reaches a node's mempool is all it can see.
+func watchMempool(ctx context.Context, w *lineWriter, conns []*chainConn, wallets []common.Address, cfg *config, opts options, hideBelow, largeUSD float64) {
+	forEachWS(ctx, conns, "the mempool", func(c *chainConn) error {
+		return c.watchMempool(ctx, wallets, cfg, opts, w, hideBelow, largeUSD)
+	})
+}
+
+func (c *chainConn) watchMempool(ctx context.Context, wallets []common.Address, cfg *config, opts options, w *lineWriter, hideBelow, largeUSD float64) error {
+	rc, err := rpc.DialContext(ctx, c.ws)
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+	txs := make(chan *types.Transaction, 256)
+	sub, err := gethclient.New(rc).SubscribeFullPendingTransactions(ctx, txs)
+	if err != nil {
+		return fmt.Errorf("subscribing to full pending transactions: %w", err)
+	}
+	defer sub.Unsubscribe()
+	log.Printf("%s: watching the mempool for %d wallet(s)", chainName(c.id), len(wallets))
+
+	tracked := map[common.Address]bool{}
+	for _, addr := range wallets {
+		tracked[addr] = true
+	}
+	signer := types.LatestSignerForChainID(new(big.Int).SetUint64(c.id))
+	tokens := c.tokenCache()
+	// Nodes may announce a transaction again; only those of tracked wallets
+	// are remembered, so the set grows with their activity, not the chain's.
+	seen := map[common.Hash]bool{}
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case err := <-sub.Err():
+			return err
+		case tx := <-txs:
+			from, err := types.Sender(signer, tx)
+			if err != nil {
+				continue
+			}
+			var moves []pendingMove
+			for _, m := range pendingMoves(from, tx) {
+				if tracked[m.from] || tracked[m.to] {
+					moves = append(moves, m)
+				}
+			}
+			if len(moves) == 0 || seen[tx.Hash()] {
+				continue
+			}
+			seen[tx.Hash()] = true
+			for _, m := range moves {
+				if m.call {
+					w.println(fmt.Sprintf("%s %-8s PENDING %s OUT call to %s tx %s",
+						time.Now().Format(time.TimeOnly), chainName(c.id), walletName(m.from), walletName(m.to), tx.Hash().Hex()))
+					continue
+				}
+				tf := nativeToken(c.id)
+				if m.token != nil {
+					tf = tokens(ctx, *m.token)
+				}
+				if !opts.wants(tf.Symbol) || !cfg.tokenAllowed(tf.TokenAddr) {
+					continue
+				}
+				h := c.priced(ctx, cfg, opts, tf, m.amount)
+				if h.err == nil && below(h.usd(), hideBelow) {
+					continue
+				}
+				dir, wallet, prep, other := "OUT", m.from, "to", m.to
+				if tracked[m.to] && !tracked[m.from] {
+					dir, wallet, prep, other = "IN ", m.to, "from", m.from
+				}
+				flag := ""
+				if dir == "OUT" && h.err == nil && !below(h.usd(), largeUSD) {
+					flag = " LARGE"
+				}
+				w.println(fmt.Sprintf("%s %-8s PENDING %s %s %s %s (%s) %s %s tx %s%s",
+					time.Now().Format(time.TimeOnly), chainName(c.id),
+					walletName(wallet), dir, h.amt.Text('f', 6), tf.Symbol, usdText(h),
+					prep, walletName(other), tx.Hash().Hex(), flag))
+			}
+		}
+	}
+}
+
+// pendingMoves decodes what a transaction sent by from moves: its native
+// value, and the ERC-20 transfer or transferFrom its calldata makes. Other
+// calls are reported as such so calls made by a tracked wallet are not
+// missed; contract creations are ignored.
+func pendingMoves(from common.Address, tx *types.Transaction) []pendingMove {
+	if tx.To() == nil {
+		return nil
+	}
+	to, data := *tx.To(), tx.Data()
+	var moves []pendingMove
+	if tx.Value().Sign() > 0 {
+		moves = append(moves, pendingMove{from: from, to: to, amount: tx.Value()})
+	}
+	word := func(i int) []byte { return data[4+32*i : 4+32*(i+1)] }
+	switch {
+	case len(data) >= 68 && [4]byte(data[:4]) == transferSelector:
+		moves = append(moves, pendingMove{from: from, to: common.BytesToAddress(word(0)), token: &to, amount: new(big.Int).SetBytes(word(1))})
+	case len(data) >= 100 && [4]byte(data[:4]) == transferFromSelector:
+		moves = append(moves, pendingMove{from: common.BytesToAddress(word(0)), to: common.BytesToAddress(word(1)), token: &to, amount: new(big.Int).SetBytes(word(2))})
+	case len(data) >= 4 && len(moves) == 0:
+		moves = append(moves, pendingMove{from: from, to: to, call: true})
+	}
+	return moves
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+func TestPendingMoves(t *testing.T) {
+	other := common.HexToAddress("0x0000000000000000000000000000000000000b0b")
+	calldata := func(sel [4]byte, words ...[]byte) []byte {
+		data := sel[:]
+		for _, w := range words {
+			data = append(data, common.LeftPadBytes(w, 32)...)
+		}
+		return data
+	}
+	tx := func(to common.Address, value int64, data []byte) *types.Transaction {
+		return types.NewTx(&types.LegacyTx{To: &to, Value: big.NewInt(value), Data: data})
+	}
+
+	moves := pendingMoves(testWallet, tx(other, 5, nil))
+	if len(moves) != 1 || moves[0].token != nil || moves[0].to != other || moves[0].amount.Int64() != 5 {
+		t.Errorf("native transfer = %+v", moves)
+	}
+
+	moves = pendingMoves(testWallet, tx(testToken, 0, calldata(transferSelector, other.Bytes(), big.NewInt(7).Bytes())))
+	if len(moves) != 1 || *moves[0].token != testToken || moves[0].from != testWallet || moves[0].to != other || moves[0].amount.Int64() != 7 {
+		t.Errorf("transfer = %+v", moves)
+	}
+
+	// A spender moves the tracked wallet's tokens.
+	moves = pendingMoves(other, tx(testToken, 0, calldata(transferFromSelector, testWallet.Bytes(), other.Bytes(), big.NewInt(9).Bytes())))
+	if len(moves) != 1 || moves[0].from != testWallet || moves[0].to != other || moves[0].amount.Int64() != 9 {
+		t.Errorf("transferFrom = %+v", moves)
+	}
+
+	moves = pendingMoves(testWallet, tx(other, 0, []byte{1, 2, 3, 4}))
+	if len(moves) != 1 || !moves[0].call {
+		t.Errorf("other call = %+v", moves)
+	}
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	_ "embed"
+	"encoding/hex"
+	"encoding/json"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// Positions carry a logo, CoinGecko ID and website for the dashboard and
+// the TUI. The embedded tokenmeta.json covers the mainnet registry by
+// symbol, so that runs without network access still have them; the token
+// lists of the config's token_lists add and override entries by contract.
+// Fetched lists are kept under <data dir>/tokenlists and refetched daily;
+// one that cannot be fetched is read from there, or skipped.
+
+// tokenMeta is what is known about an asset beyond its balance and price.
+type tokenMeta struct {
+	Logo        string `json:"logo,omitempty"`
+	CoingeckoID string `json:"coingecko_id,omitempty"`
+	Website     string `json:"website,omitempty"`
+}
+
+// tokenListTTL is how long a fetched token list is used before it is
+// fetched again.
+const tokenListTTL = 24 * time.Hour
+
+//go:embed tokenmeta.json
+var embeddedMetaJSON []byte
+
+var (
+	// tokenLists are the config's token list URLs, set by loadConfig.
+	tokenLists []string
+
+	metaOnce   sync.Once
+	metaBySym  map[string]tokenMeta
+	metaByAddr map[tokenKey]tokenMeta
+)
+
+type tokenKey struct {
+	chainID uint64
+	addr    common.Address
+}
+
+// tokenList is the Uniswap token list format. CoinGecko IDs and websites
+// are not part of the standard; the extensions some lists use are read.
+type tokenList struct {
+	Tokens []struct {
+		ChainID    uint64 `json:"chainId"`
+		Address    string `json:"address"`
+		LogoURI    string `json:"logoURI"`
+		Extensions struct {
+			CoingeckoID string `json:"coingeckoId"`
+			Website     string `json:"website"`
+		} `json:"extensions"`
+	} `json:"tokens"`
+}
+
+// metadataOf returns what is known about a position's asset: its
+// contract's token list entry, completed by the embedded entry of its
+// symbol. The native coin only has the latter.
+func metadataOf(chainID uint64, addr common.Address, symbol string) tokenMeta {
+	metaOnce.Do(loadMetadata)
+	m := metaByAddr[tokenKey{chainID, addr}]
+	if addr == (common.Address{}) {
+		m = tokenMeta{}
+	}
+	fallback := metaBySym[symbol]
+	if m.Logo == "" {
+		m.Logo = fallback.Logo
+	}
+	if m.CoingeckoID == "" {
+		m.CoingeckoID = fallback.CoingeckoID
+	}
+	if m.Website == "" {
+		m.Website = fallback.Website
+	}
+	return m
+}
+
+func loadMetadata() {
+	if err := json.Unmarshal(embeddedMetaJSON, &metaBySym); err != nil {
+		panic("tokenmeta.json: " + err.Error())
+	}
+	metaByAddr = map[tokenKey]tokenMeta{}
+	for _, url := range tokenLists {
+		list, err := fetchTokenList(url)
+		if err != nil {
+			log.Printf("warning: token list %s: %v", url, err)
+			continue
+		}
+		for _, t := range list.Tokens {
+			if !common.IsHexAddress(t.Address) {
+				continue
+			}
+			key := tokenKey{t.ChainID, common.HexToAddress(t.Address)}
+			if _, seen := metaByAddr[key]; seen {
+				continue // earlier lists win
+			}
+			metaByAddr[key] = tokenMeta{Logo: logoURL(t.LogoURI), CoingeckoID: t.Extensions.CoingeckoID, Website: t.Extensions.Website}
+		}
+	}
+}
+
+// fetchTokenList returns the list at url from its cached copy while that
+// is fresh, else fetches and caches it, falling back to a stale copy when
+// the fetch fails.
+func fetchTokenList(url string) (*tokenList, error) {
+	sum := sha256.Sum256([]byte(url))
+	path := filepath.Join(dataDir(), "tokenlists", hex.EncodeToString(sum[:8])+".json")
+	cached := func() (*tokenList, error) {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		list := &tokenList{}
+		return list, json.Unmarshal(data, list)
+	}
+	if fi, err := os.Stat(path); err == nil && time.Since(fi.ModTime()) < tokenListTTL {
+		if list, err := cached(); err == nil {
+			return list, nil
+		}
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	var raw json.RawMessage
+	if err := getJSON(ctx, url, &raw); err != nil {
+		if list, cerr := cached(); cerr == nil {
+			return list, nil
+		}
+		return nil, err
+	}
+	list := &tokenList{}
+	if err := json.Unmarshal(raw, list); err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err == nil {
+		if f, err := createAtomic(path); err == nil {
+			f.Write(raw)
+			f.Commit()
+		}
+	}
+	return list, nil
+}
+
+// logoURL makes ipfs:// logos loadable by browsers.
+func logoURL(uri string) string {
+	if cid, ok := strings.CutPrefix(uri, "ipfs://"); ok {
+		return "https://ipfs.io/ipfs/" + cid
+	}
+	return uri
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+func TestMetadata(t *testing.T) {
+	t.Setenv("PORTFOLIO_HOME", t.TempDir())
+	usdc, _ := tokenBySymbol("USDC")
+	listed := common.HexToAddress("0x00000000000000000000000000000000000000cc")
+	up := true
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !up {
+			http.Error(w, "down", http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte(`{"tokens": [
+			{"chainId": 1, "address": "` + usdc.TokenAddr.Hex() + `", "logoURI": "ipfs://QmUSDC"},
+			{"chainId": 8453, "address": "` + listed.Hex() + `", "logoURI": "https://example.com/cc.png",
+			 "extensions": {"coingeckoId": "cc-token", "website": "https://cc.example"}}]}`))
+	}))
+	defer srv.Close()
+	reload := func() {
+		metaOnce = sync.Once{}
+		t.Cleanup(func() { tokenLists, metaOnce = nil, sync.Once{} })
+		tokenLists = []string{srv.URL}
+	}
+
+	reload()
+	m := metadataOf(1, usdc.TokenAddr, "USDC")
+	if m.Logo != "https://ipfs.io/ipfs/QmUSDC" || m.CoingeckoID != "usd-coin" || m.Website == "" {
+		t.Errorf("USDC: %+v; want the list's logo and the built-in ID and website", m)
+	}
+	if m := metadataOf(8453, listed, "CC"); m.CoingeckoID != "cc-token" || m.Website != "https://cc.example" {
+		t.Errorf("listed token: %+v", m)
+	}
+	if m := metadataOf(10, common.Address{}, "ETH"); m.CoingeckoID != "ethereum" || m.Logo == "" {
+		t.Errorf("ETH: %+v", m)
+	}
+	if m := metadataOf(1, common.Address{9}, "UNKNOWN"); m != (tokenMeta{}) {
+		t.Errorf("unknown token: %+v", m)
+	}
+
+	// Offline, the list comes from the copy cached by the first load, even
+	// once it is stale.
+	up = false
+	stale := time.Now().Add(-2 * tokenListTTL)
+	files, _ := filepath.Glob(filepath.Join(dataDir(), "tokenlists", "*.json"))
+	if len(files) != 1 {
+		t.Fatalf("%d cached lists, want 1", len(files))
+	}
+	os.Chtimes(files[0], stale, stale)
+	reload()
+	if m := metadataOf(8453, listed, "CC"); m.CoingeckoID != "cc-token" {
+		t.Errorf("cached list not used: %+v", m)
+	}
+}
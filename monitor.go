@@ -0,0 +1,155 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+
+	"Test2/source"
+)
+
+// alertsConfig sets what the daemon's monitor alerts on with -notify: a
+// hot wallet whose value falls below its MinUSD, a registry feed not
+// updated for FeedMaxAge (25h when empty), and a node that stops
+// answering.
+type alertsConfig struct {
+	HotWallets []hotWallet `json:"hot_wallets"`
+	FeedMaxAge string      `json:"feed_max_age"`
+}
+
+type hotWallet struct {
+	Wallet string  `json:"wallet"`
+	MinUSD float64 `json:"min_usd"`
+}
+
+// monitor checks the alert conditions periodically and notifies each time
+// one starts or stops holding, not on every check.
+type monitor struct {
+	conns  []*chainConn
+	cfg    *config
+	value  func(common.Address) *report
+	maxAge time.Duration
+	active map[string]alert // firing conditions by key
+}
+
+func newMonitor(conns []*chainConn, cfg *config, value func(common.Address) *report) (*monitor, error) {
+	m := &monitor{conns: conns, cfg: cfg, value: value, maxAge: feedMaxAge, active: map[string]alert{}}
+	if s := cfg.Alerts.FeedMaxAge; s != "" {
+		var err error
+		if m.maxAge, err = parseSince(s); err != nil {
+			return nil, fmt.Errorf("alerts.feed_max_age: %w", err)
+		}
+	}
+	for _, hw := range cfg.Alerts.HotWallets {
+		if _, ok := walletArg(hw.Wallet); !ok {
+			return nil, fmt.Errorf("alerts.hot_wallets: %w %q", source.ErrInvalidAddress, hw.Wallet)
+		}
+	}
+	return m, nil
+}
+
+// firing evaluates every condition and returns those that hold.
+func (m *monitor) firing(ctx context.Context) []alert {
+	var out []alert
+	for _, c := range m.conns {
+		name := chainName(c.id)
+		callCtx, cancel := context.WithTimeout(ctx, 15*time.Second)
+		_, err := c.client.BlockNumber(callCtx)
+		cancel()
+		if err != nil {
+			out = append(out, alert{Key: "rpc|" + name, Critical: true,
+				Title: fmt.Sprintf("%s node is down", name), Text: err.Error()})
+			continue
+		}
+		seen := map[common.Address]bool{}
+		for _, tf := range registry(c.id) {
+			if tf.FeedAddr == (common.Address{}) || seen[tf.FeedAddr] {
+				continue
+			}
+			seen[tf.FeedAddr] = true
+			if _, err := c.checkFeed(ctx, tf.FeedAddr, m.maxAge); errors.Is(err, source.ErrStaleFeed) {
+				out = append(out, alert{Key: "feed|" + name + "|" + tf.Symbol, Critical: true,
+					Title: fmt.Sprintf("%s/USD feed on %s is stale", tf.Symbol, name), Text: err.Error()})
+			}
+		}
+	}
+	for _, hw := range m.cfg.Alerts.HotWallets {
+		addr, _ := walletArg(hw.Wallet)
+		key := "drained|" + strings.ToLower(addr.Hex())
+		rep := m.value(addr)
+		if incomplete(rep) {
+			// A failed lookup says nothing about a drain either way.
+			if a, ok := m.active[key]; ok {
+				out = append(out, a)
+			}
+			continue
+		}
+		if rep.TotalUSD < hw.MinUSD {
+			out = append(out, alert{Key: key, Critical: true,
+				Title: fmt.Sprintf("hot wallet %s is below %s", walletName(addr), usdString(hw.MinUSD)),
+				Text:  fmt.Sprintf("now holds %s", usdString(rep.TotalUSD))})
+		}
+	}
+	return out
+}
+
+func incomplete(rep *report) bool {
+	for _, p := range rep.Positions {
+		if p.Error != "" {
+			return true
+		}
+	}
+	return rep.Partial
+}
+
+// transitions returns the alerts to send for the conditions now firing:
+// those that started, and resolutions of those that stopped.
+func (m *monitor) transitions(now []alert) []alert {
+	var out []alert
+	current := map[string]bool{}
+	for _, a := range now {
+		current[a.Key] = true
+		if _, ok := m.active[a.Key]; !ok {
+			m.active[a.Key] = a
+			out = append(out, a)
+		}
+	}
+	for key, a := range m.active {
+		if !current[key] {
+			delete(m.active, key)
+			a.Resolved = true
+			out = append(out, a)
+		}
+	}
+	return out
+}
+
+// run checks every interval until ctx is done, sending each transition to
+// the notifiers.
+func (m *monitor) run(ctx context.Context, every time.Duration, notify string) {
+	tick := time.NewTicker(every)
+	defer tick.Stop()
+	for {
+		for _, a := range m.transitions(m.firing(ctx)) {
+			if ctx.Err() != nil {
+				return
+			}
+			state := "alert"
+			if a.Resolved {
+				state = "resolved"
+			}
+			log.Printf("%s: %s", state, a.Title)
+			sendAlert(ctx, notify, m.cfg, a)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-tick.C:
+		}
+	}
+}
@@ -0,0 +1,175 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// mqttConfig publishes each exported report to an MQTT broker, retained so
+// that dashboards such as Home Assistant show the latest values as soon as
+// they subscribe: <topic>/<wallet>/total_usd, and <topic>/<wallet>/<SYMBOL>
+// with the symbol's USD value summed over chains. Wallets are named by
+// their address book label when they have one. Broker is tcp://host:port,
+// or tls:// for TLS; the password is read from the variable PasswordEnv
+// names ($MQTT_PASSWORD by default).
+type mqttConfig struct {
+	Broker      string `json:"broker"`
+	Topic       string `json:"topic"`
+	Username    string `json:"username"`
+	PasswordEnv string `json:"password_env"`
+	ClientID    string `json:"client_id"`
+}
+
+// mqttMessages are the topics and payloads published for a report.
+func mqttMessages(prefix string, r *report) [][2]string {
+	wallet := r.Wallet
+	if r.Label != "" {
+		wallet = mqttTopicLevel(r.Label)
+	}
+	base := strings.TrimSuffix(prefix, "/") + "/" + wallet
+	bySymbol := map[string]float64{}
+	for _, p := range r.Positions {
+		if p.Error == "" {
+			bySymbol[p.Symbol] += p.USD
+		}
+	}
+	msgs := [][2]string{{base + "/total_usd", strconv.FormatFloat(r.TotalUSD, 'f', 2, 64)}}
+	var syms []string
+	for s := range bySymbol {
+		syms = append(syms, s)
+	}
+	sort.Strings(syms)
+	for _, s := range syms {
+		msgs = append(msgs, [2]string{base + "/" + mqttTopicLevel(s), strconv.FormatFloat(bySymbol[s], 'f', 2, 64)})
+	}
+	return msgs
+}
+
+// mqttTopicLevel keeps a name from splitting a topic or acting as a
+// wildcard.
+func mqttTopicLevel(s string) string {
+	return strings.NewReplacer("/", "_", "+", "_", "#", "_", " ", "_").Replace(s)
+}
+
+func exportMQTT(ctx context.Context, cfg *config, reps []*report) error {
+	mc := cfg.MQTT
+	if mc.Broker == "" {
+		return errors.New("set mqtt.broker in the config")
+	}
+	prefix := mc.Topic
+	if prefix == "" {
+		prefix = "portfolio"
+	}
+	env := mc.PasswordEnv
+	if env == "" {
+		env = "MQTT_PASSWORD"
+	}
+	clientID := mc.ClientID
+	if clientID == "" {
+		clientID = fmt.Sprintf("portfolio-%d", os.Getpid())
+	}
+	conn, err := dialMQTT(ctx, mc.Broker)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	} else {
+		conn.SetDeadline(time.Now().Add(30 * time.Second))
+	}
+	if err := mqttConnect(conn, clientID, mc.Username, os.Getenv(env)); err != nil {
+		return err
+	}
+	w := bufio.NewWriter(conn)
+	for _, r := range reps {
+		for _, m := range mqttMessages(prefix, r) {
+			w.Write(mqttPacket(0x31, mqttString(m[0]), []byte(m[1]))) // PUBLISH, QoS 0, retained
+		}
+	}
+	w.Write([]byte{0xe0, 0}) // DISCONNECT
+	return w.Flush()
+}
+
+func dialMQTT(ctx context.Context, broker string) (net.Conn, error) {
+	u, err := url.Parse(broker)
+	if err != nil {
+		return nil, fmt.Errorf("broker: %w", err)
+	}
+	var d net.Dialer
+	switch u.Scheme {
+	case "tcp", "mqtt":
+		return d.DialContext(ctx, "tcp", u.Host)
+	case "tls", "ssl", "mqtts":
+		return (&tls.Dialer{NetDialer: &d}).DialContext(ctx, "tcp", u.Host)
+	}
+	return nil, fmt.Errorf("broker: unknown scheme %q (want tcp:// or tls://)", u.Scheme)
+}
+
+// mqttConnect opens an MQTT 3.1.1 session and waits for the broker to
+// accept it.
+func mqttConnect(conn io.ReadWriter, clientID, username, password string) error {
+	flags := byte(0x02) // clean session
+	payload := mqttString(clientID)
+	if username != "" {
+		flags |= 0x80
+		payload = append(payload, mqttString(username)...)
+		if password != "" {
+			flags |= 0x40
+			payload = append(payload, mqttString(password)...)
+		}
+	}
+	header := append(mqttString("MQTT"), 4, flags, 0, 60) // protocol level 4, keep-alive 60s
+	if _, err := conn.Write(mqttPacket(0x10, header, payload)); err != nil {
+		return err
+	}
+	ack := make([]byte, 4)
+	if _, err := io.ReadFull(conn, ack); err != nil {
+		return fmt.Errorf("connack: %w", err)
+	}
+	if ack[0] != 0x20 || ack[1] != 2 {
+		return fmt.Errorf("connack: unexpected packet %x", ack)
+	}
+	if ack[3] != 0 {
+		return fmt.Errorf("broker refused the connection (code %d)", ack[3])
+	}
+	return nil
+}
+
+// mqttPacket frames a control packet with its remaining length.
+func mqttPacket(kind byte, parts ...[]byte) []byte {
+	n := 0
+	for _, p := range parts {
+		n += len(p)
+	}
+	out := []byte{kind}
+	for {
+		b := byte(n % 128)
+		if n /= 128; n > 0 {
+			b |= 0x80
+		}
+		out = append(out, b)
+		if n == 0 {
+			break
+		}
+	}
+	for _, p := range parts {
+		out = append(out, p...)
+	}
+	return out
+}
+
+func mqttString(s string) []byte {
+	return append([]byte{byte(len(s) >> 8), byte(len(s))}, s...)
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"net"
+	"testing"
+)
+
+// readPacket reads one MQTT control packet.
+func readPacket(r *bufio.Reader) (byte, []byte, error) {
+	kind, err := r.ReadByte()
+	if err != nil {
+		return 0, nil, err
+	}
+	n, mult := 0, 1
+	for {
+		b, err := r.ReadByte()
+		if err != nil {
+			return 0, nil, err
+		}
+		n += int(b&0x7f) * mult
+		if mult *= 128; b&0x80 == 0 {
+			break
+		}
+	}
+	body := make([]byte, n)
+	_, err = io.ReadFull(r, body)
+	return kind, body, err
+}
+
+func TestExportMQTT(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	type result struct {
+		connect   []byte
+		published map[string]string
+		retained  bool
+	}
+	done := make(chan result, 1)
+	go func() {
+		var res result
+		defer func() { done <- res }()
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		r := bufio.NewReader(conn)
+		if _, res.connect, err = readPacket(r); err != nil {
+			return
+		}
+		conn.Write([]byte{0x20, 2, 0, 0})
+		res.published, res.retained = map[string]string{}, true
+		for {
+			kind, body, err := readPacket(r)
+			if err != nil || kind == 0xe0 {
+				return
+			}
+			n := int(body[0])<<8 | int(body[1])
+			res.published[string(body[2:2+n])] = string(body[2+n:])
+			res.retained = res.retained && kind == 0x31
+		}
+	}()
+
+	t.Setenv("MQTT_PASSWORD", "pw")
+	cfg := &config{MQTT: mqttConfig{Broker: "tcp://" + ln.Addr().String(), Topic: "home/portfolio", Username: "ha", ClientID: "test"}}
+	rep := &report{Wallet: "0xaa", Label: "cold storage", TotalUSD: 6000, Positions: []reportPosition{
+		{Symbol: "ETH", Chain: "mainnet", USD: 4000},
+		{Symbol: "ETH", Chain: "base", USD: 1000},
+		{Symbol: "USDC", Chain: "mainnet", USD: 1000},
+		{Symbol: "LINK", Chain: "mainnet", Error: "no price"},
+	}}
+	if err := exportMQTT(context.Background(), cfg, []*report{rep}); err != nil {
+		t.Fatal(err)
+	}
+	res := <-done
+	want := map[string]string{
+		"home/portfolio/cold_storage/total_usd": "6000.00",
+		"home/portfolio/cold_storage/ETH":       "5000.00",
+		"home/portfolio/cold_storage/USDC":      "1000.00",
+	}
+	if len(res.published) != len(want) || !res.retained {
+		t.Fatalf("published %v (retained %v)", res.published, res.retained)
+	}
+	for topic, v := range want {
+		if res.published[topic] != v {
+			t.Errorf("%s = %q, want %q", topic, res.published[topic], v)
+		}
+	}
+	// Username and password flags, and both in the payload.
+	if flags := res.connect[7]; flags != 0xc2 || string(res.connect[len(res.connect)-2:]) != "pw" {
+		t.Errorf("connect %x", res.connect)
+	}
+}
+
+func TestMQTTPacketLength(t *testing.T) {
+	p := mqttPacket(0x30, make([]byte, 200))
+	if p[1] != 0xc8 || p[2] != 0x01 || len(p) != 203 {
+		t.Errorf("header %x, len %d", p[:3], len(p))
+	}
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+
+	"Test2/bindings"
+)
+
+// Chains that name their Multicall3 deployment have their registry balances
+// read through it, many to a call, rather than one call per token.
+
+// multicallBatch is how many balances go into one aggregate3 call, well
+// within what nodes let an eth_call use.
+const multicallBatch = 200
+
+var (
+	multicallABI = mustABI(`[
+  {"inputs":[{"components":[{"name":"target","type":"address"},{"name":"allowFailure","type":"bool"},{"name":"callData","type":"bytes"}],"name":"calls","type":"tuple[]"}],"name":"aggregate3","outputs":[{"components":[{"name":"success","type":"bool"},{"name":"returnData","type":"bytes"}],"name":"returnData","type":"tuple[]"}],"stateMutability":"payable","type":"function"},
+  {"inputs":[{"name":"addr","type":"address"}],"name":"getEthBalance","outputs":[{"name":"balance","type":"uint256"}],"stateMutability":"view","type":"function"}
+]`)
+	balanceOfABI = mustABI(bindings.ERC20MetaData.ABI)
+)
+
+// multicallCall is one call of an aggregate3 batch.
+type multicallCall struct {
+	Target       common.Address
+	AllowFailure bool
+	CallData     []byte
+}
+
+// multicallResult is what aggregate3 answers for one call.
+type multicallResult struct {
+	Success    bool
+	ReturnData []byte
+}
+
+// multicallBalances reads the wallet's balance of every token, the native
+// coin's through getEthBalance, in aggregate3 calls to multicall. A token
+// whose balanceOf fails gets its error without failing the others; a batch
+// whose call fails gets the call's error on each of its tokens.
+func multicallBalances(ctx context.Context, client chainClient, multicall common.Address, tokens []tokenFeed, wallet common.Address) ([]*big.Int, []error) {
+	balances, errs := make([]*big.Int, len(tokens)), make([]error, len(tokens))
+	batches := (len(tokens) + multicallBatch - 1) / multicallBatch
+	forEachLimited(batches, func(b int) {
+		from, to := b*multicallBatch, min((b+1)*multicallBatch, len(tokens))
+		calls := make([]multicallCall, 0, to-from)
+		for _, tf := range tokens[from:to] {
+			c := multicallCall{Target: tf.TokenAddr, AllowFailure: true}
+			if tf.TokenAddr == (common.Address{}) {
+				c.Target = multicall
+				c.CallData, _ = multicallABI.Pack("getEthBalance", wallet)
+			} else {
+				c.CallData, _ = balanceOfABI.Pack("balanceOf", wallet)
+			}
+			calls = append(calls, c)
+		}
+		vs, err := callView(ctx, client, multicall, multicallABI, "aggregate3", calls)
+		var results []multicallResult
+		if err == nil {
+			results = *abi.ConvertType(vs[0], new([]multicallResult)).(*[]multicallResult)
+			if len(results) != len(calls) {
+				err = fmt.Errorf("aggregate3: %d results for %d calls", len(results), len(calls))
+			}
+		}
+		for i := range calls {
+			switch {
+			case err != nil:
+				errs[from+i] = fmt.Errorf("multicall: %w", err)
+			case !results[i].Success || len(results[i].ReturnData) != 32:
+				errs[from+i] = errors.New("balanceOf failed")
+			default:
+				balances[from+i] = new(big.Int).SetBytes(results[i].ReturnData)
+			}
+		}
+	})
+	return balances, errs
+}
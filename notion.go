@@ -0,0 +1,152 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// notionConfig upserts every exported position into a Notion database,
+// keyed by wallet, chain, symbol and note so that the database holds each
+// position's latest row, and, with TotalsDatabaseID, one row per report
+// with its total. The integration token is $NOTION_TOKEN unless TokenEnv
+// names another variable; both databases must be shared with it.
+//
+// Columns maps what is exported (exportColumns, plus "key" and "total_usd")
+// to the database's property names, "-" leaving one out; unmapped ones keep
+// their own name. "key" must be the title property, time a date, amount,
+// usd and total_usd numbers, and the rest text. URL replaces the API base,
+// for tests.
+type notionConfig struct {
+	DatabaseID       string            `json:"database_id"`
+	TotalsDatabaseID string            `json:"totals_database_id"`
+	TokenEnv         string            `json:"token_env"`
+	Columns          map[string]string `json:"columns"`
+	URL              string            `json:"url"`
+}
+
+const notionVersion = "2022-06-28"
+
+func exportNotion(ctx context.Context, cfg *config, reps []*report) error {
+	nc := cfg.Notion
+	if nc.DatabaseID == "" {
+		return errors.New("set notion.database_id in the config")
+	}
+	env := nc.TokenEnv
+	if env == "" {
+		env = "NOTION_TOKEN"
+	}
+	n := &notionClient{base: "https://api.notion.com", token: os.Getenv(env), columns: nc.Columns}
+	if n.token == "" {
+		return fmt.Errorf("no integration token; set %s", env)
+	}
+	if nc.URL != "" {
+		n.base = strings.TrimSuffix(nc.URL, "/")
+	}
+	for _, r := range reps {
+		for i, row := range exportRows(r) {
+			p := r.Positions[i]
+			key := r.Wallet + "|" + p.key()
+			if err := n.upsert(ctx, nc.DatabaseID, key, exportColumns, row); err != nil {
+				return fmt.Errorf("%s %s: %w", r.Wallet, p.Symbol, err)
+			}
+		}
+		if nc.TotalsDatabaseID == "" {
+			continue
+		}
+		key := r.Wallet + "|" + r.Time.Format(time.RFC3339)
+		row := []any{r.Time.Format(time.RFC3339), r.Wallet, r.Label, r.TotalUSD}
+		if err := n.upsert(ctx, nc.TotalsDatabaseID, key, []string{"time", "wallet", "label", "total_usd"}, row); err != nil {
+			return fmt.Errorf("%s total: %w", r.Wallet, err)
+		}
+	}
+	return nil
+}
+
+type notionClient struct {
+	base, token string
+	columns     map[string]string
+}
+
+// column is the property a column is stored in, "" for none.
+func (n *notionClient) column(name string) string {
+	prop, ok := n.columns[name]
+	switch {
+	case !ok:
+		return name
+	case prop == "-":
+		return ""
+	}
+	return prop
+}
+
+// properties renders a row as Notion property values, keyed by the
+// mapped property names.
+func (n *notionClient) properties(key string, columns []string, row []any) map[string]any {
+	text := func(s string) []any { return []any{map[string]any{"text": map[string]string{"content": s}}} }
+	props := map[string]any{}
+	if prop := n.column("key"); prop != "" {
+		props[prop] = map[string]any{"title": text(key)}
+	}
+	for i, col := range columns {
+		prop := n.column(col)
+		if prop == "" {
+			continue
+		}
+		switch v := row[i].(type) {
+		case float64:
+			props[prop] = map[string]any{"number": v}
+		case string:
+			if col == "time" {
+				props[prop] = map[string]any{"date": map[string]string{"start": v}}
+			} else {
+				props[prop] = map[string]any{"rich_text": text(v)}
+			}
+		}
+	}
+	return props
+}
+
+// upsert updates the page of database whose key is key, or adds one.
+func (n *notionClient) upsert(ctx context.Context, database, key string, columns []string, row []any) error {
+	keyProp := n.column("key")
+	if keyProp == "" {
+		return errors.New(`the "key" column is needed to find existing rows`)
+	}
+	var found struct {
+		Results []struct {
+			ID string `json:"id"`
+		} `json:"results"`
+	}
+	query := map[string]any{"filter": map[string]any{"property": keyProp, "title": map[string]string{"equals": key}}, "page_size": 1}
+	if err := n.do(ctx, http.MethodPost, "/v1/databases/"+database+"/query", query, &found); err != nil {
+		return err
+	}
+	props := n.properties(key, columns, row)
+	if len(found.Results) > 0 {
+		return n.do(ctx, http.MethodPatch, "/v1/pages/"+found.Results[0].ID, map[string]any{"properties": props}, &struct{}{})
+	}
+	page := map[string]any{"parent": map[string]string{"database_id": database}, "properties": props}
+	return n.do(ctx, http.MethodPost, "/v1/pages", page, &struct{}{})
+}
+
+func (n *notionClient) do(ctx context.Context, method, path string, body, out any) error {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, method, n.base+path, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+n.token)
+	req.Header.Set("Notion-Version", notionVersion)
+	req.Header.Set("Content-Type", "application/json")
+	return exchangeDo(req, out)
+}
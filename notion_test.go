@@ -0,0 +1,79 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestExportNotion(t *testing.T) {
+	// A fake database: pages by id, each with its properties.
+	pages := map[string]map[string]any{}
+	parents := map[string]string{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer secret" || r.Header.Get("Notion-Version") == "" {
+			http.Error(w, `{"message":"unauthorized"}`, http.StatusUnauthorized)
+			return
+		}
+		var body map[string]any
+		json.NewDecoder(r.Body).Decode(&body)
+		switch {
+		case r.Method == http.MethodPost && strings.HasSuffix(r.URL.Path, "/query"):
+			db := strings.Split(r.URL.Path, "/")[3]
+			filter := body["filter"].(map[string]any)
+			want := filter["title"].(map[string]any)["equals"]
+			var results []map[string]string
+			for id, props := range pages {
+				title := props[filter["property"].(string)].(map[string]any)["title"].([]any)[0]
+				if parents[id] == db && title.(map[string]any)["text"].(map[string]any)["content"] == want {
+					results = append(results, map[string]string{"id": id})
+				}
+			}
+			json.NewEncoder(w).Encode(map[string]any{"results": results})
+		case r.Method == http.MethodPost && r.URL.Path == "/v1/pages":
+			id := string(rune('a' + len(pages)))
+			pages[id] = body["properties"].(map[string]any)
+			parents[id] = body["parent"].(map[string]any)["database_id"].(string)
+			w.Write([]byte(`{}`))
+		case r.Method == http.MethodPatch:
+			pages[strings.TrimPrefix(r.URL.Path, "/v1/pages/")] = body["properties"].(map[string]any)
+			w.Write([]byte(`{}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+	t.Setenv("NOTION_TOKEN", "secret")
+
+	cfg := &config{Notion: notionConfig{
+		DatabaseID: "positions", TotalsDatabaseID: "totals", URL: srv.URL,
+		Columns: map[string]string{"key": "Name", "usd": "Value", "error": "-"},
+	}}
+	rep := func(usd float64, at time.Time) *report {
+		return &report{Wallet: "0xaa", Time: at, TotalUSD: usd, Positions: []reportPosition{{Symbol: "ETH", Chain: "mainnet", Amount: 1, USD: usd}}}
+	}
+	t0 := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i, r := range []*report{rep(3000, t0), rep(3500, t0.Add(time.Hour))} {
+		if err := exportNotion(context.Background(), cfg, []*report{r}); err != nil {
+			t.Fatalf("export %d: %v", i, err)
+		}
+	}
+	// One position page, updated in place, and a totals page per report.
+	if len(pages) != 3 {
+		t.Fatalf("%d pages: %v", len(pages), pages)
+	}
+	pos := pages["a"]
+	if pos["Value"].(map[string]any)["number"] != 3500.0 || pos["error"] != nil || pos["usd"] != nil {
+		t.Errorf("position page %v", pos)
+	}
+	if pos["time"].(map[string]any)["date"].(map[string]any)["start"] != "2026-01-01T01:00:00Z" {
+		t.Errorf("time %v", pos["time"])
+	}
+	if parents["b"] != "totals" || pages["b"]["total_usd"].(map[string]any)["number"] != 3000.0 {
+		t.Errorf("totals page %v in %s", pages["b"], parents["b"])
+	}
+}
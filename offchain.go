@@ -0,0 +1,108 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// offChainChain is the chain name off-chain entries are reported under.
+const offChainChain = "off-chain"
+
+// offChainAsset is an asset entered by hand because no chain shows it: a
+// CEX balance, vested options, cash. It is priced at Price when set, else
+// as the registry token PriceAs (Symbol when empty) of the first chain
+// valued, through the usual sources. -price overrides either.
+type offChainAsset struct {
+	Symbol  string  `json:"symbol"`
+	Amount  float64 `json:"amount"`
+	Price   float64 `json:"price"`
+	PriceAs string  `json:"price_as"`
+	Note    string  `json:"note"`
+	// Wallet ties the entry to one wallet, by address or label; entries
+	// without one are part of every wallet's report.
+	Wallet string `json:"wallet"`
+
+	chain string // reported chain: off-chain, or the exchange's venue
+}
+
+// offChainHoldings returns the config's off-chain entries and exchange
+// balances for the wallet, priced on the connection's chain. They are not
+// wallet balances, so a sweep or the gas panel never sees them. An exchange
+// that cannot be read is recorded as a failed holding named after it.
+func (c *chainConn) offChainHoldings(ctx context.Context, wallet common.Address, cfg *config, opts options) []*holding {
+	var entries []offChainAsset
+	for _, a := range cfg.OffChain {
+		if forWallet(a.Wallet, wallet) {
+			a.chain = offChainChain
+			entries = append(entries, a)
+		}
+	}
+	var failed []*holding
+	for _, x := range cfg.Exchanges {
+		if !forWallet(x.Wallet, wallet) {
+			continue
+		}
+		runProgress.at(x.Venue)
+		balances, err := exchangeBalances(ctx, x)
+		if err != nil {
+			failed = append(failed, &holding{tf: tokenFeed{Symbol: x.Venue}, chain: x.Venue, err: err})
+			continue
+		}
+		entries = append(entries, balances...)
+	}
+	if len(entries) == 0 {
+		return failed
+	}
+
+	var held []*holding
+	for _, a := range entries {
+		h := &holding{tf: tokenFeed{Symbol: a.Symbol}, amt: big.NewFloat(a.Amount), note: a.Note, chain: a.chain}
+		switch {
+		case !opts.wants(a.Symbol):
+			continue
+		case a.Price > 0:
+			h.price = big.NewFloat(a.Price)
+		default:
+			as := a.PriceAs
+			if as == "" {
+				as = a.Symbol
+			}
+			tf, ok := registrySymbol(c.id, as)
+			if !ok {
+				if _, override := opts.Prices.lookup(a.Symbol); !override {
+					h.err = fmt.Errorf("no price: %s is not a %s registry token; set price or price_as", as, chainName(c.id))
+				}
+			} else {
+				h.tf.TokenAddr, h.tf.FeedAddr, h.tf.Decimals = tf.TokenAddr, tf.FeedAddr, tf.Decimals
+			}
+		}
+		held = append(held, h)
+	}
+	newPricer(ctx, c.client, c.id, c.trustFeeds, cfg, opts).priceAll(held)
+	return append(held, failed...)
+}
+
+// forWallet reports whether an entry tied to owner, an address or label,
+// belongs in the wallet's report; an entry tied to nobody belongs in all.
+func forWallet(owner string, wallet common.Address) bool {
+	if owner == "" {
+		return true
+	}
+	addr, ok := walletArg(owner)
+	return ok && addr == wallet
+}
+
+// registrySymbol returns the registry entry of a chain with the given
+// symbol, in any case.
+func registrySymbol(chainID uint64, sym string) (tokenFeed, bool) {
+	for _, tf := range registry(chainID) {
+		if strings.EqualFold(tf.Symbol, sym) {
+			return tf, true
+		}
+	}
+	return tokenFeed{}, false
+}
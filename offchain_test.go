@@ -0,0 +1,40 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+func TestOffChainHoldings(t *testing.T) {
+	mine, other := common.HexToAddress("0x01"), common.HexToAddress("0x02")
+	cfg := &config{OffChain: []offChainAsset{
+		{Symbol: "USD", Amount: 2500, Price: 1, Note: "bank"},
+		{Symbol: "BTC", Amount: 0.5, Note: "Kraken", Wallet: other.Hex()},
+		{Symbol: "OPTIONS", Amount: 1000, PriceAs: "NOPE"},
+		{Symbol: "ACME", Amount: 10},
+	}}
+	c := &chainConn{id: 1}
+	held := c.offChainHoldings(context.Background(), mine, cfg, options{Prices: priceOverrides{"ACME": 42}})
+	if len(held) != 3 {
+		t.Fatalf("got %d holdings, want the three not tied to another wallet", len(held))
+	}
+	for _, h := range held {
+		if h.chain != offChainChain {
+			t.Errorf("%s reported on %q", h.tf.Symbol, h.chain)
+		}
+	}
+	if usd, _ := held[0].usd().Float64(); held[0].err != nil || usd != 2500 || held[0].note != "bank" {
+		t.Errorf("USD: $%v, %v", usd, held[0].err)
+	}
+	if held[1].err == nil {
+		t.Error("OPTIONS priced as an unknown symbol has no error")
+	}
+	if usd, _ := held[2].usd().Float64(); held[2].err != nil || usd != 420 {
+		t.Errorf("ACME with -price: $%v, %v", usd, held[2].err)
+	}
+	if n := len(c.offChainHoldings(context.Background(), mine, cfg, options{Only: "USD"})); n != 1 {
+		t.Errorf("-only USD kept %d off-chain holdings, want 1", n)
+	}
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+
+	"Test2/source"
+)
+
+// oneInchOracles are the deployments of 1inch's spot-price-aggregator
+// (OffchainOracle) by chain ID. It derives a liquidity-weighted rate from the
+// DEX pools it knows about, which covers long-tail tokens that have no
+// Chainlink feed. Other chains have it elsewhere or not at all, and quote
+// in their own native coin, so the 1inch source skips them.
+var oneInchOracles = map[uint64]common.Address{
+	1: common.HexToAddress("0x0AdDd25a91563696D8567Df78D5A01C9a991F9B8"),
+}
+
+var offchainOracleABI = mustABI(`[
+  {"inputs":[{"name":"srcToken","type":"address"},{"name":"useSrcWrappers","type":"bool"}],"name":"getRateToEth","outputs":[{"name":"weightedRate","type":"uint256"}],"stateMutability":"view","type":"function"}
+]`)
+
+// oneInchPriceETH returns how much ETH one whole token is worth, per the
+// chain's oracle.
+func oneInchPriceETH(ctx context.Context, client chainClient, chainID uint64, tf tokenFeed) (*big.Float, error) {
+	oracle, ok := oneInchOracles[chainID]
+	if !ok {
+		return nil, fmt.Errorf("no 1inch oracle on this chain: %w", source.ErrUnsupported)
+	}
+	bz, err := offchainOracleABI.Pack("getRateToEth", tf.TokenAddr, true)
+	if err != nil {
+		return nil, err
+	}
+	out, err := client.CallContract(ctx, ethereum.CallMsg{To: &oracle, Data: bz}, nil)
+	if err != nil {
+		return nil, err
+	}
+	vs, err := offchainOracleABI.Unpack("getRateToEth", out)
+	if err != nil {
+		return nil, err
+	}
+	rate := vs[0].(*big.Int)
+	if rate.Sign() == 0 {
+		return nil, errors.New("1inch: no liquidity")
+	}
+	// The rate is scaled by 1e18 and by the decimals difference between the
+	// token and ETH, so one whole token is rate * 10^decimals / 1e36 ETH.
+	price := new(big.Float).SetInt(rate)
+	price.Mul(price, big.NewFloat(math.Pow10(tf.Decimals)))
+	price.Quo(price, big.NewFloat(1e36))
+	return price, nil
+}
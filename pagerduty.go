@@ -0,0 +1,70 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// pagerDutyConfig sends alerts to a PagerDuty service through the Events
+// API v2. RoutingKey is the service integration's key, or the variable
+// RoutingKeyEnv names ($PAGERDUTY_ROUTING_KEY by default). Critical alerts
+// page as critical, the rest as warnings; resolved conditions resolve their
+// incident. URL replaces the events endpoint's base, for tests.
+type pagerDutyConfig struct {
+	RoutingKey    string `json:"routing_key"`
+	RoutingKeyEnv string `json:"routing_key_env"`
+	URL           string `json:"url"`
+}
+
+func notifyPagerDuty(ctx context.Context, cfg *config, a alert) error {
+	pc := cfg.PagerDuty
+	key := pc.RoutingKey
+	env := pc.RoutingKeyEnv
+	if env == "" {
+		env = "PAGERDUTY_ROUTING_KEY"
+	}
+	if key == "" {
+		key = os.Getenv(env)
+	}
+	if key == "" {
+		return fmt.Errorf("no routing key; set pagerduty.routing_key or %s", env)
+	}
+	event := map[string]any{"routing_key": key, "dedup_key": a.Key, "event_action": "trigger"}
+	if a.Resolved {
+		event["event_action"] = "resolve"
+	} else {
+		severity := "warning"
+		if a.Critical {
+			severity = "critical"
+		}
+		source, _ := os.Hostname()
+		event["payload"] = map[string]any{
+			"summary":        a.Title,
+			"source":         "portfolio@" + source,
+			"severity":       severity,
+			"custom_details": map[string]string{"details": a.Text},
+		}
+	}
+	body, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	base := "https://events.pagerduty.com"
+	if pc.URL != "" {
+		base = strings.TrimSuffix(pc.URL, "/")
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, base+"/v2/enqueue", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	var resp struct {
+		Status string `json:"status"`
+	}
+	return exchangeDo(req, &resp)
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+func TestNotifyPagerDuty(t *testing.T) {
+	var events []map[string]any
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v2/enqueue" {
+			http.NotFound(w, r)
+			return
+		}
+		var e map[string]any
+		json.NewDecoder(r.Body).Decode(&e)
+		events = append(events, e)
+		w.WriteHeader(http.StatusAccepted)
+		w.Write([]byte(`{"status":"success","dedup_key":"x"}`))
+	}))
+	defer srv.Close()
+	t.Setenv("PAGERDUTY_ROUTING_KEY", "rk")
+	cfg := &config{PagerDuty: pagerDutyConfig{URL: srv.URL}}
+
+	a := alert{Key: "drained|0xaa", Title: "hot wallet 0xaa is below $1000.00", Critical: true}
+	if err := notifyPagerDuty(context.Background(), cfg, a); err != nil {
+		t.Fatal(err)
+	}
+	a.Resolved = true
+	if err := notifyPagerDuty(context.Background(), cfg, a); err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 2 || events[0]["event_action"] != "trigger" || events[0]["routing_key"] != "rk" ||
+		events[0]["payload"].(map[string]any)["severity"] != "critical" ||
+		events[1]["event_action"] != "resolve" || events[1]["dedup_key"] != "drained|0xaa" {
+		t.Errorf("events %v", events)
+	}
+	if err := notifyPagerDuty(context.Background(), &config{PagerDuty: pagerDutyConfig{RoutingKeyEnv: "UNSET_PD_KEY"}}, a); err == nil {
+		t.Error("no error without a routing key")
+	}
+}
+
+func TestMonitorHotWallets(t *testing.T) {
+	hot := common.HexToAddress("0x00000000000000000000000000000000000000aa")
+	total, failed := 5000.0, false
+	value := func(common.Address) *report {
+		r := &report{Wallet: "0x00000000000000000000000000000000000000aa", TotalUSD: total}
+		if failed {
+			r.Positions = []reportPosition{{Symbol: "ETH", Error: "timeout"}}
+		}
+		return r
+	}
+	m, err := newMonitor(nil, &config{Alerts: alertsConfig{HotWallets: []hotWallet{{Wallet: hot.Hex(), MinUSD: 1000}}}}, value)
+	if err != nil {
+		t.Fatal(err)
+	}
+	step := func() []alert { return m.transitions(m.firing(context.Background())) }
+
+	if got := step(); len(got) != 0 {
+		t.Errorf("funded: %v", got)
+	}
+	total = 10
+	if got := step(); len(got) != 1 || !got[0].Critical || got[0].Resolved {
+		t.Errorf("drained: %v", got)
+	}
+	if got := step(); len(got) != 0 {
+		t.Errorf("still drained alerted again: %v", got)
+	}
+	failed = true // unknown is not a refill
+	if got := step(); len(got) != 0 {
+		t.Errorf("failed valuation: %v", got)
+	}
+	failed, total = false, 5000
+	if got := step(); len(got) != 1 || !got[0].Resolved {
+		t.Errorf("refilled: %v", got)
+	}
+
+	if _, err := newMonitor(nil, &config{Alerts: alertsConfig{FeedMaxAge: "soon"}}, value); err == nil {
+		t.Error("bad feed_max_age accepted")
+	}
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+
+	"Test2/bindings"
+
+	"Test2/source"
+)
+
+// pendleOracle is Pendle's PY/LP oracle on mainnet. It quotes PT and YT in
+// units of the market's underlying asset from the market's TWAP.
+var pendleOracle = common.HexToAddress("0x66a1096C6366b2529274dF4f5D8247827fe4CEA8")
+
+// pendleTWAP is the averaging window passed to the oracle, in seconds.
+const pendleTWAP = uint32(900)
+
+var pendleABI = mustABI(`[
+  {"inputs":[],"name":"readTokens","outputs":[{"name":"SY","type":"address"},{"name":"PT","type":"address"},{"name":"YT","type":"address"}],"stateMutability":"view","type":"function"},
+  {"inputs":[],"name":"assetInfo","outputs":[{"name":"assetType","type":"uint8"},{"name":"assetAddress","type":"address"},{"name":"assetDecimals","type":"uint8"}],"stateMutability":"view","type":"function"},
+  {"inputs":[{"name":"market","type":"address"},{"name":"duration","type":"uint32"}],"name":"getPtToAssetRate","outputs":[{"type":"uint256"}],"stateMutability":"view","type":"function"},
+  {"inputs":[{"name":"market","type":"address"},{"name":"duration","type":"uint32"}],"name":"getYtToAssetRate","outputs":[{"type":"uint256"}],"stateMutability":"view","type":"function"}
+]`)
+
+// pendleHoldings values the wallet's PT and YT balances in each of the given
+// markets at the oracle's exchange rate to the underlying asset.
+func pendleHoldings(ctx context.Context, client chainClient, wallet common.Address, markets string) ([]*holding, error) {
+	var held []*holding
+	for _, m := range strings.Split(markets, ",") {
+		if m = strings.TrimSpace(m); m == "" {
+			continue
+		}
+		if !common.IsHexAddress(m) {
+			return held, fmt.Errorf("market: %w %q", source.ErrInvalidAddress, m)
+		}
+		market := common.HexToAddress(m)
+		vs, err := callView(ctx, client, market, pendleABI, "readTokens")
+		if err != nil {
+			return held, err
+		}
+		sy, pt, yt := vs[0].(common.Address), vs[1].(common.Address), vs[2].(common.Address)
+		vs, err = callView(ctx, client, sy, pendleABI, "assetInfo")
+		if err != nil {
+			return held, err
+		}
+		asset, err := resolveToken(ctx, client, vs[1].(common.Address))
+		if err != nil {
+			return held, err
+		}
+		asset.Decimals = int(vs[2].(uint8))
+
+		for _, leg := range []struct {
+			token  common.Address
+			method string
+		}{{pt, "getPtToAssetRate"}, {yt, "getYtToAssetRate"}} {
+			bal, err := erc20Balance(ctx, client, leg.token, wallet)
+			if err != nil {
+				return held, err
+			}
+			if bal.Sign() == 0 {
+				continue
+			}
+			vs, err := callView(ctx, client, pendleOracle, pendleABI, leg.method, market, pendleTWAP)
+			if err != nil {
+				return held, err
+			}
+			inAsset := new(big.Int).Mul(bal, vs[0].(*big.Int))
+			inAsset.Quo(inAsset, big.NewInt(1e18))
+			sym := "PT"
+			if leg.token == yt {
+				sym = "YT"
+			}
+			if token, err := bindings.NewERC20Caller(leg.token, client); err == nil {
+				if s, err := token.Symbol(&bind.CallOpts{Context: ctx}); err == nil {
+					sym = s
+				}
+			}
+			held = append(held, &holding{
+				tf:   asset,
+				amt:  tokenAmount(inAsset, asset.Decimals),
+				note: fmt.Sprintf("Pendle %s %s", tokenAmount(bal, asset.Decimals).Text('f', 4), sym),
+				id:   "pendle:" + leg.token.Hex(),
+			})
+		}
+	}
+	return held, nil
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"strconv"
+	"time"
+
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// A run reads each chain at one block, its head when the run starts unless
+// -pin-block names another, so that balances and prices read at different
+// moments during the run still add up, and a rerun at the same block gives
+// the same numbers. The block is recorded in the reports.
+
+type reportBlock struct {
+	Chain  string    `json:"chain" doc:"Chain the block is on."`
+	Number uint64    `json:"number" doc:"Block every balance and on-chain price of the chain was read at."`
+	Hash   string    `json:"hash" doc:"Hash of the block."`
+	Time   time.Time `json:"time" doc:"When the block was mined (RFC 3339, UTC)."`
+}
+
+// pin fixes the connection at the given block, the head one when nil.
+func (c *chainConn) pin(ctx context.Context, number *big.Int) error {
+	head, err := c.client.HeaderByNumber(ctx, number)
+	if err != nil {
+		if number == nil {
+			return fmt.Errorf("%s: head block: %w", chainName(c.id), err)
+		}
+		return fmt.Errorf("%s: block %s: %w", chainName(c.id), number, err)
+	}
+	c.setBlock(head)
+	c.recheckSequencer(ctx)
+	return nil
+}
+
+func (c *chainConn) setBlock(head *types.Header) {
+	c.block, c.blockHash, c.blockTime = head.Number, head.Hash(), time.Unix(int64(head.Time), 0).UTC()
+}
+
+// pinChains applies -pin-block: latest pins every chain at its head, a
+// block number pins the one chain valued at that past block, with the
+// prices of its time, and off leaves lookups reading the latest state.
+func pinChains(ctx context.Context, conns []*chainConn, spec string) error {
+	switch spec {
+	case "off":
+		return nil
+	case "latest":
+		for _, c := range conns {
+			if err := c.pin(ctx, nil); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	n, err := strconv.ParseUint(spec, 10, 64)
+	if err != nil {
+		return fmt.Errorf("want latest, off or a block number, not %q", spec)
+	}
+	if len(conns) != 1 {
+		return errors.New("a block number pins one chain; pass -rpc or a single -chains entry")
+	}
+	if err := conns[0].pin(ctx, new(big.Int).SetUint64(n)); err != nil {
+		return err
+	}
+	conns[0].historical = true
+	return nil
+}
+
+// stateTime is when the block a client reads was mined: the one it is
+// pinned at, else the head. A client serving no headers reads now.
+func stateTime(ctx context.Context, client chainClient) (time.Time, error) {
+	head, err := stateHeader(ctx, client)
+	if err != nil || head == nil {
+		return time.Now(), err
+	}
+	return time.Unix(int64(head.Time), 0), nil
+}
+
+// stateHeader is the header of the block a client reads, or nil when the
+// client serves no headers.
+func stateHeader(ctx context.Context, client chainClient) (*types.Header, error) {
+	hc, ok := client.(interface {
+		HeaderByNumber(ctx context.Context, number *big.Int) (*types.Header, error)
+	})
+	if !ok {
+		return nil, nil
+	}
+	head, err := hc.HeaderByNumber(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("block time: %w", err)
+	}
+	return head, nil
+}
+
+// pinnedBlocks lists the blocks the connections are pinned at.
+func pinnedBlocks(conns []*chainConn) []reportBlock {
+	var out []reportBlock
+	for _, c := range conns {
+		if c.block != nil {
+			out = append(out, reportBlock{Chain: chainName(c.id), Number: c.block.Uint64(), Hash: c.blockHash.Hex(), Time: c.blockTime})
+		}
+	}
+	return out
+}
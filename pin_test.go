@@ -0,0 +1,58 @@
+package main
+
+import (
+	"context"
+	"math/big"
+	"strings"
+	"testing"
+	"text/template"
+	"time"
+
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+func TestPinChains(t *testing.T) {
+	ctx := context.Background()
+	two := []*chainConn{{id: 1}, {id: 10}}
+	for _, spec := range []string{"", "head", "-1", "12x"} {
+		if err := pinChains(ctx, two, spec); err == nil {
+			t.Errorf("-pin-block %q accepted", spec)
+		}
+	}
+	if err := pinChains(ctx, two, "18000000"); err == nil {
+		t.Error("a block number pinned two chains")
+	}
+	if err := pinChains(ctx, two, "off"); err != nil || two[0].block != nil {
+		t.Errorf("off: %v, block %v", err, two[0].block)
+	}
+
+	mined := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	two[1].setBlock(&types.Header{Number: big.NewInt(120), Time: uint64(mined.Unix())})
+	got := pinnedBlocks(two)
+	if len(got) != 1 || got[0].Chain != "optimism" || got[0].Number != 120 || !got[0].Time.Equal(mined) || got[0].Hash != two[1].blockHash.Hex() {
+		t.Fatalf("pinned blocks %+v", got)
+	}
+	if two[1].historical {
+		t.Error("a pinned head is not historical")
+	}
+}
+
+func TestPrintBlocks(t *testing.T) {
+	mined := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	rep := &report{Blocks: []reportBlock{{Chain: "mainnet", Number: 19000000, Time: mined}}}
+	var b strings.Builder
+	printBlocks(&b, rep.Blocks)
+	if want := "mainnet block 19000000, " + mined.Local().Format("2006-01-02 15:04:05 MST") + "\n"; b.String() != want {
+		t.Errorf("text header %q, want %q", b.String(), want)
+	}
+
+	header := template.Must(template.New("h").Parse("{{range .Blocks}}{{.Chain}}@{{.Number}}{{end}}"))
+	summary := template.Must(template.New("s").Parse("TOTAL {{.TotalUSD}}"))
+	b.Reset()
+	if err := printTemplate(&b, rep, header, nil, summary, 0); err != nil {
+		t.Fatal(err)
+	}
+	if want := "mainnet@19000000\nTOTAL 0\n"; b.String() != want {
+		t.Errorf("template output %q, want %q", b.String(), want)
+	}
+}
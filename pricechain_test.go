@@ -0,0 +1,159 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core"
+
+	"Test2/source"
+)
+
+func TestPriceSourceOrder(t *testing.T) {
+	link, _ := tokenBySymbol("LINK")
+	sim := newSim(t, core.GenesisAlloc{
+		link.FeedAddr: mockFeed(8, scaled(t, "14.25", 8), time.Now().Unix()),
+	})
+	gecko := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasPrefix(r.URL.Path, "/simple/token_price/sim") {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte(`{"` + strings.ToLower(link.TokenAddr.Hex()) + `": {"usd": 14.5}}`))
+	}))
+	defer gecko.Close()
+	defer func(u string) { coingeckoURL = u }(coingeckoURL)
+	coingeckoURL = gecko.URL
+	coingeckoPlatforms[simChainID] = "sim"
+	defer delete(coingeckoPlatforms, simChainID)
+
+	file := priceFile{"MOCK": {Price: 3}}
+	for _, tc := range []struct {
+		name     string
+		order    priceSourcesConfig
+		tf       tokenFeed
+		usd      float64
+		source   string
+		errorHas string
+	}{
+		{"default", priceSourcesConfig{}, link, 14.25, "chainlink", ""},
+		{"global", priceSourcesConfig{Order: []string{"coingecko", "chainlink"}}, link, 14.5, "coingecko", ""},
+		{"by token", priceSourcesConfig{Order: []string{"coingecko"}, Tokens: map[string][]string{"link": {"chainlink"}}}, link, 14.25, "chainlink", ""},
+		{"falls through", priceSourcesConfig{Order: []string{"uniswap-twap", "coingecko", "manual"}}, tokenFeed{Symbol: "MOCK", TokenAddr: testToken}, 3, "manual", ""},
+		{"explains", priceSourcesConfig{Order: []string{"coingecko"}}, tokenFeed{Symbol: "MOCK", TokenAddr: testToken}, 0, "", "coingecko: "},
+	} {
+		h := &holding{tf: tc.tf, amt: big.NewFloat(1)}
+		newPricer(context.Background(), sim, simChainID, true, &config{PriceSources: tc.order}, options{PriceFile: file}).priceAll([]*holding{h})
+		switch {
+		case tc.errorHas != "":
+			if h.err == nil || !strings.Contains(h.err.Error(), tc.errorHas) {
+				t.Errorf("%s: error %v, want it to mention %q", tc.name, h.err, tc.errorHas)
+			}
+		case h.err != nil:
+			t.Errorf("%s: %v", tc.name, h.err)
+		case !floatEq(h.price, tc.usd) || h.source != tc.source:
+			t.Errorf("%s: $%s from %q, want $%g from %q", tc.name, h.price.Text('f', 4), h.source, tc.usd, tc.source)
+		}
+	}
+
+	h := &holding{tf: link, amt: big.NewFloat(1)}
+	newPricer(context.Background(), sim, simChainID, true, &config{}, options{Prices: priceOverrides{"LINK": 20}}).priceAll([]*holding{h})
+	if rep := newReport(testWallet.Hex(), []*holding{h}); rep.Positions[0].PriceSource != "override" {
+		t.Errorf("override recorded as %q", rep.Positions[0].PriceSource)
+	}
+
+	for _, bad := range []priceSourcesConfig{
+		{Order: []string{"chainlink", "oracle-of-delphi"}},
+		{Tokens: map[string][]string{"ETH": {"chainlink", "chainlink"}}},
+	} {
+		if err := bad.check(); err == nil {
+			t.Errorf("%+v accepted", bad)
+		}
+	}
+}
+
+func TestUniswapTWAP(t *testing.T) {
+	eth := defaultTokens[0]
+	factory := common.HexToAddress("0x0000000000000000000000000000000000f0c700")
+	pool := common.HexToAddress("0x0000000000000000000000000000000000001001")
+	weth := common.HexToAddress("0xffffffffffffffffffffffffffffffffffffff00")
+	// An average tick of 23027 over the window is 1.0001^23027 ≈ 10 WETH
+	// per token, the token being the pool's token0.
+	cumulative := big.NewInt(23027 * twapWindow)
+	sim := newSim(t, core.GenesisAlloc{
+		eth.FeedAddr: mockFeed(8, scaled(t, "3000", 8), time.Now().Unix()),
+		factory: {
+			Code:    mockCode(keyedMethod(uniswapFactoryABI, "getPool")),
+			Storage: map[common.Hash]common.Hash{common.BytesToHash(testToken.Bytes()): common.BytesToHash(pool.Bytes())},
+			Balance: new(big.Int),
+		},
+		pool: {
+			Code: mockCode(method(uniswapPoolABI, "liquidity", 1), method(uniswapPoolABI, "observe", 10, 11, 12, 13, 14, 15)),
+			Storage: map[common.Hash]common.Hash{
+				word(1): word(1e12),
+				// Two dynamic arrays: their offsets, then each one's length
+				// and elements. The mock answers at most 7 words, so the
+				// unused second one is empty.
+				word(10): word(0x40), word(11): word(0xa0),
+				word(12): word(2), word(13): word(0), word(14): bigWord(cumulative),
+				word(15): word(0),
+			},
+			Balance: new(big.Int),
+		},
+	})
+	uniswapFactories[simChainID], wrappedNative[simChainID] = factory, weth
+	defer delete(uniswapFactories, simChainID)
+	defer delete(wrappedNative, simChainID)
+
+	h := &holding{tf: tokenFeed{Symbol: "MOCK", TokenAddr: testToken, Decimals: 18}, amt: big.NewFloat(2)}
+	cfg := &config{PriceSources: priceSourcesConfig{Order: []string{"uniswap-twap"}}}
+	newPricer(context.Background(), sim, simChainID, true, cfg, options{}).priceAll([]*holding{h})
+	if h.err != nil {
+		t.Fatal(h.err)
+	}
+	if usd, _ := h.usd().Float64(); usd < 59990 || usd > 60010 || h.source != "uniswap-twap" {
+		t.Errorf("2 MOCK = $%.2f from %q, want about $60000 from uniswap-twap", usd, h.source)
+	}
+}
+
+func TestPriceDeviation(t *testing.T) {
+	link, _ := tokenBySymbol("LINK")
+	sim := newSim(t, core.GenesisAlloc{
+		link.FeedAddr: mockFeed(8, scaled(t, "14.25", 8), time.Now().Unix()),
+	})
+	file := priceFile{"LINK": {Price: 20}}
+	for _, tc := range []struct {
+		max        float64
+		deviations int
+	}{{0, 0}, {50, 0}, {10, 1}} {
+		h := &holding{tf: link, amt: big.NewFloat(1)}
+		cfg := &config{PriceSources: priceSourcesConfig{Order: []string{"chainlink", "manual"}, MaxDeviation: tc.max}}
+		newPricer(context.Background(), sim, simChainID, true, cfg, options{PriceFile: file}).priceAll([]*holding{h})
+		if h.err != nil || !floatEq(h.price, 14.25) || h.source != "chainlink" {
+			t.Fatalf("max %g%%: $%v from %q, %v", tc.max, h.price, h.source, h.err)
+		}
+		if len(h.deviations) != tc.deviations || len(failures([]*holding{h})) != tc.deviations {
+			t.Errorf("max %g%%: deviations %+v", tc.max, h.deviations)
+		}
+		if tc.deviations == 0 {
+			continue
+		}
+		d := newReport(testWallet.Hex(), []*holding{h}).Positions[0].Deviations
+		if len(d) != 1 || d[0].Source != "manual" || d[0].Price != 20 || d[0].Pct < 40.3 || d[0].Pct > 40.4 {
+			t.Errorf("reported deviations %+v", d)
+		}
+		if err := deviationError(h); !errors.Is(err, source.ErrPriceDeviation) || !strings.Contains(err.Error(), "manual $20") {
+			t.Errorf("strict error %v", err)
+		}
+	}
+	if err := (priceSourcesConfig{MaxDeviation: -1}).check(); err == nil {
+		t.Error("negative max_deviation_pct accepted")
+	}
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// priceFile holds last-resort USD prices for assets no source can price,
+// such as illiquid or OTC-valued tokens, keyed by upper-case symbol or by
+// lower-case contract address. An address entry wins over a symbol entry.
+type priceFile map[string]filePrice
+
+// filePrice is one entry; Time, when given, is when the price was set.
+type filePrice struct {
+	Price float64
+	Time  time.Time
+}
+
+// loadPriceFile reads a .json or .csv price file. CSV rows are
+// asset,price[,time] with an optional asset,price,time header; JSON is a
+// list of {"asset", "price", "time"} objects. Assets are symbols or
+// contract addresses, times are RFC 3339 or YYYY-MM-DD.
+func loadPriceFile(path string) (priceFile, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	pf := priceFile{}
+	add := func(asset, price, when string) error {
+		var p filePrice
+		if p.Price, err = strconv.ParseFloat(strings.TrimSpace(price), 64); err != nil || p.Price < 0 {
+			return fmt.Errorf("bad price %q for %s", price, asset)
+		}
+		if when = strings.TrimSpace(when); when != "" {
+			if p.Time, err = parseTime(when); err != nil {
+				return fmt.Errorf("bad time %q for %s", when, asset)
+			}
+		}
+		pf[priceFileKey(strings.TrimSpace(asset))] = p
+		return nil
+	}
+
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		var rows []struct {
+			Asset string  `json:"asset"`
+			Price float64 `json:"price"`
+			Time  string  `json:"time"`
+		}
+		if err := json.NewDecoder(f).Decode(&rows); err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		for _, r := range rows {
+			if err := add(r.Asset, strconv.FormatFloat(r.Price, 'g', -1, 64), r.Time); err != nil {
+				return nil, fmt.Errorf("%s: %w", path, err)
+			}
+		}
+		return pf, nil
+	}
+
+	r := csv.NewReader(f)
+	r.FieldsPerRecord = -1
+	r.Comment = '#'
+	for line := 1; ; line++ {
+		rec, err := r.Read()
+		if errors.Is(err, io.EOF) {
+			return pf, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		if line == 1 && strings.EqualFold(strings.TrimSpace(rec[0]), "asset") {
+			continue
+		}
+		if len(rec) < 2 || len(rec) > 3 {
+			return nil, fmt.Errorf("%s:%d: want asset,price[,time]", path, line)
+		}
+		when := ""
+		if len(rec) == 3 {
+			when = rec[2]
+		}
+		if err := add(rec[0], rec[1], when); err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", path, line, err)
+		}
+	}
+}
+
+func parseTime(s string) (time.Time, error) {
+	if t, err := time.Parse(time.DateOnly, s); err == nil {
+		return t, nil
+	}
+	return time.Parse(time.RFC3339, s)
+}
+
+func priceFileKey(asset string) string {
+	if common.IsHexAddress(asset) {
+		return strings.ToLower(common.HexToAddress(asset).Hex())
+	}
+	return strings.ToUpper(asset)
+}
+
+// lookup finds the entry for a token by contract address, then by symbol.
+// It is safe on a nil priceFile.
+func (pf priceFile) lookup(tf tokenFeed) (filePrice, bool) {
+	if tf.TokenAddr != (common.Address{}) {
+		if p, ok := pf[strings.ToLower(tf.TokenAddr.Hex())]; ok {
+			return p, true
+		}
+	}
+	p, ok := pf[strings.ToUpper(tf.Symbol)]
+	return p, ok
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoadPriceFile(t *testing.T) {
+	dir := t.TempDir()
+	csvPath := filepath.Join(dir, "prices.csv")
+	os.WriteFile(csvPath, []byte("asset,price,time\n# OTC marks\nfoo,1.25,2024-05-01\n0x000000000000000000000000000000000000700c,3\n"), 0o644)
+	jsonPath := filepath.Join(dir, "prices.json")
+	os.WriteFile(jsonPath, []byte(`[{"asset":"FOO","price":1.25,"time":"2024-05-01T00:00:00Z"},{"asset":"0x000000000000000000000000000000000000700C","price":3}]`), 0o644)
+
+	for _, path := range []string{csvPath, jsonPath} {
+		pf, err := loadPriceFile(path)
+		if err != nil {
+			t.Fatalf("%s: %v", path, err)
+		}
+		p, ok := pf.lookup(tokenFeed{Symbol: "Foo"})
+		if !ok || p.Price != 1.25 || p.Time.Format("2006-01-02") != "2024-05-01" {
+			t.Errorf("%s: Foo = %+v, %v", path, p, ok)
+		}
+		// The address entry wins over the symbol.
+		p, ok = pf.lookup(tokenFeed{Symbol: "FOO", TokenAddr: testToken})
+		if !ok || p.Price != 3 || !p.Time.IsZero() {
+			t.Errorf("%s: by address = %+v, %v", path, p, ok)
+		}
+	}
+
+	bad := filepath.Join(dir, "bad.csv")
+	os.WriteFile(bad, []byte("foo,cheap\n"), 0o644)
+	if _, err := loadPriceFile(bad); err == nil {
+		t.Error("bad price: want error")
+	}
+}
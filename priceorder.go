@@ -0,0 +1,91 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"Test2/source"
+)
+
+// priceSourcesConfig orders the sources a token's price is looked up in,
+// for every token or by symbol, such as
+//
+//	{"order": ["chainlink", "uniswap-twap", "coingecko", "manual"],
+//	 "tokens": {"RPL": ["uniswap-twap", "defillama"]}}
+//
+// The first source with an answer prices the token; the rest explain why
+// none did. -price and the fixed prices of config-defined chains still win
+// over all of them. With max_deviation_pct the rest are asked as well, and
+// answers further than that percentage from the price are reported.
+type priceSourcesConfig struct {
+	Order        []string            `json:"order"`
+	Tokens       map[string][]string `json:"tokens"`
+	MaxDeviation float64             `json:"max_deviation_pct"`
+}
+
+// builtinPriceSources are the names of the lookups the pricer knows itself.
+// "manual" is the -prices-file.
+var builtinPriceSources = []string{"feed-path", "chainlink", "1inch", "pyth", "redstone", "tellor", "uniswap-twap", "coingecko", "defillama", "manual"}
+
+// defaultPriceOrder is the order without a price_sources config: the
+// on-chain lookups, the registered sources, then DefiLlama and the price
+// file.
+func defaultPriceOrder() []string {
+	order := []string{"feed-path", "chainlink", "1inch", "pyth"}
+	for _, r := range source.Prices() {
+		order = append(order, r.Name)
+	}
+	return append(order, "defillama", "manual")
+}
+
+// priceSourceNames lists every source an order may name.
+func priceSourceNames() []string {
+	names := slices.Clone(builtinPriceSources)
+	for _, r := range source.Prices() {
+		names = append(names, r.Name)
+	}
+	return names
+}
+
+// check rejects unknown and repeated sources.
+func (c priceSourcesConfig) check() error {
+	if c.MaxDeviation < 0 {
+		return fmt.Errorf("max_deviation_pct %g is negative", c.MaxDeviation)
+	}
+	known := priceSourceNames()
+	valid := func(order []string) error {
+		for i, name := range order {
+			if !slices.Contains(known, name) {
+				return fmt.Errorf("unknown source %q (want %s)", name, strings.Join(known, ", "))
+			}
+			if slices.Contains(order[:i], name) {
+				return fmt.Errorf("%s listed twice", name)
+			}
+		}
+		return nil
+	}
+	if err := valid(c.Order); err != nil {
+		return err
+	}
+	for sym, order := range c.Tokens {
+		if err := valid(order); err != nil {
+			return fmt.Errorf("%s: %w", sym, err)
+		}
+	}
+	return nil
+}
+
+// of is the order a token's price is looked up in. Symbols compare
+// case-insensitively.
+func (c priceSourcesConfig) of(symbol string) []string {
+	for sym, order := range c.Tokens {
+		if strings.EqualFold(sym, symbol) {
+			return order
+		}
+	}
+	if len(c.Order) > 0 {
+		return c.Order
+	}
+	return defaultPriceOrder()
+}
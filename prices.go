@@ -0,0 +1,272 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+
+	"Test2/source"
+)
+
+// priceOverrides are USD prices given on the command line by symbol
+// (-price LINK=14.25), which win over every other source. It is a
+// flag.Value; the flag may be repeated or given a comma-separated list.
+type priceOverrides map[string]float64
+
+func (o priceOverrides) String() string {
+	var parts []string
+	for sym, v := range o {
+		parts = append(parts, fmt.Sprintf("%s=%g", sym, v))
+	}
+	sort.Strings(parts)
+	return strings.Join(parts, ",")
+}
+
+func (o priceOverrides) Set(s string) error {
+	for _, kv := range strings.Split(s, ",") {
+		sym, val, ok := strings.Cut(strings.TrimSpace(kv), "=")
+		if !ok || sym == "" {
+			return fmt.Errorf("want SYMBOL=USD, got %q", kv)
+		}
+		v, err := strconv.ParseFloat(val, 64)
+		if err != nil || v < 0 {
+			return fmt.Errorf("bad price in %q", kv)
+		}
+		o[strings.ToUpper(sym)] = v
+	}
+	return nil
+}
+
+// lookup returns the override for a symbol, matched case-insensitively.
+func (o priceOverrides) lookup(sym string) (float64, bool) {
+	v, ok := o[strings.ToUpper(sym)]
+	return v, ok
+}
+
+// pricer resolves USD prices for holdings. Feed answers are remembered so a
+// feed shared by several holdings is only read once per run; read collects
+// the ones the source being tried used.
+type pricer struct {
+	ctx          context.Context
+	client       chainClient
+	chainID      uint64
+	at           time.Time // the past time prices are wanted for; zero for now
+	chain        string    // DefiLlama chain name
+	native       tokenFeed
+	fixed        map[common.Address]float64 // by token contract, zero for the native coin
+	overrides    priceOverrides
+	file         priceFile
+	paths        map[common.Address][]feedLeg // configured feed compositions by token
+	trustFeeds   bool
+	feeds        map[common.Address]*feedRead
+	read         []*feedRead
+	order        priceSourcesConfig
+	pythCfg      pythConfig
+	tellorCfg    tellorConfig
+	redstoneCfg  redstoneConfig
+	redstoneData map[string][]redstonePackage // the gateway's packages, fetched on first use
+	redstoneErr  error
+	sources      map[string]source.PriceSource
+	tokens       map[source.Asset]tokenFeed // registry entries of the assets asked about
+}
+
+func newPricer(ctx context.Context, client chainClient, chainID uint64, trustFeeds bool, cfg *config, opts options) *pricer {
+	p := &pricer{
+		ctx:         ctx,
+		client:      client,
+		chainID:     chainID,
+		chain:       llamaChains[chainID],
+		native:      nativeToken(chainID),
+		fixed:       fixedPrices[chainID],
+		overrides:   opts.Prices,
+		file:        opts.PriceFile,
+		paths:       cfg.feedPaths(chainID),
+		order:       cfg.PriceSources,
+		pythCfg:     cfg.Pyth,
+		tellorCfg:   cfg.Tellor,
+		redstoneCfg: cfg.Redstone,
+		trustFeeds:  trustFeeds,
+		feeds:       map[common.Address]*feedRead{},
+		tokens:      map[source.Asset]tokenFeed{},
+	}
+	p.sources = p.priceSources()
+	return p
+}
+
+// feed returns the current answer of a Chainlink feed.
+func (p *pricer) feed(addr common.Address) (*big.Float, error) {
+	if !p.trustFeeds {
+		return nil, fmt.Errorf("%w: Chainlink answers untrusted while the sequencer is down", source.ErrStaleFeed)
+	}
+	r, ok := p.feeds[addr]
+	if !ok {
+		var err error
+		if r, err = readFeed(p.ctx, p.client, addr); err != nil {
+			return nil, err
+		}
+		p.feeds[addr] = r
+	}
+	if !slices.Contains(p.read, r) {
+		p.read = append(p.read, r)
+	}
+	return r.answer, nil
+}
+
+// ethUSD is the ETH/USD answer, used to convert ETH-quoted rates.
+func (p *pricer) ethUSD() (*big.Float, error) {
+	return p.feed(p.native.FeedAddr)
+}
+
+// path multiplies the answers of a configured chain of feeds, such as
+// TOKEN/ETH × ETH/USD. Answers are already scaled by their feed's decimals,
+// so an inverted leg simply divides.
+func (p *pricer) path(legs []feedLeg) (*big.Float, error) {
+	price := big.NewFloat(1)
+	for _, leg := range legs {
+		v, err := p.feed(leg.Feed)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", leg.Feed.Hex(), err)
+		}
+		if !leg.Invert {
+			price.Mul(price, v)
+			continue
+		}
+		if v.Sign() <= 0 {
+			return nil, fmt.Errorf("%s: cannot invert answer %s", leg.Feed.Hex(), v.Text('g', 10))
+		}
+		price.Quo(price, v)
+	}
+	return price, nil
+}
+
+// priceAttempt is a holding's way down its price sources.
+type priceAttempt struct {
+	h       *holding
+	order   []string
+	next    int
+	reasons causes
+}
+
+// try asks the attempt's sources in turn until one prices the holding or
+// none are left, and reports whether it stopped at DefiLlama, which is
+// asked for every holding at once. When cross-checking, the sources after
+// the one that priced the holding are asked too and compared with it.
+func (p *pricer) try(a *priceAttempt) (atLlama bool) {
+	asset := assetOf(p.chainID, a.h.tf)
+	p.tokens[asset] = a.h.tf
+	for ; a.next < len(a.order); a.next++ {
+		name := a.order[a.next]
+		if name == "defillama" {
+			if p.chain != "" {
+				return true
+			}
+			continue
+		}
+		s, ok := p.sources[name]
+		if !ok {
+			continue // registered, but not for this chain
+		}
+		key := fmt.Sprintf("%s %s on %s", name, asset.Symbol, chainName(p.chainID))
+		p.read = nil
+		price, err := guard(p.ctx, circuits, key, func() (*big.Float, error) { return s.Price(p.ctx, asset) })
+		switch {
+		case err == nil && a.h.price != nil:
+			p.crossCheck(a.h, name, price)
+			continue
+		case err == nil:
+			a.h.price, a.h.source, a.h.feeds = price, name, p.read
+			if p.order.MaxDeviation == 0 {
+				return false
+			}
+			continue
+		}
+		if !errors.Is(err, source.ErrUnsupported) {
+			a.reasons = append(a.reasons, fmt.Errorf("%s: %w", name, err))
+		}
+	}
+	return false
+}
+
+func (p *pricer) oneInch(tf tokenFeed) (*big.Float, error) {
+	rate, err := oneInchPriceETH(p.ctx, p.client, p.chainID, tf)
+	if err != nil {
+		return nil, err
+	}
+	eth, err := p.ethUSD()
+	if err != nil {
+		return nil, fmt.Errorf("ETH/USD: %w", err)
+	}
+	return new(big.Float).Mul(rate, eth), nil
+}
+
+// priceAll fills in the price of every holding that does not already have
+// one, and replaces it for symbols given with -price. Fixed prices from the
+// config come next, then each token's price sources in order; see
+// priceSourcesConfig. Holdings waiting on DefiLlama are asked for in one
+// batch. Holdings nobody can price get an error saying why.
+func (p *pricer) priceAll(held []*holding) {
+	var attempts, waiting []*priceAttempt
+	for _, h := range held {
+		if h.err != nil {
+			continue
+		}
+		if v, ok := p.overrides.lookup(h.tf.Symbol); ok {
+			h.price, h.source = big.NewFloat(v), "override"
+			continue
+		}
+		if h.price != nil {
+			continue
+		}
+		if v, ok := p.fixed[h.tf.TokenAddr]; ok {
+			h.price, h.source = big.NewFloat(v), "fixed"
+			continue
+		}
+		a := &priceAttempt{h: h, order: p.order.of(h.tf.Symbol)}
+		attempts = append(attempts, a)
+		if p.try(a) {
+			waiting = append(waiting, a)
+		}
+	}
+
+	var keys []string
+	for _, a := range waiting {
+		keys = append(keys, llamaKey(p.chain, a.h.tf))
+	}
+	fallback, llamaErr := llamaPrices(p.ctx, p.at, keys)
+	if llamaErr != nil {
+		log.Printf("price fallback: %v", llamaErr)
+	}
+	for _, a := range waiting {
+		price := fallback[llamaKey(p.chain, a.h.tf)]
+		switch {
+		case price != nil && a.h.price != nil:
+			p.crossCheck(a.h, "defillama", price)
+		case price != nil:
+			a.h.price, a.h.source = price, "defillama"
+			if p.order.MaxDeviation == 0 {
+				continue
+			}
+		case llamaErr != nil:
+			a.reasons = append(a.reasons, llamaErr)
+		default:
+			a.reasons = append(a.reasons, errors.New("defillama: unknown coin"))
+		}
+		a.next++
+		p.try(a)
+	}
+
+	for _, a := range attempts {
+		if a.h.price == nil {
+			a.h.err = fmt.Errorf("%w (%w)", source.ErrNoPriceSource, a.reasons)
+		}
+	}
+}
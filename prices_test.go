@@ -0,0 +1,250 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core"
+
+	"Test2/source"
+)
+
+// The simulated chain ID (1337) has no DefiLlama name, so these tests only
+// exercise the on-chain sources and never reach the network.
+const simChainID = 1337
+
+func TestPriceAllChainlink(t *testing.T) {
+	link, _ := tokenBySymbol("LINK")
+	sim := newSim(t, core.GenesisAlloc{
+		link.FeedAddr: mockFeed(8, scaled(t, "14.25", 8), time.Now().Unix()),
+	})
+
+	h := &holding{tf: link, amt: big.NewFloat(10)}
+	newPricer(context.Background(), sim, simChainID, true, &config{}, options{}).priceAll([]*holding{h})
+	if h.err != nil {
+		t.Fatal(h.err)
+	}
+	if !floatEq(h.usd(), 142.5) {
+		t.Errorf("usd = %s, want 142.5", h.usd().Text('f', 6))
+	}
+}
+
+func TestPriceAllOneInchFallback(t *testing.T) {
+	eth := defaultTokens[0]
+	// A 6-decimals token worth 0.0005 ETH: the oracle's rate is scaled by
+	// 1e18 and by 10^(18-6).
+	tf := tokenFeed{Symbol: "MOCK", TokenAddr: testToken, Decimals: 6}
+	rate := scaled(t, "0.0005", 30)
+	oracle := oneInchOracles[1]
+	sim := newSim(t, core.GenesisAlloc{
+		eth.FeedAddr: mockFeed(8, scaled(t, "3000", 8), time.Now().Unix()),
+		oracle:       mockOneInch(map[common.Address]*big.Int{testToken: rate}),
+	})
+	oneInchOracles[simChainID] = oracle
+	defer delete(oneInchOracles, simChainID)
+
+	h := &holding{tf: tf, amt: big.NewFloat(100)}
+	newPricer(context.Background(), sim, simChainID, true, &config{}, options{}).priceAll([]*holding{h})
+	if h.err != nil {
+		t.Fatal(h.err)
+	}
+	if !floatEq(h.price, 1.5) {
+		t.Errorf("price = %s, want 1.5", h.price.Text('f', 6))
+	}
+}
+
+func TestPriceAllUntrustedFeeds(t *testing.T) {
+	link, _ := tokenBySymbol("LINK")
+	sim := newSim(t, core.GenesisAlloc{
+		link.FeedAddr: mockFeed(8, scaled(t, "14.25", 8), time.Now().Unix()),
+	})
+
+	// With the sequencer down the feed answer is not used, and a chain
+	// without a 1inch oracle does not ask it.
+	h := &holding{tf: link, amt: big.NewFloat(10)}
+	newPricer(context.Background(), sim, simChainID, false, &config{}, options{}).priceAll([]*holding{h})
+	if h.err == nil || strings.Contains(h.err.Error(), "1inch") {
+		t.Errorf("err = %v, want 1inch skipped", h.err)
+	}
+
+	// One listed but not deployed fails too, with both reasons.
+	oneInchOracles[simChainID] = oneInchOracles[1]
+	defer delete(oneInchOracles, simChainID)
+	h = &holding{tf: link, amt: big.NewFloat(10)}
+	newPricer(context.Background(), sim, simChainID, false, &config{}, options{}).priceAll([]*holding{h})
+	if h.price != nil {
+		t.Fatalf("price = %s, want none", h.price.Text('f', 6))
+	}
+	if h.err == nil || !strings.Contains(h.err.Error(), "chainlink: stale: Chainlink answers untrusted") ||
+		!strings.Contains(h.err.Error(), "1inch:") {
+		t.Errorf("err = %v, want both sources explained", h.err)
+	}
+	if !errors.Is(h.err, source.ErrNoPriceSource) || !errors.Is(h.err, source.ErrStaleFeed) {
+		t.Errorf("err = %v, want it to wrap source.ErrNoPriceSource and source.ErrStaleFeed", h.err)
+	}
+}
+
+func TestCheckSequencer(t *testing.T) {
+	ctx := context.Background()
+	uptime := common.HexToAddress("0x00000000000000000000000000000000000000cc")
+	sequencerFeeds[simChainID] = uptime
+	defer delete(sequencerFeeds, simChainID)
+
+	// Nothing answers at the feed address: not trusted.
+	sim := newSim(t, core.GenesisAlloc{})
+	if ok, err := checkSequencer(ctx, sim, simChainID); ok || !errors.Is(err, source.ErrStaleFeed) {
+		t.Errorf("unreadable feed: ok = %v, err = %v, want untrusted and stale", ok, err)
+	}
+
+	// The grace period runs to the block read, not to now.
+	const started = 1_000
+	for _, tc := range []struct {
+		upFor time.Duration
+		want  bool
+	}{{30 * time.Minute, false}, {2 * time.Hour, true}} {
+		sim := newSim(t, core.GenesisAlloc{uptime: mockFeed(0, big.NewInt(0), started)})
+		head, err := sim.HeaderByNumber(ctx, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := sim.AdjustTime(time.Unix(started, 0).Add(tc.upFor).Sub(time.Unix(int64(head.Time), 0))); err != nil {
+			t.Fatal(err)
+		}
+		sim.Commit()
+		if ok, err := checkSequencer(ctx, sim, simChainID); ok != tc.want {
+			t.Errorf("up for %s: ok = %v (%v), want %v", tc.upFor, ok, err, tc.want)
+		}
+	}
+}
+
+func TestPriceAllKeepsPresetPrices(t *testing.T) {
+	sim := newSim(t, core.GenesisAlloc{})
+
+	h := &holding{tf: usdToken, amt: big.NewFloat(5), price: big.NewFloat(1)}
+	failed := &holding{tf: tokenFeed{Symbol: "source"}, err: errFake}
+	newPricer(context.Background(), sim, simChainID, true, &config{}, options{}).priceAll([]*holding{h, failed})
+	if h.err != nil || h.price.Cmp(big.NewFloat(1)) != 0 {
+		t.Errorf("preset price changed: %v %v", h.price, h.err)
+	}
+	if failed.err != errFake {
+		t.Errorf("failed holding err = %v, want it kept", failed.err)
+	}
+}
+
+func TestPriceAllOverrides(t *testing.T) {
+	link, _ := tokenBySymbol("LINK")
+	sim := newSim(t, core.GenesisAlloc{})
+	overrides := priceOverrides{}
+	if err := overrides.Set("link=14.25, USD=0.99"); err != nil {
+		t.Fatal(err)
+	}
+
+	// LINK has no feed here, and USD's preset price is replaced.
+	h := &holding{tf: link, amt: big.NewFloat(2)}
+	usd := &holding{tf: usdToken, amt: big.NewFloat(100), price: big.NewFloat(1)}
+	newPricer(context.Background(), sim, simChainID, true, &config{}, options{Prices: overrides}).priceAll([]*holding{h, usd})
+	if h.err != nil || !floatEq(h.price, 14.25) {
+		t.Errorf("LINK price = %v (%v), want 14.25", h.price, h.err)
+	}
+	if !floatEq(usd.price, 0.99) {
+		t.Errorf("USD price = %v, want 0.99", usd.price)
+	}
+
+	for _, bad := range []string{"LINK", "=3", "LINK=abc", "LINK=-1"} {
+		if err := (priceOverrides{}).Set(bad); err == nil {
+			t.Errorf("Set(%q): want error", bad)
+		}
+	}
+}
+
+func TestPriceAllFeedPath(t *testing.T) {
+	eth := defaultTokens[0]
+	tokenETH := common.HexToAddress("0x00000000000000000000000000000000000fee01")
+	sim := newSim(t, core.GenesisAlloc{
+		tokenETH:     mockFeed(18, scaled(t, "0.002", 18), time.Now().Unix()),
+		eth.FeedAddr: mockFeed(8, scaled(t, "3000", 8), time.Now().Unix()),
+	})
+
+	tf := tokenFeed{Symbol: "MOCK", TokenAddr: testToken, Decimals: 18}
+	p := newPricer(context.Background(), sim, simChainID, true, &config{}, options{})
+	p.paths = map[common.Address][]feedLeg{testToken: {{Feed: tokenETH}, {Feed: eth.FeedAddr}}}
+	h := &holding{tf: tf, amt: big.NewFloat(10)}
+	p.priceAll([]*holding{h})
+	if h.err != nil {
+		t.Fatal(h.err)
+	}
+	if !floatEq(h.price, 6) {
+		t.Errorf("price = %s, want 6", h.price.Text('f', 6))
+	}
+}
+
+func TestPriceAllInvertedFeed(t *testing.T) {
+	usdX := common.HexToAddress("0x00000000000000000000000000000000000fee02")
+	sim := newSim(t, core.GenesisAlloc{
+		// USD/X at 4 X per dollar, with 6 decimals.
+		usdX: mockFeed(6, scaled(t, "4", 6), time.Now().Unix()),
+	})
+
+	tf := tokenFeed{Symbol: "X", TokenAddr: testToken, Decimals: 18}
+	p := newPricer(context.Background(), sim, simChainID, true, &config{}, options{})
+	p.paths = map[common.Address][]feedLeg{testToken: {{Feed: usdX, Invert: true}}}
+	h := &holding{tf: tf, amt: big.NewFloat(10)}
+	p.priceAll([]*holding{h})
+	if h.err != nil {
+		t.Fatal(h.err)
+	}
+	if !floatEq(h.price, 0.25) {
+		t.Errorf("price = %s, want 0.25", h.price.Text('f', 6))
+	}
+}
+
+func TestPriceAllNativeCoins(t *testing.T) {
+	pol := nativeToken(137)
+	if pol.Symbol != "POL" || pol.TokenAddr != (common.Address{}) || nativeToken(43114).Symbol != "AVAX" || nativeToken(56).Symbol != "BNB" {
+		t.Fatalf("native coins %v, %v, %v", pol, nativeToken(43114), nativeToken(56))
+	}
+	for _, tf := range registry(137)[1:] {
+		if tf.Symbol == "ETH" && tf.FeedAddr == pol.FeedAddr {
+			t.Errorf("bridged %s priced by the POL feed", tf.TokenAddr.Hex())
+		}
+	}
+
+	// Polygon's ERC-20 face of POL is held as POL.
+	face := common.HexToAddress("0x0000000000000000000000000000000000001010")
+	if h := holdingOf(137, source.Position{Asset: source.Asset{Chain: 137, Address: face, Symbol: "MATIC", Decimals: 18}, Amount: big.NewFloat(1)}); h.tf != pol {
+		t.Errorf("the ERC-20 face is held as %+v", h.tf)
+	}
+
+	// A configured chain whose gas token is an ERC-20, listed among its
+	// tokens too, scans it once, as the native coin.
+	defer func(saved []chainProfile) { chains = saved }(chains)
+	defer delete(customRegistries, 4244)
+	defer delete(fixedPrices, 4244)
+	gasToken := common.HexToAddress("0x0000000000000000000000000000000000ce10")
+	err := addChain(chainConfig{Name: "celoish", ChainID: 4244,
+		Native: customToken{Symbol: "GAS", Address: gasToken, Decimals: 18, Price: 0.5},
+		Tokens: []customToken{{Symbol: "GAS", Address: gasToken}, {Symbol: "USDX", Address: testToken, Decimals: 6}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r := registry(4244); len(r) != 2 || r[0].TokenAddr != (common.Address{}) || r[0].Symbol != "GAS" || r[1].Symbol != "USDX" {
+		t.Errorf("registry %+v", r)
+	}
+	if tf, ok := registryToken(4244, gasToken); !ok || tf != nativeToken(4244) {
+		t.Errorf("the gas token resolves to %+v", tf)
+	}
+
+	sim := newSim(t, core.GenesisAlloc{
+		pol.FeedAddr: mockFeed(8, scaled(t, "0.42", 8), time.Now().Unix()),
+	})
+	h := &holding{tf: pol, amt: big.NewFloat(100)}
+	newPricer(context.Background(), sim, 137, true, &config{}, options{}).priceAll([]*holding{h})
+	if h.err != nil || !floatEq(h.usd(), 42) || h.source != "chainlink" {
+		t.Errorf("100 POL = $%v from %q: %v", h.usd(), h.source, h.err)
+	}
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// runProgress, when set, shows what a long run is doing on stderr.
+var runProgress *progress
+
+// progress is a status line naming the current wallet, chain and step with
+// the elapsed time. On a terminal it is redrawn in place several times a
+// second; otherwise a line is written every progressLogInterval. Every
+// method is a no-op on a nil *progress.
+type progress struct {
+	mu      sync.Mutex
+	w       *os.File
+	tty     bool
+	start   time.Time
+	wallets int
+	chain   string
+	step    string
+	hidden  bool
+	done    chan struct{}
+}
+
+const progressLogInterval = 10 * time.Second
+
+// isTerminal reports whether f is a character device such as a terminal.
+func isTerminal(f *os.File) bool {
+	fi, err := f.Stat()
+	return err == nil && fi.Mode()&os.ModeCharDevice != 0
+}
+
+func startProgress(w *os.File) *progress {
+	p := &progress{w: w, tty: isTerminal(w), start: time.Now(), hidden: true, done: make(chan struct{})}
+	interval := progressLogInterval
+	if p.tty {
+		interval = 200 * time.Millisecond
+	}
+	go func() {
+		t := time.NewTicker(interval)
+		defer t.Stop()
+		for {
+			select {
+			case <-t.C:
+				p.draw()
+			case <-p.done:
+				return
+			}
+		}
+	}()
+	return p
+}
+
+// wallet starts the next wallet of the run.
+func (p *progress) wallet() {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.wallets++
+	p.chain, p.step, p.hidden = "", "", false
+}
+
+// at names the chain now being scanned.
+func (p *progress) at(chain string) {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.chain, p.step, p.hidden = chain, "", false
+}
+
+// set describes the step in progress.
+func (p *progress) set(format string, args ...any) {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.step, p.hidden = fmt.Sprintf(format, args...), false
+}
+
+// clear removes the status line so results can be printed, until the next
+// update.
+func (p *progress) clear() {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.tty && !p.hidden {
+		fmt.Fprint(p.w, "\r\033[K")
+	}
+	p.hidden = true
+}
+
+// Stop clears the status line and stops redrawing it.
+func (p *progress) Stop() {
+	if p == nil {
+		return
+	}
+	p.clear()
+	close(p.done)
+}
+
+// Write prints log output on its own line rather than after the status
+// line; the status line is redrawn on the next tick.
+func (p *progress) Write(b []byte) (int, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.tty {
+		fmt.Fprint(p.w, "\r\033[K")
+	}
+	return p.w.Write(b)
+}
+
+func (p *progress) draw() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.hidden {
+		return
+	}
+	line := fmt.Sprintf("[%s] wallet %d", time.Since(p.start).Round(time.Second), p.wallets)
+	if p.chain != "" {
+		line += " · " + p.chain
+	}
+	if p.step != "" {
+		line += " · " + p.step
+	}
+	if p.tty {
+		fmt.Fprint(p.w, "\r\033[K"+line)
+	} else {
+		fmt.Fprintln(p.w, line)
+	}
+}
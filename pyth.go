@@ -0,0 +1,216 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+
+	"Test2/source"
+)
+
+// Pyth publishes prices by price ID rather than by token. The chains'
+// Pyth contracts hold the last update someone pushed, which can be old;
+// the Hermes service has every feed's latest price, and past ones by
+// publish time.
+
+// pythContracts are the Pyth price contracts of the built-in chains.
+var pythContracts = map[uint64]common.Address{
+	1:     common.HexToAddress("0x4305FB66699C3B2702D4d05CF36551390A4c69C6"),
+	10:    common.HexToAddress("0xff1a0f4744e8582DF1aE09D5611b887B6a12925C"),
+	8453:  common.HexToAddress("0x8250f4aF4B972684F7b336503E2D6dFeDeB1487a"),
+	42161: common.HexToAddress("0xff1a0f4744e8582DF1aE09D5611b887B6a12925C"),
+}
+
+// pythPriceIDs are the USD price IDs of common symbols; pyth price_ids
+// adds others.
+var pythPriceIDs = map[string]common.Hash{
+	"ETH":  common.HexToHash("0xff61491a931112ddf1bd8147cd1b641375f79f5825126d665480874634fd0ace"),
+	"WETH": common.HexToHash("0xff61491a931112ddf1bd8147cd1b641375f79f5825126d665480874634fd0ace"),
+	"BTC":  common.HexToHash("0xe62df6c8b4a85fe1a67db44dc12de5db330f7ac66b72dc658afedf0f4a415b43"),
+	"WBTC": common.HexToHash("0xe62df6c8b4a85fe1a67db44dc12de5db330f7ac66b72dc658afedf0f4a415b43"),
+	"USDC": common.HexToHash("0xeaa020c61cc479712813461ce153894a96a6c00b21ed0cfc2798d1f9a9e9c94a"),
+	"USDT": common.HexToHash("0x2b89b9dc8fdf9f34709a5b106b472f0f39bb6ca9ce04b0fd7f2e971688e2e53b"),
+	"DAI":  common.HexToHash("0xb0948a5e5313200c632b51bb5ca32f6de0d36e9950a942d19751e833f70dabfd"),
+	"LINK": common.HexToHash("0x8ac0c70fff57e9aefdf5edf44b51d62c2d433653cbb2cf5cc06bb115af04d221"),
+}
+
+// pythDefaultMaxAge is how old a Pyth price may be without max_age.
+const pythDefaultMaxAge = time.Hour
+
+// pythConfig sets up the pyth price source, e.g.
+//
+//	{"hermes": "https://hermes.pyth.network", "price_ids": {"RPL": "0x..."}, "max_age": "10m"}
+//
+// Without Hermes prices are read from the chain's Pyth contract, on the
+// chains that have a known one. Durations take a "d" suffix for days.
+type pythConfig struct {
+	Hermes   string                 `json:"hermes"`
+	PriceIDs map[string]common.Hash `json:"price_ids"`
+	MaxAge   string                 `json:"max_age"`
+
+	maxAge time.Duration
+}
+
+// parse checks the settings.
+func (c *pythConfig) parse() error {
+	if c.MaxAge != "" {
+		age, err := parseSince(c.MaxAge)
+		if err != nil {
+			return fmt.Errorf("max_age: %w", err)
+		}
+		if age <= 0 {
+			return fmt.Errorf("max_age %q is not positive", c.MaxAge)
+		}
+		c.maxAge = age
+	}
+	if c.Hermes != "" {
+		if u, err := url.Parse(c.Hermes); err != nil || u.Host == "" {
+			return fmt.Errorf("hermes %q is not a URL", c.Hermes)
+		}
+	}
+	return nil
+}
+
+// limit is how old a price may be.
+func (c *pythConfig) limit() time.Duration {
+	if c.maxAge == 0 {
+		return pythDefaultMaxAge
+	}
+	return c.maxAge
+}
+
+// priceID is the symbol's Pyth price ID, configured ones first. Symbols
+// compare case-insensitively.
+func (c *pythConfig) priceID(symbol string) (common.Hash, bool) {
+	for sym, id := range c.PriceIDs {
+		if strings.EqualFold(sym, symbol) {
+			return id, true
+		}
+	}
+	id, ok := pythPriceIDs[strings.ToUpper(symbol)]
+	return id, ok
+}
+
+var pythABI = mustABI(`[
+  {"inputs":[{"name":"id","type":"bytes32"}],"name":"getPriceUnsafe","outputs":[{"components":[{"name":"price","type":"int64"},{"name":"conf","type":"uint64"},{"name":"expo","type":"int32"},{"name":"publishTime","type":"uint256"}],"name":"price","type":"tuple"}],"stateMutability":"view","type":"function"}
+]`)
+
+// pythPrice is a Pyth price: Price × 10^Expo USD, published at Time.
+type pythPrice struct {
+	Price int64
+	Expo  int32
+	Time  time.Time
+}
+
+func (pp pythPrice) usd() *big.Float {
+	v := new(big.Float).SetInt64(pp.Price)
+	scale := new(big.Float).SetInt(new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(abs32(pp.Expo))), nil))
+	if pp.Expo < 0 {
+		return v.Quo(v, scale)
+	}
+	return v.Mul(v, scale)
+}
+
+func abs32(v int32) int32 {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
+
+// pyth prices a token by its Pyth price ID, from Hermes when configured
+// and from the chain's Pyth contract otherwise. Prices older than max_age
+// at the time being valued are stale.
+func (p *pricer) pyth(tf tokenFeed) (*big.Float, error) {
+	id, ok := p.pythCfg.priceID(tf.Symbol)
+	if !ok {
+		return nil, source.ErrUnsupported
+	}
+	var pp pythPrice
+	var err error
+	switch contract, onChain := pythContracts[p.chainID]; {
+	case p.pythCfg.Hermes != "":
+		pp, err = hermesPrice(p.ctx, p.pythCfg.Hermes, id, p.at)
+	case onChain:
+		pp, err = pythOnChain(p.ctx, p.client, contract, id)
+	default:
+		return nil, fmt.Errorf("no Pyth contract on this chain and no hermes configured: %w", source.ErrUnsupported)
+	}
+	if err != nil {
+		return nil, err
+	}
+	now := p.at
+	if now.IsZero() {
+		now = time.Now()
+	}
+	if age := now.Sub(pp.Time); age > p.pythCfg.limit() {
+		return nil, fmt.Errorf("%w: Pyth price published %s before", source.ErrStaleFeed, age.Round(time.Second))
+	}
+	if pp.Price <= 0 {
+		return nil, fmt.Errorf("Pyth price %d is not positive", pp.Price)
+	}
+	return pp.usd(), nil
+}
+
+// pythStruct is PythStructs.Price as the contract returns it.
+type pythStruct struct {
+	Price       int64
+	Conf        uint64
+	Expo        int32
+	PublishTime *big.Int
+}
+
+// pythOnChain reads the last price pushed to a Pyth contract.
+func pythOnChain(ctx context.Context, client chainClient, contract common.Address, id common.Hash) (pythPrice, error) {
+	vs, err := callView(ctx, client, contract, pythABI, "getPriceUnsafe", id)
+	if err != nil {
+		return pythPrice{}, err
+	}
+	out := *abi.ConvertType(vs[0], new(pythStruct)).(*pythStruct)
+	if out.PublishTime.Sign() == 0 {
+		return pythPrice{}, errors.New("price ID has no price on this chain")
+	}
+	return pythPrice{Price: out.Price, Expo: out.Expo, Time: time.Unix(out.PublishTime.Int64(), 0)}, nil
+}
+
+// hermesPrice pulls a price ID's latest price from Hermes, or the one
+// published at at when it is set.
+func hermesPrice(ctx context.Context, base string, id common.Hash, at time.Time) (pythPrice, error) {
+	path := "/v2/updates/price/latest"
+	if !at.IsZero() {
+		path = "/v2/updates/price/" + strconv.FormatInt(at.Unix(), 10)
+	}
+	u := strings.TrimSuffix(base, "/") + path + "?" + url.Values{"ids[]": {id.Hex()}, "parsed": {"true"}, "encoding": {"hex"}}.Encode()
+	var resp struct {
+		Parsed []struct {
+			ID    string `json:"id"`
+			Price struct {
+				Price       string `json:"price"`
+				Expo        int32  `json:"expo"`
+				PublishTime int64  `json:"publish_time"`
+			} `json:"price"`
+		} `json:"parsed"`
+	}
+	if _, err := guard(ctx, circuits, "pyth hermes", func() (any, error) { return nil, getJSON(ctx, u, &resp) }); err != nil {
+		return pythPrice{}, err
+	}
+	for _, f := range resp.Parsed {
+		if common.HexToHash(f.ID) != id {
+			continue
+		}
+		price, err := strconv.ParseInt(f.Price.Price, 10, 64)
+		if err != nil {
+			return pythPrice{}, fmt.Errorf("hermes price %q: %w", f.Price.Price, err)
+		}
+		return pythPrice{Price: price, Expo: f.Price.Expo, Time: time.Unix(f.Price.PublishTime, 0)}, nil
+	}
+	return pythPrice{}, fmt.Errorf("hermes has no price for %s", id.Hex())
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core"
+
+	"Test2/source"
+)
+
+func TestPythOnChain(t *testing.T) {
+	contract := common.HexToAddress("0x0000000000000000000000000000000000079749")
+	minusEight := new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), 256), big.NewInt(8))
+	published := time.Now().Add(-time.Minute).Unix()
+	sim := newSim(t, core.GenesisAlloc{
+		contract: {
+			Code: mockCode(method(pythABI, "getPriceUnsafe", 1, 2, 3, 4)),
+			Storage: map[common.Hash]common.Hash{
+				word(1): word(301245000000), // $3012.45
+				word(2): word(150000000),
+				word(3): bigWord(minusEight),
+				word(4): word(published),
+			},
+			Balance: new(big.Int),
+		},
+	})
+	pythContracts[simChainID] = contract
+	defer delete(pythContracts, simChainID)
+
+	eth := tokenFeed{Symbol: "ETH"}
+	cfg := &config{PriceSources: priceSourcesConfig{Order: []string{"pyth"}}}
+	h := &holding{tf: eth, amt: big.NewFloat(2)}
+	newPricer(context.Background(), sim, simChainID, true, cfg, options{}).priceAll([]*holding{h})
+	if h.err != nil || !floatEq(h.price, 3012.45) || h.source != "pyth" {
+		t.Fatalf("ETH $%v from %q: %v", h.price, h.source, h.err)
+	}
+
+	cfg.Pyth = pythConfig{MaxAge: "30s"}
+	if err := cfg.Pyth.parse(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := newPricer(context.Background(), sim, simChainID, true, cfg, options{}).pyth(eth); !errors.Is(err, source.ErrStaleFeed) {
+		t.Errorf("a minute-old price under a 30s max_age: %v", err)
+	}
+	if _, err := newPricer(context.Background(), sim, simChainID, true, cfg, options{}).pyth(tokenFeed{Symbol: "MOCK"}); !errors.Is(err, source.ErrUnsupported) {
+		t.Errorf("a symbol without a price ID: %v", err)
+	}
+	for _, bad := range []pythConfig{{MaxAge: "soon"}, {MaxAge: "0s"}, {Hermes: "hermes"}} {
+		if err := bad.parse(); err == nil {
+			t.Errorf("%+v accepted", bad)
+		}
+	}
+}
+
+func TestHermesPrice(t *testing.T) {
+	id := common.HexToHash("0x1234")
+	published := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	var paths []string
+	hermes := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.URL.Path)
+		if got := r.URL.Query()["ids[]"]; len(got) != 1 || common.HexToHash(got[0]) != id {
+			http.Error(w, "unknown ids", http.StatusNotFound)
+			return
+		}
+		w.Write([]byte(`{"parsed": [{"id": "` + strings.TrimPrefix(id.Hex(), "0x") + `", "price": {"price": "1425000", "conf": "1000", "expo": -5, "publish_time": 1709294390}}]}`))
+	}))
+	defer hermes.Close()
+
+	cfg := &config{Pyth: pythConfig{Hermes: hermes.URL + "/", PriceIDs: map[string]common.Hash{"rpl": id}}}
+	p := newPricer(context.Background(), nil, simChainID, true, cfg, options{})
+	p.at = published
+	price, err := p.pyth(tokenFeed{Symbol: "RPL"})
+	if err != nil || !floatEq(price, 14.25) {
+		t.Fatalf("RPL $%v: %v", price, err)
+	}
+	if want := "/v2/updates/price/1709294400"; len(paths) != 1 || paths[0] != want {
+		t.Errorf("asked %v, want %s", paths, want)
+	}
+
+	p.at = time.Time{}
+	if _, err := p.pyth(tokenFeed{Symbol: "RPL"}); !errors.Is(err, source.ErrStaleFeed) || paths[1] != "/v2/updates/price/latest" {
+		t.Errorf("latest from %v: %v", paths, err)
+	}
+}
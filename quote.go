@@ -0,0 +1,98 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+
+	"Test2/source"
+)
+
+// quoteAsset is a token a report is expressed in instead of USD, with the
+// USD price of one unit at the time of the run.
+type quoteAsset struct {
+	symbol   string
+	decimals int
+	usd      *big.Float
+}
+
+// reportQuote is what text output shows values in; nil for USD.
+var reportQuote *quoteAsset
+
+// priceQuote finds asset, a registry symbol or a token address, on the
+// first of conns that has it and prices one unit of it the way holdings are
+// priced, so overrides and fallbacks apply to it too.
+func priceQuote(ctx context.Context, conns []*chainConn, cfg *config, opts options, asset string) (*quoteAsset, error) {
+	var lastErr error
+	for _, c := range conns {
+		tf, ok := registrySymbol(c.id, asset)
+		if addr := common.HexToAddress(asset); common.IsHexAddress(asset) {
+			if tf, ok = registryToken(c.id, addr); !ok {
+				var err error
+				if tf, err = resolveToken(ctx, c.client, addr); err != nil {
+					lastErr = fmt.Errorf("%s: %w", chainName(c.id), err)
+					continue
+				}
+			}
+		} else if !ok {
+			continue
+		}
+		h := &holding{tf: tf, amt: big.NewFloat(1)}
+		c.pricer(ctx, cfg, opts).priceAll([]*holding{h})
+		if h.err != nil {
+			return nil, fmt.Errorf("%s: %w", tf.Symbol, h.err)
+		}
+		if h.price.Sign() <= 0 {
+			return nil, fmt.Errorf("%s: %w: priced at %s", tf.Symbol, source.ErrNoPriceSource, h.price.Text('g', 6))
+		}
+		return &quoteAsset{symbol: tf.Symbol, decimals: tf.Decimals, usd: h.price}, nil
+	}
+	if lastErr != nil {
+		return nil, lastErr
+	}
+	return nil, fmt.Errorf("%s: %w: not a token of any chain valued", asset, source.ErrNoPriceSource)
+}
+
+// registryToken returns the registry entry of a chain's token contract.
+func registryToken(chainID uint64, addr common.Address) (tokenFeed, bool) {
+	if nativeFace(chainID, addr) {
+		return nativeToken(chainID), true
+	}
+	for _, tf := range registry(chainID) {
+		if tf.TokenAddr == addr {
+			return tf, true
+		}
+	}
+	return tokenFeed{}, false
+}
+
+// format shows a USD value in the quote asset, or in dollars on a nil q.
+func (q *quoteAsset) format(usd *big.Float) string {
+	if q == nil {
+		return "$" + usd.Text('f', 2)
+	}
+	return new(big.Float).Quo(usd, q.usd).Text('f', min(q.decimals, 8)) + " " + q.symbol
+}
+
+// apply adds the quote asset's values to r; it is a no-op on a nil q.
+func (q *quoteAsset) apply(r *report) {
+	if q == nil {
+		return
+	}
+	r.Quote = q.symbol
+	r.QuoteUSD, _ = q.usd.Float64()
+	r.TotalQuote = r.TotalUSD / r.QuoteUSD
+	for i, p := range r.Positions {
+		if p.Error == "" {
+			r.Positions[i].Value = p.USD / r.QuoteUSD
+		}
+	}
+}
+
+// quoteIsUSD reports whether -quote asks for the default.
+func quoteIsUSD(asset string) bool {
+	return asset == "" || strings.EqualFold(asset, "USD")
+}
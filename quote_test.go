@@ -0,0 +1,58 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"strings"
+	"testing"
+
+	"Test2/source"
+)
+
+func TestQuoteAsset(t *testing.T) {
+	var usd *quoteAsset
+	if got := usd.format(big.NewFloat(12.5)); got != "$12.50" {
+		t.Errorf("USD format = %q", got)
+	}
+	wbtc := &quoteAsset{symbol: "WBTC", decimals: 8, usd: big.NewFloat(50000)}
+	if got := wbtc.format(big.NewFloat(25000)); got != "0.50000000 WBTC" {
+		t.Errorf("WBTC format = %q", got)
+	}
+
+	r := &report{TotalUSD: 150, Positions: []reportPosition{
+		{Symbol: "ETH", USD: 100},
+		{Symbol: "LINK", USD: 50},
+		{Symbol: "X", Error: "no price"},
+	}}
+	usd.apply(r)
+	if r.Quote != "" {
+		t.Errorf("nil quote applied %q", r.Quote)
+	}
+	usdc := &quoteAsset{symbol: "USDC", decimals: 6, usd: big.NewFloat(0.5)}
+	usdc.apply(r)
+	if r.Quote != "USDC" || r.QuoteUSD != 0.5 || r.TotalQuote != 300 || r.Positions[0].Value != 200 || r.Positions[2].Value != 0 {
+		t.Errorf("applied: %+v", r)
+	}
+
+	reportQuote = usdc
+	defer func() { reportQuote = nil }()
+	var b strings.Builder
+	printHoldings(&b, []*holding{{tf: tokenFeed{Symbol: "ETH"}, amt: big.NewFloat(1), price: big.NewFloat(100)}}, 0)
+	if !strings.Contains(b.String(), "=> 200.000000 USDC") {
+		t.Errorf("holdings in USDC:\n%s", b.String())
+	}
+}
+
+func TestPriceQuoteUnknown(t *testing.T) {
+	if !quoteIsUSD("usd") || quoteIsUSD("USDC") {
+		t.Error("quoteIsUSD")
+	}
+	if _, err := priceQuote(context.Background(), nil, &config{}, options{}, "USDC"); !errors.Is(err, source.ErrNoPriceSource) {
+		t.Errorf("err = %v, want source.ErrNoPriceSource", err)
+	}
+	usdc, _ := tokenBySymbol("USDC")
+	if tf, ok := registryToken(1, usdc.TokenAddr); !ok || tf.Symbol != "USDC" {
+		t.Errorf("registryToken = %+v, %v", tf, ok)
+	}
+}
@@ -0,0 +1,303 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/url"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+
+	"Test2/source"
+)
+
+// RedStone's pull model leaves prices off-chain until a transaction brings
+// them along: nodes sign data packages, gateways serve them, and the
+// consuming contract checks the signatures and takes the median. The
+// redstone source does the same checks itself, so a gateway cannot make up
+// prices the signers did not sign.
+
+// redstoneGateway serves the latest signed data packages.
+var redstoneGateway = "https://oracle-gateway-1.a.redstone.finance"
+
+// redstoneService is the data service used when none is configured.
+const redstoneService = "redstone-primary-prod"
+
+// redstonePrimarySigners are the nodes of redstone-primary-prod.
+var redstonePrimarySigners = []common.Address{
+	common.HexToAddress("0x8BB8F32Df04c8b654987DAaeD53D6B6091e3B774"),
+	common.HexToAddress("0xdEB22f54738d54976C4c0fe5ce6d408E40d88499"),
+	common.HexToAddress("0x51Ce04Be4b3E32572C4Ec9135221d0691Ba7d202"),
+	common.HexToAddress("0xDD682daEC5A90dD295d14DA4b0bec9281017b5bE"),
+	common.HexToAddress("0x9c5AE89C4Af6aA32cE58588DBaF90d18a855B6de"),
+}
+
+// redstoneDecimals is the fixed point numeric values are signed in.
+const redstoneDecimals = 8
+
+// redstoneConfig sets up the redstone price source, e.g.
+//
+//	{"service": "redstone-primary-prod", "signers": ["0x..."], "min_signers": 3,
+//	 "feeds": {"weETH": "weETH_FUNDAMENTAL"}, "max_age": "10m"}
+//
+// Signers default to those of redstone-primary-prod, which is also the
+// default service; another service needs its own. A token's data feed is
+// its symbol unless feeds says otherwise. Durations take a "d" suffix for
+// days.
+type redstoneConfig struct {
+	Gateway    string            `json:"gateway"`
+	Service    string            `json:"service"`
+	Signers    []common.Address  `json:"signers"`
+	MinSigners int               `json:"min_signers"`
+	Feeds      map[string]string `json:"feeds"`
+	MaxAge     string            `json:"max_age"`
+
+	maxAge time.Duration
+}
+
+// parse checks the settings.
+func (c *redstoneConfig) parse() error {
+	if c.MaxAge != "" {
+		age, err := parseSince(c.MaxAge)
+		if err != nil {
+			return fmt.Errorf("max_age: %w", err)
+		}
+		if age <= 0 {
+			return fmt.Errorf("max_age %q is not positive", c.MaxAge)
+		}
+		c.maxAge = age
+	}
+	if c.Service != "" && c.Service != redstoneService && len(c.Signers) == 0 {
+		return fmt.Errorf("service %s needs its signers", c.Service)
+	}
+	if c.MinSigners < 0 || c.MinSigners > len(c.signers()) {
+		return fmt.Errorf("min_signers %d of %d signers", c.MinSigners, len(c.signers()))
+	}
+	if c.Gateway != "" {
+		if u, err := url.Parse(c.Gateway); err != nil || u.Host == "" {
+			return fmt.Errorf("gateway %q is not a URL", c.Gateway)
+		}
+	}
+	return nil
+}
+
+func (c *redstoneConfig) gateway() string {
+	if c.Gateway != "" {
+		return c.Gateway
+	}
+	return redstoneGateway
+}
+
+func (c *redstoneConfig) service() string {
+	if c.Service != "" {
+		return c.Service
+	}
+	return redstoneService
+}
+
+func (c *redstoneConfig) signers() []common.Address {
+	if len(c.Signers) > 0 {
+		return c.Signers
+	}
+	return redstonePrimarySigners
+}
+
+// quorum is how many different authorized signers a price needs: 3, as
+// RedStone's own consumers ask, or all of them when there are fewer.
+func (c *redstoneConfig) quorum() int {
+	if c.MinSigners > 0 {
+		return c.MinSigners
+	}
+	return min(3, len(c.signers()))
+}
+
+func (c *redstoneConfig) limit() time.Duration {
+	if c.maxAge == 0 {
+		return 10 * time.Minute
+	}
+	return c.maxAge
+}
+
+// feed is the data feed a symbol is priced by. Wrapped coins are priced
+// as the coin; feeds missing as spelled are looked for in upper case.
+func (c *redstoneConfig) feed(symbol string) string {
+	for sym, id := range c.Feeds {
+		if strings.EqualFold(sym, symbol) {
+			return id
+		}
+	}
+	switch strings.ToUpper(symbol) {
+	case "WETH":
+		return "ETH"
+	case "WBTC":
+		return "BTC"
+	default:
+		return symbol
+	}
+}
+
+// redstonePackage is a signed data package as gateways serve it.
+type redstonePackage struct {
+	Timestamp  int64  `json:"timestampMilliseconds"`
+	Signature  string `json:"signature"`
+	DataPoints []struct {
+		DataFeedID string      `json:"dataFeedId"`
+		Value      json.Number `json:"value"`
+	} `json:"dataPoints"`
+}
+
+// redstonePoint is one data point of a package once its signature checks
+// out.
+type redstonePoint struct {
+	signer common.Address
+	value  *big.Int // scaled by redstoneDecimals
+	time   time.Time
+}
+
+// verify recovers the package's signer and returns its value for feed.
+// The signed message is the keccak256 hash of the data points, each a
+// bytes32 feed ID and a 32-byte value, followed by the 6-byte timestamp,
+// the 4-byte value size and the 3-byte number of points, which is what
+// RedStone's on-chain verifiers check.
+func (pkg redstonePackage) verify(feed string) (redstonePoint, error) {
+	type point struct {
+		id    [32]byte
+		value *big.Int
+	}
+	var points []point
+	var value *big.Int
+	for _, dp := range pkg.DataPoints {
+		if len(dp.DataFeedID) > 32 {
+			return redstonePoint{}, fmt.Errorf("data feed ID %q is too long", dp.DataFeedID)
+		}
+		v, ok := new(big.Rat).SetString(dp.Value.String())
+		if !ok || v.Sign() < 0 {
+			return redstonePoint{}, fmt.Errorf("%s: bad value %q", dp.DataFeedID, dp.Value)
+		}
+		v.Mul(v, new(big.Rat).SetInt(new(big.Int).Exp(big.NewInt(10), big.NewInt(redstoneDecimals), nil)))
+		scaled := new(big.Int).Quo(v.Num(), v.Denom())
+		var id [32]byte
+		copy(id[:], dp.DataFeedID)
+		points = append(points, point{id, scaled})
+		if dp.DataFeedID == feed {
+			value = scaled
+		}
+	}
+	if value == nil {
+		return redstonePoint{}, fmt.Errorf("no %s data point", feed)
+	}
+	sort.Slice(points, func(i, j int) bool { return string(points[i].id[:]) < string(points[j].id[:]) })
+	var msg []byte
+	for _, p := range points {
+		msg = append(msg, p.id[:]...)
+		msg = append(msg, common.LeftPadBytes(p.value.Bytes(), 32)...)
+	}
+	var tail [8]byte
+	binary.BigEndian.PutUint64(tail[:], uint64(pkg.Timestamp))
+	msg = append(msg, tail[2:]...)
+	binary.BigEndian.PutUint32(tail[:4], 32)
+	msg = append(msg, tail[:4]...)
+	binary.BigEndian.PutUint32(tail[:4], uint32(len(points)))
+	msg = append(msg, tail[1:4]...)
+
+	sig, err := base64.StdEncoding.DecodeString(pkg.Signature)
+	if err != nil || len(sig) != 65 {
+		return redstonePoint{}, fmt.Errorf("bad signature %q", pkg.Signature)
+	}
+	sig = slices.Clone(sig)
+	if sig[64] >= 27 {
+		sig[64] -= 27
+	}
+	pub, err := crypto.SigToPub(crypto.Keccak256(msg), sig)
+	if err != nil {
+		return redstonePoint{}, fmt.Errorf("signature: %w", err)
+	}
+	return redstonePoint{signer: crypto.PubkeyToAddress(*pub), value: value, time: time.UnixMilli(pkg.Timestamp)}, nil
+}
+
+// redstone prices a token at the median of its data feed's latest values
+// signed by different authorized signers, as long as a quorum of them
+// signed one that is recent enough. Past prices are not served.
+func (p *pricer) redstone(tf tokenFeed) (*big.Float, error) {
+	if !p.at.IsZero() {
+		return nil, fmt.Errorf("past prices: %w", source.ErrUnsupported)
+	}
+	c := &p.redstoneCfg
+	feed := c.feed(tf.Symbol)
+	pkgs, err := p.redstonePackages()
+	if err != nil {
+		return nil, err
+	}
+	signed, ok := pkgs[feed]
+	if !ok {
+		feed = strings.ToUpper(feed)
+		if signed, ok = pkgs[feed]; !ok {
+			return nil, source.ErrUnsupported
+		}
+	}
+	authorized := c.signers()
+	bySigner := map[common.Address]redstonePoint{}
+	var rejected causes
+	for _, pkg := range signed {
+		pt, err := pkg.verify(feed)
+		switch {
+		case err != nil:
+			rejected = append(rejected, err)
+		case !slices.Contains(authorized, pt.signer):
+			rejected = append(rejected, fmt.Errorf("signer %s is not authorized", pt.signer.Hex()))
+		case time.Since(pt.time) > c.limit():
+			rejected = append(rejected, fmt.Errorf("%w: %s signed %s ago", source.ErrStaleFeed, pt.signer.Hex(), time.Since(pt.time).Round(time.Second)))
+		case bySigner[pt.signer].time.Before(pt.time):
+			bySigner[pt.signer] = pt
+		}
+	}
+	if len(bySigner) < c.quorum() {
+		err := fmt.Errorf("%s: %d of %d signers needed", feed, len(bySigner), c.quorum())
+		if len(rejected) > 0 {
+			err = fmt.Errorf("%w (%w)", err, rejected)
+		}
+		return nil, err
+	}
+	var values []*big.Int
+	for _, pt := range bySigner {
+		values = append(values, pt.value)
+	}
+	return tokenAmount(medianInt(values), redstoneDecimals), nil
+}
+
+// redstonePackages fetches the service's latest data packages by data feed,
+// once per pricer.
+func (p *pricer) redstonePackages() (map[string][]redstonePackage, error) {
+	if p.redstoneData != nil || p.redstoneErr != nil {
+		return p.redstoneData, p.redstoneErr
+	}
+	c := &p.redstoneCfg
+	u := strings.TrimSuffix(c.gateway(), "/") + "/data-packages/latest/" + url.PathEscape(c.service())
+	var pkgs map[string][]redstonePackage
+	_, err := guard(p.ctx, circuits, "redstone", func() (any, error) { return nil, getJSON(p.ctx, u, &pkgs) })
+	if err == nil && pkgs == nil {
+		pkgs = map[string][]redstonePackage{}
+	}
+	p.redstoneData, p.redstoneErr = pkgs, err
+	return pkgs, err
+}
+
+// medianInt is the median of vs, the mean of the middle two for an even
+// count, as RedStone's contracts compute it.
+func medianInt(vs []*big.Int) *big.Int {
+	sorted := slices.Clone(vs)
+	slices.SortFunc(sorted, func(a, b *big.Int) int { return a.Cmp(b) })
+	mid := len(sorted) / 2
+	if len(sorted)%2 == 1 {
+		return new(big.Int).Set(sorted[mid])
+	}
+	sum := new(big.Int).Add(sorted[mid-1], sorted[mid])
+	return sum.Quo(sum, big.NewInt(2))
+}
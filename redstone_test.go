@@ -0,0 +1,113 @@
+package main
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+
+	"Test2/source"
+)
+
+// signedPackage is a one-point data package signed by key, serialized the
+// way RedStone nodes do, independently of redstonePackage.verify.
+func signedPackage(t *testing.T, key *ecdsa.PrivateKey, feed string, value int64, at time.Time) map[string]any {
+	t.Helper()
+	var msg []byte
+	id := make([]byte, 32)
+	copy(id, feed)
+	msg = append(msg, id...)
+	msg = append(msg, common.LeftPadBytes(big.NewInt(value).Bytes(), 32)...)
+	ts := make([]byte, 8)
+	binary.BigEndian.PutUint64(ts, uint64(at.UnixMilli()))
+	msg = append(msg, ts[2:]...)
+	msg = append(msg, 0, 0, 0, 32, 0, 0, 1)
+	sig, err := crypto.Sign(crypto.Keccak256(msg), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sig[64] += 27
+	return map[string]any{
+		"timestampMilliseconds": at.UnixMilli(),
+		"signature":             base64.StdEncoding.EncodeToString(sig),
+		"dataPoints":            []map[string]any{{"dataFeedId": feed, "value": json.Number(new(big.Rat).SetFrac64(value, 1e8).FloatString(8))}},
+	}
+}
+
+func TestRedstone(t *testing.T) {
+	var keys []*ecdsa.PrivateKey
+	var signers []common.Address
+	for range 4 {
+		key, err := crypto.GenerateKey()
+		if err != nil {
+			t.Fatal(err)
+		}
+		keys, signers = append(keys, key), append(signers, crypto.PubkeyToAddress(key.PublicKey))
+	}
+	outsider, _ := crypto.GenerateKey()
+	now := time.Now()
+	packages := map[string][]map[string]any{
+		"ETH": {
+			signedPackage(t, keys[0], "ETH", 301200000000, now),
+			signedPackage(t, keys[1], "ETH", 301300000000, now),
+			signedPackage(t, keys[2], "ETH", 301245000000, now),
+			// Older than max_age, and not signed by a known node.
+			signedPackage(t, keys[3], "ETH", 100000000000, now.Add(-time.Hour)),
+			signedPackage(t, outsider, "ETH", 100, now),
+		},
+		"weETH": {signedPackage(t, keys[0], "weETH", 320000000000, now)},
+	}
+	forged := signedPackage(t, keys[1], "weETH", 320000000000, now)
+	forged["dataPoints"] = []map[string]any{{"dataFeedId": "weETH", "value": json.Number("9999")}}
+	packages["weETH"] = append(packages["weETH"], forged)
+
+	fetches := 0
+	gateway := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fetches++
+		if r.URL.Path != "/data-packages/latest/test-service" {
+			http.NotFound(w, r)
+			return
+		}
+		json.NewEncoder(w).Encode(packages)
+	}))
+	defer gateway.Close()
+
+	cfg := &config{Redstone: redstoneConfig{Gateway: gateway.URL, Service: "test-service", Signers: signers, MaxAge: "5m"}}
+	if err := cfg.Redstone.parse(); err != nil {
+		t.Fatal(err)
+	}
+	p := newPricer(context.Background(), nil, simChainID, true, cfg, options{})
+	price, err := p.redstone(tokenFeed{Symbol: "WETH"})
+	if err != nil || !floatEq(price, 3012.45) {
+		t.Fatalf("WETH $%v: %v", price, err)
+	}
+	if _, err := p.redstone(tokenFeed{Symbol: "weETH"}); err == nil || !strings.Contains(err.Error(), "1 of 3 signers") {
+		t.Errorf("a forged package counted: %v", err)
+	}
+	if _, err := p.redstone(tokenFeed{Symbol: "MOCK"}); !errors.Is(err, source.ErrUnsupported) {
+		t.Errorf("an unknown feed: %v", err)
+	}
+	if fetches != 1 {
+		t.Errorf("%d gateway fetches, want 1", fetches)
+	}
+
+	for _, bad := range []redstoneConfig{{Service: "other"}, {MinSigners: 9}, {MaxAge: "later"}, {Gateway: "gateway"}} {
+		if err := bad.parse(); err == nil {
+			t.Errorf("%+v accepted", bad)
+		}
+	}
+	if got := medianInt([]*big.Int{big.NewInt(4), big.NewInt(1), big.NewInt(3), big.NewInt(2)}); got.Int64() != 2 {
+		t.Errorf("median %v, want 2", got)
+	}
+}
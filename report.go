@@ -0,0 +1,384 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/big"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// reportSchemaVersion is bumped whenever a field of report changes meaning or
+// is removed. Adding fields does not bump it; consumers must ignore unknown
+// keys. report.schema.json is generated from these structs.
+const reportSchemaVersion = 1
+
+// report is the machine-readable result of a run, used for -format json and
+// for stored snapshots.
+type report struct {
+	SchemaVersion int              `json:"schema_version" doc:"Version of this schema; see report.schema.json."`
+	Wallet        string           `json:"wallet" doc:"Valued address, lower-case hex."`
+	Label         string           `json:"label,omitempty" doc:"The wallet's address book label, if it has one."`
+	Time          time.Time        `json:"time" doc:"When the valuation was made (RFC 3339, UTC); with -blocks, the time of the block."`
+	Block         uint64           `json:"block,omitempty" doc:"Block the valuation read, with -blocks."`
+	Blocks        []reportBlock    `json:"blocks,omitempty" doc:"Block each chain was read at: the head of the moment, or the -pin-block one."`
+	Accounts      []reportAccount  `json:"accounts,omitempty" doc:"The wallet's transaction activity on each chain valued."`
+	Gas           []reportGas      `json:"gas,omitempty" doc:"Current fees on each chain, with -gas."`
+	Drift         []reportDrift    `json:"drift,omitempty" doc:"Allocation against the config's target weights, most overweight first."`
+	Positions     []reportPosition `json:"positions" doc:"Every position, in report order, including failed lookups."`
+	TotalUSD      float64          `json:"total_usd" doc:"Sum of all valued positions in USD."`
+	TotalBTC      float64          `json:"total_btc,omitempty" doc:"total_usd in bitcoin at btc_usd, with -btc."`
+	BTCUSD        float64          `json:"btc_usd,omitempty" doc:"USD price of one bitcoin the total was converted at, with -btc."`
+	Quote         string           `json:"quote,omitempty" doc:"Symbol of the -quote asset values are also expressed in."`
+	QuoteUSD      float64          `json:"quote_usd,omitempty" doc:"USD price of one unit of the quote asset, with -quote."`
+	TotalQuote    float64          `json:"total_quote,omitempty" doc:"total_usd in units of the quote asset, with -quote."`
+	Degraded      []reportDegraded `json:"degraded,omitempty" doc:"Sources skipped after repeated failures when the report was made; lookups that needed them failed."`
+	Sanctions     []reportSanction `json:"sanctions,omitempty" doc:"With -screen, the wallet itself or its transfer counterparties found on the sanctions list."`
+	Partial       bool             `json:"partial,omitempty" doc:"Set when the run was interrupted or ran out of its -budget: positions may be missing or unvalued."`
+}
+
+type reportDegraded struct {
+	Source   string    `json:"source" doc:"The feed, API or integration, with the token or chain it was used for."`
+	Failures int       `json:"failures" doc:"Failures in a row that degraded it."`
+	Until    time.Time `json:"until" doc:"When it will be tried again (RFC 3339, UTC)."`
+	Error    string    `json:"error" doc:"Its last failure."`
+}
+
+type reportAccount struct {
+	Chain         string `json:"chain" doc:"Chain the activity is on."`
+	Nonce         uint64 `json:"nonce" doc:"Transactions the wallet has sent and had mined."`
+	Pending       uint64 `json:"pending,omitempty" doc:"Transactions the wallet has pending, beyond the mined nonce."`
+	LastSentBlock uint64 `json:"last_sent_block,omitempty" doc:"Block of the wallet's last mined transaction; absent when it never sent one or the node has no history."`
+}
+
+type reportGas struct {
+	Chain           string        `json:"chain" doc:"Chain the fees are on."`
+	Native          string        `json:"native" doc:"Symbol of the coin gas is paid in."`
+	BaseFeeGwei     float64       `json:"base_fee_gwei" doc:"Base fee of the latest block, in gwei."`
+	PriorityFeeGwei float64       `json:"priority_fee_gwei" doc:"Priority fee the node suggests, in gwei."`
+	Sweeps          []reportSweep `json:"sweeps,omitempty" doc:"Estimated cost of sending each wallet balance to the -sweep-to address."`
+}
+
+type reportSweep struct {
+	Symbol     string  `json:"symbol" doc:"Symbol of the balance moved."`
+	Gas        uint64  `json:"gas,omitempty" doc:"Estimated gas of the transfer."`
+	CostNative float64 `json:"cost_native,omitempty" doc:"Fee at the current base and priority fee, in the native coin."`
+	CostUSD    float64 `json:"cost_usd,omitempty" doc:"The fee in USD; 0 when the native coin has no price."`
+	ValueUSD   float64 `json:"value_usd,omitempty" doc:"Value of the balance moved in USD."`
+	Error      string  `json:"error,omitempty" doc:"Why the transfer could not be estimated."`
+}
+
+type reportDrift struct {
+	Symbol      string  `json:"symbol" doc:"Canonical symbol of the asset."`
+	Current     float64 `json:"current_pct" doc:"Share of the valued total the asset makes up, in percent."`
+	Target      float64 `json:"target_pct" doc:"Target share, in percent; 0 for assets without a target."`
+	Drift       float64 `json:"drift_pct" doc:"Current minus target, in percentage points; positive when overweight."`
+	TradeUSD    float64 `json:"trade_usd" doc:"USD to buy (positive) or sell (negative) to reach the target."`
+	TradeAmount float64 `json:"trade_amount,omitempty" doc:"The trade in whole tokens; absent for assets not held, which have no price here."`
+}
+
+type reportPosition struct {
+	Symbol      string            `json:"symbol" doc:"Canonical symbol of the asset the position is denominated in."`
+	Chain       string            `json:"chain" doc:"Chain the position was found on."`
+	Note        string            `json:"note,omitempty" doc:"Where the funds sit or how they are locked, when not a plain wallet balance."`
+	ID          string            `json:"id,omitempty" doc:"What identifies the position across reports among those of its chain and symbol, when its note changes from run to run: the adapter and the contract or position held, e.g. vault:0x..."`
+	Amount      float64           `json:"amount" doc:"Quantity in whole tokens; 0 when the balance lookup failed."`
+	USD         float64           `json:"usd" doc:"Value in USD; 0 when the position could not be valued."`
+	Value       float64           `json:"value,omitempty" doc:"Value in units of the report's quote asset, with -quote."`
+	PriceSource string            `json:"price_source,omitempty" doc:"What produced the price, when the position was priced separately: override, fixed, feed-path, chainlink, 1inch, uniswap-twap, coingecko, defillama, manual or a registered source."`
+	Error       string            `json:"error,omitempty" doc:"Why the balance or price lookup failed; such positions are left out of total_usd."`
+	Logo        string            `json:"logo,omitempty" doc:"URL of the asset's logo image, from the configured token lists or the built-in set."`
+	CoingeckoID string            `json:"coingecko_id,omitempty" doc:"CoinGecko ID of the asset, when known."`
+	Website     string            `json:"website,omitempty" doc:"The asset's project website, when known."`
+	Feeds       []reportFeed      `json:"feeds,omitempty" doc:"The Chainlink rounds the price was computed from, when it came from feeds."`
+	Deviations  []reportDeviation `json:"deviations,omitempty" doc:"Other sources whose price differed from price_source's by more than price_sources max_deviation_pct."`
+}
+
+type reportDeviation struct {
+	Source string  `json:"source" doc:"The price source that disagreed."`
+	Price  float64 `json:"price" doc:"Its USD price for one token."`
+	Pct    float64 `json:"pct" doc:"How far it is from the price the position was valued at, in percent of that price."`
+}
+
+type reportFeed struct {
+	Address   string    `json:"address" doc:"Address of the feed contract the answer was read from."`
+	RoundID   string    `json:"round_id" doc:"Round of the answer, as a decimal string: the phase in the top 16 bits, the aggregator round below."`
+	Answer    float64   `json:"answer" doc:"The answer, scaled by the feed's decimals."`
+	Decimals  int       `json:"decimals" doc:"Decimals the feed reports its answers in."`
+	UpdatedAt time.Time `json:"updated_at" doc:"When the round was last updated, per the feed."`
+}
+
+func newReport(wallet string, held []*holding) *report {
+	r := &report{
+		SchemaVersion: reportSchemaVersion,
+		Wallet:        strings.ToLower(wallet),
+		Label:         book.label(common.HexToAddress(wallet)),
+		Time:          time.Now().UTC(),
+		Degraded:      circuits.degraded(),
+	}
+	total := new(big.Float)
+	for _, h := range held {
+		p := reportPosition{Symbol: h.tf.Symbol, Chain: h.chain, Note: h.note, ID: h.id, PriceSource: h.source}
+		chain, _ := chainByName(h.chain)
+		meta := metadataOf(chain.ID, h.tf.TokenAddr, h.tf.Symbol)
+		p.Logo, p.CoingeckoID, p.Website = meta.Logo, meta.CoingeckoID, meta.Website
+		for _, d := range h.deviations {
+			price, _ := d.price.Float64()
+			p.Deviations = append(p.Deviations, reportDeviation{Source: d.source, Price: price, Pct: d.pct})
+		}
+		for _, f := range h.feeds {
+			answer, _ := f.answer.Float64()
+			p.Feeds = append(p.Feeds, reportFeed{
+				Address:   f.feed.Hex(),
+				RoundID:   f.round.String(),
+				Answer:    answer,
+				Decimals:  int(f.decimals),
+				UpdatedAt: f.updatedAt,
+			})
+		}
+		if h.amt != nil {
+			p.Amount, _ = h.amt.Float64()
+		}
+		if h.err != nil {
+			p.Error = h.err.Error()
+		} else if h.price != nil {
+			usd := h.usd()
+			total.Add(total, usd)
+			p.USD, _ = usd.Float64()
+		}
+		r.Positions = append(r.Positions, p)
+	}
+	r.TotalUSD, _ = total.Float64()
+	return r
+}
+
+// printJSON writes the report indented, or on a single line when streaming
+// one report per wallet.
+func printJSON(w io.Writer, r *report, compact bool) error {
+	enc := json.NewEncoder(w)
+	if !compact {
+		enc.SetIndent("", "  ")
+	}
+	return enc.Encode(r)
+}
+
+// printHeader names the wallet in front of its results when a run values
+// several wallets.
+func printHeader(w io.Writer, batch bool, wallet common.Address) {
+	switch label := book.label(wallet); {
+	case batch && label != "":
+		fmt.Fprintf(w, "== %s (%s) ==\n", label, wallet.Hex())
+	case batch:
+		fmt.Fprintf(w, "== %s ==\n", wallet.Hex())
+	case label != "":
+		fmt.Fprintf(w, "%s\n", label)
+	}
+}
+
+// printBlocks heads text output with the block each chain was read at, so
+// that saved output says which state it shows.
+func printBlocks(w io.Writer, blocks []reportBlock) {
+	for _, b := range blocks {
+		fmt.Fprintf(w, "%s block %d, %s\n", b.Chain, b.Number, b.Time.Local().Format("2006-01-02 15:04:05 MST"))
+	}
+}
+
+// printFeeds lists, for -verbose, the feed rounds behind each price: the
+// feed, the round split into phase and aggregator round, how old the answer
+// was when the report was made, and the decimals it was scaled by.
+func printFeeds(w io.Writer, positions []reportPosition, now time.Time) {
+	for _, p := range positions {
+		for _, f := range p.Feeds {
+			round, _ := new(big.Int).SetString(f.RoundID, 10)
+			if round == nil {
+				round = new(big.Int)
+			}
+			phase := new(big.Int).Rsh(round, 64)
+			agg := new(big.Int).Sub(round, new(big.Int).Lsh(phase, 64))
+			fmt.Fprintf(w, "  %s on %s: feed %s round %s (phase %s, round %s) updated %s ago, %d decimals, answer %g\n",
+				p.Symbol, p.Chain, f.Address, f.RoundID, phase, agg, now.Sub(f.UpdatedAt).Round(time.Second), f.Decimals, f.Answer)
+		}
+	}
+}
+
+// printHoldings prints one line per holding and the total. Failed lookups
+// get an error line so they can be told apart from a zero balance. Holdings
+// worth less than hideBelow USD are not listed but still count in the total.
+func printHoldings(w io.Writer, held []*holding, hideBelow float64) {
+	totalUSD := big.NewFloat(0)
+	hidden := 0
+	for _, h := range held {
+		if h.err != nil {
+			fmt.Fprintln(w, errorLine(h.tf.Symbol, h))
+			continue
+		}
+		usd := h.usd()
+		totalUSD.Add(totalUSD, usd)
+		if below(usd, hideBelow) {
+			hidden++
+			continue
+		}
+		fmt.Fprintln(w, holdingLine(h.tf.Symbol, h.amt, usd, h.note))
+	}
+
+	printHidden(w, hidden, hideBelow)
+	fmt.Fprintf(w, "TOTAL %12s => %s\n", "", reportQuote.format(totalUSD))
+}
+
+func below(usd *big.Float, threshold float64) bool {
+	return threshold > 0 && usd.Cmp(big.NewFloat(threshold)) < 0
+}
+
+func printHidden(w io.Writer, n int, threshold float64) {
+	if n > 0 {
+		fmt.Fprintf(w, "(%d positions under $%g not shown)\n", n, threshold)
+	}
+}
+
+// printRollup prints one aggregate line per canonical asset, followed by
+// its per-chain breakdown, for runs that span several chains. Failed lookups
+// are listed after the assets. Assets and chain lines worth less than
+// hideBelow USD are not listed but still count in the total.
+func printRollup(w io.Writer, held []*holding, hideBelow float64) {
+	var order []string
+	var failed []*holding
+	groups := map[string][]*holding{}
+	for _, h := range held {
+		if h.err != nil {
+			failed = append(failed, h)
+			continue
+		}
+		if _, ok := groups[h.tf.Symbol]; !ok {
+			order = append(order, h.tf.Symbol)
+		}
+		groups[h.tf.Symbol] = append(groups[h.tf.Symbol], h)
+	}
+
+	totalUSD := big.NewFloat(0)
+	hidden := 0
+	for _, sym := range order {
+		amt, usd := new(big.Float), new(big.Float)
+		for _, h := range groups[sym] {
+			amt.Add(amt, h.amt)
+			usd.Add(usd, h.usd())
+		}
+		totalUSD.Add(totalUSD, usd)
+		if below(usd, hideBelow) {
+			hidden += len(groups[sym])
+			continue
+		}
+		fmt.Fprintln(w, holdingLine(sym, amt, usd, ""))
+		for _, h := range groups[sym] {
+			if below(h.usd(), hideBelow) {
+				hidden++
+				continue
+			}
+			fmt.Fprintln(w, "  "+holdingLine(h.chain, h.amt, h.usd(), h.note))
+		}
+	}
+	for _, h := range failed {
+		fmt.Fprintln(w, errorLine(h.tf.Symbol+" ["+h.chain+"]", h))
+	}
+	printHidden(w, hidden, hideBelow)
+
+	fmt.Fprintf(w, "TOTAL %12s => %s\n", "", reportQuote.format(totalUSD))
+}
+
+// printDegraded lists the sources the valuation skipped after repeated
+// failures.
+func printDegraded(w io.Writer, degraded []reportDegraded) {
+	for _, d := range degraded {
+		fmt.Fprintf(w, "DEGRADED: %s, %d failures, retried after %s\n", d.Source, d.Failures, d.Until.Local().Format(time.TimeOnly))
+	}
+}
+
+// printPartial flags output cut short by an interrupt or the -budget.
+func printPartial(w io.Writer, partial bool, stopped string) {
+	if partial {
+		fmt.Fprintf(w, "PARTIAL: %s before every lookup finished\n", stopped)
+	}
+}
+
+// errorLine shows the amount when only the price is missing, and a dash
+// when even the balance is unknown.
+func errorLine(label string, h *holding) string {
+	amt := "—"
+	if h.amt != nil {
+		amt = h.amt.Text('f', 6)
+	}
+	line := fmt.Sprintf("%-6s %12s  error: %v", label, amt, h.err)
+	if h.note != "" {
+		line += "  (" + h.note + ")"
+	}
+	return line
+}
+
+func holdingLine(label string, amt, usd *big.Float, note string) string {
+	line := fmt.Sprintf("%-6s %12s => %s",
+		label,
+		amt.Text('f', 6),
+		reportQuote.format(usd),
+	)
+	if note != "" {
+		line += "  (" + note + ")"
+	}
+	return line
+}
+
+// printTemplate renders each position with row and then the whole report
+// with summary; either may be nil. Every rendering is followed by a newline.
+// Positions worth less than hideBelow USD get no row, except failed ones.
+// Rows see reportPosition fields ({{.Symbol}}, {{.USD}}, ...), the summary
+// sees report fields ({{.Wallet}}, {{.TotalUSD}}, ...).
+func printTemplate(w io.Writer, r *report, header, row, summary *template.Template, hideBelow float64) error {
+	if header != nil {
+		if err := header.Execute(w, r); err != nil {
+			return err
+		}
+		fmt.Fprintln(w)
+	}
+	if row != nil {
+		for _, p := range r.Positions {
+			if p.Error == "" && hideBelow > 0 && p.USD < hideBelow {
+				continue
+			}
+			if err := row.Execute(w, p); err != nil {
+				return err
+			}
+			fmt.Fprintln(w)
+		}
+	}
+	if summary != nil {
+		if err := summary.Execute(w, r); err != nil {
+			return err
+		}
+		fmt.Fprintln(w)
+	}
+	return nil
+}
+
+// failures returns the holdings whose lookup failed.
+func failures(held []*holding) []*holding {
+	var out []*holding
+	for _, h := range held {
+		if h.err != nil || len(h.deviations) > 0 {
+			out = append(out, h)
+		}
+	}
+	return out
+}
+
+func reportFailures(w io.Writer, wallet common.Address, failed []*holding) {
+	fmt.Fprintf(w, "strict: %d lookups failed for %s:\n", len(failed), wallet.Hex())
+	for _, h := range failed {
+		err := h.err
+		if err == nil {
+			err = deviationError(h)
+		}
+		fmt.Fprintf(w, "  %-6s [%s] %v\n", h.tf.Symbol, h.chain, err)
+	}
+}
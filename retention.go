@@ -0,0 +1,106 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// retentionTier keeps one snapshot per Every (the newest of each such
+// period) among a wallet's snapshots younger than For; an empty Every keeps
+// them all. With tiers configured, snapshots older than the longest For are
+// deleted. Durations take a "d" suffix for days, e.g. {"every": "1h",
+// "for": "7d"}, {"every": "1d", "for": "365d"}.
+type retentionTier struct {
+	Every string `json:"every"`
+	For   string `json:"for"`
+
+	every, keep time.Duration
+}
+
+// parseRetention checks the tiers and orders them by For, shortest first.
+func parseRetention(tiers []retentionTier) error {
+	for i := range tiers {
+		t := &tiers[i]
+		var err error
+		if t.Every != "" {
+			if t.every, err = parseSince(t.Every); err != nil {
+				return fmt.Errorf("every: %w", err)
+			}
+		}
+		if t.keep, err = parseSince(t.For); err != nil {
+			return fmt.Errorf("for: %w", err)
+		}
+		if t.keep <= 0 || t.every < 0 {
+			return fmt.Errorf("%q for %q: durations must be positive", t.Every, t.For)
+		}
+	}
+	sort.SliceStable(tiers, func(i, j int) bool { return tiers[i].keep < tiers[j].keep })
+	return nil
+}
+
+// pruneSnapshotsIn deletes the snapshots in dir that the tiers no longer
+// keep at now and returns how many it deleted. Without tiers everything is
+// kept. Snapshots are told apart by their file names alone.
+func pruneSnapshotsIn(dir string, tiers []retentionTier, now time.Time) (int, error) {
+	if len(tiers) == 0 {
+		return 0, nil
+	}
+	paths, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return 0, err
+	}
+	type snap struct {
+		path string
+		at   time.Time
+	}
+	byWallet := map[string][]snap{}
+	for _, p := range paths {
+		wallet, unix, ok := strings.Cut(strings.TrimSuffix(filepath.Base(p), ".json"), "-")
+		sec, err := strconv.ParseInt(unix, 10, 64)
+		if !ok || err != nil {
+			continue // not a snapshot file
+		}
+		byWallet[wallet] = append(byWallet[wallet], snap{p, time.Unix(sec, 0)})
+	}
+
+	removed := 0
+	for _, snaps := range byWallet {
+		sort.Slice(snaps, func(i, j int) bool { return snaps[i].at.After(snaps[j].at) })
+		kept := map[[2]int64]bool{} // tier and period already holding a newer snapshot
+		for _, s := range snaps {
+			if keepSnapshot(tiers, kept, now.Sub(s.at), s.at) {
+				continue
+			}
+			if err := os.Remove(s.path); err != nil {
+				return removed, err
+			}
+			removed++
+		}
+	}
+	return removed, nil
+}
+
+// keepSnapshot decides on one snapshot of age, taken at, recording the
+// period it fills in kept. Snapshots must come newest first.
+func keepSnapshot(tiers []retentionTier, kept map[[2]int64]bool, age time.Duration, at time.Time) bool {
+	for i, t := range tiers {
+		if age >= t.keep {
+			continue
+		}
+		if t.every == 0 {
+			return true
+		}
+		period := [2]int64{int64(i), at.UnixNano() / int64(t.every)}
+		if kept[period] {
+			return false
+		}
+		kept[period] = true
+		return true
+	}
+	return false
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestPruneSnapshots(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Date(2026, 6, 1, 12, 0, 0, 0, time.UTC)
+	const wallet = "0x00000000000000000000000000000000000000aa"
+	// Snapshots every 30 minutes for three days, plus two a month old and
+	// one two years old.
+	var times []time.Time
+	for at := now.Add(-72 * time.Hour); !at.After(now); at = at.Add(30 * time.Minute) {
+		times = append(times, at)
+	}
+	times = append(times, now.AddDate(0, -1, 0), now.AddDate(0, -1, 0).Add(time.Hour), now.AddDate(-2, 0, 0))
+	for _, at := range times {
+		if err := os.WriteFile(filepath.Join(dir, fmt.Sprintf("%s-%d.json", wallet, at.Unix())), []byte("{}"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	other := filepath.Join(dir, "notes.json")
+	os.WriteFile(other, []byte("{}"), 0o644)
+
+	tiers := []retentionTier{{Every: "1d", For: "365d"}, {For: "12h"}, {Every: "1h", For: "2d"}}
+	if err := parseRetention(tiers); err != nil {
+		t.Fatal(err)
+	}
+	if tiers[0].For != "12h" {
+		t.Fatalf("tiers not ordered: %+v", tiers)
+	}
+	removed, err := pruneSnapshotsIn(dir, tiers, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	left, _ := filepath.Glob(filepath.Join(dir, wallet+"-*.json"))
+	// Every one of the last 12h (24), the newest of each hour touching
+	// 12h to 2d ago (37), of each day before that (2), and one of the two
+	// a month old.
+	if n := len(left); n != 24+37+2+1 || removed != len(times)-n {
+		t.Errorf("kept %d, removed %d of %d", n, removed, len(times))
+	}
+	if slices.Contains(left, filepath.Join(dir, fmt.Sprintf("%s-%d.json", wallet, now.AddDate(-2, 0, 0).Unix()))) {
+		t.Error("kept a snapshot older than every tier")
+	}
+	if _, err := os.Stat(other); err != nil {
+		t.Error("removed a file that is not a snapshot")
+	}
+
+	// A second pass has nothing more to do.
+	if removed, _ := pruneSnapshotsIn(dir, tiers, now); removed != 0 {
+		t.Errorf("second pass removed %d", removed)
+	}
+	if removed, _ := pruneSnapshotsIn(dir, nil, now.AddDate(10, 0, 0)); removed != 0 {
+		t.Errorf("no tiers removed %d", removed)
+	}
+	if err := parseRetention([]retentionTier{{Every: "1h", For: "soon"}}); err == nil {
+		t.Error("bad duration accepted")
+	}
+}
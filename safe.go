@@ -0,0 +1,111 @@
+package main
+
+import (
+	"context"
+	"log"
+	"slices"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+var safeABI = mustABI(`[
+	{"name":"getOwners","type":"function","stateMutability":"view","inputs":[],"outputs":[{"name":"","type":"address[]"}]}
+]`)
+
+// safeGraph reads Safe ownership on one chain for -safe-depth, remembering
+// every answer for the rest of the run. tracked are the addresses a Safe
+// may be found to own: the run's wallets and the address book's.
+type safeGraph struct {
+	client  chainClient
+	tracked []common.Address
+	owners  map[common.Address][]common.Address // nil for anything but a Safe
+}
+
+func newSafeGraph(client chainClient, tracked []common.Address) *safeGraph {
+	return &safeGraph{client: client, tracked: tracked, owners: map[common.Address][]common.Address{}}
+}
+
+// ownersOf returns a Safe's owners, and false for an account or contract
+// that is not a Safe.
+func (g *safeGraph) ownersOf(ctx context.Context, addr common.Address) ([]common.Address, bool, error) {
+	if owners, ok := g.owners[addr]; ok {
+		return owners, owners != nil, nil
+	}
+	code, err := g.client.CodeAt(ctx, addr, nil)
+	if err != nil {
+		return nil, false, err
+	}
+	var owners []common.Address
+	if len(code) > 0 {
+		if vs, err := callView(ctx, g.client, addr, safeABI, "getOwners"); err == nil {
+			owners = append([]common.Address{}, vs[0].([]common.Address)...)
+		}
+	}
+	g.owners[addr] = owners
+	return owners, owners != nil, nil
+}
+
+// structure lists the Safes within depth ownership links of root, in the
+// order they are found. A link runs from a Safe to each owner that is a
+// Safe itself, and from any address to the tracked Safes it is an owner of.
+// Root is not listed; owners that are plain accounts are signers, not part
+// of the structure.
+func (g *safeGraph) structure(ctx context.Context, root common.Address, depth int) ([]common.Address, error) {
+	seen := map[common.Address]bool{root: true}
+	var members []common.Address
+	level := []common.Address{root}
+	for ; depth > 0 && len(level) > 0; depth-- {
+		var next []common.Address
+		for _, addr := range level {
+			links, _, err := g.ownersOf(ctx, addr)
+			if err != nil {
+				return members, err
+			}
+			links = slices.Clone(links)
+			for _, t := range g.tracked {
+				owners, _, err := g.ownersOf(ctx, t)
+				if err != nil {
+					return members, err
+				}
+				if slices.Contains(owners, addr) {
+					links = append(links, t)
+				}
+			}
+			for _, l := range links {
+				if seen[l] {
+					continue
+				}
+				seen[l] = true
+				if _, ok, err := g.ownersOf(ctx, l); err != nil {
+					return members, err
+				} else if ok {
+					members = append(members, l)
+					next = append(next, l)
+				}
+			}
+		}
+		level = next
+	}
+	return members, nil
+}
+
+// safeHoldings values the Safes linked to wallet on the chain, noting on
+// each holding which Safe holds it. A failed ownership lookup is logged and
+// values what was found before it.
+func (c *chainConn) safeHoldings(ctx context.Context, g *safeGraph, wallet common.Address, depth int, cfg *config, opts options) []*holding {
+	members, err := g.structure(ctx, wallet, depth)
+	if err != nil {
+		log.Printf("%s: Safe owners: %v", chainName(c.id), err)
+	}
+	var held []*holding
+	for _, m := range members {
+		for _, h := range c.value(ctx, m, cfg, opts) {
+			if h.note != "" {
+				h.note += ", "
+			}
+			h.note += "in Safe " + walletName(m)
+			held = append(held, h)
+		}
+	}
+	return held
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"context"
+	"math/big"
+	"slices"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core"
+)
+
+// mockSafe answers getOwners with owners, ABI-encoded from storage.
+func mockSafe(owners ...common.Address) core.GenesisAccount {
+	storage := map[common.Hash]common.Hash{word(1): word(32), word(2): word(int64(len(owners)))}
+	slots := []int64{1, 2}
+	for i, o := range owners {
+		slot := int64(3 + i)
+		storage[word(slot)] = common.BytesToHash(o.Bytes())
+		slots = append(slots, slot)
+	}
+	return core.GenesisAccount{
+		Code:    mockCode(method(safeABI, "getOwners", slots...)),
+		Storage: storage,
+		Balance: new(big.Int),
+	}
+}
+
+func TestSafeStructure(t *testing.T) {
+	var (
+		root   = common.HexToAddress("0x5afe00000000000000000000000000000000000a")
+		owner  = common.HexToAddress("0x5afe00000000000000000000000000000000000b") // Safe owning root
+		child  = common.HexToAddress("0x5afe00000000000000000000000000000000000c") // tracked Safe root owns
+		grand  = common.HexToAddress("0x5afe00000000000000000000000000000000000d") // tracked Safe owner owns
+		signer = common.HexToAddress("0x00000000000000000000000000000000000000e1")
+	)
+	sim := newSim(t, core.GenesisAlloc{
+		root:  mockSafe(owner, signer),
+		owner: mockSafe(signer),
+		child: mockSafe(root),
+		grand: mockSafe(owner),
+	})
+	ctx := context.Background()
+
+	if _, ok, err := newSafeGraph(sim, nil).ownersOf(ctx, signer); err != nil || ok {
+		t.Errorf("plain account taken for a Safe: %v %v", ok, err)
+	}
+	tracked := []common.Address{child, grand}
+	for depth, want := range map[int][]common.Address{
+		0: nil,
+		1: {owner, child},
+		2: {owner, child, grand},
+		5: {owner, child, grand},
+	} {
+		got, err := newSafeGraph(sim, tracked).structure(ctx, root, depth)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !slices.Equal(got, want) {
+			t.Errorf("depth %d: %v, want %v", depth, got, want)
+		}
+	}
+}
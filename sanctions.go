@@ -0,0 +1,255 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	_ "embed"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// -screen checks the valued wallets, and the counterparties of their
+// registry token transfers over -screen-since, against a sanctions list,
+// and flags matches in the report; -watch flags sanctioned counterparties
+// as transfers come in. The list is <data dir>/sanctions.txt once `portfolio
+// sanctions update` has fetched the OFAC SDN addresses, else the small seed
+// embedded at build time. EVM addresses are the same on every chain, so
+// one list screens all of them.
+
+// sanctionsURL serves the Ethereum addresses of the OFAC SDN list, one per
+// line, extracted from the official XML.
+var sanctionsURL = "https://raw.githubusercontent.com/0xB10C/ofac-sanctioned-digital-currency-addresses/lists/sanctioned_addresses_ETH.txt"
+
+//go:embed sanctions.txt
+var embeddedSanctions []byte
+
+// screening is the list addresses are checked against, nil unless -screen.
+var screening sanctionsList
+
+type sanctionsList map[common.Address]bool
+
+type reportSanction struct {
+	Address string `json:"address" doc:"The sanctioned address, lower-case hex."`
+	Role    string `json:"role" doc:"wallet when the valued wallet itself is listed, counterparty when it sent tokens to or received them from it."`
+	Chain   string `json:"chain,omitempty" doc:"Chain of the transfer, for counterparties."`
+	Tx      string `json:"tx,omitempty" doc:"Latest transfer with the counterparty."`
+}
+
+func sanctionsPath() string {
+	return filepath.Join(dataDir(), "sanctions.txt")
+}
+
+// loadSanctions reads the updated list when there is one, else the
+// embedded seed, and says which it used.
+func loadSanctions() (sanctionsList, string, error) {
+	data, err := os.ReadFile(sanctionsPath())
+	from := sanctionsPath()
+	if errors.Is(err, os.ErrNotExist) {
+		data, from, err = embeddedSanctions, "the built-in seed list", nil
+	}
+	if err != nil {
+		return nil, "", err
+	}
+	list, err := parseSanctions(data)
+	return list, from, err
+}
+
+// parseSanctions reads one address per line; blank lines and # comments
+// are skipped.
+func parseSanctions(data []byte) (sanctionsList, error) {
+	list := sanctionsList{}
+	sc := bufio.NewScanner(bytes.NewReader(data))
+	for n := 1; sc.Scan(); n++ {
+		line, _, _ := strings.Cut(sc.Text(), "#")
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		if !common.IsHexAddress(line) {
+			return nil, fmt.Errorf("line %d: bad address %q", n, line)
+		}
+		list[common.HexToAddress(line)] = true
+	}
+	return list, sc.Err()
+}
+
+// screen returns each listed counterparty of the wallet's registry token
+// transfers over the last since, with its latest transfer.
+func (c *chainConn) screen(ctx context.Context, wallet common.Address, since time.Duration) ([]reportSanction, error) {
+	head, err := c.client.BlockNumber(ctx)
+	if err != nil {
+		return nil, err
+	}
+	from, err := c.blockAtTime(ctx, time.Now().Add(-since), head)
+	if err != nil {
+		return nil, err
+	}
+	var tokens []common.Address
+	for _, tf := range registry(c.id) {
+		if tf.TokenAddr != (common.Address{}) {
+			tokens = append(tokens, tf.TokenAddr)
+		}
+	}
+	logs, err := transferLogs(ctx, c.client, tokens, wallet, from, head)
+	if err != nil {
+		return nil, err
+	}
+	return sanctionedCounterparties(chainName(c.id), wallet, logs), nil
+}
+
+// sanctionedCounterparties returns the listed counterparties of the
+// wallet's transfers, once each with its latest transfer.
+func sanctionedCounterparties(chain string, wallet common.Address, logs []types.Log) []reportSanction {
+	var out []reportSanction
+	type seen struct {
+		i     int
+		block uint64
+	}
+	latest := map[common.Address]seen{}
+	for _, l := range logs {
+		if len(l.Topics) != 3 {
+			continue
+		}
+		other := common.BytesToAddress(l.Topics[1].Bytes())
+		if other == wallet {
+			other = common.BytesToAddress(l.Topics[2].Bytes())
+		}
+		if !screening[other] {
+			continue
+		}
+		s := reportSanction{Address: strings.ToLower(other.Hex()), Role: "counterparty", Chain: chain, Tx: l.TxHash.Hex()}
+		if prev, ok := latest[other]; !ok {
+			latest[other] = seen{len(out), l.BlockNumber}
+			out = append(out, s)
+		} else if l.BlockNumber > prev.block {
+			latest[other] = seen{prev.i, l.BlockNumber}
+			out[prev.i] = s
+		}
+	}
+	return out
+}
+
+// screenWallet checks the wallet, then its counterparties on every
+// connection.
+func screenWallet(ctx context.Context, conns []*chainConn, wallet common.Address, since time.Duration) []reportSanction {
+	var out []reportSanction
+	if screening[wallet] {
+		out = append(out, reportSanction{Address: strings.ToLower(wallet.Hex()), Role: "wallet"})
+	}
+	for _, c := range conns {
+		found, err := c.screen(ctx, wallet, since)
+		if err != nil {
+			log.Printf("%s: -screen: %v", chainName(c.id), err)
+		}
+		out = append(out, found...)
+	}
+	return out
+}
+
+func printSanctions(w io.Writer, found []reportSanction) {
+	for _, s := range found {
+		if s.Role == "wallet" {
+			fmt.Fprintf(w, "SANCTIONED: the wallet %s is on the sanctions list\n", s.Address)
+			continue
+		}
+		fmt.Fprintf(w, "SANCTIONED: counterparty %s on %s, latest transfer %s\n", walletName(common.HexToAddress(s.Address)), s.Chain, s.Tx)
+	}
+}
+
+// sanctionsCmd updates the list or checks addresses against it.
+func sanctionsCmd(args []string) {
+	usage := func() { log.Fatalf("Usage: %s sanctions update [-url URL] | check address...", os.Args[0]) }
+	if len(args) == 0 {
+		usage()
+	}
+	switch args[0] {
+	case "update":
+		fs := flag.NewFlagSet("sanctions update", flag.ExitOnError)
+		url := fs.String("url", sanctionsURL, "where to fetch the list from: one address per line")
+		fs.Parse(args[1:])
+		n, err := updateSanctions(context.Background(), *url, sanctionsPath())
+		if err != nil {
+			log.Fatalf("sanctions update: %v", err)
+		}
+		fmt.Printf("wrote %d addresses to %s\n", n, sanctionsPath())
+	case "check":
+		list, from, err := loadSanctions()
+		if err != nil {
+			log.Fatalf("sanctions: %v", err)
+		}
+		listed := false
+		for _, arg := range args[1:] {
+			addr, ok := walletArg(arg)
+			if !ok {
+				log.Fatalf("bad address %q", arg)
+			}
+			if list[addr] {
+				listed = true
+				fmt.Printf("%s: SANCTIONED\n", addr.Hex())
+			} else {
+				fmt.Printf("%s: not listed\n", addr.Hex())
+			}
+		}
+		fmt.Printf("checked against %s (%d addresses)\n", from, len(list))
+		if listed {
+			os.Exit(1)
+		}
+	default:
+		usage()
+	}
+}
+
+// updateSanctions fetches the list at url and stores it at path once it
+// parses.
+func updateSanctions(ctx context.Context, url, path string) (int, error) {
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return 0, err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("GET %s: %s", url, resp.Status)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 16<<20))
+	if err != nil {
+		return 0, err
+	}
+	list, err := parseSanctions(data)
+	if err != nil {
+		return 0, err
+	}
+	if len(list) == 0 {
+		return 0, errors.New("the list is empty")
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return 0, err
+	}
+	f, err := createAtomic(path)
+	if err != nil {
+		return 0, err
+	}
+	fmt.Fprintf(f, "# %s, fetched %s\n", url, time.Now().UTC().Format(time.RFC3339))
+	if _, err := f.Write(data); err != nil {
+		f.Abort()
+		return 0, err
+	}
+	return len(list), f.Commit()
+}
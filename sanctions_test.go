@@ -0,0 +1,61 @@
+package main
+
+import (
+	"context"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+func TestSanctionsList(t *testing.T) {
+	list, err := parseSanctions(embeddedSanctions)
+	if err != nil || len(list) == 0 {
+		t.Fatalf("embedded list: %d addresses, %v", len(list), err)
+	}
+	if _, err := parseSanctions([]byte("0x098B716B8Aaf21512996dC57EB0615e2383E2f96\nnot-an-address\n")); err == nil || !strings.Contains(err.Error(), "line 2") {
+		t.Errorf("bad line: %v", err)
+	}
+
+	listed := common.HexToAddress("0x00000000000000000000000000000000000000dd")
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(listed.Hex() + "\n"))
+	}))
+	defer srv.Close()
+	t.Setenv("PORTFOLIO_HOME", t.TempDir())
+	path := filepath.Join(dataDir(), "sanctions.txt")
+	if n, err := updateSanctions(context.Background(), srv.URL, path); n != 1 || err != nil {
+		t.Fatalf("update: %d, %v", n, err)
+	}
+	list, from, err := loadSanctions()
+	if err != nil || from != path || !list[listed] || len(list) != 1 {
+		t.Fatalf("after update: %v from %s, %v", list, from, err)
+	}
+}
+
+func TestSanctionedCounterparties(t *testing.T) {
+	bad := common.HexToAddress("0x00000000000000000000000000000000000000dd")
+	good := common.HexToAddress("0x00000000000000000000000000000000000000ee")
+	screening = sanctionsList{bad: true}
+	t.Cleanup(func() { screening = nil })
+
+	logs := []types.Log{
+		transferLog(30, 0, bad, testWallet, 1),
+		transferLog(10, 0, testWallet, bad, 1),
+		transferLog(20, 0, good, testWallet, 1),
+	}
+	found := sanctionedCounterparties("mainnet", testWallet, logs)
+	if len(found) != 1 || found[0].Address != strings.ToLower(bad.Hex()) || found[0].Tx != logs[0].TxHash.Hex() {
+		t.Fatalf("found %+v, want %s once with its block 30 transfer", found, bad.Hex())
+	}
+
+	h := &holding{amt: big.NewFloat(1), price: big.NewFloat(1)}
+	if line := transferLine(1, logs[0], tokenFeed{Symbol: "TKN"}, h, bad, testWallet, map[common.Address]bool{testWallet: true}); !strings.HasSuffix(line, "SANCTIONED counterparty") {
+		t.Errorf("watch line not flagged: %s", line)
+	}
+}
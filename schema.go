@@ -0,0 +1,95 @@
+package main
+
+import (
+	_ "embed"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"reflect"
+	"strings"
+	"time"
+)
+
+//go:generate go run . schema -o report.schema.json
+
+// reportSchema is the JSON Schema of report, generated from the Go structs
+// and checked in so that changes to it show up in review.
+//
+//go:embed report.schema.json
+var reportSchema []byte
+
+func schemaCmd(args []string) {
+	fs := flag.NewFlagSet("schema", flag.ExitOnError)
+	out := fs.String("o", "", "regenerate the schema from the Go structs into this file")
+	fs.Parse(args)
+	if *out == "" {
+		os.Stdout.Write(reportSchema)
+		return
+	}
+	data, err := generateSchema()
+	if err != nil {
+		log.Fatalf("schema: %v", err)
+	}
+	if err := os.WriteFile(*out, data, 0o644); err != nil {
+		log.Fatalf("schema: %v", err)
+	}
+}
+
+func generateSchema() ([]byte, error) {
+	s := schemaOf(reflect.TypeOf(report{}))
+	s["$schema"] = "https://json-schema.org/draft/2020-12/schema"
+	s["title"] = "Portfolio report"
+	s["description"] = fmt.Sprintf("Output of -format json and stored snapshots, schema_version %d.", reportSchemaVersion)
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(data, '\n'), nil
+}
+
+// schemaOf describes a Go type as JSON Schema. Struct fields take their name
+// from the json tag and their description from the doc tag; fields without
+// omitempty are required.
+func schemaOf(t reflect.Type) map[string]any {
+	if t == reflect.TypeOf(time.Time{}) {
+		return map[string]any{"type": "string", "format": "date-time"}
+	}
+	switch t.Kind() {
+	case reflect.String:
+		return map[string]any{"type": "string"}
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Int, reflect.Int64, reflect.Uint64:
+		return map[string]any{"type": "integer"}
+	case reflect.Float64:
+		return map[string]any{"type": "number"}
+	case reflect.Slice:
+		return map[string]any{"type": "array", "items": schemaOf(t.Elem())}
+	case reflect.Map:
+		return map[string]any{"type": "object", "additionalProperties": schemaOf(t.Elem())}
+	case reflect.Pointer:
+		return schemaOf(t.Elem())
+	case reflect.Struct:
+		props := map[string]any{}
+		required := []string{}
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			name, opts, _ := strings.Cut(f.Tag.Get("json"), ",")
+			if name == "-" || !f.IsExported() {
+				continue
+			}
+			p := schemaOf(f.Type)
+			if doc := f.Tag.Get("doc"); doc != "" {
+				p["description"] = doc
+			}
+			props[name] = p
+			if !strings.Contains(opts, "omitempty") {
+				required = append(required, name)
+			}
+		}
+		return map[string]any{"type": "object", "properties": props, "required": required}
+	}
+	return map[string]any{}
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+
+	"Test2/bindings"
+	"Test2/source"
+)
+
+// sequencerFeeds are Chainlink's L2 sequencer uptime feeds, keyed by chain ID.
+// The answer is 0 while the sequencer is up and 1 while it is down; startedAt
+// is when that status last changed.
+var sequencerFeeds = map[uint64]common.Address{
+	10:    common.HexToAddress("0x371EAD81c9102C9BF4874A9075FFFf170F2Ee389"), // OP Mainnet
+	8453:  common.HexToAddress("0xBCF85224fc0756B9Fa45aA7892530B47e10b6433"), // Base
+	42161: common.HexToAddress("0xFdB631F5EE196F0ed6FAa767959853A9F217697D"), // Arbitrum One
+}
+
+// sequencerGracePeriod is how long price answers stay untrusted after the
+// sequencer comes back, giving feeds time to catch up on queued updates.
+const sequencerGracePeriod = time.Hour
+
+// checkSequencer reports whether Chainlink answers on the chain can be
+// trusted at the block the client reads. On L1 and chains without an uptime
+// feed it always returns true; an unreadable feed is not trusted.
+func checkSequencer(ctx context.Context, client chainClient, chainID uint64) (bool, error) {
+	feed, ok := sequencerFeeds[chainID]
+	if !ok {
+		return true, nil
+	}
+	uptime, err := bindings.NewAggregatorCaller(feed, client)
+	if err != nil {
+		return false, fmt.Errorf("%w: sequencer uptime feed: %w", source.ErrStaleFeed, err)
+	}
+	round, err := uptime.LatestRoundData(&bind.CallOpts{Context: ctx})
+	if err != nil {
+		return false, fmt.Errorf("%w: sequencer uptime feed: %w", source.ErrStaleFeed, err)
+	}
+	at, err := stateTime(ctx, client)
+	if err != nil {
+		return false, fmt.Errorf("%w: sequencer uptime feed: %w", source.ErrStaleFeed, err)
+	}
+	since := time.Unix(round.StartedAt.Int64(), 0)
+	if round.Answer.Sign() != 0 {
+		return false, fmt.Errorf("L2 sequencer is down since %s", since.UTC().Format(time.RFC3339))
+	}
+	if up := at.Sub(since); up < sequencerGracePeriod {
+		return false, fmt.Errorf("L2 sequencer came back up %s before the block, within the %s grace period",
+			up.Round(time.Second), sequencerGracePeriod)
+	}
+	return true, nil
+}
+
+// recheckSequencer decides again whether Chainlink answers are trusted once
+// the connection reads another block, warning when that changes.
+func (c *chainConn) recheckSequencer(ctx context.Context) {
+	if _, ok := sequencerFeeds[c.id]; !ok {
+		return
+	}
+	trusted, err := checkSequencer(ctx, c.caller(), c.id)
+	if trusted == c.trustFeeds {
+		return
+	}
+	c.trustFeeds = trusted
+	if trusted {
+		log.Printf("note: trusting Chainlink answers on %s again at block %s", chainName(c.id), c.block)
+		return
+	}
+	log.Printf("warning: %v", err)
+	log.Printf("warning: ignoring Chainlink answers on %s at block %s until the sequencer is stable", chainName(c.id), c.block)
+}
@@ -0,0 +1,209 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math/big"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// maxSeriesBlocks keeps a mistyped -blocks step from starting a run of
+// millions of valuations.
+const maxSeriesBlocks = 10_000
+
+// parseBlocks reads -blocks: comma-separated block numbers and ranges
+// first..last:step, last included when the step lands on it.
+func parseBlocks(s string) ([]uint64, error) {
+	var blocks []uint64
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		first, rest, isRange := strings.Cut(part, "..")
+		from, err := strconv.ParseUint(first, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("bad block %q", part)
+		}
+		if !isRange {
+			blocks = append(blocks, from)
+			continue
+		}
+		last, stepText, ok := strings.Cut(rest, ":")
+		to, err1 := strconv.ParseUint(last, 10, 64)
+		step, err2 := strconv.ParseUint(stepText, 10, 64)
+		if !ok || err1 != nil || err2 != nil || step == 0 || to < from {
+			return nil, fmt.Errorf("bad range %q, want first..last:step", part)
+		}
+		if (to-from)/step+1 > maxSeriesBlocks {
+			return nil, fmt.Errorf("%s is more than %d blocks", part, maxSeriesBlocks)
+		}
+		for b := from; b <= to; b += step {
+			blocks = append(blocks, b)
+		}
+	}
+	if len(blocks) > maxSeriesBlocks {
+		return nil, fmt.Errorf("more than %d blocks", maxSeriesBlocks)
+	}
+	return blocks, nil
+}
+
+// pinnedClient answers every call meant for the latest block at block
+// instead, so that the valuation code needs no notion of history. Log
+// queries without an end stop there too.
+type pinnedClient struct {
+	chainClient
+	block *big.Int
+}
+
+func (p pinnedClient) at(block *big.Int) *big.Int {
+	if block == nil {
+		return p.block
+	}
+	return block
+}
+
+func (p pinnedClient) CallContract(ctx context.Context, call ethereum.CallMsg, block *big.Int) ([]byte, error) {
+	return p.chainClient.CallContract(ctx, call, p.at(block))
+}
+
+func (p pinnedClient) CodeAt(ctx context.Context, contract common.Address, block *big.Int) ([]byte, error) {
+	return p.chainClient.CodeAt(ctx, contract, p.at(block))
+}
+
+func (p pinnedClient) BalanceAt(ctx context.Context, account common.Address, block *big.Int) (*big.Int, error) {
+	return p.chainClient.BalanceAt(ctx, account, p.at(block))
+}
+
+// HeaderByNumber reads the pinned block's header for the latest one, when
+// the node serves headers.
+func (p pinnedClient) HeaderByNumber(ctx context.Context, number *big.Int) (*types.Header, error) {
+	hc, ok := p.chainClient.(headerClient)
+	if !ok {
+		return nil, errors.New("the node serves no block headers")
+	}
+	return hc.HeaderByNumber(ctx, p.at(number))
+}
+
+func (p pinnedClient) FilterLogs(ctx context.Context, q ethereum.FilterQuery) ([]types.Log, error) {
+	if q.BlockHash == nil && q.ToBlock == nil {
+		q.ToBlock = p.block
+	}
+	return p.chainClient.FilterLogs(ctx, q)
+}
+
+// atBlock is the connection as of a past block: its lookups read that
+// block's state and its prices are the ones of the block's time.
+func (c *chainConn) atBlock(ctx context.Context, block uint64) (*chainConn, error) {
+	n := new(big.Int).SetUint64(block)
+	head, err := c.client.HeaderByNumber(ctx, n)
+	if err != nil {
+		return nil, fmt.Errorf("block %d: %w", block, err)
+	}
+	pinned := *c
+	pinned.setBlock(head)
+	pinned.historical = true
+	pinned.recheckSequencer(ctx)
+	return &pinned, nil
+}
+
+// runSeries is the -blocks run: every wallet's series on the one chain, as
+// a table or one JSON report per block.
+func runSeries(ctx context.Context, out io.Writer, conns []*chainConn, wallets []string, file, tag, list string, asJSON, batch bool, cfg *config, opts options) {
+	blocks, err := parseBlocks(list)
+	if err != nil {
+		fatalf("-blocks: %v", err)
+	}
+	if len(conns) != 1 {
+		fatalf("-blocks values one chain; pass -rpc or a single -chains entry")
+	}
+	err = forEachAddress(ctx, wallets, file, func(wallet common.Address) {
+		if tag != "" && !book.hasTag(wallet, tag) {
+			return
+		}
+		runProgress.wallet()
+		reps, err := conns[0].valueSeries(ctx, wallet, blocks, cfg, opts)
+		runProgress.clear()
+		if err != nil {
+			log.Printf("%s: %v", wallet.Hex(), err)
+		}
+		if asJSON {
+			for _, rep := range reps {
+				if err := printJSON(out, rep, true); err != nil {
+					fatalf("%v", err)
+				}
+			}
+			return
+		}
+		printHeader(out, batch, wallet)
+		printSeries(out, reps)
+		printPartial(out, ctx.Err() != nil, "stopped")
+	})
+	if err != nil {
+		fatalf("%v", err)
+	}
+}
+
+// valueSeries values the wallet on the chain at each block, reusing the
+// connection. Positions only the present knows, off-chain ones and
+// validator balances, are left out.
+func (c *chainConn) valueSeries(ctx context.Context, wallet common.Address, blocks []uint64, cfg *config, opts options) ([]*report, error) {
+	opts.Validators, opts.Withdrawal = "", ""
+	var reps []*report
+	for _, b := range blocks {
+		if ctx.Err() != nil {
+			break
+		}
+		runProgress.set("block %d", b)
+		at, err := c.atBlock(ctx, b)
+		if err != nil {
+			return reps, err
+		}
+		rep := newReport(wallet.Hex(), at.value(ctx, wallet, cfg, opts))
+		rep.Time, rep.Block = at.blockTime, b
+		rep.Partial = ctx.Err() != nil
+		reps = append(reps, rep)
+	}
+	return reps, nil
+}
+
+// printSeries writes one row per block: its time, the total and the USD
+// value of every symbol held at any of the blocks.
+func printSeries(w io.Writer, reps []*report) {
+	var symbols []string
+	seen := map[string]bool{}
+	for _, rep := range reps {
+		for _, p := range rep.Positions {
+			if !seen[p.Symbol] {
+				seen[p.Symbol] = true
+				symbols = append(symbols, p.Symbol)
+			}
+		}
+	}
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintf(tw, "BLOCK\tTIME\tTOTAL\t%s\t\n", strings.Join(symbols, "\t"))
+	for _, rep := range reps {
+		usd := map[string]float64{}
+		failed := map[string]bool{}
+		for _, p := range rep.Positions {
+			usd[p.Symbol] += p.USD
+			failed[p.Symbol] = failed[p.Symbol] || p.Error != ""
+		}
+		fmt.Fprintf(tw, "%d\t%s\t%s\t", rep.Block, rep.Time.Local().Format("2006-01-02 15:04"), fmt.Sprintf("$%.2f", rep.TotalUSD))
+		for _, sym := range symbols {
+			cell := fmt.Sprintf("%.2f", usd[sym])
+			if failed[sym] {
+				cell += "?"
+			}
+			fmt.Fprintf(tw, "%s\t", cell)
+		}
+		fmt.Fprintln(tw)
+	}
+	tw.Flush()
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"context"
+	"math/big"
+	"slices"
+	"sync"
+	"testing"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core"
+)
+
+func TestParseBlocks(t *testing.T) {
+	for in, want := range map[string][]uint64{
+		"100":                  {100},
+		"100..130:10":          {100, 110, 120, 130},
+		"100..125:10, 7":       {100, 110, 120, 7},
+		"18000000..18000000:1": {18000000},
+	} {
+		got, err := parseBlocks(in)
+		if err != nil || !slices.Equal(got, want) {
+			t.Errorf("parseBlocks(%q) = %v, %v; want %v", in, got, err, want)
+		}
+	}
+	for _, bad := range []string{"", "x", "100..", "100..200", "100..200:0", "200..100:5", "0..100000000:1"} {
+		if _, err := parseBlocks(bad); err == nil {
+			t.Errorf("parseBlocks(%q) accepted", bad)
+		}
+	}
+}
+
+// recordingClient notes the block of every state read. Registry balances
+// are read concurrently, so the notes are guarded.
+type recordingClient struct {
+	chainClient
+	mu     sync.Mutex
+	blocks []*big.Int
+}
+
+func (r *recordingClient) record(block *big.Int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.blocks = append(r.blocks, block)
+}
+
+func (r *recordingClient) CallContract(ctx context.Context, call ethereum.CallMsg, block *big.Int) ([]byte, error) {
+	r.record(block)
+	return r.chainClient.CallContract(ctx, call, block)
+}
+
+func (r *recordingClient) BalanceAt(ctx context.Context, account common.Address, block *big.Int) (*big.Int, error) {
+	r.record(block)
+	return r.chainClient.BalanceAt(ctx, account, block)
+}
+
+func TestPinnedClient(t *testing.T) {
+	link, _ := tokenBySymbol("LINK")
+	sim := newSim(t, core.GenesisAlloc{
+		link.TokenAddr: mockToken(18, map[common.Address]*big.Int{testWallet: scaled(t, "4", 18)}),
+	})
+	rec := &recordingClient{chainClient: sim}
+	// The simulated chain only has state for its current block, 0.
+	held := options{Only: "ETH,LINK"}.filter(1, walletHoldings(context.Background(), pinnedClient{rec, big.NewInt(0)}, 1, testWallet, &config{}))
+	if len(held) != 1 || held[0].tf.Symbol != "LINK" || !floatEq(held[0].amt, 4) {
+		t.Fatalf("%d holdings at block 0", len(held))
+	}
+	if len(rec.blocks) == 0 {
+		t.Fatal("no state reads")
+	}
+	for _, b := range rec.blocks {
+		if b == nil || b.Sign() != 0 {
+			t.Fatalf("read at block %v, want 0", b)
+		}
+	}
+}
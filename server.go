@@ -0,0 +1,631 @@
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"embed"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"log"
+	"math"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+
+	"Test2/source"
+)
+
+// webAssets is the dashboard served at /: plain HTML and JS that read the
+// JSON API below, so the binary is all a deployment needs.
+//
+//go:embed web
+var webAssets embed.FS
+
+// server answers the HTTP API of server mode:
+//
+//	GET    /api/wallets                   the wallets served, with their labels
+//	POST   /api/wallets                   register {"wallet": address or label}
+//	DELETE /api/wallets/{wallet}          unregister a wallet
+//	GET    /api/wallets/{wallet}/report   the wallet's current report
+//	GET    /api/wallets/{wallet}/history  its stored snapshots, oldest first
+//	GET    /api/cache                     statistics of the report cache
+//	DELETE /api/cache                     empty the report cache
+//	GET    /api/metrics                   the same statistics for Prometheus
+//
+// With tenants configured every API request names one by its key, as
+// "Authorization: Bearer <key>" or "X-API-Key: <key>", and sees only that
+// tenant's wallets and history. Without, one open tenant serves the wallets
+// given on the command line, which cannot be changed through the API, to
+// callers with one of the -api-keys, or to anyone when there are none.
+// Serving beyond localhost needs keys either way.
+//
+// Reports are valued on demand and reused for refresh; valuations run one
+// at a time, across tenants, so a burst of requests cannot multiply the RPC
+// load.
+type server struct {
+	value   func(common.Address) *report
+	refresh time.Duration
+	save    bool // store every fresh valuation as a snapshot
+	// retention prunes the snapshots saved; nil keeps them all.
+	retention []retentionTier
+	// valued, when set, is handed every fresh complete valuation, outside
+	// the valuing lock.
+	valued func(*report)
+
+	open    *tenant
+	keys    []string           // API keys the open tenant requires, if any
+	tenants map[string]*tenant // by API key
+
+	// Requests are rate limited per tenant, per -api-keys key, and across
+	// all callers by global; keyLimits and global are nil for no limit.
+	keyLimits map[string]*rateLimiter
+	global    *rateLimiter
+
+	cors corsConfig
+
+	valuing sync.Mutex // held while valuing; guards every tenant's reports
+}
+
+// corsConfig lets browser pages from Origins call the API. An origin of
+// "*" allows any. Methods and Headers default to what the API uses;
+// MaxAge is how many seconds a browser may cache a preflight answer.
+type corsConfig struct {
+	Origins []string `json:"origins"`
+	Methods []string `json:"methods"`
+	Headers []string `json:"headers"`
+	MaxAge  int      `json:"max_age"`
+}
+
+// handler adds the CORS headers for allowed origins and answers their
+// preflight requests itself, before authentication: browsers send those
+// without credentials.
+func (c corsConfig) handler(next http.Handler) http.Handler {
+	methods, headers := c.Methods, c.Headers
+	if len(methods) == 0 {
+		methods = []string{http.MethodGet, http.MethodPost, http.MethodDelete}
+	}
+	if len(headers) == 0 {
+		headers = []string{"Authorization", "Content-Type", "X-API-Key"}
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		origin := r.Header.Get("Origin")
+		if origin == "" || len(c.Origins) == 0 {
+			next.ServeHTTP(w, r)
+			return
+		}
+		w.Header().Add("Vary", "Origin")
+		if !slices.Contains(c.Origins, "*") && !slices.Contains(c.Origins, origin) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		w.Header().Set("Access-Control-Allow-Origin", origin)
+		if r.Method != http.MethodOptions || r.Header.Get("Access-Control-Request-Method") == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		w.Header().Set("Access-Control-Allow-Methods", strings.Join(methods, ", "))
+		w.Header().Set("Access-Control-Allow-Headers", strings.Join(headers, ", "))
+		if c.MaxAge > 0 {
+			w.Header().Set("Access-Control-Max-Age", fmt.Sprint(c.MaxAge))
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
+
+// tenant is one user of the server, with its own wallets, snapshot store
+// and request budget.
+type tenant struct {
+	name  string
+	dir   string // where its snapshots and registered wallets are stored
+	fixed bool   // wallets come from the command line
+	// anyWallet lets the tenant value wallets it has not registered, as the
+	// daemon does for whoever can reach its socket.
+	anyWallet bool
+	limit     *rateLimiter // nil for no limit
+
+	mu      sync.Mutex
+	wallets []common.Address
+	reports map[common.Address]*report
+	cache   cacheStats // of reports
+}
+
+// tenantConfig is a user of server mode. Key, or the environment variable
+// KeyEnv, is its API key. Wallets are registered up front; more can be
+// added through the API and are kept across restarts. RateLimit is the
+// requests per minute it may make, 60 when unset.
+type tenantConfig struct {
+	Name      string   `json:"name"`
+	Key       string   `json:"key"`
+	KeyEnv    string   `json:"key_env"`
+	Wallets   []string `json:"wallets"`
+	RateLimit float64  `json:"rate_limit"`
+}
+
+var tenantName = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// newTenants builds the configured tenants by API key, with the wallets
+// they registered before.
+func newTenants(configs []tenantConfig) (map[string]*tenant, error) {
+	out := map[string]*tenant{}
+	names := map[string]bool{}
+	for _, tc := range configs {
+		if !tenantName.MatchString(tc.Name) || names[tc.Name] {
+			return nil, fmt.Errorf("tenant %q: need a unique name of letters, digits, - and _", tc.Name)
+		}
+		names[tc.Name] = true
+		key := tc.Key
+		if tc.KeyEnv != "" {
+			key = os.Getenv(tc.KeyEnv)
+		}
+		if key == "" {
+			return nil, fmt.Errorf("tenant %s: no API key (set key or key_env)", tc.Name)
+		}
+		if _, dup := out[key]; dup {
+			return nil, fmt.Errorf("tenant %s: API key shared with another tenant", tc.Name)
+		}
+		rate := tc.RateLimit
+		if rate <= 0 {
+			rate = 60
+		}
+		t := &tenant{
+			name:    tc.Name,
+			dir:     filepath.Join(dataDir(), "tenants", tc.Name),
+			limit:   newRateLimiter(rate),
+			reports: map[common.Address]*report{},
+		}
+		wallets := tc.Wallets
+		registered, err := t.loadWallets()
+		if err != nil {
+			return nil, fmt.Errorf("tenant %s: %w", tc.Name, err)
+		}
+		for _, w := range append(wallets, registered...) {
+			addr, ok := walletArg(w)
+			if !ok {
+				return nil, fmt.Errorf("tenant %s: wallet: %w %q", tc.Name, source.ErrInvalidAddress, w)
+			}
+			if !slices.Contains(t.wallets, addr) {
+				t.wallets = append(t.wallets, addr)
+			}
+		}
+		out[key] = t
+	}
+	return out, nil
+}
+
+// serves reports whether the tenant may value the wallet.
+func (t *tenant) serves(wallet common.Address) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.anyWallet || slices.Contains(t.wallets, wallet)
+}
+
+func (t *tenant) walletsPath() string {
+	return filepath.Join(t.dir, "wallets.json")
+}
+
+func (t *tenant) snapshotDir() string {
+	return filepath.Join(t.dir, "snapshots")
+}
+
+// loadWallets reads the wallets registered through the API.
+func (t *tenant) loadWallets() ([]string, error) {
+	data, err := os.ReadFile(t.walletsPath())
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var wallets []string
+	return wallets, json.Unmarshal(data, &wallets)
+}
+
+// saveWallets stores the tenant's wallets; t.mu is held.
+func (t *tenant) saveWallets() error {
+	if err := os.MkdirAll(t.dir, 0o755); err != nil {
+		return err
+	}
+	f, err := createAtomic(t.walletsPath())
+	if err != nil {
+		return err
+	}
+	if err := json.NewEncoder(f).Encode(t.wallets); err != nil {
+		f.Abort()
+		return err
+	}
+	return f.Commit()
+}
+
+// historyPoint is one snapshot as the history endpoint returns it.
+type historyPoint struct {
+	Time     time.Time          `json:"time"`
+	TotalUSD float64            `json:"total_usd"`
+	Assets   map[string]float64 `json:"assets"`
+	Partial  bool               `json:"partial,omitempty"`
+}
+
+type tenantHandler func(w http.ResponseWriter, r *http.Request, t *tenant)
+
+// caller is who an authenticated request is from: the tenant it acts for
+// and the budget it draws from, nil for none.
+type caller struct {
+	tenant *tenant
+	limit  *rateLimiter
+}
+
+type callerKey struct{}
+
+func (s *server) handler() http.Handler {
+	api := http.NewServeMux()
+	api.HandleFunc("GET /api/wallets", s.api(s.handleWallets))
+	api.HandleFunc("POST /api/wallets", s.api(s.handleRegister))
+	api.HandleFunc("DELETE /api/wallets/{wallet}", s.api(s.handleUnregister))
+	api.HandleFunc("GET /api/wallets/{wallet}/report", s.api(s.handleReport))
+	api.HandleFunc("GET /api/wallets/{wallet}/history", s.api(s.handleHistory))
+	// The stream is served at /v1/stream/{wallet}, and under /api beside
+	// the wallet's other resources for the dashboard's sake.
+	api.HandleFunc("GET /v1/stream/{wallet}", s.api(s.handleStream))
+	api.HandleFunc("GET /api/wallets/{wallet}/stream", s.api(s.handleStream))
+	api.HandleFunc("GET /api/ws", s.api(s.handleWS))
+	api.HandleFunc("GET /api/cache", s.api(s.handleCache))
+	api.HandleFunc("DELETE /api/cache", s.api(s.handleCacheClear))
+	api.HandleFunc("GET /api/metrics", s.api(s.handleMetrics))
+
+	mux := http.NewServeMux()
+	guarded := s.cors.handler(s.authenticate(api))
+	mux.Handle("/api/", guarded)
+	mux.Handle("/v1/", guarded)
+	web, err := fs.Sub(webAssets, "web")
+	if err != nil {
+		panic(err)
+	}
+	mux.Handle("/", http.FileServerFS(web))
+	return mux
+}
+
+// authenticate lets a request through to next only with an API key it
+// knows, and tells next whose it is. The open tenant needs one of keys when
+// any are set. The dashboard's files hold no data and are not guarded; the
+// page asks for a key when the API refuses it.
+func (s *server) authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := requestKey(r)
+		t := s.open
+		switch {
+		case t == nil:
+			t = s.tenantByKey(key)
+		case len(s.keys) > 0 && !s.knownKey(key):
+			t = nil
+		}
+		if t == nil {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="portfolio"`)
+			writeError(w, http.StatusUnauthorized, errors.New("missing or unknown API key"))
+			return
+		}
+		c := caller{tenant: t, limit: t.limit}
+		if t == s.open {
+			c.limit = s.keyLimits[key]
+		}
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), callerKey{}, c)))
+	})
+}
+
+// api hands a request to h with its tenant, once the caller's own rate
+// limit and then the server's allow it.
+func (s *server) api(h tenantHandler) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		c := r.Context().Value(callerKey{}).(caller)
+		now := time.Now()
+		for _, l := range []*rateLimiter{c.limit, s.global} {
+			if l == nil {
+				continue
+			}
+			if ok, wait := l.allow(now); !ok {
+				w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(max(wait.Seconds(), 1)))))
+				writeError(w, http.StatusTooManyRequests, errors.New("rate limit exceeded"))
+				return
+			}
+		}
+		h(w, r, c.tenant)
+	}
+}
+
+// requestKey is the API key a request carries, if any. Streams and the
+// websocket also take it as ?key=, as browsers cannot set headers on them.
+func requestKey(r *http.Request) string {
+	if key, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok {
+		return strings.TrimSpace(key)
+	}
+	stream := strings.HasSuffix(r.URL.Path, "/stream") || strings.HasPrefix(r.URL.Path, "/v1/stream/")
+	if key := r.Header.Get("X-API-Key"); key != "" || !stream && r.URL.Path != "/api/ws" {
+		return key
+	}
+	return r.URL.Query().Get("key")
+}
+
+// knownKey compares key with the open tenant's keys in constant time.
+func (s *server) knownKey(key string) bool {
+	found := false
+	for _, k := range s.keys {
+		if subtle.ConstantTimeCompare([]byte(k), []byte(key)) == 1 {
+			found = true
+		}
+	}
+	return found
+}
+
+// tenantByKey compares key with every tenant's in constant time.
+func (s *server) tenantByKey(key string) *tenant {
+	var found *tenant
+	for k, t := range s.tenants {
+		if subtle.ConstantTimeCompare([]byte(k), []byte(key)) == 1 {
+			found = t
+		}
+	}
+	return found
+}
+
+func (s *server) handleWallets(w http.ResponseWriter, r *http.Request, t *tenant) {
+	type wallet struct {
+		Address common.Address `json:"address"`
+		Label   string         `json:"label,omitempty"`
+	}
+	out := []wallet{}
+	t.mu.Lock()
+	for _, a := range t.wallets {
+		out = append(out, wallet{a, book.label(a)})
+	}
+	t.mu.Unlock()
+	writeJSON(w, http.StatusOK, out)
+}
+
+func (s *server) handleRegister(w http.ResponseWriter, r *http.Request, t *tenant) {
+	if t.fixed {
+		writeError(w, http.StatusForbidden, errors.New("this server's wallets are set on its command line"))
+		return
+	}
+	var req struct {
+		Wallet string `json:"wallet"`
+	}
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 4096)).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	addr, ok := walletArg(req.Wallet)
+	if !ok {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("wallet: %w %q", source.ErrInvalidAddress, req.Wallet))
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if !slices.Contains(t.wallets, addr) {
+		t.wallets = append(t.wallets, addr)
+		if err := t.saveWallets(); err != nil {
+			t.wallets = t.wallets[:len(t.wallets)-1]
+			writeError(w, http.StatusInternalServerError, err)
+			return
+		}
+	}
+	writeJSON(w, http.StatusCreated, map[string]common.Address{"address": addr})
+}
+
+func (s *server) handleUnregister(w http.ResponseWriter, r *http.Request, t *tenant) {
+	if t.fixed {
+		writeError(w, http.StatusForbidden, errors.New("this server's wallets are set on its command line"))
+		return
+	}
+	wallet, ok := s.wallet(w, r, t)
+	if !ok {
+		return
+	}
+	s.evict(t, wallet)
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	before := t.wallets
+	t.wallets = slices.DeleteFunc(slices.Clone(t.wallets), func(a common.Address) bool { return a == wallet })
+	if err := t.saveWallets(); err != nil {
+		t.wallets = before
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *server) handleReport(w http.ResponseWriter, r *http.Request, t *tenant) {
+	wallet, ok := s.wallet(w, r, t)
+	if !ok {
+		return
+	}
+	writeJSON(w, http.StatusOK, s.report(t, wallet))
+}
+
+func (s *server) handleHistory(w http.ResponseWriter, r *http.Request, t *tenant) {
+	wallet, ok := s.wallet(w, r, t)
+	if !ok {
+		return
+	}
+	snaps, err := listSnapshotsIn(t.snapshotDir(), wallet.Hex())
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	points := []historyPoint{}
+	for _, snap := range snaps {
+		p := historyPoint{Time: snap.Time, TotalUSD: snap.TotalUSD, Assets: map[string]float64{}, Partial: snap.Partial}
+		for _, pos := range snap.Positions {
+			if pos.Error == "" && pos.USD > 0 {
+				p.Assets[strings.ToUpper(pos.Symbol)] += pos.USD
+			}
+		}
+		points = append(points, p)
+	}
+	writeJSON(w, http.StatusOK, points)
+}
+
+// wallet resolves the request's wallet, answering 404 for one the tenant
+// does not have.
+func (s *server) wallet(w http.ResponseWriter, r *http.Request, t *tenant) (common.Address, bool) {
+	if addr, ok := walletArg(r.PathValue("wallet")); ok && t.serves(addr) {
+		return addr, true
+	}
+	writeError(w, http.StatusNotFound, fmt.Errorf("unknown wallet %q", r.PathValue("wallet")))
+	return common.Address{}, false
+}
+
+// report returns the tenant's last report of the wallet, valuing it again
+// once it is older than refresh.
+func (s *server) report(t *tenant, wallet common.Address) *report {
+	s.valuing.Lock()
+	defer s.valuing.Unlock()
+	if rep := t.reports[wallet]; rep != nil && time.Since(rep.Time) < s.refresh {
+		t.cache.Hits++
+		return rep
+	} else if rep != nil {
+		t.cache.Evictions++
+	}
+	t.cache.Misses++
+	rep := s.value(wallet)
+	t.reports[wallet] = rep
+	if s.valued != nil && !rep.Partial {
+		go s.valued(rep)
+	}
+	if s.save && !rep.Partial {
+		if _, err := saveSnapshotIn(t.snapshotDir(), rep); err != nil {
+			log.Printf("%s: snapshot: %v", t.name, err)
+		} else if _, err := pruneSnapshotsIn(t.snapshotDir(), s.retention, rep.Time); err != nil {
+			log.Printf("%s: snapshot retention: %v", t.name, err)
+		}
+	}
+	return rep
+}
+
+// loopback reports whether a listen address only accepts local
+// connections.
+func loopback(addr string) bool {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return false
+	}
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Printf("serve: %v", err)
+	}
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
+
+// serveCmd runs server mode: the API and dashboard above, for the config's
+// tenants or else the wallets given, until interrupted.
+func serveCmd(args []string) {
+	fs := flag.NewFlagSet("serve", flag.ExitOnError)
+	fs.String("config", "config.json", "path to the JSON config file")
+	rpc := fs.String("rpc", "", "RPC endpoint of the chain valued by default (default: ETH_RPC_URL)")
+	chainList := fs.String("chains", "", "comma-separated chains to value the wallets on (default: the chain -rpc points at)")
+	listen := fs.String("listen", "127.0.0.1:8080", "address to serve the dashboard and API on")
+	refresh := fs.Duration("refresh", time.Minute, "how long a valuation is reused before the next request values the wallet again")
+	save := fs.Bool("save", false, "store every fresh valuation as a snapshot, building up the dashboard's history")
+	export := fs.String("export", "", "send every fresh valuation to these comma-separated services: "+strings.Join(exporterNames(), ", "))
+	rateLimit := fs.Float64("rate-limit", 0, "requests per minute the API answers across all callers; 0 for no limit")
+	keyRateLimit := fs.Float64("key-rate-limit", 60, "requests per minute each of the -api-keys may make; 0 for no limit")
+	apiKeys := fs.String("api-keys", "", "comma-separated API keys the wallets are served to, as PORTFOLIO_API_KEYS or the api-keys setting; required to listen beyond localhost without tenants")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s serve [flags] [wallet]...\n(with tenants in the config, wallets are registered per tenant instead)\n", os.Args[0])
+		fs.PrintDefaults()
+	}
+	concurrencyFlag(fs)
+	cfg, err := parseSettings(fs, args)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	s := &server{refresh: *refresh, save: *save, retention: cfg.Retention, cors: cfg.CORS, keyLimits: map[string]*rateLimiter{}}
+	if *rateLimit > 0 {
+		s.global = newRateLimiter(*rateLimit)
+	}
+	if len(cfg.Tenants) > 0 {
+		if fs.NArg() > 0 {
+			log.Fatal("serve: with tenants configured, register wallets per tenant rather than on the command line")
+		}
+		if s.tenants, err = newTenants(cfg.Tenants); err != nil {
+			log.Fatalf("serve: %v", err)
+		}
+	} else {
+		wallets := fs.Args()
+		if len(wallets) == 0 {
+			wallets = settingAddresses(cfg)
+		}
+		s.open = &tenant{name: "default", dir: dataDir(), fixed: true, reports: map[common.Address]*report{}}
+		if err := forEachAddress(ctx, wallets, "", func(a common.Address) { s.open.wallets = append(s.open.wallets, a) }); err != nil {
+			log.Fatal(err)
+		}
+		if len(s.open.wallets) == 0 {
+			fs.Usage()
+			os.Exit(2)
+		}
+		for _, k := range strings.Split(*apiKeys, ",") {
+			if k = strings.TrimSpace(k); k != "" {
+				s.keys = append(s.keys, k)
+				if *keyRateLimit > 0 {
+					s.keyLimits[k] = newRateLimiter(*keyRateLimit)
+				}
+			}
+		}
+		if len(s.keys) == 0 && !loopback(*listen) {
+			log.Fatalf("serve: refusing to serve %s without authentication; set -api-keys or configure tenants", *listen)
+		}
+	}
+	conns := openChains(ctx, *rpc, *chainList, cfg)
+	s.value = func(wallet common.Address) *report {
+		return valueWallet(ctx, conns, wallet, cfg, options{})
+	}
+	if *export != "" {
+		if err := checkExporters(*export); err != nil {
+			log.Fatalf("-export: %v", err)
+		}
+		s.valued = func(rep *report) { exportReports(ctx, *export, cfg, []*report{rep}) }
+	}
+
+	srv := &http.Server{Addr: *listen, Handler: s.handler(), ReadHeaderTimeout: 10 * time.Second,
+		BaseContext: func(net.Listener) context.Context { return ctx }} // ends streams on shutdown
+	go func() {
+		<-ctx.Done()
+		shutdown, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		srv.Shutdown(shutdown)
+	}()
+	if s.open != nil {
+		log.Printf("serving %d wallets on http://%s", len(s.open.wallets), *listen)
+	} else {
+		log.Printf("serving %d tenants on http://%s", len(s.tenants), *listen)
+	}
+	if err := srv.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,298 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+func TestServer(t *testing.T) {
+	t.Setenv("PORTFOLIO_HOME", t.TempDir())
+	wallet := common.HexToAddress("0x00000000000000000000000000000000000000aa")
+	valued := 0
+	s := &server{
+		open:    &tenant{dir: dataDir(), fixed: true, wallets: []common.Address{wallet}, reports: map[common.Address]*report{}},
+		refresh: time.Hour,
+		save:    true,
+		value: func(w common.Address) *report {
+			valued++
+			rep := newReport(w.Hex(), nil)
+			rep.Positions = []reportPosition{{Symbol: "ETH", Chain: "mainnet", Amount: 1, USD: 2000}}
+			rep.TotalUSD = 2000
+			return rep
+		},
+	}
+	srv := httptest.NewServer(s.handler())
+	defer srv.Close()
+
+	get := func(path string, want int) string {
+		t.Helper()
+		resp, err := http.Get(srv.URL + path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		if resp.StatusCode != want {
+			t.Fatalf("GET %s: %d %s, want %d", path, resp.StatusCode, body, want)
+		}
+		return string(body)
+	}
+
+	if body := get("/api/wallets", 200); !strings.Contains(body, strings.ToLower(wallet.Hex())) {
+		t.Errorf("wallets: %s", body)
+	}
+	for i := 0; i < 2; i++ {
+		var rep report
+		if err := json.Unmarshal([]byte(get("/api/wallets/"+wallet.Hex()+"/report", 200)), &rep); err != nil {
+			t.Fatal(err)
+		}
+		if rep.TotalUSD != 2000 {
+			t.Errorf("report total %v", rep.TotalUSD)
+		}
+	}
+	if valued != 1 {
+		t.Errorf("wallet valued %d times within the refresh interval, want 1", valued)
+	}
+
+	var points []historyPoint
+	if err := json.Unmarshal([]byte(get("/api/wallets/"+wallet.Hex()+"/history", 200)), &points); err != nil {
+		t.Fatal(err)
+	}
+	if len(points) != 1 || points[0].Assets["ETH"] != 2000 {
+		t.Errorf("history %+v, want the saved valuation", points)
+	}
+
+	get("/api/wallets/0x00000000000000000000000000000000000000bb/report", 404)
+	if body := get("/", 200); !strings.Contains(body, "<title>Portfolio</title>") {
+		t.Errorf("dashboard not served: %.100s", body)
+	}
+	get("/app.js", 200)
+}
+
+func TestServerTenants(t *testing.T) {
+	t.Setenv("PORTFOLIO_HOME", t.TempDir())
+	t.Setenv("BOB_KEY", "bob-key")
+	alice := common.HexToAddress("0x00000000000000000000000000000000000000aa")
+	bob := common.HexToAddress("0x00000000000000000000000000000000000000bb")
+	tenants, err := newTenants([]tenantConfig{
+		{Name: "alice", Key: "alice-key", Wallets: []string{alice.Hex()}, RateLimit: 5},
+		{Name: "bob", KeyEnv: "BOB_KEY"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &server{tenants: tenants, refresh: time.Hour, save: true, value: func(w common.Address) *report {
+		rep := newReport(w.Hex(), nil)
+		rep.Positions = []reportPosition{{Symbol: "ETH", Chain: "mainnet", Amount: 1, USD: 2000}}
+		return rep
+	}}
+	srv := httptest.NewServer(s.handler())
+	defer srv.Close()
+
+	do := func(method, path, key, body string) int {
+		t.Helper()
+		req, _ := http.NewRequest(method, srv.URL+path, strings.NewReader(body))
+		if key != "" {
+			req.Header.Set("Authorization", "Bearer "+key)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+	check := func(method, path, key, body string, want int) {
+		t.Helper()
+		if got := do(method, path, key, body); got != want {
+			t.Errorf("%s %s as %q: %d, want %d", method, path, key, got, want)
+		}
+	}
+
+	check("GET", "/api/wallets", "", "", 401)
+	check("GET", "/api/wallets", "mallory", "", 401)
+	check("GET", "/api/wallets/"+alice.Hex()+"/report", "alice-key", "", 200)
+	check("GET", "/api/wallets/"+alice.Hex()+"/report", "bob-key", "", 404)
+	check("POST", "/api/wallets", "bob-key", `{"wallet": "`+bob.Hex()+`"}`, 201)
+	check("GET", "/api/wallets/"+bob.Hex()+"/report", "bob-key", "", 200)
+
+	// Histories are the tenant's own.
+	if snaps, _ := listSnapshotsIn(tenants["bob-key"].snapshotDir(), alice.Hex()); len(snaps) != 0 {
+		t.Error("bob's store has alice's snapshot")
+	}
+	if snaps, _ := listSnapshotsIn(tenants["alice-key"].snapshotDir(), alice.Hex()); len(snaps) != 1 {
+		t.Errorf("alice has %d snapshots, want 1", len(snaps))
+	}
+
+	// Registered wallets survive a restart; removed ones do not.
+	again, err := newTenants([]tenantConfig{{Name: "bob", Key: "bob-key"}})
+	if err != nil || len(again["bob-key"].wallets) != 1 {
+		t.Fatalf("bob after restart: %v, %v", again, err)
+	}
+	check("DELETE", "/api/wallets/"+bob.Hex(), "bob-key", "", 204)
+	if again, _ = newTenants([]tenantConfig{{Name: "bob", Key: "bob-key"}}); len(again["bob-key"].wallets) != 0 {
+		t.Error("unregistered wallet came back")
+	}
+
+	// Alice's budget of 5 requests is spent by the burst below; bob's is not.
+	for i := 0; i < 5; i++ {
+		do("GET", "/api/wallets", "alice-key", "")
+	}
+	check("GET", "/api/wallets", "alice-key", "", 429)
+	check("GET", "/api/wallets", "bob-key", "", 200)
+
+	if _, err := newTenants([]tenantConfig{{Name: "a", Key: "k"}, {Name: "b", Key: "k"}}); err == nil {
+		t.Error("shared API key accepted")
+	}
+}
+
+func TestServerAPIKeys(t *testing.T) {
+	wallet := common.HexToAddress("0x00000000000000000000000000000000000000aa")
+	s := &server{
+		open:    &tenant{fixed: true, wallets: []common.Address{wallet}, reports: map[common.Address]*report{}},
+		keys:    []string{"k1", "k2"},
+		refresh: time.Hour,
+		value:   func(w common.Address) *report { return newReport(w.Hex(), nil) },
+	}
+	srv := httptest.NewServer(s.handler())
+	defer srv.Close()
+	for _, tc := range []struct {
+		path, header, value string
+		want                int
+	}{
+		{"/api/wallets", "", "", 401},
+		{"/api/wallets", "Authorization", "Bearer nope", 401},
+		{"/api/wallets", "Authorization", "Bearer k2", 200},
+		{"/api/wallets", "X-API-Key", "k1", 200},
+		{"/api/nothing", "", "", 401},
+		{"/", "", "", 200},
+	} {
+		req, _ := http.NewRequest("GET", srv.URL+tc.path, nil)
+		if tc.header != "" {
+			req.Header.Set(tc.header, tc.value)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != tc.want {
+			t.Errorf("%s with %s %q: %d, want %d", tc.path, tc.header, tc.value, resp.StatusCode, tc.want)
+		}
+		if resp.StatusCode == 401 && resp.Header.Get("WWW-Authenticate") == "" {
+			t.Errorf("%s: 401 without WWW-Authenticate", tc.path)
+		}
+	}
+
+	for addr, want := range map[string]bool{
+		"127.0.0.1:8080": true, "localhost:8080": true, "[::1]:8080": true,
+		":8080": false, "0.0.0.0:8080": false, "192.168.1.5:8080": false,
+	} {
+		if loopback(addr) != want {
+			t.Errorf("loopback(%q) = %v", addr, !want)
+		}
+	}
+}
+
+func TestServerCORS(t *testing.T) {
+	wallet := common.HexToAddress("0x00000000000000000000000000000000000000aa")
+	s := &server{
+		open:    &tenant{fixed: true, wallets: []common.Address{wallet}, reports: map[common.Address]*report{}},
+		keys:    []string{"k"},
+		cors:    corsConfig{Origins: []string{"https://dash.example"}, MaxAge: 600},
+		refresh: time.Hour,
+		value:   func(w common.Address) *report { return newReport(w.Hex(), nil) },
+	}
+	srv := httptest.NewServer(s.handler())
+	defer srv.Close()
+	send := func(method, origin string, header ...string) *http.Response {
+		t.Helper()
+		req, _ := http.NewRequest(method, srv.URL+"/api/wallets", nil)
+		req.Header.Set("Origin", origin)
+		for i := 0; i+1 < len(header); i += 2 {
+			req.Header.Set(header[i], header[i+1])
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		return resp
+	}
+
+	pre := send("OPTIONS", "https://dash.example", "Access-Control-Request-Method", "GET")
+	if pre.StatusCode != 204 || pre.Header.Get("Access-Control-Allow-Origin") != "https://dash.example" ||
+		!strings.Contains(pre.Header.Get("Access-Control-Allow-Headers"), "Authorization") ||
+		pre.Header.Get("Access-Control-Max-Age") != "600" {
+		t.Errorf("preflight: %d %v", pre.StatusCode, pre.Header)
+	}
+	if got := send("GET", "https://dash.example", "Authorization", "Bearer k"); got.StatusCode != 200 || got.Header.Get("Access-Control-Allow-Origin") == "" {
+		t.Errorf("allowed GET: %d %v", got.StatusCode, got.Header)
+	}
+	if got := send("OPTIONS", "https://evil.example", "Access-Control-Request-Method", "GET"); got.Header.Get("Access-Control-Allow-Origin") != "" {
+		t.Errorf("other origin allowed: %v", got.Header)
+	}
+}
+
+func TestServerRateLimits(t *testing.T) {
+	wallet := common.HexToAddress("0x00000000000000000000000000000000000000aa")
+	s := &server{
+		open:      &tenant{fixed: true, wallets: []common.Address{wallet}, reports: map[common.Address]*report{}},
+		keys:      []string{"a", "b", "c"},
+		keyLimits: map[string]*rateLimiter{"a": newRateLimiter(2), "b": newRateLimiter(2), "c": newRateLimiter(2)},
+		global:    newRateLimiter(3),
+		refresh:   time.Hour,
+		value:     func(w common.Address) *report { return newReport(w.Hex(), nil) },
+	}
+	srv := httptest.NewServer(s.handler())
+	defer srv.Close()
+	get := func(key string) *http.Response {
+		req, _ := http.NewRequest("GET", srv.URL+"/api/wallets", nil)
+		req.Header.Set("X-API-Key", key)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		return resp
+	}
+
+	var codes []int
+	for _, key := range []string{"a", "a", "a", "b", "c"} {
+		codes = append(codes, get(key).StatusCode)
+	}
+	// a's third request is over its own limit; c's is over the global one.
+	if want := []int{200, 200, 429, 200, 429}; !slices.Equal(codes, want) {
+		t.Errorf("codes %v, want %v", codes, want)
+	}
+	resp := get("a")
+	if after, err := strconv.Atoi(resp.Header.Get("Retry-After")); resp.StatusCode != 429 || err != nil || after < 1 || after > 30 {
+		t.Errorf("429 with Retry-After %q", resp.Header.Get("Retry-After"))
+	}
+}
+
+func TestRateLimiter(t *testing.T) {
+	l := newRateLimiter(60)
+	now := time.Now()
+	for i := 0; i < 60; i++ {
+		if ok, _ := l.allow(now); !ok {
+			t.Fatalf("request %d of the burst refused", i)
+		}
+	}
+	ok, wait := l.allow(now)
+	if ok || wait != time.Second {
+		t.Errorf("after the burst: %v, wait %v; want a second's wait", ok, wait)
+	}
+	if ok, _ := l.allow(now.Add(time.Second)); !ok {
+		t.Error("refused after the refill")
+	}
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// settingsEnvPrefix prefixes the environment variable of every flag:
+// -hide-below is PORTFOLIO_HIDE_BELOW.
+const settingsEnvPrefix = "PORTFOLIO_"
+
+// parseSettings parses a command's flags and loads its config file. Each
+// flag not given on the command line is taken from its PORTFOLIO_<FLAG>
+// environment variable, then from the config file's settings object, and
+// otherwise keeps its default. The config file itself is named by -config
+// or PORTFOLIO_CONFIG.
+func parseSettings(fs *flag.FlagSet, args []string) (*config, error) {
+	fs.Parse(args)
+	given := map[string]bool{}
+	fs.Visit(func(f *flag.Flag) { given[f.Name] = true })
+
+	var err error
+	fs.VisitAll(func(f *flag.Flag) {
+		if v, ok := os.LookupEnv(settingEnv(f.Name)); ok && !given[f.Name] && err == nil {
+			if err = fs.Set(f.Name, v); err != nil {
+				err = fmt.Errorf("%s: %w", settingEnv(f.Name), err)
+			}
+			given[f.Name] = true
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	path := "config.json"
+	if f := fs.Lookup("config"); f != nil {
+		path = f.Value.String()
+	}
+	cfg, err := loadConfig(path)
+	if err != nil {
+		return nil, fmt.Errorf("config: %w", err)
+	}
+	for name, raw := range cfg.Settings {
+		// The settings object is shared by every command, so names another
+		// command defines are not an error here.
+		if fs.Lookup(name) == nil || given[name] {
+			continue
+		}
+		v, err := settingValue(raw)
+		if err == nil {
+			err = fs.Set(name, v)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("config: settings.%s: %w", name, err)
+		}
+	}
+	return cfg, nil
+}
+
+func settingEnv(flagName string) string {
+	return settingsEnvPrefix + strings.ToUpper(strings.ReplaceAll(flagName, "-", "_"))
+}
+
+// settingValue renders a config setting as flag text: strings as they are,
+// lists joined with commas, numbers and booleans in their JSON form.
+func settingValue(raw json.RawMessage) (string, error) {
+	var s string
+	if err := json.Unmarshal(raw, &s); err == nil {
+		return s, nil
+	}
+	var list []json.RawMessage
+	if err := json.Unmarshal(raw, &list); err == nil {
+		parts := make([]string, len(list))
+		for i, item := range list {
+			v, err := settingValue(item)
+			if err != nil {
+				return "", err
+			}
+			parts[i] = v
+		}
+		return strings.Join(parts, ","), nil
+	}
+	var v any
+	if err := json.Unmarshal(raw, &v); err != nil {
+		return "", err
+	}
+	switch v.(type) {
+	case float64, bool:
+		return string(raw), nil
+	}
+	return "", fmt.Errorf("want a string, number, boolean or list, got %s", raw)
+}
+
+// settingAddresses returns the wallets to value when none are given on the
+// command line: PORTFOLIO_ADDRESSES (comma-separated), then the config
+// file's addresses.
+func settingAddresses(cfg *config) []string {
+	if v := os.Getenv(settingsEnvPrefix + "ADDRESSES"); v != "" {
+		return strings.Split(v, ",")
+	}
+	return cfg.Addresses
+}
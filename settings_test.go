@@ -0,0 +1,37 @@
+package main
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestParseSettingsPrecedence(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	err := os.WriteFile(path, []byte(`{"settings": {"format": "json", "chains": ["mainnet", "base"], "hide-below": 5, "strict": true, "unknown": 1}}`), 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PORTFOLIO_CONFIG", path)
+	t.Setenv("PORTFOLIO_HIDE_BELOW", "10")
+
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.String("config", "config.json", "")
+	format := fs.String("format", "text", "")
+	chainList := fs.String("chains", "", "")
+	hideBelow := fs.Float64("hide-below", 0, "")
+	strict := fs.Bool("strict", false, "")
+	if _, err := parseSettings(fs, []string{"-format", "template"}); err != nil {
+		t.Fatal(err)
+	}
+	if *format != "template" {
+		t.Errorf("format = %q, want the command line's template", *format)
+	}
+	if *hideBelow != 10 {
+		t.Errorf("hide-below = %g, want the environment's 10", *hideBelow)
+	}
+	if *chainList != "mainnet,base" || !*strict {
+		t.Errorf("chains = %q, strict = %t, want the config file's", *chainList, *strict)
+	}
+}
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// sheetsConfig appends every exported position as a row to a Google Sheet,
+// in exportColumns order. Credentials is a service account's JSON key,
+// $GOOGLE_APPLICATION_CREDENTIALS when empty; the spreadsheet must be
+// shared with the account's email. Range is the tab to append to, "Sheet1"
+// when empty. URL replaces the Sheets API base, for tests.
+type sheetsConfig struct {
+	SpreadsheetID string `json:"spreadsheet_id"`
+	Range         string `json:"range"`
+	Credentials   string `json:"credentials"`
+	URL           string `json:"url"`
+}
+
+const sheetsScope = "https://www.googleapis.com/auth/spreadsheets"
+
+func exportSheets(ctx context.Context, cfg *config, reps []*report) error {
+	sc := cfg.Sheets
+	if sc.SpreadsheetID == "" {
+		return errors.New("set sheets.spreadsheet_id in the config")
+	}
+	path := sc.Credentials
+	if path == "" {
+		path = os.Getenv("GOOGLE_APPLICATION_CREDENTIALS")
+	}
+	if path == "" {
+		return errors.New("no service account key; set sheets.credentials or GOOGLE_APPLICATION_CREDENTIALS")
+	}
+	sa, err := loadServiceAccount(path)
+	if err != nil {
+		return err
+	}
+	token, err := sa.token(ctx, sheetsScope, time.Now())
+	if err != nil {
+		return fmt.Errorf("token: %w", err)
+	}
+
+	var rows [][]any
+	for _, r := range reps {
+		rows = append(rows, exportRows(r)...)
+	}
+	body, err := json.Marshal(map[string]any{"values": rows})
+	if err != nil {
+		return err
+	}
+	base, rng := "https://sheets.googleapis.com", sc.Range
+	if sc.URL != "" {
+		base = strings.TrimSuffix(sc.URL, "/")
+	}
+	if rng == "" {
+		rng = "Sheet1"
+	}
+	u := fmt.Sprintf("%s/v4/spreadsheets/%s/values/%s:append?valueInputOption=RAW&insertDataOption=INSERT_ROWS",
+		base, url.PathEscape(sc.SpreadsheetID), url.PathEscape(rng))
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, u, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Content-Type", "application/json")
+	var resp struct{}
+	return exchangeDo(req, &resp)
+}
+
+// serviceAccount is the part of a Google service account key used to get
+// access tokens.
+type serviceAccount struct {
+	ClientEmail string `json:"client_email"`
+	PrivateKey  string `json:"private_key"`
+	TokenURI    string `json:"token_uri"`
+
+	key *rsa.PrivateKey
+}
+
+func loadServiceAccount(path string) (*serviceAccount, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	sa := &serviceAccount{}
+	if err := json.Unmarshal(data, sa); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	block, _ := pem.Decode([]byte(sa.PrivateKey))
+	if block == nil || sa.ClientEmail == "" || sa.TokenURI == "" {
+		return nil, fmt.Errorf("%s: not a service account key", path)
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("%s: private key: %w", path, err)
+	}
+	var ok bool
+	if sa.key, ok = key.(*rsa.PrivateKey); !ok {
+		return nil, fmt.Errorf("%s: private key is not RSA", path)
+	}
+	return sa, nil
+}
+
+// token trades a signed JWT for an access token to scope, valid an hour.
+func (sa *serviceAccount) token(ctx context.Context, scope string, now time.Time) (string, error) {
+	jwt, err := sa.assertion(scope, now)
+	if err != nil {
+		return "", err
+	}
+	form := url.Values{"grant_type": {"urn:ietf:params:oauth:grant-type:jwt-bearer"}, "assertion": {jwt}}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, sa.TokenURI, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	var resp struct {
+		AccessToken string `json:"access_token"`
+	}
+	if err := exchangeDo(req, &resp); err != nil {
+		return "", err
+	}
+	if resp.AccessToken == "" {
+		return "", errors.New("no access_token in the answer")
+	}
+	return resp.AccessToken, nil
+}
+
+// assertion is the RS256-signed JWT the token endpoint expects.
+func (sa *serviceAccount) assertion(scope string, now time.Time) (string, error) {
+	enc := base64.RawURLEncoding
+	header := enc.EncodeToString([]byte(`{"alg":"RS256","typ":"JWT"}`))
+	claims, err := json.Marshal(map[string]any{
+		"iss":   sa.ClientEmail,
+		"scope": scope,
+		"aud":   sa.TokenURI,
+		"iat":   now.Unix(),
+		"exp":   now.Add(time.Hour).Unix(),
+	})
+	if err != nil {
+		return "", err
+	}
+	signed := header + "." + enc.EncodeToString(claims)
+	sum := sha256.Sum256([]byte(signed))
+	sig, err := rsa.SignPKCS1v15(nil, sa.key, crypto.SHA256, sum[:])
+	if err != nil {
+		return "", err
+	}
+	return signed + "." + enc.EncodeToString(sig), nil
+}
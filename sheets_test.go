@@ -0,0 +1,82 @@
+package main
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestExportSheets(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, _ := x509.MarshalPKCS8PrivateKey(key)
+
+	var appended struct{ Values [][]any }
+	var path string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/token":
+			r.ParseForm()
+			parts := strings.Split(r.Form.Get("assertion"), ".")
+			sig, _ := base64.RawURLEncoding.DecodeString(parts[2])
+			sum := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+			if len(parts) != 3 || rsa.VerifyPKCS1v15(&key.PublicKey, crypto.SHA256, sum[:], sig) != nil {
+				http.Error(w, "bad assertion", http.StatusUnauthorized)
+				return
+			}
+			w.Write([]byte(`{"access_token":"tok","expires_in":3600}`))
+		default:
+			if r.Header.Get("Authorization") != "Bearer tok" {
+				http.Error(w, "no token", http.StatusUnauthorized)
+				return
+			}
+			path = r.URL.EscapedPath() + "?" + r.URL.RawQuery
+			json.NewDecoder(r.Body).Decode(&appended)
+			w.Write([]byte(`{}`))
+		}
+	}))
+	defer srv.Close()
+
+	creds := filepath.Join(t.TempDir(), "sa.json")
+	sa, _ := json.Marshal(map[string]string{
+		"client_email": "bot@example.iam.gserviceaccount.com",
+		"private_key":  string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})),
+		"token_uri":    srv.URL + "/token",
+	})
+	os.WriteFile(creds, sa, 0o600)
+
+	cfg := &config{Sheets: sheetsConfig{SpreadsheetID: "sheet-1", Range: "Treasury", Credentials: creds, URL: srv.URL}}
+	rep := &report{Wallet: "0xaa", Time: time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC), Positions: []reportPosition{
+		{Symbol: "ETH", Chain: "mainnet", Amount: 1.5, USD: 4500},
+		{Symbol: "LINK", Chain: "mainnet", Error: "no price"},
+	}}
+	if err := exportSheets(context.Background(), cfg, []*report{rep}); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(path, "/v4/spreadsheets/sheet-1/values/Treasury:append?valueInputOption=RAW") {
+		t.Errorf("path %s", path)
+	}
+	if len(appended.Values) != 2 || len(appended.Values[0]) != len(exportColumns) ||
+		appended.Values[0][0] != "2026-01-02T03:04:05Z" || appended.Values[0][7] != 4500.0 || appended.Values[1][8] != "no price" {
+		t.Errorf("rows %v", appended.Values)
+	}
+
+	if err := checkExporters("sheets, nope"); err == nil {
+		t.Error("unknown exporter accepted")
+	}
+}
@@ -0,0 +1,219 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// slackConfig posts to a Slack incoming webhook: a summary of each report
+// with -export slack, and alerts with -notify slack. The webhook is
+// WebhookURL, or the variable WebhookEnv names ($SLACK_WEBHOOK_URL by
+// default), as it is a secret. Summaries highlight positions that moved by
+// at least HighlightUSD (10000 when unset) since the wallet's previous
+// snapshot.
+type slackConfig struct {
+	WebhookURL   string  `json:"webhook_url"`
+	WebhookEnv   string  `json:"webhook_env"`
+	HighlightUSD float64 `json:"highlight_usd"`
+}
+
+func (s slackConfig) webhook() (string, error) {
+	if s.WebhookURL != "" {
+		return s.WebhookURL, nil
+	}
+	env := s.WebhookEnv
+	if env == "" {
+		env = "SLACK_WEBHOOK_URL"
+	}
+	if hook := os.Getenv(env); hook != "" {
+		return hook, nil
+	}
+	return "", fmt.Errorf("no webhook; set slack.webhook_url or %s", env)
+}
+
+// slackBlock is a Block Kit block; only the fields used here.
+type slackBlock struct {
+	Type string     `json:"type"`
+	Text *slackText `json:"text,omitempty"`
+}
+
+type slackText struct {
+	Type string `json:"type"`
+	Text string `json:"text"`
+}
+
+func slackSection(md string) slackBlock {
+	return slackBlock{Type: "section", Text: &slackText{Type: "mrkdwn", Text: md}}
+}
+
+func exportSlack(ctx context.Context, cfg *config, reps []*report) error {
+	hook, err := cfg.Slack.webhook()
+	if err != nil {
+		return err
+	}
+	highlight := cfg.Slack.HighlightUSD
+	if highlight == 0 {
+		highlight = 10_000
+	}
+	for _, r := range reps {
+		prev, err := previousSnapshot(r)
+		if err != nil {
+			return err
+		}
+		text := fmt.Sprintf("%s: %s", reportName(r), usdString(r.TotalUSD))
+		if err := postSlack(ctx, hook, text, slackSummary(r, prev, highlight)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func notifySlack(ctx context.Context, cfg *config, a alert) error {
+	hook, err := cfg.Slack.webhook()
+	if err != nil {
+		return err
+	}
+	icon := ":warning:"
+	switch {
+	case a.Resolved:
+		icon = ":white_check_mark: resolved:"
+	case a.Critical:
+		icon = ":rotating_light:"
+	}
+	blocks := []slackBlock{slackSection(fmt.Sprintf("%s *%s*\n%s", icon, a.Title, a.Text))}
+	return postSlack(ctx, hook, a.Title, blocks)
+}
+
+// previousSnapshot is the wallet's newest snapshot taken before r, or nil.
+func previousSnapshot(r *report) (*report, error) {
+	all, err := listSnapshots(r.Wallet)
+	if err != nil {
+		return nil, err
+	}
+	for i := len(all) - 1; i >= 0; i-- {
+		if all[i].Time.Before(r.Time) {
+			return all[i], nil
+		}
+	}
+	return nil, nil
+}
+
+// slackSummary lays a report out as a header with the total, then one
+// section per chain listing its positions, largest first. Positions that
+// moved by at least highlight USD since prev are flagged with the change.
+func slackSummary(r, prev *report, highlight float64) []slackBlock {
+	title := fmt.Sprintf("*%s* — %s", reportName(r), usdString(r.TotalUSD))
+	if prev != nil {
+		title += fmt.Sprintf(" (%s since %s)", signedUSD(r.TotalUSD-prev.TotalUSD), prev.Time.Format(time.DateTime))
+	}
+	if r.Partial {
+		title += "\n_partial: the run was interrupted_"
+	}
+	blocks := []slackBlock{slackSection(title), {Type: "divider"}}
+
+	before := map[string]float64{}
+	if prev != nil {
+		for _, p := range prev.Positions {
+			before[p.key()] = p.USD
+		}
+	}
+	var chains []string
+	byChain := map[string][]reportPosition{}
+	for _, p := range r.Positions {
+		if _, ok := byChain[p.Chain]; !ok {
+			chains = append(chains, p.Chain)
+		}
+		byChain[p.Chain] = append(byChain[p.Chain], p)
+	}
+	for _, chain := range chains {
+		ps := byChain[chain]
+		sort.SliceStable(ps, func(i, j int) bool { return ps[i].USD > ps[j].USD })
+		var b strings.Builder
+		fmt.Fprintf(&b, "*%s*", chain)
+		for _, p := range ps {
+			if p.Error != "" {
+				fmt.Fprintf(&b, "\n• %s: _error: %s_", p.Symbol, p.Error)
+				continue
+			}
+			fmt.Fprintf(&b, "\n• %s %.6g — %s", p.Symbol, p.Amount, usdString(p.USD))
+			if p.Note != "" {
+				fmt.Fprintf(&b, " (%s)", p.Note)
+			}
+			if was, ok := before[p.key()]; prev != nil && math.Abs(p.USD-was) >= highlight {
+				if !ok {
+					fmt.Fprintf(&b, "  :rotating_light: *new*")
+				} else {
+					fmt.Fprintf(&b, "  :rotating_light: *%s*", signedUSD(p.USD-was))
+				}
+			}
+		}
+		blocks = append(blocks, slackSection(truncate(b.String(), 3000)))
+	}
+	return blocks
+}
+
+// truncate keeps s within Slack's limit on a text field.
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	cut := n - len("…")
+	for cut > 0 && !utf8.RuneStart(s[cut]) {
+		cut--
+	}
+	return s[:cut] + "…"
+}
+
+func reportName(r *report) string {
+	if r.Label != "" {
+		return r.Label
+	}
+	return r.Wallet
+}
+
+func usdString(v float64) string { return fmt.Sprintf("$%.2f", v) }
+
+func signedUSD(v float64) string {
+	if v < 0 {
+		return fmt.Sprintf("-$%.2f", -v)
+	}
+	return fmt.Sprintf("+$%.2f", v)
+}
+
+// postSlack sends a message; text is what notifications show. Errors leave
+// out the webhook URL, which is a secret.
+func postSlack(ctx context.Context, hook, text string, blocks []slackBlock) error {
+	body, err := json.Marshal(map[string]any{"text": text, "blocks": blocks})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, hook, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := (&http.Client{Timeout: 30 * time.Second}).Do(req)
+	if ue := (*url.Error)(nil); errors.As(err, &ue) {
+		return fmt.Errorf("webhook: %w", ue.Err)
+	} else if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return errors.New(resp.Status + ": " + strings.TrimSpace(string(msg)))
+	}
+	return nil
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+func TestSlackSummary(t *testing.T) {
+	t0 := time.Date(2026, 3, 1, 9, 0, 0, 0, time.UTC)
+	prev := &report{Wallet: "0xaa", Time: t0, TotalUSD: 50_000, Positions: []reportPosition{
+		{Symbol: "ETH", Chain: "mainnet", Amount: 10, USD: 30_000},
+		{Symbol: "USDC", Chain: "mainnet", Amount: 20_000, USD: 20_000},
+	}}
+	cur := &report{Wallet: "0xaa", Label: "treasury", Time: t0.Add(24 * time.Hour), TotalUSD: 61_000, Positions: []reportPosition{
+		{Symbol: "USDC", Chain: "mainnet", Amount: 20_000, USD: 20_000},
+		{Symbol: "ETH", Chain: "mainnet", Amount: 10, USD: 41_000},
+		{Symbol: "LINK", Chain: "base", Error: "no price"},
+	}}
+	blocks := slackSummary(cur, prev, 10_000)
+	if len(blocks) != 4 || blocks[1].Type != "divider" {
+		t.Fatalf("blocks: %+v", blocks)
+	}
+	if got := blocks[0].Text.Text; !strings.Contains(got, "*treasury* — $61000.00 (+$11000.00 since 2026-03-01 09:00:00)") {
+		t.Errorf("header %q", got)
+	}
+	mainnet := blocks[2].Text.Text
+	if !strings.HasPrefix(mainnet, "*mainnet*\n• ETH 10 — $41000.00  :rotating_light: *+$11000.00*\n• USDC") || strings.Count(mainnet, "rotating_light") != 1 {
+		t.Errorf("mainnet section %q", mainnet)
+	}
+	if !strings.Contains(blocks[3].Text.Text, "LINK: _error: no price_") {
+		t.Errorf("base section %q", blocks[3].Text.Text)
+	}
+	if got := truncate(strings.Repeat("€", 10), 10); len(got) > 10 || !strings.HasSuffix(got, "…") {
+		t.Errorf("truncate %q", got)
+	}
+}
+
+func TestNotifySlack(t *testing.T) {
+	var got map[string]any
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&got)
+		w.Write([]byte("ok"))
+	}))
+	defer srv.Close()
+	t.Setenv("SLACK_WEBHOOK_URL", srv.URL)
+
+	c := whaleChange{Wallet: common.HexToAddress("0x00000000000000000000000000000000000000aa"), Symbol: "ETH", Chain: "mainnet", Before: 1e6, After: 4e5}
+	if err := notifySlack(context.Background(), &config{}, c.alert()); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(got["text"].(string), "moved -$600000.00 of ETH on mainnet") {
+		t.Errorf("message %v", got)
+	}
+
+	if err := notifySlack(context.Background(), &config{Slack: slackConfig{WebhookURL: "http://127.0.0.1:1/secret-path"}}, c.alert()); err == nil || strings.Contains(err.Error(), "secret-path") {
+		t.Errorf("err = %v, want one without the webhook URL", err)
+	}
+}
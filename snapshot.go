@@ -0,0 +1,206 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// key identifies the same position across snapshots: by its ID when it
+// has one, since then the note carries figures that change.
+func (p reportPosition) key() string {
+	if p.ID != "" {
+		return p.Chain + "|" + p.Symbol + "|" + p.ID
+	}
+	return p.Chain + "|" + p.Symbol + "|" + p.Note
+}
+
+// Snapshots are stored reports, one JSON file each under <data dir>/snapshots.
+
+// dataDir is where the tool keeps state between runs: $PORTFOLIO_HOME, or
+// ~/.portfolio.
+func dataDir() string {
+	if dir := os.Getenv("PORTFOLIO_HOME"); dir != "" {
+		return dir
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ".portfolio"
+	}
+	return filepath.Join(home, ".portfolio")
+}
+
+func snapshotDir() string {
+	return filepath.Join(dataDir(), "snapshots")
+}
+
+func saveSnapshot(s *report) (string, error) {
+	return saveSnapshotIn(snapshotDir(), s)
+}
+
+// saveSnapshotIn stores a snapshot in dir, for stores other than the
+// user's own, such as a server tenant's.
+func saveSnapshotIn(dir string, s *report) (string, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", err
+	}
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	path := filepath.Join(dir, fmt.Sprintf("%s-%d.json", s.Wallet, s.Time.Unix()))
+	return path, os.WriteFile(path, data, 0o644)
+}
+
+func loadSnapshot(path string) (*report, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	s := &report{}
+	if err := json.Unmarshal(data, s); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return s, nil
+}
+
+// listSnapshots returns the stored snapshots of a wallet (all wallets when
+// empty), oldest first.
+func listSnapshots(wallet string) ([]*report, error) {
+	return listSnapshotsIn(snapshotDir(), wallet)
+}
+
+func listSnapshotsIn(dir, wallet string) ([]*report, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	var out []*report
+	for _, p := range paths {
+		s, err := loadSnapshot(p)
+		if err != nil {
+			return nil, err
+		}
+		if wallet == "" || s.Wallet == strings.ToLower(wallet) {
+			out = append(out, s)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Time.Before(out[j].Time) })
+	return out, nil
+}
+
+// parseSince accepts Go durations plus a "d" suffix for days.
+func parseSince(s string) (time.Duration, error) {
+	if days, ok := strings.CutSuffix(s, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil {
+			return 0, fmt.Errorf("bad duration %q", s)
+		}
+		return time.Duration(n) * 24 * time.Hour, nil
+	}
+	return time.ParseDuration(s)
+}
+
+func diffCmd(args []string) {
+	fs := flag.NewFlagSet("diff", flag.ExitOnError)
+	since := fs.String("since", "", "compare the latest snapshot with the one from this long ago (e.g. 7d, 12h)")
+	fs.String("config", "config.json", "path to the JSON config file, for its address book")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s diff <snapshot-a> <snapshot-b>\n       %s diff -since 7d [address|label]\n", os.Args[0], os.Args[0])
+		fs.PrintDefaults()
+	}
+	if _, err := parseSettings(fs, args); err != nil {
+		log.Fatal(err)
+	}
+
+	var a, b *report
+	var err error
+	switch {
+	case *since != "":
+		wallet := fs.Arg(0)
+		if addr, ok := book.resolve(wallet); ok {
+			wallet = addr.Hex()
+		}
+		a, b, err = snapshotsSince(*since, wallet)
+	case fs.NArg() == 2:
+		if a, err = loadSnapshot(fs.Arg(0)); err == nil {
+			b, err = loadSnapshot(fs.Arg(1))
+		}
+	default:
+		fs.Usage()
+		os.Exit(2)
+	}
+	if err != nil {
+		log.Fatalf("diff: %v", err)
+	}
+	printDiff(a, b)
+}
+
+// snapshotsSince picks the newest snapshot and the newest one taken at least
+// the given duration before it.
+func snapshotsSince(since, wallet string) (*report, *report, error) {
+	d, err := parseSince(since)
+	if err != nil {
+		return nil, nil, err
+	}
+	all, err := listSnapshots(wallet)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(all) == 0 {
+		return nil, nil, fmt.Errorf("no snapshots in %s", snapshotDir())
+	}
+	latest := all[len(all)-1]
+	cutoff := latest.Time.Add(-d)
+	for i := len(all) - 1; i >= 0; i-- {
+		if !all[i].Time.After(cutoff) && all[i].Wallet == latest.Wallet {
+			return all[i], latest, nil
+		}
+	}
+	return nil, nil, fmt.Errorf("no snapshot of %s older than %s", latest.Wallet, since)
+}
+
+// printDiff lists positions added (+), removed (-) and changed (~) between
+// two snapshots, with quantity and value deltas.
+func printDiff(a, b *report) {
+	fmt.Printf("%s -> %s\n", a.Time.Format(time.RFC3339), b.Time.Format(time.RFC3339))
+	before := map[string]reportPosition{}
+	for _, p := range a.Positions {
+		before[p.key()] = p
+	}
+	for _, p := range b.Positions {
+		old, ok := before[p.key()]
+		delete(before, p.key())
+		switch {
+		case !ok:
+			fmt.Printf("+ %s %f => $%.2f\n", diffLabel(p), p.Amount, p.USD)
+		case old.Amount != p.Amount || old.USD != p.USD:
+			fmt.Printf("~ %s %f -> %f (%+f)  $%.2f -> $%.2f (%+.2f)\n", diffLabel(p),
+				old.Amount, p.Amount, p.Amount-old.Amount, old.USD, p.USD, p.USD-old.USD)
+		}
+	}
+	for _, p := range a.Positions {
+		if _, ok := before[p.key()]; ok {
+			fmt.Printf("- %s %f => $%.2f\n", diffLabel(p), p.Amount, p.USD)
+		}
+	}
+	fmt.Printf("TOTAL $%.2f -> $%.2f (%+.2f)\n", a.TotalUSD, b.TotalUSD, b.TotalUSD-a.TotalUSD)
+}
+
+func diffLabel(p reportPosition) string {
+	label := fmt.Sprintf("%-6s", p.Symbol)
+	if p.Chain != "" && p.Chain != "mainnet" {
+		label += " [" + p.Chain + "]"
+	}
+	if p.Note != "" {
+		label += " (" + p.Note + ")"
+	}
+	return label
+}
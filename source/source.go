@@ -0,0 +1,134 @@
+// Package source defines where a valuation gets its data: an AssetSource
+// finds an address's positions on a chain and a PriceSource prices an
+// asset in USD. The tool's own integrations implement these interfaces;
+// another one is added by implementing the method of either and
+// registering a constructor for it from an init function in a file of the
+// portfolio command, or a package it imports.
+package source
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// Asset is a token on a chain.
+type Asset struct {
+	Chain    uint64         // chain ID
+	Address  common.Address // token contract; zero for the chain's native coin
+	Symbol   string
+	Decimals int
+}
+
+// Position is an amount of an asset held by an address.
+type Position struct {
+	Asset  Asset
+	Amount *big.Float // in whole tokens
+	// Note says where the funds sit or how they are locked, when they are
+	// not a plain wallet balance.
+	Note string
+	// Price is the USD price of one token when the source knows it, such
+	// as for a position denominated in dollars; nil to have it priced.
+	Price *big.Float
+}
+
+// AssetSource finds the positions an address holds in one protocol or
+// venue, on the chain it was opened for.
+type AssetSource interface {
+	Positions(ctx context.Context, owner common.Address) ([]Position, error)
+}
+
+// PriceSource prices one token of an asset in USD. It returns an error
+// wrapping ErrUnsupported for assets it does not cover, so that the next
+// source is tried without the miss being reported.
+type PriceSource interface {
+	Price(ctx context.Context, asset Asset) (*big.Float, error)
+}
+
+// ErrUnsupported is what a PriceSource answers for assets it cannot price.
+var ErrUnsupported = errors.New("unsupported asset")
+
+// The valuation's failures worth telling apart wrap one of these, so that
+// code deciding what to do about an error, the tool's or a caller's, asks
+// errors.Is rather than reading its text.
+var (
+	// ErrStaleFeed: a Chainlink answer is too old to use, was never
+	// completed, or cannot be trusted while an L2 sequencer is down.
+	ErrStaleFeed = errors.New("stale")
+	// ErrNoPriceSource: no source had a price for a holding.
+	ErrNoPriceSource = errors.New("no price")
+	// ErrPriceDeviation: price sources disagree on a holding by more than
+	// price_sources max_deviation_pct.
+	ErrPriceDeviation = errors.New("price sources disagree")
+	// ErrRPCUnavailable: a node could not be dialled or did not answer.
+	ErrRPCUnavailable = errors.New("RPC unavailable")
+	// ErrInvalidAddress: a string given as an address is not one.
+	ErrInvalidAddress = errors.New("bad address")
+	// ErrDegraded: a source failed too often lately and is not called.
+	ErrDegraded = errors.New("degraded")
+)
+
+// Client is the chain connection sources are opened with.
+type Client interface {
+	bind.ContractCaller
+	BalanceAt(ctx context.Context, account common.Address, blockNumber *big.Int) (*big.Int, error)
+}
+
+// Configurable is implemented by sources that take settings. Configure is
+// called right after Open with the source's "settings" from the "adapters"
+// section of the config, and only when there are some; an error keeps the
+// source from being used on that chain.
+//
+// A source that also implements io.Closer is closed once the valuation it
+// was opened for is done with it.
+type Configurable interface {
+	Configure(settings json.RawMessage) error
+}
+
+// Registration is a named constructor of a source. Open returns nil for
+// chains the source has nothing on.
+type Registration[S any] struct {
+	Name string
+	Open func(chainID uint64, c Client) S
+}
+
+var (
+	mu     sync.Mutex
+	assets []Registration[AssetSource]
+	prices []Registration[PriceSource]
+)
+
+// RegisterAssets adds an asset source to every valuation, after the
+// built-in ones.
+func RegisterAssets(name string, open func(chainID uint64, c Client) AssetSource) {
+	mu.Lock()
+	defer mu.Unlock()
+	assets = append(assets, Registration[AssetSource]{name, open})
+}
+
+// RegisterPrices adds a price source, tried after the on-chain built-in
+// ones and before the DefiLlama fallback.
+func RegisterPrices(name string, open func(chainID uint64, c Client) PriceSource) {
+	mu.Lock()
+	defer mu.Unlock()
+	prices = append(prices, Registration[PriceSource]{name, open})
+}
+
+// Assets returns the registered asset sources in registration order.
+func Assets() []Registration[AssetSource] {
+	mu.Lock()
+	defer mu.Unlock()
+	return append([]Registration[AssetSource](nil), assets...)
+}
+
+// Prices returns the registered price sources in registration order.
+func Prices() []Registration[PriceSource] {
+	mu.Lock()
+	defer mu.Unlock()
+	return append([]Registration[PriceSource](nil), prices...)
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math/big"
+	"slices"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+
+	"Test2/source"
+)
+
+// The protocol integrations are source.AssetSources and the on-chain price
+// lookups source.PriceSources, so that they and registered sources are
+// asked the same way. Registry balances are read directly: each token's
+// lookup can fail on its own.
+
+var (
+	_ source.AssetSource = holdingSource{}
+	_ source.PriceSource = pricerSource{}
+)
+
+// namedAssets is an asset source and the name its failures are shown under.
+type namedAssets struct {
+	name string
+	source.AssetSource
+}
+
+// holdingSource adapts a built-in integration, which finds holdings.
+type holdingSource struct {
+	chainID uint64
+	find    findFunc
+}
+
+func (s holdingSource) Positions(ctx context.Context, owner common.Address) ([]source.Position, error) {
+	held, err := s.find(ctx, owner)
+	ps := make([]source.Position, len(held))
+	for i, h := range held {
+		ps[i] = source.Position{Asset: assetOf(s.chainID, h.tf), Amount: h.amt, Note: h.note, Price: h.price}
+	}
+	return ps, err
+}
+
+// assetSources lists the enabled adapters that apply to the chain and the
+// run's options, built-in first, in the order they are asked.
+func assetSources(client chainClient, chainID uint64, cfg *config, opts options) []namedAssets {
+	var out []namedAssets
+	for _, a := range builtinAdapters {
+		if a.chains != nil && !slices.Contains(a.chains, chainID) || !adapterEnabled(a.name, a.optIn, cfg, opts) {
+			continue
+		}
+		if find := a.open(client, chainID, cfg, opts); find != nil {
+			out = append(out, namedAssets{a.name, holdingSource{chainID, find}})
+		}
+	}
+	for _, r := range source.Assets() {
+		if !adapterEnabled(r.Name, false, cfg, opts) {
+			continue
+		}
+		if s := openRegistered(r, chainID, client, cfg); s != nil {
+			out = append(out, namedAssets{r.Name, s})
+		}
+	}
+	return out
+}
+
+// assetOf is the SDK's view of a registry entry.
+func assetOf(chainID uint64, tf tokenFeed) source.Asset {
+	return source.Asset{Chain: chainID, Address: tf.TokenAddr, Symbol: tf.Symbol, Decimals: tf.Decimals}
+}
+
+// holdingOf turns a source's position into a holding, with the registry
+// entry of its asset when the chain has one, so it is priced by its feed.
+// The native coin's ERC-20 face is held as the native coin.
+func holdingOf(chainID uint64, p source.Position) *holding {
+	tf, ok := registryToken(chainID, p.Asset.Address)
+	if !ok || tf.Symbol != p.Asset.Symbol && !nativeFace(chainID, p.Asset.Address) {
+		tf = tokenFeed{Symbol: p.Asset.Symbol, TokenAddr: p.Asset.Address, Decimals: p.Asset.Decimals}
+	}
+	amt := p.Amount
+	if amt == nil {
+		amt = new(big.Float)
+	}
+	return &holding{tf: tf, amt: amt, note: p.Note, price: p.Price}
+}
+
+// pricerSource adapts one of the pricer's lookups. The pricer remembers
+// the registry entry of each asset it asks about, feed included.
+type pricerSource struct {
+	p      *pricer
+	lookup func(tf tokenFeed) (*big.Float, error)
+}
+
+func (s pricerSource) Price(ctx context.Context, asset source.Asset) (*big.Float, error) {
+	tf, ok := s.p.tokens[asset]
+	if !ok {
+		tf = holdingOf(asset.Chain, source.Position{Asset: asset}).tf
+	}
+	return s.lookup(tf)
+}
+
+// priceSources are the pricer's lookups and the registered sources, by
+// name. DefiLlama is not one: it is asked for every holding at once.
+func (p *pricer) priceSources() map[string]source.PriceSource {
+	lookup := func(f func(tf tokenFeed) (*big.Float, error)) source.PriceSource { return pricerSource{p, f} }
+	out := map[string]source.PriceSource{
+		"feed-path": lookup(func(tf tokenFeed) (*big.Float, error) {
+			legs, ok := p.paths[tf.TokenAddr]
+			if !ok {
+				return nil, source.ErrUnsupported
+			}
+			return p.path(legs)
+		}),
+		"chainlink": lookup(func(tf tokenFeed) (*big.Float, error) {
+			if tf.FeedAddr == (common.Address{}) {
+				return nil, source.ErrUnsupported
+			}
+			return p.feed(tf.FeedAddr)
+		}),
+		"1inch": lookup(func(tf tokenFeed) (*big.Float, error) {
+			if tf.TokenAddr == (common.Address{}) {
+				return nil, fmt.Errorf("native coin: %w", source.ErrUnsupported)
+			}
+			return p.oneInch(tf)
+		}),
+		"pyth":     lookup(p.pyth),
+		"redstone": lookup(p.redstone),
+		"tellor":   lookup(p.tellor),
+		"uniswap-twap": lookup(func(tf tokenFeed) (*big.Float, error) {
+			rate, err := uniswapTWAP(p.ctx, p.client, p.chainID, tf)
+			if err != nil {
+				return nil, err
+			}
+			eth, err := p.ethUSD()
+			if err != nil {
+				return nil, fmt.Errorf("ETH/USD: %w", err)
+			}
+			return rate.Mul(rate, eth), nil
+		}),
+		"coingecko": lookup(func(tf tokenFeed) (*big.Float, error) {
+			if !p.at.IsZero() {
+				return nil, fmt.Errorf("past prices: %w", source.ErrUnsupported)
+			}
+			return coingeckoPrice(p.ctx, p.chainID, tf)
+		}),
+		"manual": lookup(func(tf tokenFeed) (*big.Float, error) {
+			fp, ok := p.file.lookup(tf)
+			if !ok {
+				return nil, source.ErrUnsupported
+			}
+			if !fp.Time.IsZero() {
+				log.Printf("%s: using the price file's $%g as of %s", tf.Symbol, fp.Price, fp.Time.Format(time.DateOnly))
+			}
+			return big.NewFloat(fp.Price), nil
+		}),
+	}
+	for _, r := range source.Prices() {
+		if s := r.Open(p.chainID, p.client); s != nil {
+			out[r.Name] = s
+		}
+	}
+	return out
+}
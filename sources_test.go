@@ -0,0 +1,87 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core"
+
+	"Test2/source"
+)
+
+// sourceTestChain is the only chain the sources registered here open on,
+// so that they stay out of the other tests.
+const sourceTestChain = 4242
+
+var sourceTestToken = common.HexToAddress("0x00000000000000000000000000000000000042aa")
+
+type testAssets struct{}
+
+func (testAssets) Positions(ctx context.Context, owner common.Address) ([]source.Position, error) {
+	asset := source.Asset{Chain: sourceTestChain, Address: sourceTestToken, Symbol: "TST", Decimals: 18}
+	return []source.Position{
+		{Asset: asset, Amount: big.NewFloat(3), Note: "in the test protocol"},
+		{Asset: source.Asset{Chain: sourceTestChain, Symbol: "CREDIT"}, Amount: big.NewFloat(5), Price: big.NewFloat(1)},
+	}, errors.New("second page unavailable")
+}
+
+type testPrices struct{}
+
+func (testPrices) Price(ctx context.Context, asset source.Asset) (*big.Float, error) {
+	if asset.Address != sourceTestToken {
+		return nil, fmt.Errorf("%s: %w", asset.Symbol, source.ErrUnsupported)
+	}
+	return big.NewFloat(2.5), nil
+}
+
+func init() {
+	source.RegisterAssets("test-assets", func(chainID uint64, c source.Client) source.AssetSource {
+		if chainID != sourceTestChain {
+			return nil
+		}
+		return testAssets{}
+	})
+	source.RegisterPrices("test-prices", func(chainID uint64, c source.Client) source.PriceSource {
+		if chainID != sourceTestChain {
+			return nil
+		}
+		return testPrices{}
+	})
+}
+
+func TestRegisteredSources(t *testing.T) {
+	sim := newSim(t, core.GenesisAlloc{})
+	ctx := context.Background()
+	held := collectHoldings(ctx, sim, sourceTestChain, common.Address{1}, &config{}, options{})
+	var got []string
+	for _, h := range held {
+		if h.err != nil {
+			got = append(got, h.tf.Symbol+": "+h.err.Error())
+		} else {
+			got = append(got, h.tf.Symbol+" "+h.amt.Text('f', 0))
+		}
+	}
+	// A failing source keeps what it found and is reported under its name.
+	if want := "TST 3|CREDIT 5|test-assets: second page unavailable"; strings.Join(got, "|") != want {
+		t.Fatalf("holdings %q, want %q", strings.Join(got, "|"), want)
+	}
+
+	held = held[:2]
+	other := &holding{tf: tokenFeed{Symbol: "OTHER", TokenAddr: common.Address{2}, Decimals: 18}, amt: big.NewFloat(1)}
+	newPricer(ctx, sim, sourceTestChain, true, &config{}, options{}).priceAll(append(held, other))
+	if held[0].err != nil || !floatEq(held[0].usd(), 7.5) {
+		t.Errorf("TST: %s, %v", held[0].usd().Text('f', 2), held[0].err)
+	}
+	if held[1].err != nil || !floatEq(held[1].usd(), 5) {
+		t.Errorf("CREDIT kept its own price: %s, %v", held[1].usd().Text('f', 2), held[1].err)
+	}
+	// A source that does not cover an asset is not a reason it went unpriced.
+	if other.err == nil || strings.Contains(other.err.Error(), "test-prices") {
+		t.Errorf("OTHER: %v", other.err)
+	}
+}
@@ -0,0 +1,184 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// portfolioRow names the whole-portfolio line of the stats table.
+const portfolioRow = "PORTFOLIO"
+
+// seriesStats summarises the returns between consecutive snapshots.
+type seriesStats struct {
+	Name        string
+	Points      int     // snapshots the series has a price in
+	Return      float64 // total return over the period
+	Volatility  float64 // annualised standard deviation of log returns
+	MaxDrawdown float64 // largest peak-to-trough fall, as a positive fraction
+	Sharpe      float64 // annualised mean over standard deviation of log returns, no risk-free rate
+}
+
+// historyStats computes per-asset and portfolio metrics from a wallet's
+// snapshots, oldest first. An asset's price in a snapshot is its USD value
+// over its amount across chains. The portfolio's return between two
+// snapshots weighs each asset's price return by its share of the earlier
+// snapshot, so deposits and withdrawals do not count as performance.
+// Annualising uses the mean spacing of the snapshots.
+func historyStats(snaps []*report) []seriesStats {
+	prices := make([]map[string]float64, len(snaps))
+	values := make([]map[string]float64, len(snaps))
+	for i, s := range snaps {
+		usd, amt := map[string]float64{}, map[string]float64{}
+		for _, p := range s.Positions {
+			if p.Error != "" || p.Amount <= 0 || p.USD <= 0 {
+				continue
+			}
+			sym := strings.ToUpper(p.Symbol)
+			usd[sym] += p.USD
+			amt[sym] += p.Amount
+		}
+		prices[i], values[i] = map[string]float64{}, usd
+		for sym, v := range usd {
+			prices[i][sym] = v / amt[sym]
+		}
+	}
+
+	returns := map[string][]float64{}
+	points := map[string]int{}
+	var order []string
+	var gaps []float64
+	for i := range snaps {
+		for sym := range prices[i] {
+			if points[sym] == 0 {
+				order = append(order, sym)
+			}
+			points[sym]++
+		}
+		if i == 0 {
+			continue
+		}
+		gaps = append(gaps, snaps[i].Time.Sub(snaps[i-1].Time).Seconds())
+		var port, weight float64
+		for sym, before := range prices[i-1] {
+			now, ok := prices[i][sym]
+			if !ok {
+				continue
+			}
+			r := now/before - 1
+			returns[sym] = append(returns[sym], r)
+			port += values[i-1][sym] * r
+			weight += values[i-1][sym]
+		}
+		if weight > 0 {
+			returns[portfolioRow] = append(returns[portfolioRow], port/weight)
+		}
+	}
+	if len(gaps) == 0 {
+		return nil
+	}
+	var meanGap float64
+	for _, g := range gaps {
+		meanGap += g
+	}
+	meanGap /= float64(len(gaps))
+	periodsPerYear := (365 * 24 * time.Hour).Seconds() / meanGap
+
+	sort.Strings(order)
+	points[portfolioRow] = len(snaps)
+	var out []seriesStats
+	for _, name := range append([]string{portfolioRow}, order...) {
+		if len(returns[name]) == 0 {
+			continue
+		}
+		out = append(out, summarise(name, points[name], returns[name], periodsPerYear))
+	}
+	return out
+}
+
+func summarise(name string, points int, returns []float64, periodsPerYear float64) seriesStats {
+	s := seriesStats{Name: name, Points: points}
+	index, peak := 1.0, 1.0
+	var logs []float64
+	for _, r := range returns {
+		index *= 1 + r
+		peak = math.Max(peak, index)
+		s.MaxDrawdown = math.Max(s.MaxDrawdown, 1-index/peak)
+		logs = append(logs, math.Log1p(r))
+	}
+	s.Return = index - 1
+	var mean, variance float64
+	for _, l := range logs {
+		mean += l
+	}
+	mean /= float64(len(logs))
+	if len(logs) > 1 {
+		for _, l := range logs {
+			variance += (l - mean) * (l - mean)
+		}
+		variance /= float64(len(logs) - 1)
+	}
+	if sd := math.Sqrt(variance); sd > 0 {
+		s.Volatility = sd * math.Sqrt(periodsPerYear)
+		s.Sharpe = mean / sd * math.Sqrt(periodsPerYear)
+	}
+	return s
+}
+
+func statsCmd(args []string) {
+	fs := flag.NewFlagSet("stats", flag.ExitOnError)
+	since := fs.String("since", "", "only use snapshots from this long before the latest (e.g. 90d)")
+	fs.String("config", "config.json", "path to the JSON config file, for its address book")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s stats [-since 90d] [address|label]\n(needs snapshots stored with -save)\n", os.Args[0])
+		fs.PrintDefaults()
+	}
+	if _, err := parseSettings(fs, args); err != nil {
+		log.Fatal(err)
+	}
+	wallet := fs.Arg(0)
+	if addr, ok := walletArg(wallet); ok {
+		wallet = addr.Hex()
+	}
+	snaps, err := listSnapshots(wallet)
+	if err != nil {
+		log.Fatalf("stats: %v", err)
+	}
+	if len(snaps) > 0 && wallet == "" {
+		for _, s := range snaps {
+			if s.Wallet != snaps[0].Wallet {
+				log.Fatal("stats: snapshots of several wallets; name the one to analyse")
+			}
+		}
+	}
+	if *since != "" && len(snaps) > 0 {
+		d, err := parseSince(*since)
+		if err != nil {
+			log.Fatalf("stats: %v", err)
+		}
+		cutoff := snaps[len(snaps)-1].Time.Add(-d)
+		for len(snaps) > 0 && snaps[0].Time.Before(cutoff) {
+			snaps = snaps[1:]
+		}
+	}
+	stats := historyStats(snaps)
+	if len(stats) == 0 {
+		log.Fatalf("stats: need at least two snapshots in %s", snapshotDir())
+	}
+
+	fmt.Printf("%d snapshots, %s to %s\n", len(snaps),
+		snaps[0].Time.Format(time.DateOnly), snaps[len(snaps)-1].Time.Format(time.DateOnly))
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(tw, "ASSET\tPOINTS\tRETURN\tVOLATILITY\tMAX DRAWDOWN\tSHARPE\t")
+	for _, s := range stats {
+		fmt.Fprintf(tw, "%s\t%d\t%+.2f%%\t%.2f%%\t%.2f%%\t%.2f\t\n", s.Name, s.Points,
+			100*s.Return, 100*s.Volatility, 100*s.MaxDrawdown, s.Sharpe)
+	}
+	tw.Flush()
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestHistoryStats(t *testing.T) {
+	day := func(n int, positions ...reportPosition) *report {
+		return &report{Time: time.Date(2024, 1, 1+n, 0, 0, 0, 0, time.UTC), Positions: positions}
+	}
+	eth := func(amount, price float64) reportPosition {
+		return reportPosition{Symbol: "ETH", Amount: amount, USD: amount * price}
+	}
+	usdc := func(amount float64) reportPosition {
+		return reportPosition{Symbol: "USDC", Amount: amount, USD: amount}
+	}
+	snaps := []*report{
+		day(0, eth(1, 2000), usdc(2000)),
+		// Doubling the ETH held is a deposit, not a return.
+		day(1, eth(2, 2200), usdc(2000)),
+		day(2, eth(2, 1650), usdc(2000)),
+		day(3, eth(2, 1980), usdc(2000)),
+	}
+	stats := historyStats(snaps)
+	if len(stats) != 3 || stats[0].Name != portfolioRow || stats[1].Name != "ETH" || stats[2].Name != "USDC" {
+		t.Fatalf("stats = %+v", stats)
+	}
+	near := func(a, b float64) bool { return math.Abs(a-b) < 1e-9 }
+	ethStats := stats[1]
+	if !near(ethStats.Return, -0.01) || !near(ethStats.MaxDrawdown, 0.25) || ethStats.Volatility <= 0 {
+		t.Errorf("ETH = %+v", ethStats)
+	}
+	if usdcStats := stats[2]; usdcStats.Return != 0 || usdcStats.Volatility != 0 || usdcStats.MaxDrawdown != 0 {
+		t.Errorf("USDC = %+v", usdcStats)
+	}
+	// Day 1: half in ETH up 10% → +5%. Day 2: 4400 of 6400 in ETH down 25%.
+	want := 1.05*(1-4400.0/6400*0.25)*(1+3300.0/5300*0.2) - 1
+	if port := stats[0]; !near(port.Return, want) {
+		t.Errorf("portfolio return = %g, want %g", port.Return, want)
+	}
+
+	if historyStats(snaps[:1]) != nil {
+		t.Error("a single snapshot has no returns")
+	}
+}
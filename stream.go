@@ -0,0 +1,58 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// streamKeepAlive is how often an idle stream sends a comment, so that
+// proxies do not close it.
+const streamKeepAlive = 15 * time.Second
+
+// handleStream sends the wallet's report as a Server-Sent Event now and
+// again each time it is revalued, every refresh, until the client goes
+// away. Browsers' EventSource cannot set headers, so the key may come as
+// ?key=.
+func (s *server) handleStream(w http.ResponseWriter, r *http.Request, t *tenant) {
+	wallet, ok := s.wallet(w, r, t)
+	if !ok {
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeError(w, http.StatusInternalServerError, fmt.Errorf("streaming unsupported"))
+		return
+	}
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	refresh := time.NewTicker(max(s.refresh, time.Second))
+	defer refresh.Stop()
+	keepAlive := time.NewTicker(streamKeepAlive)
+	defer keepAlive.Stop()
+	var last time.Time
+	for {
+		if rep := s.report(t, wallet); !rep.Time.Equal(last) {
+			last = rep.Time
+			data, err := json.Marshal(rep)
+			if err != nil {
+				return
+			}
+			fmt.Fprintf(w, "event: report\nid: %d\ndata: %s\n\n", rep.Time.UnixMilli(), data)
+			flusher.Flush()
+		}
+		select {
+		case <-r.Context().Done():
+			return
+		case <-keepAlive.C:
+			fmt.Fprint(w, ": keep-alive\n\n")
+			flusher.Flush()
+			continue
+		case <-refresh.C:
+		}
+	}
+}
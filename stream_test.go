@@ -0,0 +1,84 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+func TestServerStream(t *testing.T) {
+	wallet := common.HexToAddress("0x00000000000000000000000000000000000000aa")
+	var valued atomic.Int64
+	s := &server{
+		open:    &tenant{fixed: true, wallets: []common.Address{wallet}, reports: map[common.Address]*report{}},
+		keys:    []string{"k1"},
+		refresh: 10 * time.Millisecond,
+		value: func(w common.Address) *report {
+			rep := newReport(w.Hex(), nil)
+			rep.TotalUSD = float64(1000 * valued.Add(1))
+			return rep
+		},
+	}
+	srv := httptest.NewServer(s.handler())
+	defer srv.Close()
+
+	path := srv.URL + "/api/wallets/" + wallet.Hex() + "/stream"
+	if resp, err := http.Get(path); err != nil {
+		t.Fatal(err)
+	} else if resp.Body.Close(); resp.StatusCode != 401 {
+		t.Fatalf("stream without key: %d", resp.StatusCode)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	req, _ := http.NewRequestWithContext(ctx, "GET", path+"?key=k1", nil)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != 200 || resp.Header.Get("Content-Type") != "text/event-stream" {
+		t.Fatalf("stream: %d %s", resp.StatusCode, resp.Header.Get("Content-Type"))
+	}
+
+	var totals []float64
+	sc := bufio.NewScanner(resp.Body)
+	sc.Buffer(nil, 1<<20)
+	for len(totals) < 2 && sc.Scan() {
+		data, ok := strings.CutPrefix(sc.Text(), "data: ")
+		if !ok {
+			continue
+		}
+		var rep report
+		if err := json.Unmarshal([]byte(data), &rep); err != nil {
+			t.Fatal(err)
+		}
+		totals = append(totals, rep.TotalUSD)
+	}
+	if len(totals) < 2 || totals[1] <= totals[0] {
+		t.Errorf("streamed totals %v, want two successive valuations", totals)
+	}
+	cancel()
+
+	ctx, cancel = context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	req, _ = http.NewRequestWithContext(ctx, "GET", srv.URL+"/v1/stream/"+wallet.Hex()+"?key=k1", nil)
+	if resp, err := http.DefaultClient.Do(req); err != nil {
+		t.Fatal(err)
+	} else if resp.Body.Close(); resp.StatusCode != 200 || resp.Header.Get("Content-Type") != "text/event-stream" {
+		t.Errorf("/v1/stream: %d %s", resp.StatusCode, resp.Header.Get("Content-Type"))
+	}
+
+	r := httptest.NewRequest("GET", "/api/wallets?key=k1", nil)
+	if key := requestKey(r); key != "" {
+		t.Errorf("?key= accepted outside streams: %q", key)
+	}
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// superfluidCFA is the constant-flow agreement contract per chain, which
+// knows each account's net flow rate for a super token.
+var superfluidCFA = map[uint64]common.Address{
+	1:   common.HexToAddress("0x2844c1BBdA121E9E43105630b9C8310e5c72744b"),
+	137: common.HexToAddress("0x6EeE6060f715257b970700bc2656De21dEdF074C"),
+}
+
+// superTokens are checked on every run; more can be listed in the config
+// under the chain's name.
+var superTokens = map[uint64][]common.Address{
+	137: {
+		common.HexToAddress("0xCAa7349CEA390F89641fe306D93591f87595dc1F"), // USDCx
+		common.HexToAddress("0x1305F6B6Df9Dc47159D12Eb7aC2804d4A33173c2"), // DAIx
+		common.HexToAddress("0x3aD736904E9e65189c3000c7DD2c8AC8bB7cD4e3"), // MATICx
+	},
+}
+
+var superfluidABI = mustABI(`[
+  {"inputs":[{"name":"account","type":"address"}],"name":"realtimeBalanceOfNow","outputs":[
+     {"name":"availableBalance","type":"int256"},{"name":"deposit","type":"uint256"},{"name":"owedDeposit","type":"uint256"},{"name":"timestamp","type":"uint256"}
+  ],"stateMutability":"view","type":"function"},
+  {"inputs":[],"name":"getUnderlyingToken","outputs":[{"type":"address"}],"stateMutability":"view","type":"function"},
+  {"inputs":[{"name":"token","type":"address"},{"name":"account","type":"address"}],"name":"getNetFlow","outputs":[{"type":"int96"}],"stateMutability":"view","type":"function"}
+]`)
+
+// secondsPerMonth turns per-second flow rates into the monthly figures the
+// Superfluid dashboard shows.
+const secondsPerMonth = 30 * 24 * 60 * 60
+
+// superfluidHoldings reads the real-time balance of each super token, which
+// moves every second while streams are open, and annotates it with the net
+// monthly flow. Super tokens always have 18 decimals and are priced as their
+// underlying token (the native coin for native super tokens).
+func superfluidHoldings(ctx context.Context, client chainClient, chainID uint64, wallet common.Address, extra []common.Address) ([]*holding, error) {
+	var held []*holding
+	for _, token := range append(superTokens[chainID], extra...) {
+		vs, err := callView(ctx, client, token, superfluidABI, "realtimeBalanceOfNow", wallet)
+		if err != nil {
+			return held, err
+		}
+		bal := vs[0].(*big.Int)
+		if bal.Sign() <= 0 {
+			continue
+		}
+		vs, err = callView(ctx, client, token, superfluidABI, "getUnderlyingToken")
+		if err != nil {
+			return held, err
+		}
+		underlying := nativeToken(chainID)
+		if addr := vs[0].(common.Address); addr != (common.Address{}) {
+			if underlying, err = resolveToken(ctx, client, addr); err != nil {
+				return held, err
+			}
+		}
+		note := "Superfluid"
+		if cfa, ok := superfluidCFA[chainID]; ok {
+			vs, err := callView(ctx, client, cfa, superfluidABI, "getNetFlow", token, wallet)
+			if err != nil {
+				return held, err
+			}
+			if rate := vs[0].(*big.Int); rate.Sign() != 0 {
+				monthly := new(big.Int).Mul(rate, big.NewInt(secondsPerMonth))
+				note += fmt.Sprintf(", net %s %s/month", tokenAmount(monthly, 18).Text('f', 2), underlying.Symbol)
+			}
+		}
+		held = append(held, &holding{tf: underlying, amt: tokenAmount(bal, 18), note: note, id: "superfluid:" + token.Hex()})
+	}
+	return held, nil
+}
@@ -0,0 +1,199 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"math/big"
+	"os"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+// sweepReader is the part of the node API sweep planning uses.
+type sweepReader interface {
+	gasReader
+	PendingNonceAt(ctx context.Context, account common.Address) (uint64, error)
+}
+
+// sweepTx is one unsigned EIP-1559 transaction of a sweep. Unsigned is the
+// exact payload to sign, 0x02 || rlp([chainId, nonce, ...]); its keccak256
+// is the signing hash.
+type sweepTx struct {
+	Chain                string         `json:"chain"`
+	ChainID              uint64         `json:"chain_id"`
+	From                 common.Address `json:"from"`
+	To                   common.Address `json:"to"`
+	Recipient            common.Address `json:"recipient"`
+	Symbol               string         `json:"symbol"`
+	Amount               string         `json:"amount"`
+	USD                  float64        `json:"usd,omitempty"`
+	Nonce                uint64         `json:"nonce"`
+	Gas                  uint64         `json:"gas"`
+	MaxFeePerGas         *hexutil.Big   `json:"max_fee_per_gas"`
+	MaxPriorityFeePerGas *hexutil.Big   `json:"max_priority_fee_per_gas"`
+	Value                *hexutil.Big   `json:"value"`
+	Data                 hexutil.Bytes  `json:"data"`
+	Unsigned             hexutil.Bytes  `json:"unsigned_tx"`
+}
+
+// sweepCmd prints the unsigned transactions that would move every wallet
+// balance worth at least -min-usd to -to. It never signs or sends anything;
+// the transactions are meant to be signed elsewhere.
+func sweepCmd(args []string) {
+	fs := flag.NewFlagSet("sweep", flag.ExitOnError)
+	fs.String("config", "config.json", "path to the JSON config file")
+	rpc := fs.String("rpc", "", "RPC endpoint of the chain swept by default (default: ETH_RPC_URL)")
+	chainList := fs.String("chains", "", "comma-separated chains to sweep on (default: the chain -rpc points at)")
+	toArg := fs.String("to", "", "address or address book label to send the balances to")
+	minUSD := fs.Float64("min-usd", 0, "leave balances worth less than this many USD, and unpriced ones, where they are")
+	fs.Bool("dry-run", true, "print the transactions only; sweep never signs or sends, so this is always on")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s sweep -to <address> [flags] <wallet>...\n", os.Args[0])
+		fs.PrintDefaults()
+	}
+	cfg, err := parseSettings(fs, args)
+	if err != nil {
+		log.Fatal(err)
+	}
+	to, ok := walletArg(*toArg)
+	if !ok {
+		fs.Usage()
+		os.Exit(2)
+	}
+	wallets := fs.Args()
+	if len(wallets) == 0 {
+		wallets = settingAddresses(cfg)
+	}
+
+	ctx := context.Background()
+	conns := openChains(ctx, *rpc, *chainList, cfg)
+	txs := []sweepTx{}
+	err = forEachAddress(ctx, wallets, "", func(wallet common.Address) {
+		for _, c := range conns {
+			held := walletHoldings(ctx, c.client, c.id, wallet, cfg)
+			canonicalize(c.id, held)
+			newPricer(ctx, c.client, c.id, c.trustFeeds, cfg, options{}).priceAll(held)
+			planned, err := planSweep(ctx, c.client, c.id, wallet, to, held, *minUSD)
+			if err != nil {
+				log.Fatalf("%s: %s: %v", chainName(c.id), wallet.Hex(), err)
+			}
+			txs = append(txs, planned...)
+		}
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(txs); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// planSweep builds the transactions moving a wallet's plain balances to to,
+// with consecutive nonces from the wallet's pending nonce. Token transfers
+// come first. The native coin goes last, less what every transaction of the
+// sweep may spend on gas at its fee cap, and is left out when that is more
+// than the balance. Balances that fail to estimate are skipped with a
+// warning: the token may be paused or the balance locked.
+func planSweep(ctx context.Context, client sweepReader, chainID uint64, wallet, to common.Address, held []*holding, minUSD float64) ([]sweepTx, error) {
+	head, err := client.HeaderByNumber(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	tip, err := client.SuggestGasTipCap(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("priority fee: %w", err)
+	}
+	feeCap := new(big.Int).Set(tip)
+	if head.BaseFee != nil {
+		feeCap.Add(feeCap, new(big.Int).Mul(head.BaseFee, big.NewInt(2)))
+	}
+	nonce, err := client.PendingNonceAt(ctx, wallet)
+	if err != nil {
+		return nil, err
+	}
+
+	var txs []sweepTx
+	var native *holding
+	var nativeGas uint64
+	reserved := new(big.Int)
+	for _, h := range held {
+		if h.err != nil || h.raw == nil || minUSD > 0 && (h.price == nil || below(h.usd(), minUSD)) {
+			continue
+		}
+		call, err := sweepCall(wallet, to, h)
+		if err != nil {
+			return nil, err
+		}
+		gas, err := client.EstimateGas(ctx, call)
+		if err != nil {
+			log.Printf("sweep: skipping %s of %s: %v", h.tf.Symbol, wallet.Hex(), err)
+			continue
+		}
+		if h.tf.TokenAddr == (common.Address{}) {
+			native, nativeGas = h, gas
+			reserved.Add(reserved, new(big.Int).Mul(feeCap, new(big.Int).SetUint64(gas)))
+			continue
+		}
+		gas += gas / 10 // margin for state changing before the transaction is mined
+		reserved.Add(reserved, new(big.Int).Mul(feeCap, new(big.Int).SetUint64(gas)))
+		txs = append(txs, newSweepTx(chainID, wallet, to, h, *call.To, gas, feeCap, tip, new(big.Int), call.Data))
+	}
+	if native != nil {
+		value := new(big.Int).Sub(native.raw, reserved)
+		if value.Sign() > 0 {
+			moved := &holding{tf: native.tf, raw: value, amt: tokenAmount(value, native.tf.Decimals), price: native.price}
+			txs = append(txs, newSweepTx(chainID, wallet, to, moved, to, nativeGas, feeCap, tip, value, nil))
+		} else {
+			log.Printf("sweep: leaving %s of %s: the balance does not cover the sweep's gas", native.tf.Symbol, wallet.Hex())
+		}
+	}
+	for i := range txs {
+		txs[i].Nonce = nonce + uint64(i)
+		if txs[i].Unsigned, err = unsignedPayload(&txs[i]); err != nil {
+			return nil, err
+		}
+	}
+	return txs, nil
+}
+
+func newSweepTx(chainID uint64, wallet, recipient common.Address, h *holding, to common.Address, gas uint64, feeCap, tip, value *big.Int, data []byte) sweepTx {
+	tx := sweepTx{
+		Chain:                chainName(chainID),
+		ChainID:              chainID,
+		From:                 wallet,
+		To:                   to,
+		Recipient:            recipient,
+		Symbol:               h.tf.Symbol,
+		Amount:               new(big.Rat).SetFrac(h.raw, new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(h.tf.Decimals)), nil)).FloatString(h.tf.Decimals),
+		Gas:                  gas,
+		MaxFeePerGas:         (*hexutil.Big)(feeCap),
+		MaxPriorityFeePerGas: (*hexutil.Big)(tip),
+		Value:                (*hexutil.Big)(value),
+		Data:                 data,
+	}
+	if h.price != nil {
+		tx.USD, _ = h.usd().Float64()
+	}
+	return tx
+}
+
+// unsignedPayload encodes a sweep transaction the way EIP-1559 signs it.
+func unsignedPayload(tx *sweepTx) ([]byte, error) {
+	enc, err := rlp.EncodeToBytes([]any{
+		new(big.Int).SetUint64(tx.ChainID), tx.Nonce,
+		tx.MaxPriorityFeePerGas.ToInt(), tx.MaxFeePerGas.ToInt(), tx.Gas,
+		tx.To, tx.Value.ToInt(), []byte(tx.Data), types.AccessList{},
+	})
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte{types.DynamicFeeTxType}, enc...), nil
+}
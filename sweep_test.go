@@ -0,0 +1,69 @@
+package main
+
+import (
+	"context"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+func TestPlanSweep(t *testing.T) {
+	key, _ := crypto.GenerateKey()
+	wallet := crypto.PubkeyToAddress(key.PublicKey)
+	to := common.HexToAddress("0x0000000000000000000000000000000000000b0b")
+	sim := newSim(t, core.GenesisAlloc{
+		wallet:    {Balance: big.NewInt(params.Ether)},
+		testToken: mockToken(18, nil),
+	})
+	ctx := context.Background()
+	held := []*holding{
+		{tf: defaultTokens[0], amt: big.NewFloat(1), raw: big.NewInt(params.Ether), price: big.NewFloat(3000)},
+		// The mock token cannot transfer, so it is skipped.
+		{tf: tokenFeed{Symbol: "MOCK", TokenAddr: testToken, Decimals: 18}, amt: big.NewFloat(1), raw: big.NewInt(1e18), price: big.NewFloat(1)},
+	}
+
+	txs, err := planSweep(ctx, sim, simChainID, wallet, to, held, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(txs) != 1 || txs[0].Symbol != "ETH" || txs[0].Gas != params.TxGas {
+		t.Fatalf("txs = %+v, want the ETH sweep only", txs)
+	}
+
+	// Signing the payload elsewhere gives a transaction the chain accepts.
+	p := txs[0]
+	tx := types.NewTx(&types.DynamicFeeTx{
+		ChainID: big.NewInt(simChainID), Nonce: p.Nonce, GasTipCap: p.MaxPriorityFeePerGas.ToInt(),
+		GasFeeCap: p.MaxFeePerGas.ToInt(), Gas: p.Gas, To: &p.To, Value: p.Value.ToInt(), Data: p.Data,
+	})
+	signer := types.NewLondonSigner(big.NewInt(simChainID))
+	if got, want := crypto.Keccak256Hash(p.Unsigned), signer.Hash(tx); got != want {
+		t.Fatalf("payload hash = %s, want the signing hash %s", got.Hex(), want.Hex())
+	}
+	sig, err := crypto.Sign(crypto.Keccak256(p.Unsigned), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signed, err := tx.WithSignature(signer, sig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := sim.SendTransaction(ctx, signed); err != nil {
+		t.Fatal(err)
+	}
+	sim.Commit()
+	got, _ := sim.BalanceAt(ctx, to, nil)
+	if got.Cmp(p.Value.ToInt()) != 0 {
+		t.Errorf("recipient balance = %s, want %s", got, p.Value.ToInt())
+	}
+
+	// A minimum above every balance sweeps nothing.
+	if txs, err := planSweep(ctx, sim, simChainID, wallet, to, held, 1e6); err != nil || len(txs) != 0 {
+		t.Errorf("with -min-usd: txs = %+v, %v", txs, err)
+	}
+}
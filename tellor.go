@@ -0,0 +1,160 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+
+	"Test2/source"
+)
+
+// Tellor reporters stake to submit values under a query ID, and a value
+// can be disputed for a while after it is reported. The tellor source
+// therefore reads the newest value reported at least delay before the time
+// being valued, as Tellor advises its consumers to.
+
+// tellorConfig sets up the tellor price source, e.g.
+//
+//	{"contracts": {"mainnet": "0x..."}, "query_ids": {"RPL": "0x..."},
+//	 "delay": "15m", "max_age": "1d"}
+//
+// Contracts are the chains' Tellor oracles by chain name; chains without
+// one are not priced. A token's query ID is the SpotPrice query of its
+// symbol in USD unless query_ids registers another, whose value must be
+// likewise a uint256 with 18 decimals. Durations take a "d" suffix for
+// days.
+type tellorConfig struct {
+	Contracts map[string]common.Address `json:"contracts"`
+	QueryIDs  map[string]common.Hash    `json:"query_ids"`
+	Delay     string                    `json:"delay"`
+	MaxAge    string                    `json:"max_age"`
+
+	delay, maxAge time.Duration
+}
+
+// parse checks the settings.
+func (c *tellorConfig) parse() error {
+	for name := range c.Contracts {
+		if _, ok := chainByName(name); !ok {
+			return fmt.Errorf("contracts: unknown chain %q", name)
+		}
+	}
+	for _, d := range []struct {
+		name string
+		text string
+		to   *time.Duration
+	}{{"delay", c.Delay, &c.delay}, {"max_age", c.MaxAge, &c.maxAge}} {
+		if d.text == "" {
+			continue
+		}
+		v, err := parseSince(d.text)
+		if err != nil {
+			return fmt.Errorf("%s: %w", d.name, err)
+		}
+		if v <= 0 {
+			return fmt.Errorf("%s %q is not positive", d.name, d.text)
+		}
+		*d.to = v
+	}
+	return nil
+}
+
+func (c *tellorConfig) disputeDelay() time.Duration {
+	if c.delay == 0 {
+		return 15 * time.Minute
+	}
+	return c.delay
+}
+
+func (c *tellorConfig) limit() time.Duration {
+	if c.maxAge == 0 {
+		return 24 * time.Hour
+	}
+	return c.maxAge
+}
+
+// queryID is the symbol's registered query ID, or its SpotPrice query
+// in USD. Wrapped coins are priced as the coin.
+func (c *tellorConfig) queryID(symbol string) common.Hash {
+	for sym, id := range c.QueryIDs {
+		if strings.EqualFold(sym, symbol) {
+			return id
+		}
+	}
+	asset := strings.ToLower(symbol)
+	switch asset {
+	case "weth":
+		asset = "eth"
+	case "wbtc":
+		asset = "btc"
+	}
+	return spotPriceQuery(asset, "usd")
+}
+
+var (
+	tellorABI = mustABI(`[
+  {"inputs":[{"name":"queryId","type":"bytes32"},{"name":"timestamp","type":"uint256"}],"name":"getDataBefore","outputs":[{"name":"ifRetrieve","type":"bool"},{"name":"value","type":"bytes"},{"name":"timestampRetrieved","type":"uint256"}],"stateMutability":"view","type":"function"}
+]`)
+	tellorString, _ = abi.NewType("string", "", nil)
+	tellorBytes, _  = abi.NewType("bytes", "", nil)
+	tellorUint, _   = abi.NewType("uint256", "", nil)
+)
+
+// spotPriceQuery is the ID of Tellor's SpotPrice query of asset in
+// currency: keccak256(abi.encode("SpotPrice", abi.encode(asset, currency))).
+func spotPriceQuery(asset, currency string) common.Hash {
+	params, _ := abi.Arguments{{Type: tellorString}, {Type: tellorString}}.Pack(asset, currency)
+	data, _ := abi.Arguments{{Type: tellorString}, {Type: tellorBytes}}.Pack("SpotPrice", params)
+	return crypto.Keccak256Hash(data)
+}
+
+// tellor prices a token at the newest value reported under its query ID
+// at least the dispute delay before the time being valued. Values older
+// than max_age at that time are stale.
+func (p *pricer) tellor(tf tokenFeed) (*big.Float, error) {
+	contract, ok := p.tellorCfg.Contracts[chainName(p.chainID)]
+	if !ok {
+		return nil, fmt.Errorf("no Tellor contract configured for this chain: %w", source.ErrUnsupported)
+	}
+	now := p.at
+	if now.IsZero() {
+		now = time.Now()
+	}
+	value, reported, err := tellorValue(p.ctx, p.client, contract, p.tellorCfg.queryID(tf.Symbol), now.Add(-p.tellorCfg.disputeDelay()))
+	if err != nil {
+		return nil, err
+	}
+	if age := now.Sub(reported); age > p.tellorCfg.limit() {
+		return nil, fmt.Errorf("%w: Tellor value reported %s before", source.ErrStaleFeed, age.Round(time.Second))
+	}
+	return tokenAmount(value, 18), nil
+}
+
+// tellorValue reads the newest value of a query reported before before,
+// decoded as a uint256, and when it was reported. Queries nobody reports
+// are unsupported.
+func tellorValue(ctx context.Context, client chainClient, contract common.Address, query common.Hash, before time.Time) (*big.Int, time.Time, error) {
+	vs, err := callView(ctx, client, contract, tellorABI, "getDataBefore", query, big.NewInt(before.Unix()))
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	if !vs[0].(bool) {
+		return nil, time.Time{}, fmt.Errorf("query %s has no value: %w", query.Hex(), source.ErrUnsupported)
+	}
+	out, err := abi.Arguments{{Type: tellorUint}}.Unpack(vs[1].([]byte))
+	if err != nil {
+		return nil, time.Time{}, fmt.Errorf("value of query %s: %w", query.Hex(), err)
+	}
+	value := out[0].(*big.Int)
+	if value.Sign() == 0 {
+		return nil, time.Time{}, errors.New("Tellor value is zero")
+	}
+	return value, time.Unix(vs[2].(*big.Int).Int64(), 0), nil
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core"
+
+	"Test2/source"
+)
+
+func TestSpotPriceQuery(t *testing.T) {
+	if got, want := spotPriceQuery("eth", "usd"), common.HexToHash("0x83a7f3d48786ac2667503a61e8c415438ed2922eb86a2906e4ee66d9a2ce4992"); got != want {
+		t.Errorf("eth/usd query %s, want %s", got.Hex(), want.Hex())
+	}
+	cfg := tellorConfig{QueryIDs: map[string]common.Hash{"rpl": common.HexToHash("0x01")}}
+	if cfg.queryID("WETH") != spotPriceQuery("eth", "usd") || cfg.queryID("RPL") != common.HexToHash("0x01") {
+		t.Error("query IDs not looked up by symbol")
+	}
+}
+
+func TestTellor(t *testing.T) {
+	oracle := common.HexToAddress("0x000000000000000000000000000000000007e110")
+	reported := time.Now().Add(-time.Hour).Unix()
+	sim := newSim(t, core.GenesisAlloc{
+		oracle: {
+			Code: mockCode(method(tellorABI, "getDataBefore", 1, 2, 3, 4, 5)),
+			Storage: map[common.Hash]common.Hash{
+				word(1): word(1),    // ifRetrieve
+				word(2): word(0x60), // offset of value
+				word(3): word(reported),
+				word(4): word(32),
+				word(5): bigWord(scaled(t, "14.25", 18)),
+			},
+			Balance: new(big.Int),
+		},
+	})
+	cfg := &config{
+		PriceSources: priceSourcesConfig{Tokens: map[string][]string{"LINK": {"tellor"}}},
+		Tellor:       tellorConfig{Contracts: map[string]common.Address{chainName(simChainID): oracle}},
+	}
+	link, _ := tokenBySymbol("LINK")
+	h := &holding{tf: link, amt: big.NewFloat(2)}
+	newPricer(context.Background(), sim, simChainID, true, cfg, options{}).priceAll([]*holding{h})
+	if h.err != nil || !floatEq(h.price, 14.25) || h.source != "tellor" {
+		t.Fatalf("LINK $%v from %q: %v", h.price, h.source, h.err)
+	}
+
+	cfg.Tellor.MaxAge = "30m"
+	if err := cfg.Tellor.parse(); err == nil {
+		t.Fatal("a contract on an unknown chain accepted")
+	}
+	cfg.Tellor.maxAge = 30 * time.Minute
+	if _, err := newPricer(context.Background(), sim, simChainID, true, cfg, options{}).tellor(link); !errors.Is(err, source.ErrStaleFeed) {
+		t.Errorf("an hour-old value under a 30m max_age: %v", err)
+	}
+	if _, err := newPricer(context.Background(), sim, 10, true, cfg, options{}).tellor(link); !errors.Is(err, source.ErrUnsupported) {
+		t.Errorf("a chain without a contract: %v", err)
+	}
+	if err := (&tellorConfig{Delay: "-1m"}).parse(); err == nil {
+		t.Error("a negative delay accepted")
+	}
+}
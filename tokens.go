@@ -0,0 +1,270 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"go/format"
+	"log"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+
+	"Test2/bindings"
+)
+
+//go:generate go run . tokens update -o tokens_default.go
+
+// tokenFeed ties a token contract to the Chainlink feed used to price it.
+// The native coin has a zero TokenAddr.
+type tokenFeed struct {
+	Symbol    string
+	TokenAddr common.Address
+	FeedAddr  common.Address
+	Decimals  int
+}
+
+// Sources used by `tokens update`. The feed directory is the same JSON that
+// backs data.chain.link; the token list supplies contract addresses and
+// decimals; the market endpoint only provides the ranking.
+const (
+	feedDirectoryURL = "https://reference-data-directory.vercel.app/feeds-mainnet.json"
+	tokenListURL     = "https://tokens.uniswap.org"
+	marketsURL       = "https://api.coingecko.com/api/v3/coins/markets?vs_currency=usd&order=market_cap_desc&per_page=250&page=%d"
+)
+
+// marketPages bounds how deep the ranking is walked: most of the top coins
+// are not mainnet ERC-20s with a USD feed, so one page falls short of n.
+const marketPages = 4
+
+// feedAliases prices wrapped assets with the feed of what they wrap.
+var feedAliases = map[string]string{
+	"WETH": "ETH",
+	"WBTC": "BTC",
+}
+
+// bridgedDecimals are the decimals of every canonical asset in
+// bridgedTokens, on every chain.
+var bridgedDecimals = map[string]int{"ETH": 18, "USDC": 6, "USDT": 6, "DAI": 18, "WBTC": 8}
+
+// registry returns the tokens scanned and priced on a chain, native coin
+// first. Mainnet uses the generated defaultTokens; the other built-in
+// chains use the native coin of their profile and the bridged tokens of
+// canonical.go, priced through DefiLlama except for WETH where the native
+// coin is ETH, which shares its feed. Config-defined chains use the tokens
+// they declare. Other chains are not supported and have no registry.
+func registry(chainID uint64) []tokenFeed {
+	if chainID == 1 {
+		return defaultTokens
+	}
+	if r, ok := customRegistries[chainID]; ok {
+		return r
+	}
+	profile, ok := chainByID(chainID)
+	if !ok {
+		return nil
+	}
+	native := profile.Native
+	out := []tokenFeed{native}
+	var bridged []tokenFeed
+	for addr, asset := range bridgedTokens[chainID] {
+		tf := tokenFeed{Symbol: asset.Symbol, TokenAddr: addr, Decimals: bridgedDecimals[asset.Symbol]}
+		if asset.Symbol == native.Symbol {
+			tf.FeedAddr = native.FeedAddr
+		}
+		bridged = append(bridged, tf)
+	}
+	sort.Slice(bridged, func(i, j int) bool {
+		return bytes.Compare(bridged[i].TokenAddr[:], bridged[j].TokenAddr[:]) < 0
+	})
+	return append(out, bridged...)
+}
+
+// nativeToken is the registry entry of a chain's native coin.
+func nativeToken(chainID uint64) tokenFeed {
+	if p, ok := chainByID(chainID); ok {
+		return p.Native
+	}
+	return defaultTokens[0]
+}
+
+// nativeFace reports whether addr is the ERC-20 face of the chain's native
+// coin; see chainProfile.
+func nativeFace(chainID uint64, addr common.Address) bool {
+	p, ok := chainByID(chainID)
+	return ok && addr != (common.Address{}) && addr == p.NativeToken
+}
+
+// tokenBySymbol returns the registry entry with the given symbol.
+func tokenBySymbol(sym string) (tokenFeed, bool) {
+	for _, tf := range defaultTokens {
+		if tf.Symbol == sym {
+			return tf, true
+		}
+	}
+	return tokenFeed{}, false
+}
+
+// resolveToken returns the registry entry for a token contract, or builds one
+// from the contract's own symbol and decimals when the registry lacks it.
+func resolveToken(ctx context.Context, client chainClient, addr common.Address) (tokenFeed, error) {
+	for _, tf := range defaultTokens {
+		if tf.TokenAddr == addr {
+			return tf, nil
+		}
+	}
+	token, err := bindings.NewERC20Caller(addr, client)
+	if err != nil {
+		return tokenFeed{}, err
+	}
+	opts := &bind.CallOpts{Context: ctx}
+	dec, err := token.Decimals(opts)
+	if err != nil {
+		return tokenFeed{}, fmt.Errorf("decimals: %w", err)
+	}
+	tf := tokenFeed{Symbol: addr.Hex()[:8], TokenAddr: addr, Decimals: int(dec)}
+	if sym, err := token.Symbol(opts); err == nil {
+		tf.Symbol = sym
+	}
+	return tf, nil
+}
+
+func tokensCmd(args []string) {
+	if len(args) == 0 || args[0] != "update" {
+		log.Fatalf("Usage: %s tokens update [-o file] [-n count]", os.Args[0])
+	}
+	fs := flag.NewFlagSet("tokens update", flag.ExitOnError)
+	out := fs.String("o", "tokens_default.go", "output file")
+	n := fs.Int("n", 100, "number of tokens to include")
+	fs.Parse(args[1:])
+
+	tokens, err := fetchTopTokens(*n)
+	if err != nil {
+		log.Fatalf("tokens update: %v", err)
+	}
+	src, err := renderTokens(tokens)
+	if err != nil {
+		log.Fatalf("tokens update: %v", err)
+	}
+	if err := os.WriteFile(*out, src, 0o644); err != nil {
+		log.Fatalf("tokens update: %v", err)
+	}
+	fmt.Printf("wrote %d tokens to %s\n", len(tokens), *out)
+}
+
+// fetchTopTokens walks the market-cap ranking and keeps every mainnet token
+// that has both a known contract and a USD-quoted Chainlink feed.
+func fetchTopTokens(n int) ([]tokenFeed, error) {
+	ctx := context.Background()
+	var feeds []struct {
+		Name         string `json:"name"`
+		ProxyAddress string `json:"proxyAddress"`
+	}
+	if err := getJSON(ctx, feedDirectoryURL, &feeds); err != nil {
+		return nil, fmt.Errorf("feed directory: %w", err)
+	}
+	usdFeeds := map[string]common.Address{}
+	for _, f := range feeds {
+		base, quote, ok := strings.Cut(f.Name, " / ")
+		if !ok || quote != "USD" || !common.IsHexAddress(f.ProxyAddress) {
+			continue
+		}
+		usdFeeds[strings.ToUpper(base)] = common.HexToAddress(f.ProxyAddress)
+	}
+
+	var list struct {
+		Tokens []struct {
+			ChainID  int    `json:"chainId"`
+			Address  string `json:"address"`
+			Symbol   string `json:"symbol"`
+			Decimals int    `json:"decimals"`
+		} `json:"tokens"`
+	}
+	if err := getJSON(ctx, tokenListURL, &list); err != nil {
+		return nil, fmt.Errorf("token list: %w", err)
+	}
+	contracts := map[string]tokenFeed{}
+	for _, t := range list.Tokens {
+		sym := strings.ToUpper(t.Symbol)
+		if _, dup := contracts[sym]; t.ChainID != 1 || dup {
+			continue
+		}
+		contracts[sym] = tokenFeed{Symbol: t.Symbol, TokenAddr: common.HexToAddress(t.Address), Decimals: t.Decimals}
+	}
+
+	out := []tokenFeed{{Symbol: "ETH", FeedAddr: usdFeeds["ETH"], Decimals: 18}}
+	seen := map[string]bool{"ETH": true}
+	for page := 1; page <= marketPages && len(out) < n; page++ {
+		var markets []struct {
+			Symbol string `json:"symbol"`
+		}
+		if err := getJSON(ctx, fmt.Sprintf(marketsURL, page), &markets); err != nil {
+			return nil, fmt.Errorf("markets: %w", err)
+		}
+		for _, m := range markets {
+			if len(out) >= n {
+				break
+			}
+			sym := strings.ToUpper(m.Symbol)
+			tf, ok := contracts[sym]
+			if !ok || seen[sym] {
+				continue
+			}
+			feedSym := sym
+			if alias, ok := feedAliases[sym]; ok {
+				feedSym = alias
+			}
+			feed, ok := usdFeeds[feedSym]
+			if !ok {
+				continue
+			}
+			tf.FeedAddr = feed
+			out = append(out, tf)
+			seen[sym] = true
+		}
+	}
+	if len(out) < n {
+		log.Printf("tokens update: only %d of %d ranked tokens have a mainnet contract and a USD feed", len(out), n)
+	}
+	return out, nil
+}
+
+func renderTokens(tokens []tokenFeed) ([]byte, error) {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "// Code generated by \"tokens update\"; DO NOT EDIT.\n\npackage main\n\n")
+	fmt.Fprintf(&b, "import \"github.com/ethereum/go-ethereum/common\"\n\n")
+	fmt.Fprintf(&b, "var defaultTokens = []tokenFeed{\n")
+	for _, t := range tokens {
+		token := "common.Address{}"
+		if t.TokenAddr != (common.Address{}) {
+			token = fmt.Sprintf("common.HexToAddress(%q)", t.TokenAddr.Hex())
+		}
+		fmt.Fprintf(&b, "{%q, %s, common.HexToAddress(%q), %d},\n", t.Symbol, token, t.FeedAddr.Hex(), t.Decimals)
+	}
+	fmt.Fprintf(&b, "}\n")
+	return format.Source(b.Bytes())
+}
+
+func getJSON(ctx context.Context, url string, v any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	client := &http.Client{Timeout: 30 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("GET %s: %s", url, resp.Status)
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
@@ -0,0 +1,49 @@
+package main
+
+import "github.com/ethereum/go-ethereum/common"
+
+// defaultTokens is the mainnet registry, kept by hand: large-cap tokens that
+// have a Chainlink USD feed. `tokens update` replaces it with the top of the
+// market-cap ranking.
+var defaultTokens = []tokenFeed{
+	{"ETH", common.Address{}, common.HexToAddress("0x5f4eC3Df9cbd43714FE2740f5E3616155c5b8419"), 18},
+	{"USDT", common.HexToAddress("0xdAC17F958D2ee523a2206206994597C13D831ec7"), common.HexToAddress("0x3E7d1eAB13ad0104d2750B8863b489D65364e32D"), 6},
+	{"BNB", common.HexToAddress("0xB8c77482e45F1F44dE1745F52C74426C631bDD52"), common.HexToAddress("0x14e613AC84a31f709eadbdF89C6CC390fDc9540A"), 18},
+	{"USDC", common.HexToAddress("0xA0b86991c6218b36c1d19D4a2e9Eb0cE3606eB48"), common.HexToAddress("0x8fFfFfd4AfB6115b954Bd326cbe7B4BA576818f6"), 6},
+	{"stETH", common.HexToAddress("0xae7ab96520DE3A18E5e111B5EaAb095312D7fE84"), common.HexToAddress("0xCfE54B5cD566aB89272946F602D76Ea879CAb4a8"), 18},
+	{"WBTC", common.HexToAddress("0x2260FAC5E5542a773Aa44fBCfeDf7C193bc2C599"), common.HexToAddress("0xF4030086522a5bEEa4988F8cA5B36dbC97BeE88c"), 8},
+	{"LINK", common.HexToAddress("0x514910771AF9Ca656af840dff83E8264EcF986CA"), common.HexToAddress("0x2c1d072e956AFFC0D435Cb7AC38EF18d24d9127c"), 18},
+	{"WETH", common.HexToAddress("0xC02aaA39b223FE8D0A0e5C4F27eAD9083C756Cc2"), common.HexToAddress("0x5f4eC3Df9cbd43714FE2740f5E3616155c5b8419"), 18},
+	{"MATIC", common.HexToAddress("0x7D1AfA7B718fb893dB30A3aBc0Cfc608AaCfeBB0"), common.HexToAddress("0x7bAC85A8a13A4BcD8abb3eB7d6b4d632c5a57676"), 18},
+	{"DAI", common.HexToAddress("0x6B175474E89094C44Da98b954EedeAC495271d0F"), common.HexToAddress("0xAed0c38402a5d19df6E4c03F4E2DceD6e29c1ee9"), 18},
+	{"UNI", common.HexToAddress("0x1f9840a85d5aF5bf1D1762F925BDADdC4201F984"), common.HexToAddress("0x553303d460EE0afB37EdFf9bE42922D8FF63220e"), 18},
+	{"TUSD", common.HexToAddress("0x0000000000085d4780B73119b644AE5ecd22b376"), common.HexToAddress("0xec746eCF986E2927Abd291a2A1716c940100f8Ba"), 18},
+	{"CRO", common.HexToAddress("0xA0b73E1Ff0B80914AB6fe0444E65848C4C34450b"), common.HexToAddress("0x00Cb80Cf097D9aA9A3779ad8EE7cF98437eaE050"), 8},
+	{"MKR", common.HexToAddress("0x9f8F72aA9304c8B593d555F12eF6589cC3A579A2"), common.HexToAddress("0xec1D1B3b0443256cc3860e24a46F108e699484Aa"), 18},
+	{"AAVE", common.HexToAddress("0x7Fc66500c84A76Ad7e9c93437bFc5Ac33E2DDaE9"), common.HexToAddress("0x547a514d5e3769680Ce22B2361c10Ea13619e8a9"), 18},
+	{"GRT", common.HexToAddress("0xc944E90C64B2c07662A292be6244BDf05Cda44a7"), common.HexToAddress("0x86cF33a451dE9dc61a2862FD94FF4ad4Bd65A5d2"), 18},
+	{"SNX", common.HexToAddress("0xC011a73ee8576Fb46F5E1c5751cA3B9Fe0af2a6F"), common.HexToAddress("0xDC3EA94CD0AC27d9A86C180091e7f78C683d3699"), 18},
+	{"APE", common.HexToAddress("0x4d224452801ACEd8B2F0aebE155379bb5D594381"), common.HexToAddress("0xD10aBbC76679a20055E167BB80A24ac851b37056"), 18},
+	{"FRAX", common.HexToAddress("0x853d955aCEf822Db058eb8505911ED77F175b99e"), common.HexToAddress("0xB9E1E3A9feFf48998E45Fa90847ed4D467E8BcfD"), 18},
+	{"MANA", common.HexToAddress("0x0F5D2fB29fb7d3CFeE444a200298f468908cC942"), common.HexToAddress("0x56a4857acbcfe3a66965c251628B1c9f1c408C19"), 18},
+	{"SAND", common.HexToAddress("0x3845badAde8e6dFF049820680d1F14bD3903a5d0"), common.HexToAddress("0x35E3f7E558C04cE7eEE1629258EcbbA03B36Ec56"), 18},
+	{"CRV", common.HexToAddress("0xD533a949740bb3306d119CC777fa900bA034cd52"), common.HexToAddress("0xCd627aA160A6fA45Eb793D19Ef54f5062F20f33f"), 18},
+	{"RPL", common.HexToAddress("0xD33526068D116cE69F19A9ee46F0bd304F21A51f"), common.HexToAddress("0x4E155eD98aFE9034b7A5962f6C84c86d869daA9d"), 18},
+	{"COMP", common.HexToAddress("0xc00e94Cb662C3520282E6f5717214004A7f26888"), common.HexToAddress("0xdbd020CAeF83eFd542f4De03e3cF0C28A4428bd5"), 18},
+	{"1INCH", common.HexToAddress("0x111111111117dC0aa78b770fA6A738034120C302"), common.HexToAddress("0xc929ad75B72593967DE83E7F7Cda0493458261D9"), 18},
+	{"ENS", common.HexToAddress("0xC18360217D8F7Ab5e7c516566761Ea12Ce7F9D72"), common.HexToAddress("0x5C00128d4d1c2F4f652C267d7bcdD7aC99C16E16"), 18},
+	{"PYUSD", common.HexToAddress("0x6c3ea9036406852006290770BEdFcAbA0e23A0e8"), common.HexToAddress("0x8f1dF6D7F2db73eECE86a18b4381F4707b918FB1"), 6},
+	{"RSR", common.HexToAddress("0x320623b8E4fF03373931769A31Fc52A4E78B5d70"), common.HexToAddress("0x759bBC1be8F90eE6457C44abc7d443842a976d02"), 18},
+	{"BAL", common.HexToAddress("0xba100000625a3754423978a60c9317c58a424e3D"), common.HexToAddress("0xdF2917806E30300537aEB49A7663062F4d1F2b5F"), 18},
+	{"YFI", common.HexToAddress("0x0bc529c00C6401aEF6D220BE8C6Ea1667F6Ad93e"), common.HexToAddress("0xA027702dbb89fbd58938e4324ac03B58d812b0E1"), 18},
+	{"SUSHI", common.HexToAddress("0x6B3595068778DD592e39A122f4f5a5cF09C90fE2"), common.HexToAddress("0xCc70F09A6CC17553b2E31954cD36E4A2d89501f7"), 18},
+	{"CVX", common.HexToAddress("0x4e3FBD56CD56c3e72c1403e103b45Db9da5B9D2B"), common.HexToAddress("0xd962fC30A72A84cE50161031391756Bf2876Af5D"), 18},
+	{"FXS", common.HexToAddress("0x3432B6A60D23Ca0dFCa7761B7ab56459D9C964D0"), common.HexToAddress("0x6Ebc52C8C1089be9eB3945C4350B68B8E4C2233f"), 18},
+	{"LUSD", common.HexToAddress("0x5f98805A4E8be255a32880FDeC7F6728C6568bA0"), common.HexToAddress("0x3D7aE7E594f2f2091Ad8798313450130d0Aba3a0"), 18},
+	{"USDP", common.HexToAddress("0x8E870D67F660D95d5be530380D0eC0bd388289E1"), common.HexToAddress("0x09023c0DA49Aaf8fc3fA3ADF34C6A7016D38D5e3"), 18},
+	{"ZRX", common.HexToAddress("0xE41d2489571d322189246DaFA5ebDe1F4699F498"), common.HexToAddress("0x2885d15b8Af22648b98B122b22FDF4D2a56c6023"), 18},
+	{"KNC", common.HexToAddress("0xdeFA4e8a7bcBA345F687a2f1456F5Edd9CE97202"), common.HexToAddress("0xf8fF43E991A81e6eC886a3D281A2C6cC19aE70Fc"), 18},
+	{"STG", common.HexToAddress("0xAf5191B0De278C7286d6C7CC6ab6BB8A73bA2Cd6"), common.HexToAddress("0x7A9f34a0Aa917D438e9b6E630067062B7F8f6f3d"), 18},
+	{"REN", common.HexToAddress("0x408e41876cCCDC0F92210600ef50372656052a38"), common.HexToAddress("0x0f59666EDE214281e956cb3b2D0d69415AfF4A01"), 18},
+	{"BUSD", common.HexToAddress("0x4Fabb145d64652a948d72533023f6E7A623C7C53"), common.HexToAddress("0x833D8Eb16D306ed1FbB5D7A2E019e106B960965A"), 18},
+}
@@ -0,0 +1,354 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// The -tui front-end: one tab per wallet, each a table of its positions
+// that is revalued every -refresh, with keys to sort, filter and inspect
+// them. It values the same way the plain run does; only the output differs.
+
+const tuiHelp = "tab/←→ wallet  ↑↓ select  enter detail  s sort  / filter  r refresh  q quit"
+
+// tuiSort is the order of a wallet's table.
+type tuiSort int
+
+const (
+	tuiByValue tuiSort = iota
+	tuiBySymbol
+	tuiByChain
+)
+
+func (s tuiSort) String() string {
+	return [...]string{"value", "symbol", "chain"}[s]
+}
+
+var (
+	tuiTab      = lipgloss.NewStyle().Padding(0, 1)
+	tuiTabOn    = tuiTab.Reverse(true)
+	tuiSelected = lipgloss.NewStyle().Reverse(true)
+	tuiDim      = lipgloss.NewStyle().Faint(true)
+)
+
+// tuiReport is a wallet's valuation arriving; tuiTick starts the next
+// round of them.
+type tuiReport struct {
+	i   int
+	rep *report
+}
+
+type tuiTick struct{}
+
+// tuiLog keeps the last line logged while the screen is taken, to show it
+// in the status line instead of scribbling over the table.
+type tuiLog struct {
+	mu   sync.Mutex
+	last string
+}
+
+func (l *tuiLog) Write(p []byte) (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if line := strings.TrimSpace(string(p)); line != "" {
+		l.last = line
+	}
+	return len(p), nil
+}
+
+func (l *tuiLog) line() string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.last
+}
+
+type tuiModel struct {
+	wallets []common.Address
+	reports []*report // nil until a wallet is first valued
+	value   func(common.Address) *report
+	refresh time.Duration
+	log     *tuiLog
+
+	tab       int
+	cursor    int
+	sort      tuiSort
+	filter    string
+	filtering bool
+	detail    bool
+	loading   int // wallet being valued, -1 between rounds
+}
+
+// tuiRows is a report's table: the positions matching filter, a
+// case-insensitive substring of the symbol, chain or note, in the given
+// order. Failed lookups sort last by value.
+func tuiRows(rep *report, by tuiSort, filter string) []reportPosition {
+	if rep == nil {
+		return nil
+	}
+	filter = strings.ToLower(filter)
+	var rows []reportPosition
+	for _, p := range rep.Positions {
+		if filter == "" || strings.Contains(strings.ToLower(p.Symbol+" "+p.Chain+" "+p.Note), filter) {
+			rows = append(rows, p)
+		}
+	}
+	slices.SortStableFunc(rows, func(a, b reportPosition) int {
+		switch by {
+		case tuiBySymbol:
+			return cmp.Compare(a.Symbol, b.Symbol)
+		case tuiByChain:
+			return cmp.Compare(a.Chain, b.Chain)
+		}
+		if (a.Error != "") != (b.Error != "") {
+			if a.Error != "" {
+				return 1
+			}
+			return -1
+		}
+		return cmp.Compare(b.USD, a.USD)
+	})
+	return rows
+}
+
+func (m *tuiModel) Init() tea.Cmd {
+	return m.load(0)
+}
+
+// load values wallet i in the background; wallets are valued one after the
+// other so a refresh costs no more RPC load than a plain run.
+func (m *tuiModel) load(i int) tea.Cmd {
+	m.loading = i
+	wallet := m.wallets[i]
+	return func() tea.Msg {
+		return tuiReport{i: i, rep: m.value(wallet)}
+	}
+}
+
+func (m *tuiModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tuiReport:
+		m.reports[msg.i] = msg.rep
+		m.clampCursor()
+		if msg.i+1 < len(m.wallets) {
+			return m, m.load(msg.i + 1)
+		}
+		m.loading = -1
+		return m, tea.Tick(m.refresh, func(time.Time) tea.Msg { return tuiTick{} })
+	case tuiTick:
+		if m.loading < 0 {
+			return m, m.load(0)
+		}
+	case tea.KeyMsg:
+		if m.filtering {
+			return m, m.editFilter(msg)
+		}
+		switch msg.String() {
+		case "q", "ctrl+c":
+			return m, tea.Quit
+		case "esc":
+			if !m.detail {
+				return m, tea.Quit
+			}
+			m.detail = false
+		case "tab", "right", "l":
+			m.switchTab(1)
+		case "shift+tab", "left", "h":
+			m.switchTab(-1)
+		case "down", "j":
+			m.cursor++
+			m.clampCursor()
+		case "up", "k":
+			m.cursor--
+			m.clampCursor()
+		case "enter":
+			m.detail = !m.detail
+		case "s":
+			m.sort = (m.sort + 1) % 3
+		case "/":
+			m.filtering = true
+		case "r":
+			if m.loading < 0 {
+				return m, m.load(0)
+			}
+		}
+	}
+	return m, nil
+}
+
+func (m *tuiModel) editFilter(key tea.KeyMsg) tea.Cmd {
+	switch key.Type {
+	case tea.KeyEnter:
+		m.filtering = false
+	case tea.KeyEsc:
+		m.filtering, m.filter = false, ""
+	case tea.KeyCtrlC:
+		return tea.Quit
+	case tea.KeyBackspace:
+		if r := []rune(m.filter); len(r) > 0 {
+			m.filter = string(r[:len(r)-1])
+		}
+	case tea.KeyRunes, tea.KeySpace:
+		m.filter += string(key.Runes)
+	}
+	m.clampCursor()
+	return nil
+}
+
+func (m *tuiModel) switchTab(step int) {
+	m.tab = (m.tab + step + len(m.wallets)) % len(m.wallets)
+	m.cursor = 0
+}
+
+func (m *tuiModel) clampCursor() {
+	n := len(tuiRows(m.reports[m.tab], m.sort, m.filter))
+	m.cursor = max(0, min(m.cursor, n-1))
+}
+
+func (m *tuiModel) View() string {
+	var b strings.Builder
+	if len(m.wallets) > 1 {
+		for i, w := range m.wallets {
+			style := tuiTab
+			if i == m.tab {
+				style = tuiTabOn
+			}
+			b.WriteString(style.Render(walletName(w)))
+		}
+		b.WriteString("\n\n")
+	} else {
+		fmt.Fprintf(&b, "%s\n\n", walletName(m.wallets[0]))
+	}
+
+	rep := m.reports[m.tab]
+	if rep == nil {
+		b.WriteString("valuing...\n")
+	} else {
+		rows := tuiRows(rep, m.sort, m.filter)
+		var table strings.Builder
+		tw := tabwriter.NewWriter(&table, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, "SYMBOL\tCHAIN\tAMOUNT\tUSD\tNOTE")
+		for _, p := range rows {
+			if p.Error != "" {
+				fmt.Fprintf(tw, "%s\t%s\t\terror\t%s\n", p.Symbol, p.Chain, p.Error)
+				continue
+			}
+			fmt.Fprintf(tw, "%s\t%s\t%.6f\t$%.2f\t%s\n", p.Symbol, p.Chain, p.Amount, p.USD, p.Note)
+		}
+		tw.Flush()
+		for i, line := range strings.Split(strings.TrimSuffix(table.String(), "\n"), "\n") {
+			if i == m.cursor+1 {
+				line = tuiSelected.Render(line)
+			}
+			b.WriteString(line + "\n")
+		}
+		valued := "valued " + rep.Time.Local().Format(time.TimeOnly)
+		for _, blk := range rep.Blocks {
+			valued += fmt.Sprintf(", %s block %d", blk.Chain, blk.Number)
+		}
+		fmt.Fprintf(&b, "\nTOTAL $%.2f  (%s)\n", rep.TotalUSD, valued)
+		if m.detail && m.cursor < len(rows) {
+			b.WriteString("\n" + tuiDetail(rep, rows[m.cursor]))
+		}
+	}
+
+	status := fmt.Sprintf("sort: %s", m.sort)
+	if m.filtering || m.filter != "" {
+		status += fmt.Sprintf("  filter: %s", m.filter)
+		if m.filtering {
+			status += "_"
+		}
+	}
+	if m.loading >= 0 {
+		status += fmt.Sprintf("  valuing %s...", walletName(m.wallets[m.loading]))
+	}
+	b.WriteString("\n" + status + "\n")
+	if line := m.log.line(); line != "" {
+		b.WriteString(tuiDim.Render(line) + "\n")
+	}
+	b.WriteString(tuiDim.Render(tuiHelp))
+	return b.String()
+}
+
+// tuiDetail describes one position and the wallet's whole holding of its
+// asset across chains.
+func tuiDetail(rep *report, p reportPosition) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s on %s\n", p.Symbol, p.Chain)
+	if p.Note != "" {
+		fmt.Fprintf(&b, "  held as  %s\n", p.Note)
+	}
+	if p.Error != "" {
+		fmt.Fprintf(&b, "  error    %s\n", p.Error)
+		return b.String()
+	}
+	fmt.Fprintf(&b, "  amount   %g\n", p.Amount)
+	if p.Amount > 0 && p.USD > 0 {
+		fmt.Fprintf(&b, "  price    $%.6g\n", p.USD/p.Amount)
+	}
+	fmt.Fprintf(&b, "  value    $%.2f", p.USD)
+	if rep.TotalUSD > 0 {
+		fmt.Fprintf(&b, " (%.2f%% of the wallet)", 100*p.USD/rep.TotalUSD)
+	}
+	b.WriteString("\n")
+	var amount, usd float64
+	var chains []string
+	for _, q := range rep.Positions {
+		if q.Symbol == p.Symbol && q.Error == "" {
+			amount += q.Amount
+			usd += q.USD
+			if !slices.Contains(chains, q.Chain) {
+				chains = append(chains, q.Chain)
+			}
+		}
+	}
+	if amount != p.Amount {
+		fmt.Fprintf(&b, "  all %s  %g, $%.2f on %s\n", p.Symbol, amount, usd, strings.Join(chains, ", "))
+	}
+	if p.Website != "" {
+		fmt.Fprintf(&b, "  website  %s\n", p.Website)
+	}
+	if p.CoingeckoID != "" {
+		fmt.Fprintf(&b, "  market   https://www.coingecko.com/en/coins/%s\n", p.CoingeckoID)
+	}
+	return b.String()
+}
+
+// runTUI takes over the terminal until the user quits. Log lines go to the
+// status line meanwhile. With the addresses read from stdin, keys are read
+// from the terminal instead.
+func runTUI(ctx context.Context, conns []*chainConn, wallets []common.Address, cfg *config, opts options, refresh time.Duration, keysFromTTY bool) error {
+	m := &tuiModel{
+		wallets: wallets,
+		reports: make([]*report, len(wallets)),
+		refresh: refresh,
+		log:     &tuiLog{},
+		value: func(wallet common.Address) *report {
+			return valueWallet(ctx, conns, wallet, cfg, opts)
+		},
+	}
+	prev := log.Writer()
+	log.SetOutput(m.log)
+	defer log.SetOutput(prev)
+
+	popts := []tea.ProgramOption{tea.WithAltScreen(), tea.WithContext(ctx)}
+	if keysFromTTY {
+		popts = append(popts, tea.WithInputTTY())
+	}
+	_, err := tea.NewProgram(m, popts...).Run()
+	if errors.Is(err, tea.ErrProgramKilled) && ctx.Err() != nil {
+		return nil
+	}
+	return err
+}
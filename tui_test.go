@@ -0,0 +1,98 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+func TestTUIRows(t *testing.T) {
+	rep := &report{Positions: []reportPosition{
+		{Symbol: "USDC", Chain: "mainnet", USD: 500},
+		{Symbol: "LINK", Chain: "mainnet", Error: "no price"},
+		{Symbol: "ETH", Chain: "arbitrum", USD: 2000},
+		{Symbol: "ETH", Chain: "mainnet", USD: 100, Note: "Aave"},
+	}}
+	symbols := func(rows []reportPosition) string {
+		var s []string
+		for _, p := range rows {
+			s = append(s, p.Symbol+"@"+p.Chain)
+		}
+		return strings.Join(s, " ")
+	}
+	for _, tc := range []struct {
+		by     tuiSort
+		filter string
+		want   string
+	}{
+		{tuiByValue, "", "ETH@arbitrum USDC@mainnet ETH@mainnet LINK@mainnet"},
+		{tuiBySymbol, "", "ETH@arbitrum ETH@mainnet LINK@mainnet USDC@mainnet"},
+		{tuiByChain, "", "ETH@arbitrum USDC@mainnet LINK@mainnet ETH@mainnet"},
+		{tuiByValue, "eth", "ETH@arbitrum ETH@mainnet"},
+		{tuiByValue, "aave", "ETH@mainnet"},
+	} {
+		if got := symbols(tuiRows(rep, tc.by, tc.filter)); got != tc.want {
+			t.Errorf("sort %s, filter %q: got %s, want %s", tc.by, tc.filter, got, tc.want)
+		}
+	}
+}
+
+func TestTUIKeys(t *testing.T) {
+	a, b := common.HexToAddress("0xa"), common.HexToAddress("0xb")
+	m := &tuiModel{
+		wallets: []common.Address{a, b},
+		reports: make([]*report, 2),
+		log:     &tuiLog{},
+		value: func(w common.Address) *report {
+			return &report{Wallet: w.Hex(), TotalUSD: 2100, Positions: []reportPosition{
+				{Symbol: "ETH", Chain: "mainnet", Amount: 1, USD: 2000},
+				{Symbol: "USDC", Chain: "mainnet", Amount: 100, USD: 100},
+			}}
+		},
+	}
+	// Run the wallets' valuations the way the program would.
+	cmd := m.Init()
+	for i := 0; i < 2; i++ {
+		_, cmd = m.Update(cmd())
+	}
+	if m.loading != -1 || m.reports[1] == nil {
+		t.Fatalf("loading %d after both wallets were valued", m.loading)
+	}
+
+	keys := func(ks ...string) {
+		for _, k := range ks {
+			var msg tea.KeyMsg
+			switch k {
+			case "down":
+				msg = tea.KeyMsg{Type: tea.KeyDown}
+			case "enter":
+				msg = tea.KeyMsg{Type: tea.KeyEnter}
+			case "tab":
+				msg = tea.KeyMsg{Type: tea.KeyTab}
+			default:
+				msg = tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(k)}
+			}
+			m.Update(msg)
+		}
+	}
+	keys("down", "down", "down", "enter")
+	if m.cursor != 1 || !m.detail {
+		t.Fatalf("cursor %d, detail %v; want the last row's detail", m.cursor, m.detail)
+	}
+	if v := m.View(); !strings.Contains(v, "USDC on mainnet") || !strings.Contains(v, "4.76% of the wallet") {
+		t.Errorf("detail pane missing from view:\n%s", v)
+	}
+	keys("/", "u", "s", "enter")
+	if m.filter != "us" || m.filtering || m.cursor != 0 {
+		t.Errorf("filter %q (editing %v), cursor %d", m.filter, m.filtering, m.cursor)
+	}
+	keys("tab", "s")
+	if m.tab != 1 || m.sort != tuiBySymbol {
+		t.Errorf("tab %d, sort %s", m.tab, m.sort)
+	}
+	if _, cmd := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("q")}); cmd == nil {
+		t.Error("q does not quit")
+	}
+}
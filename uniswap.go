@@ -0,0 +1,101 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+
+	"Test2/source"
+)
+
+// Uniswap v3 prices a token by its pool against the wrapped native coin:
+// the tick averaged over twapWindow, from the pool's own oracle, which a
+// single block's trades cannot move far.
+
+// twapWindow is how far back the pools' tick is averaged from, in seconds.
+const twapWindow = 1800
+
+var (
+	uniswapFactories = map[uint64]common.Address{
+		1:     common.HexToAddress("0x1F98431c8aD98523631AE4a59f267346ea31F984"),
+		10:    common.HexToAddress("0x1F98431c8aD98523631AE4a59f267346ea31F984"),
+		8453:  common.HexToAddress("0x33128a8fC17869897dcE68Ed026d694621f6FDfD"),
+		42161: common.HexToAddress("0x1F98431c8aD98523631AE4a59f267346ea31F984"),
+	}
+	wrappedNative = map[uint64]common.Address{
+		1:     common.HexToAddress("0xC02aaA39b223FE8D0A0e5C4F27eAD9083C756Cc2"),
+		10:    common.HexToAddress("0x4200000000000000000000000000000000000006"),
+		8453:  common.HexToAddress("0x4200000000000000000000000000000000000006"),
+		42161: common.HexToAddress("0x82aF49447D8a07e3bd95BD0d56f35241523fBab1"),
+	}
+	uniswapFeeTiers = []uint32{500, 3000, 10000}
+)
+
+var (
+	uniswapFactoryABI = mustABI(`[
+  {"inputs":[{"name":"tokenA","type":"address"},{"name":"tokenB","type":"address"},{"name":"fee","type":"uint24"}],"name":"getPool","outputs":[{"name":"pool","type":"address"}],"stateMutability":"view","type":"function"}
+]`)
+	uniswapPoolABI = mustABI(`[
+  {"inputs":[],"name":"liquidity","outputs":[{"name":"","type":"uint128"}],"stateMutability":"view","type":"function"},
+  {"inputs":[{"name":"secondsAgos","type":"uint32[]"}],"name":"observe","outputs":[{"name":"tickCumulatives","type":"int56[]"},{"name":"secondsPerLiquidityCumulativeX128s","type":"uint160[]"}],"stateMutability":"view","type":"function"}
+]`)
+)
+
+// uniswapTWAP returns how much of the wrapped native coin one whole token
+// is worth, by the deepest of its fee tiers' pools.
+func uniswapTWAP(ctx context.Context, client chainClient, chainID uint64, tf tokenFeed) (*big.Float, error) {
+	factory, ok := uniswapFactories[chainID]
+	weth := wrappedNative[chainID]
+	if !ok || tf.TokenAddr == (common.Address{}) || tf.TokenAddr == weth {
+		return nil, source.ErrUnsupported
+	}
+	var pool common.Address
+	deepest := new(big.Int)
+	for _, fee := range uniswapFeeTiers {
+		vs, err := callView(ctx, client, factory, uniswapFactoryABI, "getPool", tf.TokenAddr, weth, big.NewInt(int64(fee)))
+		if err != nil {
+			return nil, err
+		}
+		addr := vs[0].(common.Address)
+		if addr == (common.Address{}) {
+			continue
+		}
+		vs, err = callView(ctx, client, addr, uniswapPoolABI, "liquidity")
+		if err != nil {
+			return nil, err
+		}
+		if l := vs[0].(*big.Int); l.Cmp(deepest) > 0 {
+			pool, deepest = addr, l
+		}
+	}
+	if pool == (common.Address{}) {
+		return nil, errors.New("no pool with liquidity")
+	}
+	vs, err := callView(ctx, client, pool, uniswapPoolABI, "observe", []uint32{twapWindow, 0})
+	if err != nil {
+		return nil, err
+	}
+	cumulatives := vs[0].([]*big.Int)
+	if len(cumulatives) != 2 {
+		return nil, fmt.Errorf("observe: %d tick cumulatives", len(cumulatives))
+	}
+	// The average tick rounds towards negative infinity, as the pools'
+	// own oracle library does.
+	delta := new(big.Int).Sub(cumulatives[1], cumulatives[0])
+	tick, rem := new(big.Int).QuoRem(delta, big.NewInt(twapWindow), new(big.Int))
+	if rem.Sign() < 0 {
+		tick.Sub(tick, big.NewInt(1))
+	}
+	// 1.0001^tick is token1 per token0 in base units.
+	ratio := math.Pow(1.0001, float64(tick.Int64()))
+	scale := math.Pow10(tf.Decimals - 18)
+	if bytes.Compare(tf.TokenAddr[:], weth[:]) < 0 {
+		return big.NewFloat(ratio * scale), nil
+	}
+	return big.NewFloat(scale / ratio), nil
+}
@@ -0,0 +1,212 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+
+	"golang.org/x/mod/semver"
+)
+
+// releaseKey is the base64 ed25519 public key the releases' checksum files
+// are signed with, set at build time like version:
+//
+//	go build -ldflags "-X main.releaseKey=..."
+var releaseKey = ""
+
+// releaseRepo is the GitHub repository releases are published to. Every
+// release carries one binary per platform, named as releaseAsset returns,
+// and a checksums.txt in sha256sum format covering them; signed releases
+// add checksums.txt.sig, an ed25519 signature of checksums.txt.
+const releaseRepo = "qInsp1re/testtask2"
+
+type release struct {
+	Tag    string `json:"tag_name"`
+	Assets []struct {
+		Name string `json:"name"`
+		URL  string `json:"browser_download_url"`
+	} `json:"assets"`
+}
+
+func (r *release) asset(name string) string {
+	for _, a := range r.Assets {
+		if a.Name == name {
+			return a.URL
+		}
+	}
+	return ""
+}
+
+func releaseAsset() string {
+	name := fmt.Sprintf("portfolio_%s_%s", runtime.GOOS, runtime.GOARCH)
+	if runtime.GOOS == "windows" {
+		name += ".exe"
+	}
+	return name
+}
+
+// updateCmd replaces the running binary with the latest release once its
+// signature and checksum check out. A build without the release key only
+// updates with -insecure, on the checksum alone.
+func updateCmd(args []string) {
+	fs := flag.NewFlagSet("update", flag.ExitOnError)
+	check := fs.Bool("check", false, "only report whether a newer release exists")
+	force := fs.Bool("force", false, "install the latest release even if it is not newer, e.g. over a dev build")
+	repo := fs.String("repo", releaseRepo, "GitHub repository to take releases from")
+	insecure := fs.Bool("insecure", false, "in a build without the release key, install the release without checking its signature, trusting the checksum listed next to it")
+	fs.Parse(args)
+
+	ctx := context.Background()
+	var rel release
+	if err := getJSON(ctx, "https://api.github.com/repos/"+*repo+"/releases/latest", &rel); err != nil {
+		log.Fatalf("update: latest release: %v", err)
+	}
+	newer := semver.IsValid(version) && semver.Compare(rel.Tag, version) > 0
+	switch {
+	case *check && newer:
+		fmt.Printf("%s is available (running %s)\n", rel.Tag, version)
+		return
+	case *check || !newer && !*force:
+		fmt.Printf("running %s; the latest release is %s\n", version, rel.Tag)
+		return
+	}
+
+	bin, err := fetchRelease(ctx, &rel, *insecure)
+	if err != nil {
+		log.Fatalf("update: %v", err)
+	}
+	path, err := os.Executable()
+	if err == nil {
+		path, err = filepath.EvalSymlinks(path)
+	}
+	if err != nil {
+		log.Fatalf("update: locating the running binary: %v", err)
+	}
+	if err := replaceBinary(path, bin); err != nil {
+		log.Fatalf("update: %v", err)
+	}
+	fmt.Printf("updated %s from %s to %s\n", path, version, rel.Tag)
+}
+
+// fetchRelease downloads this platform's binary of rel and verifies it
+// against the release's signed checksums. Without a release key there is
+// nothing to check the signature with, and only insecure skips it.
+func fetchRelease(ctx context.Context, rel *release, insecure bool) ([]byte, error) {
+	if releaseKey == "" && !insecure {
+		return nil, errors.New("this build has no release key to check the release's signature with; refusing to update (-insecure trusts the checksum alone)")
+	}
+	name := releaseAsset()
+	binURL, sumsURL := rel.asset(name), rel.asset("checksums.txt")
+	if binURL == "" {
+		return nil, fmt.Errorf("release %s has no %s", rel.Tag, name)
+	}
+	if sumsURL == "" {
+		return nil, fmt.Errorf("release %s has no checksums.txt", rel.Tag)
+	}
+	sums, err := download(ctx, sumsURL)
+	if err != nil {
+		return nil, fmt.Errorf("checksums: %w", err)
+	}
+	if releaseKey == "" {
+		log.Print("warning: -insecure: this build has no release key; checking the checksum only")
+	} else {
+		sigURL := rel.asset("checksums.txt.sig")
+		if sigURL == "" {
+			return nil, fmt.Errorf("release %s is not signed", rel.Tag)
+		}
+		sig, err := download(ctx, sigURL)
+		if err != nil {
+			return nil, fmt.Errorf("signature: %w", err)
+		}
+		if err := verifySignature(releaseKey, sums, sig); err != nil {
+			return nil, err
+		}
+	}
+	bin, err := download(ctx, binURL)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+	if err := verifyChecksum(sums, name, bin); err != nil {
+		return nil, err
+	}
+	return bin, nil
+}
+
+// verifySignature checks an ed25519 signature, raw or base64, of data.
+func verifySignature(key string, data, sig []byte) error {
+	pub, err := base64.StdEncoding.DecodeString(key)
+	if err != nil || len(pub) != ed25519.PublicKeySize {
+		return errors.New("bad release key in this build")
+	}
+	if dec, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(sig))); err == nil {
+		sig = dec
+	}
+	if !ed25519.Verify(pub, data, sig) {
+		return errors.New("checksums.txt signature does not verify")
+	}
+	return nil
+}
+
+// verifyChecksum finds name in a sha256sum listing and compares data's hash.
+func verifyChecksum(sums []byte, name string, data []byte) error {
+	sc := bufio.NewScanner(bytes.NewReader(sums))
+	for sc.Scan() {
+		sum, file, ok := strings.Cut(strings.TrimSpace(sc.Text()), " ")
+		if !ok || strings.TrimLeft(strings.TrimSpace(file), "*") != name {
+			continue
+		}
+		got := sha256.Sum256(data)
+		if hex.EncodeToString(got[:]) != strings.ToLower(sum) {
+			return fmt.Errorf("%s: checksum mismatch", name)
+		}
+		return nil
+	}
+	return fmt.Errorf("checksums.txt does not list %s", name)
+}
+
+// replaceBinary swaps the file at path for bin in one rename, so a failed
+// update leaves the old binary in place.
+func replaceBinary(path string, bin []byte) error {
+	f, err := createAtomic(path)
+	if err != nil {
+		return err
+	}
+	f.mode = 0o755
+	if _, err := f.Write(bin); err != nil {
+		f.Abort()
+		return err
+	}
+	return f.Commit()
+}
+
+func download(ctx context.Context, url string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	client := &http.Client{Timeout: 5 * time.Minute}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("GET %s: %s", url, resp.Status)
+	}
+	return io.ReadAll(resp.Body)
+}
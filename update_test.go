@@ -0,0 +1,49 @@
+package main
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"strings"
+	"testing"
+)
+
+func TestVerifyRelease(t *testing.T) {
+	bin := []byte("binary")
+	sum := sha256.Sum256(bin)
+	sums := []byte(hex.EncodeToString(sum[:]) + "  portfolio_linux_amd64\n" +
+		"0000000000000000000000000000000000000000000000000000000000000000 *portfolio_darwin_arm64\n")
+
+	if err := verifyChecksum(sums, "portfolio_linux_amd64", bin); err != nil {
+		t.Error(err)
+	}
+	if err := verifyChecksum(sums, "portfolio_darwin_arm64", bin); err == nil {
+		t.Error("mismatching checksum accepted")
+	}
+	if err := verifyChecksum(sums, "portfolio_windows_amd64.exe", bin); err == nil {
+		t.Error("unlisted asset accepted")
+	}
+
+	pub, priv, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key := base64.StdEncoding.EncodeToString(pub)
+	sig := []byte(base64.StdEncoding.EncodeToString(ed25519.Sign(priv, sums)) + "\n")
+	if err := verifySignature(key, sums, sig); err != nil {
+		t.Error(err)
+	}
+	if err := verifySignature(key, append(sums, 'x'), sig); err == nil {
+		t.Error("signature over other data accepted")
+	}
+}
+
+func TestFetchReleaseWithoutKey(t *testing.T) {
+	defer func(k string) { releaseKey = k }(releaseKey)
+	releaseKey = ""
+	if _, err := fetchRelease(context.Background(), &release{Tag: "v9.9.9"}, false); err == nil || !strings.Contains(err.Error(), "no release key") {
+		t.Errorf("unsigned update allowed: %v", err)
+	}
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"context"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// vaultToken is an interest-bearing wrapper whose shares are worth a growing
+// amount of the underlying token. Shares are valued by asking the vault what
+// they convert to and pricing that amount of the underlying.
+type vaultToken struct {
+	Symbol     string
+	Vault      common.Address
+	Underlying string // registry symbol of the asset shares convert to
+	// PerShare uses pricePerShare() (underlying per 1e18 shares) instead of
+	// ERC-4626 convertToAssets.
+	PerShare bool
+}
+
+var vaultTokens = []vaultToken{
+	{"sDAI", common.HexToAddress("0x83F20F44975D03b1b09e64809B757c47f942BEeA"), "DAI", false}, // Maker DSR
+	// sfrxETH accrues frxETH, which is priced as ETH.
+	{"sfrxETH", common.HexToAddress("0xac3E018457B222d93114458476f3E3416Abbe38F"), "ETH", true},
+}
+
+var erc4626ABI = mustABI(`[
+  {"inputs":[{"name":"shares","type":"uint256"}],"name":"convertToAssets","outputs":[{"type":"uint256"}],"stateMutability":"view","type":"function"},
+  {"inputs":[],"name":"pricePerShare","outputs":[{"type":"uint256"}],"stateMutability":"view","type":"function"}
+]`)
+
+// vaultHoldings returns the wallet's vault shares expressed in, and priced
+// as, their underlying tokens including accrued interest.
+func vaultHoldings(ctx context.Context, client chainClient, wallet common.Address) ([]*holding, error) {
+	var held []*holding
+	for _, v := range vaultTokens {
+		shares, err := erc20Balance(ctx, client, v.Vault, wallet)
+		if err != nil {
+			return held, err
+		}
+		if shares.Sign() == 0 {
+			continue
+		}
+		assets, err := vaultAssets(ctx, client, v, shares)
+		if err != nil {
+			return held, err
+		}
+		tf, _ := tokenBySymbol(v.Underlying)
+		held = append(held, &holding{
+			tf:   tf,
+			amt:  tokenAmount(assets, tf.Decimals),
+			note: tokenAmount(shares, 18).Text('f', 4) + " " + v.Symbol,
+			id:   "vault:" + v.Vault.Hex(),
+		})
+	}
+	return held, nil
+}
+
+// vaultAssets returns how much of the underlying the shares are worth.
+func vaultAssets(ctx context.Context, client chainClient, v vaultToken, shares *big.Int) (*big.Int, error) {
+	if !v.PerShare {
+		vs, err := callView(ctx, client, v.Vault, erc4626ABI, "convertToAssets", shares)
+		if err != nil {
+			return nil, err
+		}
+		return vs[0].(*big.Int), nil
+	}
+	vs, err := callView(ctx, client, v.Vault, erc4626ABI, "pricePerShare")
+	if err != nil {
+		return nil, err
+	}
+	assets := new(big.Int).Mul(shares, vs[0].(*big.Int))
+	return assets.Quo(assets, big.NewInt(1e18)), nil
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"context"
+	"math/big"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// votingEscrow is a Curve-style vote-escrow contract. Locked tokens leave the
+// wallet, so they are read back from locked() and valued as the lock token.
+type votingEscrow struct {
+	Symbol string
+	Escrow common.Address
+	// BalancerLP marks escrows whose lock token is a Balancer pool share, which
+	// is valued as its underlying tokens.
+	BalancerLP bool
+}
+
+var votingEscrows = []votingEscrow{
+	{"veCRV", common.HexToAddress("0x5f3b5DfEb7B28CDbD7FAba78963EE202a494e2A2"), false},
+	{"veBAL", common.HexToAddress("0xC128a9954e6c874eA3d62ce62B468bA073093F25"), true}, // locks the 80BAL-20WETH BPT
+}
+
+var votingEscrowABI = mustABI(`[
+  {"inputs":[{"name":"addr","type":"address"}],"name":"locked","outputs":[{"name":"amount","type":"int128"},{"name":"end","type":"uint256"}],"stateMutability":"view","type":"function"},
+  {"inputs":[],"name":"token","outputs":[{"type":"address"}],"stateMutability":"view","type":"function"}
+]`)
+
+// voteEscrowHoldings returns the wallet's locked amounts, annotated with
+// when each lock expires.
+func voteEscrowHoldings(ctx context.Context, client chainClient, wallet common.Address) ([]*holding, error) {
+	var held []*holding
+	for _, ve := range votingEscrows {
+		vs, err := callView(ctx, client, ve.Escrow, votingEscrowABI, "locked", wallet)
+		if err != nil {
+			return held, err
+		}
+		amount, end := vs[0].(*big.Int), vs[1].(*big.Int)
+		if amount.Sign() <= 0 {
+			continue
+		}
+		note := ve.Symbol + ", locked until " + time.Unix(end.Int64(), 0).UTC().Format("2006-01-02")
+		vs, err = callView(ctx, client, ve.Escrow, votingEscrowABI, "token")
+		if err != nil {
+			return held, err
+		}
+		token := vs[0].(common.Address)
+		if ve.BalancerLP {
+			hs, err := balancerShare(ctx, client, token, amount, note, "ve:"+ve.Escrow.Hex())
+			if err != nil {
+				return held, err
+			}
+			held = append(held, hs...)
+			continue
+		}
+		tf, err := resolveToken(ctx, client, token)
+		if err != nil {
+			return held, err
+		}
+		held = append(held, &holding{tf: tf, amt: tokenAmount(amount, tf.Decimals), note: note, id: "ve:" + ve.Escrow.Hex()})
+	}
+	return held, nil
+}
@@ -0,0 +1,189 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"math/big"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+)
+
+// lineWriter serializes the lines of the watchers running side by side.
+type lineWriter struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func (lw *lineWriter) println(line string) {
+	lw.mu.Lock()
+	defer lw.mu.Unlock()
+	fmt.Fprintln(lw.w, line)
+}
+
+// forEachWS runs fn concurrently for every chain with a websocket endpoint
+// and waits for all of them. Chains without one are skipped with a warning.
+func forEachWS(ctx context.Context, conns []*chainConn, what string, fn func(*chainConn) error) {
+	var wg sync.WaitGroup
+	for _, c := range conns {
+		name := chainName(c.id)
+		if c.ws == "" {
+			log.Printf("%s: no websocket endpoint, not watching %s; set WS_URL_%s or rpc.%s.ws", name, what, strings.ToUpper(name), name)
+			continue
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := fn(c); err != nil && ctx.Err() == nil {
+				log.Printf("%s: watching %s: %v", name, what, err)
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+// watchTransfers follows ERC-20 Transfer events to or from wallets on every
+// chain with a websocket endpoint, printing each as it is mined with its
+// USD value, until ctx is done. Transfers worth less than hideBelow USD are
+// not printed.
+func watchTransfers(ctx context.Context, w *lineWriter, conns []*chainConn, wallets []common.Address, cfg *config, opts options, hideBelow float64) {
+	forEachWS(ctx, conns, "transfers", func(c *chainConn) error {
+		return c.watch(ctx, wallets, cfg, opts, w, hideBelow)
+	})
+}
+
+func (c *chainConn) watch(ctx context.Context, wallets []common.Address, cfg *config, opts options, w *lineWriter, hideBelow float64) error {
+	ws, err := ethclient.DialContext(ctx, c.ws)
+	if err != nil {
+		return err
+	}
+	defer ws.Close()
+
+	var topics []common.Hash
+	tracked := map[common.Address]bool{}
+	for _, addr := range wallets {
+		topics = append(topics, common.BytesToHash(addr.Bytes()))
+		tracked[addr] = true
+	}
+	// Topics are ANDed across positions, so outgoing and incoming
+	// transfers need a subscription each.
+	logs := make(chan types.Log, 64)
+	errs := make(chan error, 2)
+	for _, q := range [][][]common.Hash{{{transferTopic}, topics}, {{transferTopic}, nil, topics}} {
+		sub, err := ws.SubscribeFilterLogs(ctx, ethereum.FilterQuery{Topics: q}, logs)
+		if err != nil {
+			return err
+		}
+		defer sub.Unsubscribe()
+		go func() {
+			if err := <-sub.Err(); err != nil {
+				errs <- err
+			}
+		}()
+	}
+	log.Printf("%s: watching transfers of %d wallet(s)", chainName(c.id), len(wallets))
+
+	tokens := c.tokenCache()
+	type logID struct {
+		tx    common.Hash
+		index uint
+	}
+	seen := map[logID]bool{} // a transfer between two tracked wallets matches both subscriptions
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case err := <-errs:
+			return err
+		case l := <-logs:
+			// ERC-721 transfers have the token ID as a fourth topic.
+			if l.Removed || len(l.Topics) != 3 || len(l.Data) != 32 {
+				continue
+			}
+			key := logID{l.TxHash, l.Index}
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+			tf := tokens(ctx, l.Address)
+			if !opts.wants(tf.Symbol) || !cfg.tokenAllowed(tf.TokenAddr) {
+				continue
+			}
+			from := common.BytesToAddress(l.Topics[1].Bytes())
+			to := common.BytesToAddress(l.Topics[2].Bytes())
+			h := c.priced(ctx, cfg, opts, tf, new(big.Int).SetBytes(l.Data))
+			if h.err == nil && below(h.usd(), hideBelow) {
+				continue
+			}
+			w.println(transferLine(c.id, l, tf, h, from, to, tracked))
+		}
+	}
+}
+
+// tokenCache returns a lookup of the token contracts a watcher comes
+// across: the chain's registry first, then the contract's own metadata.
+// Lookups are remembered, failed ones as 18-decimals tokens named by
+// address.
+func (c *chainConn) tokenCache() func(context.Context, common.Address) tokenFeed {
+	tokens := map[common.Address]tokenFeed{}
+	for _, tf := range registry(c.id) {
+		tokens[tf.TokenAddr] = tf
+	}
+	return func(ctx context.Context, addr common.Address) tokenFeed {
+		tf, ok := tokens[addr]
+		if !ok {
+			var err error
+			if tf, err = resolveToken(ctx, c.client, addr); err != nil {
+				tf = tokenFeed{Symbol: addr.Hex(), TokenAddr: addr, Decimals: 18}
+			}
+			tokens[addr] = tf
+		}
+		return tf
+	}
+}
+
+// priced values a raw amount of a token. It uses a fresh pricer every time,
+// so a long watch does not keep pricing with the feed answers it read
+// first.
+func (c *chainConn) priced(ctx context.Context, cfg *config, opts options, tf tokenFeed, raw *big.Int) *holding {
+	h := &holding{tf: tf, amt: tokenAmount(raw, tf.Decimals)}
+	newPricer(ctx, c.client, c.id, c.trustFeeds, cfg, opts).priceAll([]*holding{h})
+	return h
+}
+
+func transferLine(chainID uint64, l types.Log, tf tokenFeed, h *holding, from, to common.Address, tracked map[common.Address]bool) string {
+	dir, wallet, prep, other := "OUT", from, "to", to
+	if tracked[to] && !tracked[from] {
+		dir, wallet, prep, other = "IN ", to, "from", from
+	}
+	line := fmt.Sprintf("%s %-8s block %d %s %s %s %s (%s) %s %s tx %s",
+		time.Now().Format(time.TimeOnly), chainName(chainID), l.BlockNumber,
+		walletName(wallet), dir, h.amt.Text('f', 6), tf.Symbol, usdText(h),
+		prep, walletName(other), l.TxHash.Hex())
+	if screening[other] {
+		line += " SANCTIONED counterparty"
+	}
+	return line
+}
+
+func usdText(h *holding) string {
+	if h.err != nil {
+		return "price unknown"
+	}
+	return reportQuote.format(h.usd())
+}
+
+// walletName is an address's book label, or its hex.
+func walletName(addr common.Address) string {
+	if label := book.label(addr); label != "" {
+		return label
+	}
+	return addr.Hex()
+}
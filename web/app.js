@@ -0,0 +1,167 @@
+// Dashboard for `portfolio serve`: reads /api and redraws every minute.
+"use strict";
+
+const colors = ["#2b6cb0", "#dd6b20", "#38a169", "#d53f8c", "#805ad5", "#d69e2e", "#319795", "#718096"];
+const usd = v => "$" + v.toLocaleString(undefined, { minimumFractionDigits: 2, maximumFractionDigits: 2 });
+const svgNS = "http://www.w3.org/2000/svg";
+const $ = id => document.getElementById(id);
+
+// A multi-tenant server wants the tenant's API key on every request. It is
+// taken once from ?key= or a prompt and kept in the browser.
+function apiKey(ask) {
+  const given = new URLSearchParams(location.search).get("key");
+  if (given) localStorage.setItem("portfolio-key", given);
+  if (ask) localStorage.setItem("portfolio-key", prompt("API key") || "");
+  return localStorage.getItem("portfolio-key");
+}
+
+async function api(path, retried) {
+  const key = apiKey(false);
+  const resp = await fetch("api/" + path, { headers: key ? { Authorization: "Bearer " + key } : {} });
+  if (resp.status === 401 && !retried) {
+    apiKey(true);
+    return api(path, true);
+  }
+  const body = await resp.json();
+  if (!resp.ok) throw new Error(body.error || resp.statusText);
+  return body;
+}
+
+function cell(row, text, cls) {
+  const td = row.insertCell();
+  td.textContent = text;
+  if (cls) td.className = cls;
+}
+
+// symbolCell shows the asset's logo next to its symbol, which links to its
+// website when known. A logo that fails to load is hidden.
+function symbolCell(row, p) {
+  const td = row.insertCell();
+  if (p.logo) {
+    const img = document.createElement("img");
+    img.src = p.logo;
+    img.alt = "";
+    img.className = "logo";
+    img.loading = "lazy";
+    img.onerror = () => img.remove();
+    td.appendChild(img);
+  }
+  if (p.website) {
+    const a = document.createElement("a");
+    a.href = p.website;
+    a.rel = "noopener noreferrer";
+    a.target = "_blank";
+    a.textContent = p.symbol;
+    td.appendChild(a);
+  } else {
+    td.append(p.symbol);
+  }
+}
+
+function drawHoldings(rep) {
+  const body = $("holdings").tBodies[0];
+  body.replaceChildren();
+  const rows = [...rep.positions].sort((a, b) => (b.error ? -1 : b.usd) - (a.error ? -1 : a.usd));
+  for (const p of rows) {
+    const tr = body.insertRow();
+    symbolCell(tr, p);
+    cell(tr, p.chain);
+    if (p.error) {
+      cell(tr, "", "num");
+      cell(tr, "error", "num error");
+      cell(tr, p.error, "error");
+      continue;
+    }
+    cell(tr, p.amount.toPrecision(6), "num");
+    cell(tr, usd(p.usd), "num");
+    cell(tr, p.note || "");
+  }
+  $("total").textContent = usd(rep.total_usd) + (rep.partial ? " (partial)" : "");
+}
+
+// drawAllocation draws a donut of the assets' USD shares, the smallest
+// past the palette folded into "other".
+function drawAllocation(rep) {
+  const by = {};
+  for (const p of rep.positions) {
+    if (!p.error && p.usd > 0) by[p.symbol] = (by[p.symbol] || 0) + p.usd;
+  }
+  let slices = Object.entries(by).sort((a, b) => b[1] - a[1]);
+  if (slices.length > colors.length) {
+    const rest = slices.slice(colors.length - 1).reduce((s, [, v]) => s + v, 0);
+    slices = slices.slice(0, colors.length - 1).concat([["other", rest]]);
+  }
+  const total = slices.reduce((s, [, v]) => s + v, 0);
+  const svg = $("allocation"), legend = $("legend");
+  svg.replaceChildren();
+  legend.replaceChildren();
+  let angle = -Math.PI / 2;
+  slices.forEach(([sym, v], i) => {
+    const share = v / total, next = angle + share * 2 * Math.PI;
+    const path = document.createElementNS(svgNS, "path");
+    const large = share > 0.5 ? 1 : 0;
+    const [x0, y0, x1, y1] = [Math.cos(angle), Math.sin(angle), Math.cos(next), Math.sin(next)];
+    path.setAttribute("d", share >= 0.9999
+      ? "M 1 0 A 1 1 0 1 1 -1 0 A 1 1 0 1 1 1 0 M 0.6 0 A 0.6 0.6 0 1 0 -0.6 0 A 0.6 0.6 0 1 0 0.6 0 Z"
+      : `M ${x0} ${y0} A 1 1 0 ${large} 1 ${x1} ${y1} L ${0.6 * x1} ${0.6 * y1} A 0.6 0.6 0 ${large} 0 ${0.6 * x0} ${0.6 * y0} Z`);
+    path.setAttribute("fill", colors[i]);
+    path.setAttribute("fill-rule", "evenodd");
+    svg.appendChild(path);
+    const li = document.createElement("li");
+    const swatch = document.createElement("span");
+    swatch.style.background = colors[i];
+    li.append(swatch, `${sym} ${(100 * share).toFixed(1)}% (${usd(v)})`);
+    legend.appendChild(li);
+    angle = next;
+  });
+}
+
+function drawHistory(points) {
+  const svg = $("history");
+  svg.replaceChildren();
+  if (points.length < 2) {
+    $("history-note").textContent = "History needs at least two snapshots: run with -save or `portfolio serve -save`.";
+    return;
+  }
+  const t0 = Date.parse(points[0].time), t1 = Date.parse(points[points.length - 1].time);
+  const max = Math.max(...points.map(p => p.total_usd)) || 1;
+  const line = document.createElementNS(svgNS, "polyline");
+  line.setAttribute("points", points.map(p =>
+    `${600 * (Date.parse(p.time) - t0) / (t1 - t0 || 1)},${200 - 190 * p.total_usd / max}`).join(" "));
+  svg.appendChild(line);
+  $("history-note").textContent =
+    `${points.length} snapshots, ${points[0].time.slice(0, 10)} to ${points[points.length - 1].time.slice(0, 10)}, peak ${usd(max)}`;
+}
+
+async function refresh() {
+  const wallet = $("wallet").value;
+  if (!wallet) return;
+  $("status").textContent = "valuing…";
+  try {
+    const [rep, history] = await Promise.all([api(`wallets/${wallet}/report`), api(`wallets/${wallet}/history`)]);
+    drawHoldings(rep);
+    drawAllocation(rep);
+    drawHistory(history);
+    const blocks = (rep.blocks || []).map(b => `${b.chain} block ${b.number}`);
+    $("status").textContent = ["valued " + new Date(rep.time).toLocaleTimeString(), ...blocks].join(", ");
+  } catch (err) {
+    $("status").textContent = err.message;
+  }
+}
+
+async function start() {
+  const select = $("wallet");
+  for (const w of await api("wallets")) {
+    select.add(new Option(w.label ? `${w.label} (${w.address.slice(0, 8)}…)` : w.address, w.address));
+  }
+  const wanted = new URLSearchParams(location.search).get("wallet");
+  if (wanted) select.value = wanted;
+  select.onchange = () => {
+    history.replaceState(null, "", "?wallet=" + select.value);
+    refresh();
+  };
+  refresh();
+  setInterval(refresh, 60000);
+}
+
+start().catch(err => { $("status").textContent = err.message; });
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"os"
+	"os/signal"
+	"sort"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// whaleChange is a position of a watched address that moved by at least
+// the alert threshold between two valuations.
+type whaleChange struct {
+	Wallet common.Address
+	Symbol string
+	Chain  string
+	Before float64 // USD
+	After  float64
+}
+
+func (c whaleChange) delta() float64 { return c.After - c.Before }
+
+// whaleChanges compares two valuations of one address position by
+// position. Positions whose lookup failed in either are left out, so an
+// RPC hiccup does not read as the whole balance moving.
+func whaleChanges(wallet common.Address, prev, cur *report, threshold float64) []whaleChange {
+	failed := map[string]bool{}
+	before := map[string]reportPosition{}
+	for _, p := range prev.Positions {
+		if p.Error != "" {
+			failed[p.key()] = true
+		}
+		before[p.key()] = p
+	}
+	after := map[string]reportPosition{}
+	for _, p := range cur.Positions {
+		if p.Error != "" {
+			failed[p.key()] = true
+		}
+		after[p.key()] = p
+	}
+	var out []whaleChange
+	for _, key := range unionKeys(before, after) {
+		if failed[key] {
+			continue
+		}
+		a, b := before[key], after[key]
+		p := b
+		if p.Symbol == "" {
+			p = a
+		}
+		if math.Abs(b.USD-a.USD) >= threshold {
+			out = append(out, whaleChange{Wallet: wallet, Symbol: p.Symbol, Chain: p.Chain, Before: a.USD, After: b.USD})
+		}
+	}
+	return out
+}
+
+func unionKeys(a, b map[string]reportPosition) []string {
+	var keys []string
+	for k := range a {
+		keys = append(keys, k)
+	}
+	for k := range b {
+		if _, ok := a[k]; !ok {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func (c whaleChange) alert() alert {
+	return alert{
+		Key:   "whale|" + strings.ToLower(c.Wallet.Hex()) + "|" + c.Chain + "|" + c.Symbol,
+		Title: fmt.Sprintf("%s moved %s of %s on %s", walletName(c.Wallet), signedUSD(c.delta()), c.Symbol, c.Chain),
+		Text:  fmt.Sprintf("%s → %s", usdString(c.Before), usdString(c.After)),
+	}
+}
+
+func printWhaleChange(w io.Writer, c whaleChange) {
+	fmt.Fprintf(w, "%s %s %-6s [%s] $%.2f -> $%.2f (%+.2f)\n", time.Now().Format(time.TimeOnly),
+		walletName(c.Wallet), c.Symbol, c.Chain, c.Before, c.After, c.delta())
+}
+
+// whalesCmd revalues third-party addresses every interval and prints each
+// position that moved by at least the threshold, until interrupted. Only
+// on-chain positions count: the config's off-chain assets and exchange
+// accounts are the user's own.
+func whalesCmd(args []string) {
+	fs := flag.NewFlagSet("whales", flag.ExitOnError)
+	fs.String("config", "config.json", "path to the JSON config file")
+	rpc := fs.String("rpc", "", "RPC endpoint of the chain valued by default (default: ETH_RPC_URL)")
+	chainList := fs.String("chains", "", "comma-separated chains to watch the addresses on (default: the chain -rpc points at)")
+	interval := fs.Duration("interval", 5*time.Minute, "how often to revalue the addresses")
+	threshold := fs.Float64("threshold", 100_000, "alert when a position's USD value changes by at least this much")
+	notify := fs.String("notify", "", "also send each alert to these comma-separated services: "+strings.Join(notifierNames(), ", "))
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s whales [flags] [address|label]...\n(without addresses, the config's whales are watched)\n", os.Args[0])
+		fs.PrintDefaults()
+	}
+	cfg, err := parseSettings(fs, args)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if *notify != "" {
+		if err := checkNotifiers(*notify); err != nil {
+			log.Fatalf("-notify: %v", err)
+		}
+	}
+	addrs := fs.Args()
+	if len(addrs) == 0 {
+		addrs = cfg.Whales
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	var watched []common.Address
+	if err := forEachAddress(ctx, addrs, "", func(a common.Address) { watched = append(watched, a) }); err != nil {
+		log.Fatal(err)
+	}
+	if len(watched) == 0 {
+		fs.Usage()
+		os.Exit(2)
+	}
+	conns := openChains(ctx, *rpc, *chainList, cfg)
+	value := func(wallet common.Address) *report {
+		var held []*holding
+		for _, c := range conns {
+			held = append(held, c.value(ctx, wallet, cfg, options{})...)
+		}
+		return newReport(wallet.Hex(), held)
+	}
+
+	last := map[common.Address]*report{}
+	for _, a := range watched {
+		last[a] = value(a)
+		log.Printf("watching %s: $%.2f", walletName(a), last[a].TotalUSD)
+	}
+	tick := time.NewTicker(*interval)
+	defer tick.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-tick.C:
+		}
+		for _, a := range watched {
+			cur := value(a)
+			if ctx.Err() != nil {
+				return
+			}
+			for _, c := range whaleChanges(a, last[a], cur, *threshold) {
+				printWhaleChange(os.Stdout, c)
+				sendAlert(ctx, *notify, cfg, c.alert())
+			}
+			last[a] = cur
+		}
+	}
+}
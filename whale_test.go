@@ -0,0 +1,57 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+func TestWhaleChanges(t *testing.T) {
+	wallet := common.HexToAddress("0x00000000000000000000000000000000000000aa")
+	prev := &report{Positions: []reportPosition{
+		{Symbol: "ETH", Chain: "mainnet", USD: 1_000_000},
+		{Symbol: "USDC", Chain: "mainnet", USD: 500_000},
+		{Symbol: "LINK", Chain: "mainnet", USD: 200_000},
+		{Symbol: "UNI", Chain: "mainnet", Error: "no price"},
+	}}
+	cur := &report{Positions: []reportPosition{
+		{Symbol: "ETH", Chain: "mainnet", USD: 1_050_000},
+		{Symbol: "USDC", Chain: "mainnet", USD: 300_000},
+		{Symbol: "LINK", Chain: "mainnet", Error: "RPC timeout"},
+		{Symbol: "UNI", Chain: "mainnet", USD: 900_000},
+		{Symbol: "ETH", Chain: "base", USD: 150_000},
+	}}
+	got := whaleChanges(wallet, prev, cur, 100_000)
+	if len(got) != 2 {
+		t.Fatalf("changes: %+v", got)
+	}
+	// Sorted by position key: base's new ETH, then mainnet's USDC outflow.
+	if got[0].Chain != "base" || got[0].delta() != 150_000 || got[1].Symbol != "USDC" || got[1].delta() != -200_000 {
+		t.Errorf("changes: %+v", got)
+	}
+
+	var b strings.Builder
+	printWhaleChange(&b, got[1])
+	if !strings.Contains(b.String(), "USDC   [mainnet] $500000.00 -> $300000.00 (-200000.00)") {
+		t.Errorf("line: %q", b.String())
+	}
+}
+
+func TestWhaleChangesSameNote(t *testing.T) {
+	// Two Balancer pools both hold WETH under the same note; only the one
+	// that moved is reported.
+	a, b := "balancer:0x00000000000000000000000000000000000000a1", "balancer:0x00000000000000000000000000000000000000b2"
+	prev := &report{Positions: []reportPosition{
+		{Symbol: "WETH", Chain: "mainnet", Note: "Balancer LP", ID: a, USD: 400_000},
+		{Symbol: "WETH", Chain: "mainnet", Note: "Balancer LP", ID: b, USD: 300_000},
+	}}
+	cur := &report{Positions: []reportPosition{
+		{Symbol: "WETH", Chain: "mainnet", Note: "Balancer LP", ID: a, USD: 400_000},
+		{Symbol: "WETH", Chain: "mainnet", Note: "Balancer LP", ID: b, USD: 100_000},
+	}}
+	got := whaleChanges(common.Address{}, prev, cur, 100_000)
+	if len(got) != 1 || got[0].delta() != -200_000 {
+		t.Errorf("changes: %+v", got)
+	}
+}
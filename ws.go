@@ -0,0 +1,230 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/gorilla/websocket"
+)
+
+// GET /api/ws is the push counterpart of the report endpoint. A client
+// subscribes to wallets, optionally narrowed to some symbols, with
+//
+//	{"op": "subscribe", "wallets": ["0x…", "treasury"], "symbols": ["ETH"]}
+//
+// and drops them with "op": "unsubscribe". Each subscribed wallet's current
+// state arrives as deltas from nothing, and then, every refresh, only what
+// changed: a position's balance or price, or the wallet's total.
+
+// wsRequest is a message from a client.
+type wsRequest struct {
+	Op      string   `json:"op"`
+	Wallets []string `json:"wallets"`
+	Symbols []string `json:"symbols"`
+}
+
+// wsDelta is a message to a client. Type is balance, price or total, with
+// the new value and the one it replaces (0 for a position not seen
+// before), or error. Time is when the value was found.
+type wsDelta struct {
+	Type     string    `json:"type"`
+	Wallet   string    `json:"wallet,omitempty"`
+	Symbol   string    `json:"symbol,omitempty"`
+	Chain    string    `json:"chain,omitempty"`
+	Note     string    `json:"note,omitempty"`
+	Value    float64   `json:"value"`
+	Previous float64   `json:"previous"`
+	Time     time.Time `json:"time"`
+	Error    string    `json:"error,omitempty"`
+}
+
+// reportDeltas lists what changed from prev, nil for none, to cur among the
+// positions of the symbols wanted, all when empty. A position gone from
+// cur has its balance drop to 0; one whose lookup failed is left as it was.
+func reportDeltas(prev, cur *report, symbols []string) []wsDelta {
+	positions := func(r *report) map[string]reportPosition {
+		m := map[string]reportPosition{}
+		if r == nil {
+			return m
+		}
+		for _, p := range r.Positions {
+			if len(symbols) == 0 || slices.ContainsFunc(symbols, func(s string) bool { return strings.EqualFold(s, p.Symbol) }) {
+				m[p.key()] = p
+			}
+		}
+		return m
+	}
+	price := func(p reportPosition) float64 {
+		if p.Amount == 0 {
+			return 0
+		}
+		return p.USD / p.Amount
+	}
+	before, after := positions(prev), positions(cur)
+	var out []wsDelta
+	delta := func(kind string, p reportPosition, value, previous float64) {
+		if value != previous {
+			out = append(out, wsDelta{Type: kind, Wallet: cur.Wallet, Symbol: p.Symbol, Chain: p.Chain, Note: p.Note,
+				Value: value, Previous: previous, Time: cur.Time})
+		}
+	}
+	for _, p := range cur.Positions {
+		if _, ok := after[p.key()]; !ok || p.Error != "" {
+			continue
+		}
+		was := before[p.key()]
+		delta("balance", p, p.Amount, was.Amount)
+		delta("price", p, price(p), price(was))
+	}
+	var was float64
+	if prev != nil {
+		for _, p := range prev.Positions {
+			if _, ok := before[p.key()]; !ok {
+				continue
+			}
+			if _, ok := after[p.key()]; !ok {
+				delta("balance", p, 0, p.Amount)
+			}
+		}
+		was = prev.TotalUSD
+	}
+	delta("total", reportPosition{}, cur.TotalUSD, was)
+	return out
+}
+
+// handleWS upgrades the request and pushes deltas of the wallets the client
+// subscribes to until either side closes the connection.
+func (s *server) handleWS(w http.ResponseWriter, r *http.Request, t *tenant) {
+	upgrader := websocket.Upgrader{CheckOrigin: func(r *http.Request) bool { return s.cors.allowsSocket(r) }}
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return // the upgrader has answered
+	}
+	defer conn.Close()
+
+	// The reader owns subs and pokes the writer when it changes; writes
+	// are the writer's alone.
+	var (
+		mu     sync.Mutex
+		subs   = map[common.Address][]string{}
+		fresh  = map[common.Address]bool{}
+		errs   []wsDelta
+		poke   = make(chan struct{}, 1)
+		closed = make(chan struct{})
+	)
+	go func() {
+		defer close(closed)
+		for {
+			_, msg, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+			var req wsRequest
+			mu.Lock()
+			if err := json.Unmarshal(msg, &req); err != nil {
+				errs = append(errs, wsDelta{Type: "error", Error: "bad message: " + err.Error(), Time: time.Now().UTC()})
+			} else {
+				errs = append(errs, s.subscribe(t, req, subs, fresh)...)
+			}
+			mu.Unlock()
+			select {
+			case poke <- struct{}{}:
+			default:
+			}
+		}
+	}()
+
+	refresh := time.NewTicker(max(s.refresh, time.Second))
+	defer refresh.Stop()
+	keepAlive := time.NewTicker(streamKeepAlive)
+	defer keepAlive.Stop()
+	last := map[common.Address]*report{}
+	for {
+		mu.Lock()
+		out := errs
+		errs = nil
+		wanted := make(map[common.Address][]string, len(subs))
+		for wallet, symbols := range subs {
+			wanted[wallet] = symbols
+			if fresh[wallet] {
+				delete(last, wallet)
+				delete(fresh, wallet)
+			}
+		}
+		mu.Unlock()
+		for wallet := range last {
+			if _, ok := wanted[wallet]; !ok {
+				delete(last, wallet)
+			}
+		}
+		for wallet, symbols := range wanted {
+			rep := s.report(t, wallet)
+			if prev := last[wallet]; prev == nil || !rep.Time.Equal(prev.Time) {
+				out = append(out, reportDeltas(prev, rep, symbols)...)
+				last[wallet] = rep
+			}
+		}
+		for _, d := range out {
+			if err := conn.WriteJSON(d); err != nil {
+				return
+			}
+		}
+
+		select {
+		case <-closed:
+			return
+		case <-r.Context().Done():
+			conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseGoingAway, ""), time.Now().Add(time.Second))
+			return
+		case <-keepAlive.C:
+			if err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(10*time.Second)); err != nil {
+				return
+			}
+		case <-poke:
+		case <-refresh.C:
+		}
+	}
+}
+
+// subscribe applies a client's request to its subscriptions, marking the
+// wallets whose full state it should be sent again, and returns errors for
+// what it could not do.
+func (s *server) subscribe(t *tenant, req wsRequest, subs map[common.Address][]string, fresh map[common.Address]bool) []wsDelta {
+	var errs []wsDelta
+	if req.Op != "subscribe" && req.Op != "unsubscribe" {
+		return []wsDelta{{Type: "error", Error: fmt.Sprintf("unknown op %q (want subscribe or unsubscribe)", req.Op), Time: time.Now().UTC()}}
+	}
+	for _, arg := range req.Wallets {
+		wallet, ok := walletArg(arg)
+		if !ok || !t.serves(wallet) {
+			errs = append(errs, wsDelta{Type: "error", Wallet: arg, Error: "unknown wallet", Time: time.Now().UTC()})
+			continue
+		}
+		if req.Op == "unsubscribe" {
+			delete(subs, wallet)
+			continue
+		}
+		subs[wallet] = req.Symbols
+		fresh[wallet] = true
+	}
+	return errs
+}
+
+// allowsSocket reports whether a browser page may open a websocket: pages
+// of the server's own host always may, others when they are allowed
+// origins. Browsers do not apply CORS to websockets, so the server must.
+func (c corsConfig) allowsSocket(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" || slices.Contains(c.Origins, "*") || slices.Contains(c.Origins, origin) {
+		return true
+	}
+	u, err := url.Parse(origin)
+	return err == nil && strings.EqualFold(u.Host, r.Host)
+}
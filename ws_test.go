@@ -0,0 +1,116 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/gorilla/websocket"
+)
+
+func TestReportDeltas(t *testing.T) {
+	prev := &report{Wallet: "w", TotalUSD: 3005, Positions: []reportPosition{
+		{Symbol: "ETH", Chain: "mainnet", Amount: 1, USD: 2000},
+		{Symbol: "USDC", Chain: "base", Amount: 1000, USD: 1000},
+		{Symbol: "DAI", Chain: "mainnet", Amount: 5, USD: 5},
+	}}
+	cur := &report{Wallet: "w", TotalUSD: 5400, Positions: []reportPosition{
+		{Symbol: "ETH", Chain: "mainnet", Amount: 2, USD: 4400},
+		{Symbol: "USDC", Chain: "base", Amount: 1000, USD: 1000},
+		{Symbol: "DAI", Chain: "mainnet", Error: "no price"},
+	}}
+	deltas := func(ds []wsDelta) string {
+		var s []string
+		for _, d := range ds {
+			s = append(s, fmt.Sprintf("%s %s %g->%g", d.Type, d.Symbol, d.Previous, d.Value))
+		}
+		return strings.Join(s, "; ")
+	}
+	if got, want := deltas(reportDeltas(prev, cur, nil)), "balance ETH 1->2; price ETH 2000->2200; total  3005->5400"; got != want {
+		t.Errorf("deltas %q, want %q", got, want)
+	}
+
+	cur.Positions = cur.Positions[:1]
+	if got, want := deltas(reportDeltas(prev, cur, []string{"usdc"})), "balance USDC 1000->0; total  3005->5400"; got != want {
+		t.Errorf("dropped USDC: %q, want %q", got, want)
+	}
+	if got, want := deltas(reportDeltas(nil, prev, []string{"eth"})), "balance ETH 0->1; price ETH 0->2000; total  0->3005"; got != want {
+		t.Errorf("first report: %q, want %q", got, want)
+	}
+
+	gmx := func(pnl float64) *report {
+		return &report{Wallet: "w", TotalUSD: 100 + pnl, Positions: []reportPosition{{Symbol: "USD", Chain: "arbitrum",
+			Note: fmt.Sprintf("GMX ETH long, PnL $%.2f", pnl), ID: "gmx:ETH long", Amount: 100 + pnl, USD: 100 + pnl}}}
+	}
+	if got, want := deltas(reportDeltas(gmx(5), gmx(20), nil)), "balance USD 105->120; total  105->120"; got != want {
+		t.Errorf("position with a changing note: %q, want %q", got, want)
+	}
+}
+
+func TestServerWS(t *testing.T) {
+	wallet := common.HexToAddress("0x00000000000000000000000000000000000000aa")
+	var valued atomic.Int64
+	s := &server{
+		open:    &tenant{fixed: true, wallets: []common.Address{wallet}, reports: map[common.Address]*report{}},
+		keys:    []string{"k1"},
+		refresh: 10 * time.Millisecond,
+		value: func(w common.Address) *report {
+			n := float64(valued.Add(1))
+			rep := newReport(w.Hex(), nil)
+			rep.Positions = []reportPosition{
+				{Symbol: "ETH", Chain: "mainnet", Amount: n, USD: 2000 * n},
+				{Symbol: "USDC", Chain: "mainnet", Amount: 100, USD: 100},
+			}
+			rep.TotalUSD = 2000*n + 100
+			return rep
+		},
+	}
+	srv := httptest.NewServer(s.handler())
+	defer srv.Close()
+	url := "ws" + strings.TrimPrefix(srv.URL, "http") + "/api/ws"
+
+	if _, resp, err := websocket.DefaultDialer.Dial(url, nil); err == nil || resp.StatusCode != 401 {
+		t.Fatalf("without key: %v", err)
+	}
+	if _, resp, err := websocket.DefaultDialer.Dial(url+"?key=k1", http.Header{"Origin": {"https://evil.example"}}); err == nil || resp.StatusCode != 403 {
+		t.Fatalf("foreign origin: %v", err)
+	}
+	conn, _, err := websocket.DefaultDialer.Dial(url+"?key=k1", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	conn.SetReadDeadline(time.Now().Add(10 * time.Second))
+	read := func() wsDelta {
+		t.Helper()
+		var d wsDelta
+		if err := conn.ReadJSON(&d); err != nil {
+			t.Fatal(err)
+		}
+		return d
+	}
+
+	conn.WriteJSON(wsRequest{Op: "subscribe", Wallets: []string{"0x00000000000000000000000000000000000000bb"}})
+	if d := read(); d.Type != "error" || d.Error != "unknown wallet" {
+		t.Errorf("unknown wallet: %+v", d)
+	}
+	conn.WriteJSON(wsRequest{Op: "subscribe", Wallets: []string{wallet.Hex()}, Symbols: []string{"eth"}})
+	var kinds []string
+	for len(kinds) < 6 {
+		d := read()
+		if d.Symbol == "USDC" {
+			t.Errorf("unsubscribed symbol: %+v", d)
+		}
+		kinds = append(kinds, d.Type)
+	}
+	// The first report comes as deltas from nothing; later ones change the
+	// ETH balance and total, not its price.
+	if got, want := strings.Join(kinds, " "), "balance price total balance total balance"; got != want {
+		t.Errorf("deltas %q, want %q", got, want)
+	}
+}